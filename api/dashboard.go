@@ -0,0 +1,190 @@
+package api
+
+import (
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// dashboardResource is a resource shown by the dashboard with its time to expiry
+type dashboardResource struct {
+	utils.ReportEntry
+	Source   string
+	ExpireIn string
+}
+
+// dashboardRegion groups the resources and failed deletions of a region
+type dashboardRegion struct {
+	Name      string
+	Deletable []dashboardResource
+	Expiring  []dashboardResource
+	Failures  []utils.ReportFailure
+	Leaks     []utils.Leak
+}
+
+type dashboardData struct {
+	GeneratedAt    time.Time
+	ExpiringWithin time.Duration
+	Reports        []CheckReport
+	Regions        []*dashboardRegion
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Funcs(template.FuncMap{
+	"date": func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.UTC().Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="60">
+<title>Pleco</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; width: 100%; }
+th, td { border: 1px solid #ddd; padding: 4px 8px; text-align: left; font-size: 14px; }
+th { background: #f4f4f4; }
+.error { color: #b00020; }
+.muted { color: #777; }
+</style>
+</head>
+<body>
+<h1>Pleco</h1>
+<p class="muted">Resources deletable or expiring within {{.ExpiringWithin}}, as of {{date .GeneratedAt}}. The page refreshes every minute.</p>
+
+<h2>Last checks</h2>
+{{if .Reports}}
+<table>
+<tr><th>Source</th><th>Started at</th><th>Dry run</th><th>Deletable</th><th>Expiring</th><th>Failed deletions</th><th>Errors</th></tr>
+{{range .Reports}}
+<tr><td>{{.Source}}</td><td>{{date .StartedAt}}</td><td>{{.DryRun}}</td><td>{{len .Deletable}}</td><td>{{len .Expiring}}</td><td>{{len .Failures}}</td>
+<td>{{if .Errors}}<span class="error">{{range .Errors}}{{.}}<br>{{end}}</span>{{else}}-{{end}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<p>No check done yet.</p>
+{{end}}
+
+{{range .Regions}}
+<h2>{{.Name}}</h2>
+{{if .Failures}}
+<h3 class="error">Failed deletions</h3>
+<table>
+<tr><th>Type</th><th>Name</th><th>Error</th></tr>
+{{range .Failures}}<tr><td>{{.Type}}</td><td>{{.Name}}</td><td class="error">{{.Error}}</td></tr>{{end}}
+</table>
+{{end}}
+{{if .Deletable}}
+<h3>Deletable</h3>
+<table>
+<tr><th>Type</th><th>Name</th><th>Source</th><th>Creation date</th><th>Expiration time</th><th>Expired</th></tr>
+{{range .Deletable}}<tr><td>{{.Type}}</td><td>{{.Name}}</td><td>{{.Source}}</td><td>{{date .CreationDate}}</td><td>{{date .ExpirationTime}}</td><td>{{.ExpireIn}}</td></tr>{{end}}
+</table>
+{{end}}
+{{if .Expiring}}
+<h3>Expiring</h3>
+<table>
+<tr><th>Type</th><th>Name</th><th>Source</th><th>Creation date</th><th>Expiration time</th><th>Expires in</th></tr>
+{{range .Expiring}}<tr><td>{{.Type}}</td><td>{{.Name}}</td><td>{{.Source}}</td><td>{{date .CreationDate}}</td><td>{{date .ExpirationTime}}</td><td>{{.ExpireIn}}</td></tr>{{end}}
+</table>
+{{end}}
+{{if .Leaks}}
+<h3>Leaked</h3>
+<table>
+<tr><th>Category</th><th>Type</th><th>Name</th><th>Reason</th></tr>
+{{range .Leaks}}<tr><td>{{.Category}}</td><td>{{.Type}}</td><td>{{.Name}}</td><td>{{.Reason}}</td></tr>{{end}}
+</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
+
+// formatExpireIn returns the time left before the expiration, or since it for expired resources
+func formatExpireIn(expirationTime time.Time, now time.Time) string {
+	if expirationTime.IsZero() {
+		return "-"
+	}
+
+	duration := expirationTime.Sub(now)
+	if duration < 0 {
+		duration = -duration
+	}
+	duration = duration.Truncate(time.Minute)
+
+	if expirationTime.Before(now) {
+		return fmt.Sprintf("%s ago", duration)
+	}
+
+	return duration.String()
+}
+
+func newDashboardData(reports []CheckReport, now time.Time) dashboardData {
+	regions := make(map[string]*dashboardRegion)
+	getRegion := func(name string) *dashboardRegion {
+		if name == "" {
+			name = "global"
+		}
+		if _, exists := regions[name]; !exists {
+			regions[name] = &dashboardRegion{Name: name}
+		}
+		return regions[name]
+	}
+
+	for _, report := range reports {
+		for _, entry := range report.Deletable {
+			region := getRegion(entry.Region)
+			region.Deletable = append(region.Deletable, dashboardResource{ReportEntry: entry, Source: report.Source, ExpireIn: formatExpireIn(entry.ExpirationTime, now)})
+		}
+		for _, entry := range report.Expiring {
+			region := getRegion(entry.Region)
+			region.Expiring = append(region.Expiring, dashboardResource{ReportEntry: entry, Source: report.Source, ExpireIn: formatExpireIn(entry.ExpirationTime, now)})
+		}
+		for _, failure := range report.Failures {
+			region := getRegion(failure.Region)
+			region.Failures = append(region.Failures, failure)
+		}
+		for _, leak := range report.Leaks {
+			region := getRegion(leak.Region)
+			region.Leaks = append(region.Leaks, leak)
+		}
+	}
+
+	data := dashboardData{GeneratedAt: now, ExpiringWithin: ExpiringWithin, Reports: reports}
+	for _, region := range regions {
+		sort.Slice(region.Expiring, func(i, j int) bool {
+			return region.Expiring[i].ExpirationTime.Before(region.Expiring[j].ExpirationTime)
+		})
+		data.Regions = append(data.Regions, region)
+	}
+	sort.Slice(data.Regions, func(i, j int) bool {
+		return data.Regions[i].Name < data.Regions[j].Name
+	})
+
+	return data
+}
+
+// handleDashboard shows the last reports by region in a web page
+func (s *Server) handleDashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s not found", r.URL.Path))
+		return
+	}
+
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err := dashboardTemplate.Execute(w, newDashboardData(s.getReports(), time.Now()))
+	if err != nil {
+		log.Errorf("Can't write the dashboard: %s", err)
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// metric is a gauge of the Prometheus text format
+type metric struct {
+	name string
+	help string
+}
+
+var (
+	resourcesMetric = metric{"pleco_resources", "Resources of the last check by resource type, region and result (scanned, not_expired, kept, scheduled, expired, aborted, deleted, failed, stuck)."}
+	errorsMetric    = metric{"pleco_check_errors", "Errors of the last check."}
+	startedMetric   = metric{"pleco_check_started_timestamp_seconds", "Start time of the last check."}
+)
+
+// handleMetrics returns the summary of the last check of every provider and of the last scan in the Prometheus text
+// format
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(w, s.getReports())
+}
+
+func writeMetrics(w io.Writer, reports []CheckReport) {
+	writeHeader(w, resourcesMetric)
+	for _, report := range reports {
+		for _, row := range report.Summary {
+			if row.Cleaner == "" {
+				continue
+			}
+
+			results := []struct {
+				name  string
+				count int
+			}{
+				{"scanned", row.Scanned},
+				{"not_expired", row.NotExpired},
+				{"kept", row.Kept},
+				{"scheduled", row.Scheduled},
+				{"expired", row.Expired},
+				{"aborted", row.Aborted},
+				{"deleted", row.Deleted},
+				{"failed", row.Failed},
+				{"stuck", row.Stuck},
+			}
+			for _, result := range results {
+				writeSample(w, resourcesMetric, result.count, "source", report.Source, "dry_run", fmt.Sprint(report.DryRun),
+					"resource_type", row.Cleaner, "region", row.Region, "result", result.name)
+			}
+		}
+	}
+
+	writeHeader(w, errorsMetric)
+	for _, report := range reports {
+		writeSample(w, errorsMetric, len(report.Errors), "source", report.Source, "dry_run", fmt.Sprint(report.DryRun))
+	}
+
+	writeHeader(w, startedMetric)
+	for _, report := range reports {
+		writeSample(w, startedMetric, report.StartedAt.Unix(), "source", report.Source, "dry_run", fmt.Sprint(report.DryRun))
+	}
+}
+
+func writeHeader(w io.Writer, m metric) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+}
+
+// writeSample writes a sample of the metric with its labels, given as name and value pairs
+func writeSample(w io.Writer, m metric, value interface{}, labels ...string) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%q", labels[i], labels[i+1]))
+	}
+
+	_, _ = fmt.Fprintf(w, "%s{%s} %v\n", m.name, strings.Join(pairs, ","), value)
+}
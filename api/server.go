@@ -0,0 +1,406 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/pleco"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ExpiringWithin is how soon resources expire to be listed by the plan endpoint
+const ExpiringWithin = 24 * time.Hour
+
+// maxHistory is the number of deletions kept in memory for the history endpoint, the audit log keeps all of them
+const maxHistory = 1000
+
+// defaultProtection is how long a resource is protected when no duration is given
+const defaultProtection = 24 * time.Hour
+
+// CheckReport is the report of a check served by the API
+type CheckReport struct {
+	Source    string                `json:"source"`
+	DryRun    bool                  `json:"dry_run"`
+	StartedAt time.Time             `json:"started_at"`
+	Deletable []utils.ReportEntry   `json:"deletable"`
+	Expiring  []utils.ReportEntry   `json:"expiring"`
+	Failures  []utils.ReportFailure `json:"failures"`
+	Leaks     []utils.Leak          `json:"leaks"`
+	Stuck     []utils.StuckResource `json:"stuck"`
+	Errors    []string              `json:"errors"`
+	Summary   []utils.SummaryRow    `json:"summary"`
+}
+
+// Deletion is a deletion done by a check, its outcome tells whether it failed
+type Deletion struct {
+	utils.ReportEntry
+	Source    string    `json:"source"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// Protection is a resource temporarily protected from deletion
+type Protection struct {
+	Identifier string    `json:"identifier"`
+	Until      time.Time `json:"until"`
+}
+
+// ProtectionStore keeps the temporary protections across restarts, the state store for instance
+type ProtectionStore interface {
+	// SaveProtection records the end of the protection of a resource, a protection ending now removes it
+	SaveProtection(ctx context.Context, identifier string, until time.Time) error
+}
+
+// Server serves the reports of the checks, runs scans and protects resources on demand
+type Server struct {
+	sync.Mutex
+	config      pleco.Config
+	token       string
+	protections ProtectionStore
+	reports     map[string]CheckReport
+	history     []Deletion
+	scanning    int32
+}
+
+// NewServer returns a server scanning with the configuration, requests need the token as bearer if it's set. The
+// protections are saved to the protection store if any, they are kept in memory only otherwise.
+func NewServer(config pleco.Config, token string, protections ProtectionStore) *Server {
+	return &Server{
+		config:      config,
+		token:       token,
+		protections: protections,
+		reports:     make(map[string]CheckReport),
+	}
+}
+
+func newCheckReport(source string, report *utils.Report) CheckReport {
+	return CheckReport{
+		Source:    source,
+		DryRun:    report.DryRun,
+		StartedAt: report.StartedAt,
+		Deletable: orEmpty(report.Candidates()),
+		Expiring:  orEmpty(report.Expiring()),
+		Failures:  report.Failures(),
+		Leaks:     report.Leaks(),
+		Stuck:     report.Stuck(),
+		Errors:    report.Errors(),
+		Summary:   withSource(report.Summary(), source),
+	}
+}
+
+// withSource sets the source of the summary rows
+func withSource(summary []utils.SummaryRow, source string) []utils.SummaryRow {
+	for i := range summary {
+		summary[i].Source = source
+	}
+
+	return summary
+}
+
+func orEmpty(entries []utils.ReportEntry) []utils.ReportEntry {
+	if entries == nil {
+		return []utils.ReportEntry{}
+	}
+
+	return entries
+}
+
+// Report records the report of a check, it's the report handler of the daemon checks
+func (s *Server) Report(ctx context.Context, source string, report *utils.Report) {
+	checkReport := newCheckReport(source, report)
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.reports[source] = checkReport
+	if report.DryRun {
+		return
+	}
+
+	for _, entry := range checkReport.Deletable {
+		// the deletions aborted or skipped weren't tried
+		if entry.Outcome != utils.OutcomeDeleted && entry.Outcome != utils.OutcomeFailed {
+			continue
+		}
+
+		s.history = append(s.history, Deletion{
+			ReportEntry: entry,
+			Source:      source,
+			DeletedAt:   time.Now().UTC(),
+		})
+	}
+
+	if len(s.history) > maxHistory {
+		s.history = s.history[len(s.history)-maxHistory:]
+	}
+}
+
+// Handler returns the API routes
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/scan", s.handleScan)
+	mux.HandleFunc("/plan", s.handlePlan)
+	mux.HandleFunc("/deletions/history", s.handleHistory)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/protect", s.handleProtections)
+	mux.HandleFunc("/protect/", s.handleProtect)
+	mux.HandleFunc("/", s.handleDashboard)
+
+	return s.authenticate(mux)
+}
+
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" && !s.isAuthorized(r) {
+			// browsers ask for the token as password to show the dashboard
+			w.Header().Set("WWW-Authenticate", `Basic realm="pleco"`)
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isAuthorized returns true if the request has the token as bearer, or as basic auth password. Tokens are compared in
+// constant time so response times don't leak them.
+func (s *Server) isAuthorized(r *http.Request) bool {
+	if authorization := r.Header.Get("Authorization"); strings.HasPrefix(authorization, "Bearer ") {
+		return isToken(strings.TrimPrefix(authorization, "Bearer "), s.token)
+	}
+
+	_, password, hasBasicAuth := r.BasicAuth()
+	return hasBasicAuth && isToken(password, s.token)
+}
+
+func isToken(value string, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(value), []byte(token)) == 1
+}
+
+// ListenAndServe serves the API until the context is done. Without token, it only listens on a loopback address: anyone
+// reaching the API could protect resources or run scans otherwise.
+func (s *Server) ListenAndServe(ctx context.Context, address string) error {
+	if s.token == "" && !isLoopback(address) {
+		return fmt.Errorf("the API can't listen on %s without token, set PLECO_API_TOKEN or listen on a loopback address (ex: 127.0.0.1:8080)", address)
+	}
+
+	server := &http.Server{Addr: address, Handler: s.Handler()}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Infof("Serving the API on %s.", address)
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+
+	return err
+}
+
+// isLoopback returns true if the address only listens on a loopback interface, an address without host listens on all
+// of them
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil || host == "" {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(value)
+	if err != nil {
+		log.Errorf("Can't write API response: %s", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"error": message})
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Sprintf("method %s not allowed", r.Method))
+	return false
+}
+
+// handleScan runs a dry run check of every provider and returns its report, one scan runs at a time
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost) {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&s.scanning, 0, 1) {
+		writeError(w, http.StatusConflict, "a scan is already running")
+		return
+	}
+	defer atomic.StoreInt32(&s.scanning, 0)
+
+	config := s.config
+	config.DryRun = true
+	config.ExpiringWithin = ExpiringWithin
+	config.ReportFormat = ""
+
+	log.Info("Scanning on API request.")
+	report, err := pleco.Run(r.Context(), config)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	checkReport := newCheckReport("scan", report)
+	s.Lock()
+	s.reports["scan"] = checkReport
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, checkReport)
+}
+
+// handlePlan returns the last report of every provider and of the last scan
+func (s *Server) handlePlan(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.getReports())
+}
+
+// getReports returns the last report of every provider and of the last scan, sorted by source
+func (s *Server) getReports() []CheckReport {
+	s.Lock()
+	reports := make([]CheckReport, 0, len(s.reports))
+	for _, report := range s.reports {
+		reports = append(reports, report)
+	}
+	s.Unlock()
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Source < reports[j].Source
+	})
+
+	return reports
+}
+
+// handleHistory returns the last deletions, the most recent first
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	s.Lock()
+	history := make([]Deletion, 0, len(s.history))
+	for i := len(s.history) - 1; i >= 0; i-- {
+		history = append(history, s.history[i])
+	}
+	s.Unlock()
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// handleProtections lists the resources temporarily protected
+func (s *Server) handleProtections(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	protections := []Protection{}
+	for identifier, until := range utils.GetTemporaryProtections() {
+		protections = append(protections, Protection{Identifier: identifier, Until: until})
+	}
+
+	writeJSON(w, http.StatusOK, protections)
+}
+
+// handleProtect protects the resource with this ARN, name or id for the duration parameter (ex: 2h, 3d), 24 hours by
+// default, or removes its protection
+func (s *Server) handleProtect(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodPost, http.MethodDelete) {
+		return
+	}
+
+	identifier := strings.TrimPrefix(r.URL.Path, "/protect/")
+	if identifier == "" {
+		writeError(w, http.StatusBadRequest, "missing resource ARN, name or id")
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		if _, isProtected := utils.GetTemporaryProtections()[identifier]; !isProtected {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("%s isn't protected", identifier))
+			return
+		}
+		if !s.saveProtection(w, r, identifier, time.Now().UTC()) {
+			return
+		}
+		utils.Unprotect(identifier)
+
+		log.Infof("Removed the protection of %s on API request.", identifier)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	duration := defaultProtection
+	if value := r.URL.Query().Get("duration"); value != "" {
+		seconds, err := utils.ParseTTL(value)
+		if err != nil || seconds <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid duration %s", value))
+			return
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+
+	until := time.Now().Add(duration).UTC().Truncate(time.Second)
+	if !s.saveProtection(w, r, identifier, until) {
+		return
+	}
+	utils.ProtectUntil(identifier, until)
+	log.Infof("Protected %s until %s on API request.", identifier, until.Format(time.RFC3339))
+
+	writeJSON(w, http.StatusOK, Protection{Identifier: identifier, Until: until})
+}
+
+// saveProtection saves the end of the protection of the resource to the protection store if any, it writes an error
+// response and returns false if it can't
+func (s *Server) saveProtection(w http.ResponseWriter, r *http.Request, identifier string, until time.Time) bool {
+	if s.protections == nil {
+		return true
+	}
+
+	err := s.protections.SaveProtection(r.Context(), identifier, until)
+	if err != nil {
+		log.Errorf("Can't save the protection of %s: %s", identifier, err)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("can't save the protection of %s: %s", identifier, err))
+		return false
+	}
+
+	return true
+}
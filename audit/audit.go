@@ -0,0 +1,214 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	aws2 "github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Record is the audit trail entry of a resource found deletable: deleted, failed, aborted by a circuit breaker or
+// skipped. Every record holds the hash of the previous one, so removing or editing a record breaks the chain.
+type Record struct {
+	Time           time.Time         `json:"time"`
+	Actor          string            `json:"actor"`
+	Source         string            `json:"source"`
+	Action         string            `json:"action"`
+	Outcome        string            `json:"outcome"`
+	Error          string            `json:"error,omitempty"`
+	ResourceType   string            `json:"resource_type"`
+	Name           string            `json:"name"`
+	Arn            string            `json:"arn,omitempty"`
+	Region         string            `json:"region,omitempty"`
+	CreationDate   time.Time         `json:"creation_date,omitempty"`
+	TTL            int64             `json:"ttl"`
+	ExpirationTime time.Time         `json:"expiration_time"`
+	Tags           map[string]string `json:"tags,omitempty"`
+	PreviousHash   string            `json:"previous_hash"`
+	Hash           string            `json:"hash"`
+}
+
+// Log appends the deletions of every check to a local file or to an S3 object per check
+type Log struct {
+	sync.Mutex
+	destination string
+	actor       string
+	lastHash    string
+	s3Session   *s3.S3
+	bucket      string
+	prefix      string
+}
+
+// NewLog returns an audit log writing to a local path or to s3://bucket/prefix, a local log carries on the hash chain
+// of its last record
+func NewLog(ctx context.Context, destination string, actor string) (*Log, error) {
+	auditLog := &Log{destination: destination, actor: actor}
+
+	if !strings.HasPrefix(destination, "s3://") {
+		lastHash, err := getLastHash(destination)
+		if err != nil {
+			return nil, err
+		}
+		auditLog.lastHash = lastHash
+
+		return auditLog, nil
+	}
+
+	location := strings.SplitN(strings.TrimPrefix(destination, "s3://"), "/", 2)
+	auditLog.bucket = location[0]
+	if len(location) == 2 {
+		auditLog.prefix = strings.TrimSuffix(location[1], "/")
+	}
+	if auditLog.bucket == "" {
+		return nil, fmt.Errorf("expected s3://bucket/prefix, got %s", destination)
+	}
+
+	sess, err := aws2.CreateSessionWithoutRegion()
+	if err != nil {
+		return nil, err
+	}
+
+	region, err := s3manager.GetBucketRegion(ctx, sess, auditLog.bucket, aws2.DefaultRegion())
+	if err != nil {
+		return nil, err
+	}
+	auditLog.s3Session = s3.New(sess, &aws.Config{Region: aws.String(region)})
+
+	return auditLog, nil
+}
+
+func getLastHash(path string) (string, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var lastRecord Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		err := json.Unmarshal(scanner.Bytes(), &lastRecord)
+		if err != nil {
+			return "", fmt.Errorf("can't parse audit log %s: %s", path, err)
+		}
+	}
+
+	return lastRecord.Hash, scanner.Err()
+}
+
+// Report is a report handler recording the outcome of the resources found deletable by a check, dry runs delete
+// nothing so they're not recorded
+func (l *Log) Report(ctx context.Context, source string, report *utils.Report) {
+	if report.DryRun {
+		return
+	}
+
+	entries := report.Candidates()
+	if len(entries) == 0 {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	var content bytes.Buffer
+	previousHash := l.lastHash
+	for _, entry := range entries {
+		record := Record{
+			Time:           time.Now().UTC(),
+			Actor:          l.actor,
+			Source:         source,
+			Action:         "delete",
+			Outcome:        entry.Outcome,
+			Error:          entry.Error,
+			ResourceType:   entry.Type,
+			Name:           entry.Name,
+			Arn:            entry.Arn,
+			Region:         entry.Region,
+			CreationDate:   entry.CreationDate,
+			TTL:            entry.TTL,
+			ExpirationTime: entry.ExpirationTime,
+			Tags:           entry.Tags,
+			PreviousHash:   previousHash,
+		}
+
+		line, err := hashRecord(&record)
+		if err != nil {
+			log.Errorf("Can't write audit record of %s %s: %s", entry.Type, entry.Name, err)
+			return
+		}
+		content.Write(line)
+		content.WriteString("\n")
+		previousHash = record.Hash
+	}
+
+	err := l.write(ctx, source, content.Bytes())
+	if err != nil {
+		log.Errorf("Can't write audit log %s: %s", l.destination, err)
+		return
+	}
+
+	l.lastHash = previousHash
+}
+
+// hashRecord sets the record hash, computed from its content and the previous hash, and returns its json line
+func hashRecord(record *Record) ([]byte, error) {
+	record.Hash = ""
+	content, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(content)
+	record.Hash = hex.EncodeToString(hash[:])
+
+	return json.Marshal(record)
+}
+
+func (l *Log) write(ctx context.Context, source string, content []byte) error {
+	if l.s3Session == nil {
+		file, err := os.OpenFile(l.destination, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = file.Write(content)
+		return err
+	}
+
+	key := fmt.Sprintf("%s-%s.jsonl", time.Now().UTC().Format("2006-01-02T15-04-05.000Z"), strings.ToLower(source))
+	if l.prefix != "" {
+		key = l.prefix + "/" + key
+	}
+
+	_, err := l.s3Session.PutObjectWithContext(ctx,
+		&s3.PutObjectInput{
+			Bucket:      aws.String(l.bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(content),
+			ContentType: aws.String("application/x-ndjson"),
+		})
+
+	return err
+}
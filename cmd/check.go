@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/Qovery/pleco/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the credentials are allowed the AWS actions of the enabled cleaners",
+	Long: `
+Check simulates, with iam:SimulatePrincipalPolicy, the AWS actions needed by the cleaners enabled with the same options
+as start, for the credentials or for each role to assume. It prints the minimal IAM policy allowing them.
+
+Exit codes: 0 when every action is allowed, 1 on error, 2 when permissions are missing.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setLogLevel()
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+
+		log.Infof("Checking Pleco %s permissions", GetCurrentVersion())
+
+		os.Exit(core.Check(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	addCheckFlags(checkCmd)
+}
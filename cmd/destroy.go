@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/Qovery/pleco/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// destroyClusterCmd represents the destroy-cluster command
+var destroyClusterCmd = &cobra.Command{
+	Use:   "destroy-cluster",
+	Short: "Delete an EKS cluster and every resource tagged with its name now",
+	Long: `
+Destroy-cluster deletes an EKS cluster and the resources of the regions tagged with its name, by the cluster tag key or
+by the kubernetes.io/cluster/<name> tag, and its load balancers, without waiting for their expiration. Checks run until
+one deletes nothing, each one deleting the resources whose dependencies are gone. Protected and excluded resources are
+kept. Without --disable-dry-run, the resources are only listed.
+
+Exit codes: 0 once destroyed, 1 on error, 2 when a dry run found resources to destroy, 3 when resources are left
+after the attempts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setLogLevel()
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+
+		os.Exit(core.DestroyCluster(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(destroyClusterCmd)
+
+	destroyClusterCmd.Flags().String("cluster-id", "", "Name of the EKS cluster to destroy")
+	destroyClusterCmd.Flags().BoolP("disable-dry-run", "y", false, "Disable dry run mode")
+	destroyClusterCmd.Flags().StringSliceP("aws-regions", "a", nil, "Set AWS regions where the cluster resources are looked up")
+	destroyClusterCmd.Flags().StringP("tag-name", "t", "ttl", "Set the tag name holding the time to leave")
+	destroyClusterCmd.Flags().String("cluster-tag-key", "ClusterName", "Set the tag name holding the EKS cluster name on its VPCs")
+	addClusterTagFlags(destroyClusterCmd)
+	destroyClusterCmd.Flags().StringArray("exclude", nil, "Regex matched against resources names, ids and ARNs, matching resources are never deleted (can be repeated)")
+	destroyClusterCmd.Flags().Int("parallelism", 4, "Maximum number of cleaners running at the same time")
+	destroyClusterCmd.Flags().Int64("deletion-wait-timeout", 10, "Minutes to wait for asynchronous deletions (ex: NAT gateways, EKS node groups) before deleting the resources depending on them, which are retried on the next checks once reached (0 to never wait)")
+	destroyClusterCmd.Flags().Bool("override-deletion-protection", false, "Disable the deletion protection of the load balancers before deleting them")
+	destroyClusterCmd.Flags().String("final-snapshot-ttl", "", "Take a final snapshot of the deleted databases and volumes, deleted after this ttl (ex: 7d), none if empty")
+	destroyClusterCmd.Flags().Int("attempts", 20, "Maximum number of checks deleting the cluster resources")
+	destroyClusterCmd.Flags().Int64("attempt-interval", 60, "Time in seconds between two checks")
+	addEndpointFlags(destroyClusterCmd)
+	_ = destroyClusterCmd.MarkFlagRequired("cluster-id")
+}
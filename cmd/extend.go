@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/Qovery/pleco/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// extendCmd represents the extend command
+var extendCmd = &cobra.Command{
+	Use:   "extend",
+	Short: "Push back the expiration of AWS resources",
+	Long: `
+Extend pushes back the expiration of AWS resources picked by ARN, by rewriting their expiration date tag if they have
+one or their ttl tag otherwise. Resources with neither never expire and can't be extended.
+
+Exit codes: 0 on success, 1 on error.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setLogLevel()
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+
+		os.Exit(core.Extend(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(extendCmd)
+
+	extendCmd.Flags().String("by", "", "Duration to add to the expiration, in seconds or with a unit among s, m, h, d and w (ex: 48h, 3d)")
+	extendCmd.Flags().StringArray("arn", nil, "ARN of a resource to extend (can be repeated)")
+	extendCmd.Flags().String("aws-region", "", "Set the AWS region of the resources whose ARN has none (ex: S3 buckets), default region of the partition if empty")
+	extendCmd.Flags().StringP("tag-name", "t", "ttl", "Set the tag name holding the time to leave")
+	addEndpointFlags(extendCmd)
+	_ = extendCmd.MarkFlagRequired("by")
+	_ = extendCmd.MarkFlagRequired("arn")
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/Qovery/pleco/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// operatorCmd represents the operator command
+var operatorCmd = &cobra.Command{
+	Use:   "operator",
+	Short: "Start Pleco as a Kubernetes operator running the checks declared by CleanupPolicy resources",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setLogLevel()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		log.Infof("Starting Pleco %s operator", GetCurrentVersion())
+
+		core.StartOperator(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(operatorCmd)
+
+	operatorCmd.Flags().StringP("kube-conn", "k", "in", "Kubernetes connection method, choose between : in/out")
+	operatorCmd.Flags().Int64("shutdown-timeout", 25, "Time in seconds to wait for running checks to stop on SIGTERM")
+	addEndpointFlags(operatorCmd)
+}
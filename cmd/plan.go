@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"github.com/Qovery/pleco/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Run a single dry run check and report the resources Pleco would delete",
+	Long: `
+Plan runs a single check in dry run mode and prints the resources that would be deleted, as a table or exported as json
+or csv, along with the resources expiring soon.
+
+Exit codes: 0 when there is nothing to delete, 1 on error, 2 when deletions are pending.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setLogLevel()
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+
+		log.Infof("Planning Pleco %s deletions", GetCurrentVersion())
+
+		os.Exit(core.Plan(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(planCmd)
+
+	addCheckFlags(planCmd)
+	planCmd.Flags().String("output", "", "Format of the report, choose between : table/json/csv (report-format if empty)")
+	planCmd.Flags().String("output-file", "", "Write the report to this file instead of the standard output")
+	planCmd.Flags().Int64("expiring-within", 0, "Add to the report the resources expiring within this number of hours (0 to disable)")
+}
@@ -37,6 +37,7 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.pleco.yaml)")
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "level", "info", "set log level")
+	rootCmd.PersistentFlags().String("log-format", "text", "set log format, choose between : text/json")
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -78,10 +79,20 @@ func setLogLevel() error {
 
 	logrus.SetLevel(lvl)
 
-	// use timestamp
-	formatter := &logrus.TextFormatter{
-		FullTimestamp: true,
+	logFormat, _ := rootCmd.Flags().GetString("log-format")
+	switch logFormat {
+	case "json":
+		// resource logs carry the resource_type, resource_id, region, action and dry_run fields
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	case "text":
+		// use timestamp
+		formatter := &logrus.TextFormatter{
+			FullTimestamp: true,
+		}
+		logrus.SetFormatter(formatter)
+	default:
+		return fmt.Errorf("unknown log format %s, choose between text/json", logFormat)
 	}
-	logrus.SetFormatter(formatter)
+
 	return nil
 }
\ No newline at end of file
@@ -5,6 +5,7 @@ import (
 	"github.com/Qovery/pleco/core"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
 )
 
 // startCmd represents the start command
@@ -12,7 +13,10 @@ var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start Pleco as a daemon",
 	Run: func(cmd *cobra.Command, args []string) {
-		_ = setLogLevel()
+		err := setLogLevel()
+		if err != nil {
+			log.Fatal(err)
+		}
 
 		disableDryRun, _ := cmd.Flags().GetBool("disable-dry-run")
 		interval, _ := cmd.Flags().GetInt64("check-interval")
@@ -22,7 +26,7 @@ var startCmd = &cobra.Command{
 		fmt.Println("")
 		log.Infof("Starting Pleco %s", GetCurrentVersion())
 
-		core.StartDaemon(disableDryRun, interval, cmd)
+		os.Exit(core.StartDaemon(disableDryRun, interval, cmd))
 	},
 }
 
@@ -31,25 +35,128 @@ func init() {
 
 	startCmd.Flags().BoolP("disable-dry-run", "y", false, "Disable dry run mode")
 	startCmd.Flags().Int64P("check-interval", "i", 120, "Check interval in seconds")
-	startCmd.Flags().StringP("tag-name", "t", "ttl", "Set the tag name to check for deletion")
+	startCmd.Flags().String("schedule", "", "Run the checks at the times of a cron expression (ex: \"0 */2 * * *\") instead of every check interval")
+	startCmd.Flags().Bool("once", false, "Run a single check, print the summary of the cleaners and exit with a non zero code if cleaners or deletions failed, or if a dry run found pending deletions")
+	startCmd.Flags().String("api-listen", "", "Serve the HTTP API on this address (ex: :8080), requests need the PLECO_API_TOKEN environment variable as bearer token, needed unless listening on a loopback address")
+	startCmd.Flags().StringArray("deletion-window", nil, "Only delete resources during a [days] hh:mm-hh:mm local time window (ex: \"mon-thu 20:00-06:00\"), checks outside of it only report (can be repeated)")
+	startCmd.Flags().Int64("shutdown-timeout", 25, "Time in seconds to wait for running checks to stop on SIGTERM")
+	startCmd.Flags().String("state-store", "", "Record when resources were first seen, tagged and deleted in a local json file or a dynamodb://region/table DynamoDB table")
+	startCmd.Flags().Int("stuck-after", 10, "Report the resources still there after this number of deletions in a row as stuck, needs the state store (0 to disable)")
+	startCmd.Flags().Bool("diff", false, "Log what changed since the previous check: new, newly expired, deleted resources and deletions failing in a row, needs the state store")
+	startCmd.Flags().String("compliance-report", "", "Write the resources missing the required tags found by each check to this json or csv (.csv extension) file")
+	startCmd.Flags().String("audit-log", "", "Append every deletion as a hash chained json record to a local file or to an object per check in s3://bucket/prefix")
+	startCmd.Flags().StringArray("notify", nil, "Post a summary after each check to a <slack|webhook>[:<info|warning|error>]=<url> channel (can be repeated)")
+	startCmd.Flags().StringArray("deletion-events", nil, "Publish an event for every deletion to a <sns|eventbridge>=<topic or bus ARN> target (can be repeated)")
+	startCmd.Flags().Bool("enable-datadog", false, "Send deletion events and check metrics to Datadog (DD_API_KEY and optional DD_SITE environment variables)")
+	startCmd.Flags().StringSlice("datadog-tags", nil, "Tags added to every Datadog event and metric (ex: env:staging)")
+	startCmd.Flags().String("otlp-endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), "Export the spans of the checks to this OpenTelemetry collector endpoint with OTLP/HTTP (ex: http://otel-collector:4318), OTEL_EXPORTER_OTLP_HEADERS adds headers")
+	startCmd.Flags().Int64("notify-expiring-within", 24, "Include in notifications the resources expiring within this number of hours (0 to disable)")
+	startCmd.Flags().Int64("notify-owners-within", 0, "Notify the owner (owner or email tag) of a resource scheduled for deletion this number of hours before its deletion (0 to disable)")
+	startCmd.Flags().String("owner-email-from", "", "Sender address of the emails sent to owners through SES (SLACK_BOT_TOKEN environment variable enables Slack direct messages)")
+	startCmd.Flags().String("owner-email-region", "us-east-1", "AWS region of the SES service sending emails to owners")
+	addCheckFlags(startCmd)
+}
+
+// addClusterTagFlags adds the flags matching the tags of the load balancers of the EKS clusters
+func addClusterTagFlags(cmd *cobra.Command) {
+	cmd.Flags().String("tag-key-pattern", "", "Regex (or glob prefixed with glob:) matching the tag keys of the load balancers of an EKS cluster, {cluster} is its name")
+	cmd.Flags().String("tag-value-pattern", "", "Regex (or glob prefixed with glob:) matching the tag values of the load balancers of an EKS cluster, {cluster} is its name")
+}
+
+// addCheckFlags adds the flags configuring the checks, shared by the commands running them
+func addCheckFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("tag-name", "t", "ttl", "Set the tag name holding the time to leave in seconds, checked for deletion")
+	cmd.Flags().String("cluster-tag-key", "ClusterName", "Set the tag name holding the EKS cluster name on its VPCs")
+	addClusterTagFlags(cmd)
+	cmd.Flags().Int("parallelism", 4, "Maximum number of cleaners running at the same time")
+	cmd.Flags().Int64("cleaner-timeout", 0, "Stop a cleaner running longer than this number of minutes, it fails and the others keep running (0 for no timeout)")
+	cmd.Flags().Int64("deletion-wait-timeout", 10, "Minutes to wait for asynchronous deletions (ex: NAT gateways, EKS node groups) before deleting the resources depending on them, which are retried on the next checks once reached (0 to never wait)")
+	cmd.Flags().StringArray("exclude", nil, "Regex matched against resources names, ids and ARNs, matching resources are never tagged nor deleted (can be repeated)")
+	cmd.Flags().StringArray("production-marker", nil, "Tag marking production resources as key=value or key (ex: environment=production), they are never deleted and an alert is logged if they carry a ttl (can be repeated)")
+	cmd.Flags().StringArray("untagged-max-age", nil, "Delete the resources of a type without ttl nor expiration date once older than a max age, if their name matches, as <resource type>=<max age>:<name regex> (ex: \"S3 bucket=14d:z-test-.*\", can be repeated)")
+	cmd.Flags().Int64("min-age", 0, "Never delete resources created less than this number of minutes ago, whatever their ttl (0 to disable)")
+	cmd.Flags().Int("max-deletions", 0, "Abort the checks which would delete more resources than this, before any deletion (0 for no limit)")
+	cmd.Flags().Int("max-deletions-per-type", 0, "Abort the deletion of a resource type in a region if there are more resources to delete than this (0 for no limit)")
+	cmd.Flags().StringArray("terraform-state", nil, "Terraform state (local path or s3://bucket/key) whose resources are never deleted (can be repeated)")
+	cmd.Flags().Int64("deletion-grace-period", 0, "Tag expired resources with their deletion date and delete them after this number of minutes (0 to delete right away)")
+	cmd.Flags().Int64("quarantine-period", 0, "Stop expired RDS databases and EC2 instances, scale expired auto scaling groups and the node groups of expired EKS clusters to zero, and delete them after this number of minutes (0 to disable)")
+	cmd.Flags().String("report-format", "table", "Format of the report of the resources a dry run would delete, choose between : table/json/csv")
+	cmd.Flags().StringSlice("required-tags", nil, "Report the AWS resources missing one of these tags (ex: owner,cost-center,ttl), without deleting them")
+	cmd.Flags().Bool("estimate-costs", false, "Add the estimated monthly cost of AWS resources to the dry run report, from the AWS Pricing API")
 
 	// AWS
-	startCmd.Flags().StringSliceP("aws-regions", "a", nil, "Set AWS regions")
-	startCmd.Flags().BoolP("enable-eks", "e", false, "Enable EKS watch")
-	startCmd.Flags().BoolP("enable-rds", "r", false, "Enable RDS watch")
-	startCmd.Flags().BoolP("enable-documentdb", "m", false, "Enable DocumentDB watch")
-	startCmd.Flags().BoolP("enable-elasticache", "c", false, "Enable Elasticache watch")
-	startCmd.Flags().BoolP("enable-elb", "l", false, "Enable Elastic Load Balancers watch (true is eks is enabled)")
-	startCmd.Flags().BoolP("enable-ebs", "b", false, "Enable Elastic Volumes watch (true is eks is enabled)")
-	startCmd.Flags().BoolP("enable-vpc", "p", false, "Enable VPC watch and its children (internet gateways, route tables, subnets, security groups)")
-	startCmd.Flags().BoolP("enable-s3", "s", false, "Enable S3 watch")
-	startCmd.Flags().BoolP("enable-cloudwatch-logs", "w", false, "Enable Cloudwatch Logs watch")
-	startCmd.Flags().BoolP("enable-kms", "n", false, "Enable KMS watch")
-	startCmd.Flags().BoolP("enable-iam", "u", false, "Enable IAM watch (groups, policies, roles, users)")
-	startCmd.Flags().BoolP("enable-ssh-keys", "z", false, "Enable Key Pair watch")
-	startCmd.Flags().BoolP("enable-ecr", "o", false, "Enable ECR watch")
+	cmd.Flags().StringSliceP("aws-regions", "a", nil, "Set AWS regions")
+	cmd.Flags().Bool("all-regions", false, "Check every region enabled on the AWS account (aws-regions are ignored)")
+	cmd.Flags().StringSlice("aws-role-arns", nil, "Set IAM roles to assume, pleco checks the account of each role")
+	cmd.Flags().StringArray("aws-account", nil, "Check the account of these credentials: comma separated role-arn, profile, session-name, external-id and web-identity-token-file keys (can be repeated)")
+	cmd.Flags().BoolP("enable-eks", "e", false, "Enable EKS watch")
+	cmd.Flags().BoolP("enable-rds", "r", false, "Enable RDS watch")
+	cmd.Flags().BoolP("enable-documentdb", "m", false, "Enable DocumentDB watch")
+	cmd.Flags().BoolP("enable-elasticache", "c", false, "Enable Elasticache watch")
+	cmd.Flags().BoolP("enable-elb", "l", false, "Enable Elastic Load Balancers watch (true is eks is enabled)")
+	cmd.Flags().BoolP("enable-ebs", "b", false, "Enable Elastic Volumes watch (true is eks is enabled)")
+	cmd.Flags().BoolP("enable-vpc", "p", false, "Enable VPC watch and its children (internet gateways, route tables, subnets, security groups, transit gateways, VPN connections)")
+	cmd.Flags().BoolP("enable-s3", "s", false, "Enable S3 watch")
+	cmd.Flags().BoolP("enable-cloudwatch-logs", "w", false, "Enable Cloudwatch Logs watch")
+	cmd.Flags().BoolP("enable-kms", "n", false, "Enable KMS watch")
+	cmd.Flags().BoolP("enable-iam", "u", false, "Enable IAM watch (groups, policies, roles, users)")
+	cmd.Flags().BoolP("enable-ssh-keys", "z", false, "Enable Key Pair watch")
+	cmd.Flags().BoolP("enable-ecr", "o", false, "Enable ECR watch")
+	cmd.Flags().BoolP("enable-glue", "g", false, "Enable Glue watch (databases and their tables, crawlers, jobs)")
+	cmd.Flags().BoolP("enable-elastic-beanstalk", "j", false, "Enable Elastic Beanstalk watch (environments, application versions)")
+	cmd.Flags().Bool("enable-eventbridge", false, "Enable EventBridge rules watch, their targets are removed before their deletion")
+	cmd.Flags().Bool("enable-cloudwatch-alarms", false, "Enable CloudWatch metric and composite alarms watch")
+	cmd.Flags().Bool("enable-db-groups", false, "Enable the watch of the RDS, DocumentDB and Elasticache subnet and parameter groups no database uses anymore")
+	cmd.Flags().Bool("enable-ecs", false, "Enable ECS watch (services scaled to zero before their deletion, clusters, unused task definitions)")
+	cmd.Flags().Bool("enable-apprunner", false, "Enable App Runner services watch")
+	cmd.Flags().Bool("enable-ec2-instances", false, "Enable EC2 instances watch, except the instances of auto scaling groups")
+	cmd.Flags().Bool("enable-autoscaling", false, "Enable auto scaling groups watch (deleted with their instances), except the ones of EKS node groups")
+	cmd.Flags().Bool("disable-eks", false, "Disable EKS watch, even if enabled")
+	cmd.Flags().Bool("disable-rds", false, "Disable RDS watch, even if enabled")
+	cmd.Flags().Bool("disable-documentdb", false, "Disable DocumentDB watch, even if enabled")
+	cmd.Flags().Bool("disable-elasticache", false, "Disable Elasticache watch, even if enabled")
+	cmd.Flags().Bool("disable-elb", false, "Disable Elastic Load Balancers watch, even if enabled or implied by eks")
+	cmd.Flags().Bool("disable-ebs", false, "Disable Elastic Volumes watch, even if enabled or implied by eks")
+	cmd.Flags().Bool("disable-vpc", false, "Disable VPC watch, even if enabled")
+	cmd.Flags().Bool("disable-s3", false, "Disable S3 watch, even if enabled")
+	cmd.Flags().Bool("disable-cloudwatch-logs", false, "Disable Cloudwatch Logs watch, even if enabled")
+	cmd.Flags().Bool("disable-kms", false, "Disable KMS watch, even if enabled")
+	cmd.Flags().Bool("disable-iam", false, "Disable IAM watch, even if enabled")
+	cmd.Flags().Bool("disable-ssh-keys", false, "Disable Key Pair watch, even if enabled")
+	cmd.Flags().Bool("disable-ecr", false, "Disable ECR watch, even if enabled")
+	cmd.Flags().Bool("disable-glue", false, "Disable Glue watch, even if enabled")
+	cmd.Flags().Bool("disable-elastic-beanstalk", false, "Disable Elastic Beanstalk watch, even if enabled")
+	cmd.Flags().Bool("disable-eventbridge", false, "Disable EventBridge rules watch, even if enabled")
+	cmd.Flags().Bool("disable-cloudwatch-alarms", false, "Disable CloudWatch alarms watch, even if enabled")
+	cmd.Flags().Bool("disable-db-groups", false, "Disable database subnet and parameter groups watch, even if enabled")
+	cmd.Flags().Bool("disable-ecs", false, "Disable ECS watch, even if enabled")
+	cmd.Flags().Bool("disable-apprunner", false, "Disable App Runner services watch, even if enabled")
+	cmd.Flags().Bool("disable-ec2-instances", false, "Disable EC2 instances watch, even if enabled")
+	cmd.Flags().Bool("disable-autoscaling", false, "Disable auto scaling groups watch, even if enabled")
+	cmd.Flags().Bool("detect-leaks", false, "Report the resources without ttl left orphaned: unassociated elastic IPs, detached volumes, unused target groups and security groups, empty VPCs")
+	cmd.Flags().Int64("leak-min-age", 7, "Number of days detached volumes and empty VPCs have to be old to be reported as leaked")
+	cmd.Flags().Float64("quota-threshold", 0, "Report the quotas of load balancers, listeners, target groups, elastic IPs, VPCs and security groups used above this percent and run their cleaners first in the next check (0 to disable)")
+	cmd.Flags().StringSlice("delete-leaks", nil, "Delete the leaked resources of these categories: eip/ebs/target-group/security-group/vpc")
+	cmd.Flags().String("final-snapshot-ttl", "", "Take a final snapshot of the deleted RDS databases, DocumentDB clusters and EBS volumes, deleted after this ttl (ex: 7d), none by default")
+	cmd.Flags().Bool("override-deletion-protection", false, "Disable the deletion protection of expired load balancers before deleting them")
+	cmd.Flags().StringArray("cleaner-plugin", nil, "Load a Go plugin registering cleaners of other resource types, checked in every region (can be repeated)")
+	addEndpointFlags(cmd)
 
 
 	// K8s
-	startCmd.Flags().StringP("kube-conn", "k", "off","Kubernetes connection method, choose between : off/in/out")
+	cmd.Flags().StringP("kube-conn", "k", "off","Kubernetes connection method, choose between : off/in/out")
+
+	// HTTP endpoints
+	cmd.Flags().String("http-list-url", "", "Check the resources returned as json by this endpoint of an internal platform (PLECO_HTTP_TOKEN environment variable is sent as bearer token if set)")
+	cmd.Flags().String("http-delete-url", "", "Endpoint deleting the expired resources of the http-list-url one: DELETE on the url with its {id} placeholder replaced, or POST of the resource without it")
+	cmd.Flags().String("http-name", "HTTP", "Name of the platform of the http-list-url endpoint, source of its reports and default resource type")
+}
+
+// addEndpointFlags adds the flags configuring the endpoints and the credentials of the AWS sessions
+func addEndpointFlags(cmd *cobra.Command) {
+	cmd.Flags().String("aws-profile", "", "Profile of the AWS shared configuration holding the credentials (default is the AWS_PROFILE environment variable, or the default credential chain)")
+	cmd.Flags().String("aws-session-name", "pleco", "Session name of the assumed IAM roles, shown in CloudTrail")
+	cmd.Flags().String("aws-endpoint", "", "Endpoint of every AWS service, to run against an emulator like LocalStack (default is the AWS_ENDPOINT_URL environment variable)")
+	cmd.Flags().StringArray("aws-service-endpoint", nil, "Endpoint of an AWS service as <endpoint prefix>=<url> (ex: s3=https://s3.internal), other services keep their default endpoint (can be repeated)")
+	cmd.Flags().String("aws-partition", "aws", "AWS partition of the global services and of the default region, choose between : aws/aws-cn/aws-us-gov")
 }
\ No newline at end of file
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/Qovery/pleco/core"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// tagCmd represents the tag command
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Set the ttl tag of existing AWS resources",
+	Long: `
+Tag sets the ttl tag of existing AWS resources, picked by ARN, by name pattern or by EKS cluster, so pleco deletes
+them once expired. The ttl counts from the creation of the resources.
+
+Exit codes: 0 on success, 1 on error.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		err := setLogLevel()
+		if err != nil {
+			log.Error(err)
+			os.Exit(1)
+		}
+
+		os.Exit(core.Tag(cmd))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+
+	tagCmd.Flags().String("ttl", "", "Time to leave to set, in seconds or with a unit among s, m, h, d and w (ex: 2h, 3d)")
+	tagCmd.Flags().StringArray("arn", nil, "ARN of a resource to tag (can be repeated)")
+	tagCmd.Flags().String("name-pattern", "", "Regex matched against the names of the load balancers and of the resources known by the tagging API in the regions")
+	tagCmd.Flags().String("cluster", "", "Name of an EKS cluster to tag with its load balancers and VPCs in the regions")
+	tagCmd.Flags().StringSliceP("aws-regions", "a", nil, "Set AWS regions where name patterns and clusters are looked up")
+	tagCmd.Flags().StringP("tag-name", "t", "ttl", "Set the tag name holding the time to leave")
+	tagCmd.Flags().String("cluster-tag-key", "ClusterName", "Set the tag name holding the EKS cluster name on its VPCs")
+	addClusterTagFlags(tagCmd)
+	addEndpointFlags(tagCmd)
+	_ = tagCmd.MarkFlagRequired("ttl")
+}
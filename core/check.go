@@ -0,0 +1,64 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+// Check simulates the IAM actions needed by the enabled AWS cleaners and prints the minimal policy allowing them. It
+// returns the process exit code: 0 if every action is allowed, 1 on error and 2 if permissions are missing.
+func Check(cmd *cobra.Command) int {
+	checkEnvVars(cmd)
+	config := getConfig(cmd, false)
+
+	var extraActions []string
+	states, _ := cmd.Flags().GetStringArray("terraform-state")
+	for _, state := range states {
+		if strings.HasPrefix(state, "s3://") {
+			extraActions = append(extraActions, "s3:GetObject", "s3:GetBucketLocation")
+		}
+	}
+
+	actions := aws.RequiredActions(*config.AWS, config.Policy, extraActions...)
+	if len(actions) == 0 {
+		log.Error("No AWS cleaner is enabled, enable at least one to check its permissions")
+		return 1
+	}
+	if len(config.AWS.Plugins) > 0 {
+		log.Warn("The permissions of the cleaner plugins are unknown, they aren't checked.")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	checks, err := aws.CheckPermissions(ctx, *config.AWS, actions)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	exitCode := 0
+	for _, check := range checks {
+		if len(check.Denied) == 0 {
+			log.Infof("%s is allowed every required action.", check.PrincipalArn)
+			continue
+		}
+
+		log.Warnf("%s is denied %d of %d required actions: %s", check.PrincipalArn, len(check.Denied), len(actions), strings.Join(check.Denied, ", "))
+		exitCode = 2
+	}
+
+	policy, err := aws.GetPolicyDocument(actions)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	fmt.Println(policy)
+
+	return exitCode
+}
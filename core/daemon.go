@@ -1,14 +1,25 @@
 package core
 
 import (
-	"github.com/Qovery/pleco/providers/aws"
-	"github.com/Qovery/pleco/providers/k8s"
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/api"
+	"github.com/Qovery/pleco/pleco"
+	"github.com/Qovery/pleco/state"
+	"github.com/Qovery/pleco/utils"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 )
 
-func StartDaemon(disableDryRun bool, interval int64, cmd *cobra.Command) {
+// StartDaemon runs the checks on their schedule until stopped, or a single check with the once flag. It returns the
+// process exit code, a single check prints the summary of its cleaners and returns 1 if a cleaner failed, 2 if a dry
+// run found pending deletions, 3 if deletions failed and 0 otherwise.
+func StartDaemon(disableDryRun bool, interval int64, cmd *cobra.Command) int {
 	var wg sync.WaitGroup
 	dryRun := true
 	if disableDryRun {
@@ -18,13 +29,247 @@ func StartDaemon(disableDryRun bool, interval int64, cmd *cobra.Command) {
 	}
 
 	checkEnvVars(cmd)
+	config := getConfig(cmd, dryRun)
 
-	// run Kubernetes check
-	k8s.RunPlecoKubernetes(cmd, interval, dryRun, &wg)
+	once, _ := cmd.Flags().GetBool("once")
+	schedule := getCheckSchedule(cmd, interval, once)
 
-	// run AWS checks
-	regions, _ := cmd.Flags().GetStringSlice("aws-regions")
-	aws.RunPlecoAWS(cmd, regions, interval, dryRun, &wg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
 
-	wg.Wait()
-}
\ No newline at end of file
+	// states are reloaded between checks of the daemon only
+	if once {
+		interval = 0
+	}
+	loadTerraformStates(ctx, cmd, interval)
+
+	checksCtx := setNotifications(ctx, cmd)
+	checksCtx, store := setStateStore(checksCtx, cmd)
+	checksCtx = setComplianceReport(checksCtx, cmd)
+	// a single check prints the summary of its cleaners once done, the daemon logs it after every check
+	var result *utils.RunResult
+	if once {
+		result = utils.NewRunResult()
+		checksCtx = utils.WithReportHandler(checksCtx, result.Report, 0)
+	} else {
+		checksCtx = utils.WithReportHandler(checksCtx, utils.LogSummary, 0)
+	}
+
+	apiAddress, _ := cmd.Flags().GetString("api-listen")
+	if apiAddress != "" {
+		// the protections of the API are lost on restart without state store
+		var protections api.ProtectionStore
+		if store != nil {
+			protections = store
+		}
+		server := api.NewServer(config, os.Getenv("PLECO_API_TOKEN"), protections)
+		checksCtx = utils.WithReportHandler(checksCtx, server.Report, api.ExpiringWithin)
+		go func() {
+			err := server.ListenAndServe(ctx, apiAddress)
+			if err != nil {
+				log.Fatalf("Can't serve the API: %s", err)
+			}
+		}()
+	}
+
+	err := pleco.Start(checksCtx, config, schedule, &wg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	shutdownTimeout, _ := cmd.Flags().GetInt64("shutdown-timeout")
+	waitForChecks(ctx, &wg, time.Duration(shutdownTimeout)*time.Second)
+
+	if !once {
+		return utils.ExitOK
+	}
+	if ctx.Err() != nil {
+		return utils.ExitError
+	}
+
+	return getExitCode(result)
+}
+
+// getExitCode prints the summary of the single check run and returns its exit code
+func getExitCode(result *utils.RunResult) int {
+	err := result.WriteSummary(os.Stderr)
+	if err != nil {
+		log.Errorf("Can't print the check summary: %s", err)
+	}
+
+	return result.ExitCode()
+}
+
+// setStateStore returns a context recording the state of the resources in the state store, if any, and the store. The
+// temporary protections saved in the store are restored.
+func setStateStore(ctx context.Context, cmd *cobra.Command) (context.Context, *state.Store) {
+	destination, _ := cmd.Flags().GetString("state-store")
+	diff, _ := cmd.Flags().GetBool("diff")
+	if destination == "" {
+		if diff {
+			log.Fatal("The diff flag needs a state store.")
+		}
+		return ctx, nil
+	}
+
+	store, err := state.NewStore(ctx, destination)
+	if err != nil {
+		log.Fatalf("Can't open state store %s: %s", destination, err)
+	}
+	for identifier, until := range store.Protections() {
+		utils.ProtectUntil(identifier, until)
+	}
+
+	ctx = utils.WithStateRecorder(ctx, store.Record)
+	stuckAfter, _ := cmd.Flags().GetInt("stuck-after")
+	ctx = utils.WithStuckDetection(ctx, store.SurvivedDeletions, stuckAfter)
+	if diff {
+		ctx = utils.WithReportHandler(ctx, store.LogDiff, 0)
+	}
+
+	return utils.WithReportHandler(ctx, store.Report, 0), store
+}
+
+// setComplianceReport returns a context logging the resources missing required tags after each check, and writing them
+// to the compliance report file if set
+func setComplianceReport(ctx context.Context, cmd *cobra.Command) context.Context {
+	requiredTags, _ := cmd.Flags().GetStringSlice("required-tags")
+	path, _ := cmd.Flags().GetString("compliance-report")
+	if len(requiredTags) == 0 {
+		if path != "" {
+			log.Fatal("The compliance-report flag needs required tags.")
+		}
+		return ctx
+	}
+
+	return utils.WithReportHandler(ctx, utils.NewComplianceReportHandler(path), 0)
+}
+
+// getCheckSchedule returns the schedule of the checks: the cron expression if set, else the check interval, and no
+// schedule for a single check
+func getCheckSchedule(cmd *cobra.Command, interval int64, once bool) utils.CheckSchedule {
+	expression, _ := cmd.Flags().GetString("schedule")
+	if expression != "" && once {
+		log.Fatal("The once and schedule flags can't be used together.")
+	}
+
+	if once {
+		return nil
+	}
+
+	if expression == "" {
+		return utils.IntervalSchedule(time.Duration(interval) * time.Second)
+	}
+
+	schedule, err := utils.ParseCronSchedule(expression)
+	if err != nil {
+		log.Fatalf("Can't parse the check schedule: %s", err)
+	}
+	log.Infof("Checks scheduled at %q, the first one at %s.", expression, schedule.FirstCheck(time.Now()).Format(time.RFC3339))
+
+	return schedule
+}
+
+// Plan runs a single dry run check and prints the resources it would delete. It returns the process exit code, like
+// terraform's detailed exit code: 0 if there is nothing to delete, 1 on error and 2 if deletions are pending.
+func Plan(cmd *cobra.Command) int {
+	checkEnvVars(cmd)
+	config := getConfig(cmd, true)
+
+	format, _ := cmd.Flags().GetString("output")
+	if format == "" {
+		format, _ = cmd.Flags().GetString("report-format")
+	}
+	if format != "table" && format != "json" && format != "csv" {
+		log.Errorf("Unknown report format %s, choose between table/json/csv", format)
+		return 1
+	}
+
+	expiringWithin, _ := cmd.Flags().GetInt64("expiring-within")
+	config.ExpiringWithin = time.Duration(expiringWithin) * time.Hour
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	loadTerraformStates(ctx, cmd, 0)
+
+	report, err := pleco.Run(ctx, config)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	err = writePlan(cmd, report, format)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	result := utils.NewRunResult()
+	result.Report(ctx, "plan", report)
+
+	return getExitCode(result)
+}
+
+// writePlan prints the report of the plan on the standard output, or writes it to the output file
+func writePlan(cmd *cobra.Command, report *utils.Report, format string) error {
+	path, _ := cmd.Flags().GetString("output-file")
+	withExpiring := report.ExpiringWithin > 0
+	if path == "" {
+		return report.Export(os.Stdout, format, withExpiring)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("can't write the plan to %s: %s", path, err)
+	}
+
+	err = report.Export(file, format, withExpiring)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("can't write the plan to %s: %s", path, err)
+	}
+
+	log.Infof("Wrote the plan of %d resources to delete to %s.", len(report.Entries()), path)
+
+	return nil
+}
+
+// cancelOnSignal cancels the checks context on SIGTERM (ex: pod eviction) or SIGINT
+func cancelOnSignal(cancel context.CancelFunc) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-signals
+	log.Infof("Received %s, stopping checks.", sig)
+	cancel()
+}
+
+// waitForChecks returns once every check is stopped, or when the shutdown timeout is reached after the context is done
+func waitForChecks(ctx context.Context, wg *sync.WaitGroup, shutdownTimeout time.Duration) {
+	checksDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(checksDone)
+	}()
+
+	select {
+	case <-checksDone:
+		return
+	case <-ctx.Done():
+	}
+
+	log.Infof("Waiting up to %s for running checks to stop.", shutdownTimeout)
+
+	select {
+	case <-checksDone:
+		log.Info("All checks stopped.")
+	case <-time.After(shutdownTimeout):
+		log.Warn("Shutdown timeout reached, exiting with checks still running.")
+	}
+}
@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"github.com/Qovery/pleco/pleco"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws/session"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+// DestroyCluster deletes the EKS cluster and every resource tagged with its name in the regions, without waiting for
+// their expiration. Checks targeting them run until one deletes nothing, each one deleting the resources whose
+// dependencies are gone. It returns the process exit code: 0 once destroyed, 1 on error, 2 if a dry run found
+// resources to destroy and 3 if resources are left after the attempts.
+func DestroyCluster(cmd *cobra.Command) int {
+	checkEnvVars(cmd)
+
+	disableDryRun, _ := cmd.Flags().GetBool("disable-dry-run")
+	dryRun := !disableDryRun
+	clusterName, _ := cmd.Flags().GetString("cluster-id")
+	attempts, _ := cmd.Flags().GetInt("attempts")
+	interval, _ := cmd.Flags().GetInt64("attempt-interval")
+
+	setAWSEndpoints(cmd)
+
+	tagName, _ := cmd.Flags().GetString("tag-name")
+	awsConfig := aws.Config{TagName: tagName, Services: make(map[string]bool)}
+	awsConfig.Regions, _ = cmd.Flags().GetStringSlice("aws-regions")
+	awsConfig.ClusterTagKey, _ = cmd.Flags().GetString("cluster-tag-key")
+	awsConfig.ClusterTags = getClusterTagPatterns(cmd)
+	awsConfig.Parallelism, _ = cmd.Flags().GetInt("parallelism")
+	deletionWaitTimeout, _ := cmd.Flags().GetInt64("deletion-wait-timeout")
+	awsConfig.DeletionWaitTimeout = time.Duration(deletionWaitTimeout) * time.Minute
+	awsConfig.OverrideDeletionProtection, _ = cmd.Flags().GetBool("override-deletion-protection")
+	awsConfig.FinalSnapshotTTL = getFinalSnapshotTTL(cmd)
+	for _, service := range aws.Services {
+		awsConfig.Services[service] = true
+	}
+
+	if len(awsConfig.Regions) == 0 {
+		log.Error("The cluster resources are looked up in the --aws-regions, set at least one region")
+		return utils.ExitError
+	}
+
+	// only exclusions apply, targets are deleted right away whatever their age
+	config := pleco.Config{
+		DryRun: dryRun,
+		Policy: utils.DeletionPolicy{Exclusions: getDeletionPolicy(cmd).Exclusions},
+		AWS:    &awsConfig,
+	}
+
+	sessions := make(map[string]*session.Session)
+	for _, region := range awsConfig.Regions {
+		sess, err := aws.CreateSession(region)
+		if err != nil {
+			return utils.ExitError
+		}
+		sessions[region] = sess
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	for attempt := 1; ; attempt++ {
+		targets := utils.NewTargets()
+		var left []string
+
+		for region, sess := range sessions {
+			arns, err := aws.ListClusterResources(ctx, sess, region, clusterName, config.AWS.ClusterTagKey, config.AWS.ClusterTags)
+			if err != nil {
+				log.Error(err)
+				return utils.ExitError
+			}
+			left = append(left, arns...)
+
+			if dryRun || len(arns) == 0 {
+				continue
+			}
+
+			err = aws.TargetClusterResources(ctx, sess, region, clusterName, arns, config.AWS.TagName, targets)
+			if err != nil {
+				log.Errorf("Can't target the resources of cluster %s in %s: %s", clusterName, region, err)
+				return utils.ExitError
+			}
+		}
+
+		if len(left) == 0 {
+			log.Infof("No resource of cluster %s is left.", clusterName)
+			return utils.ExitOK
+		}
+
+		if dryRun {
+			for _, resourceArn := range left {
+				log.Infof("Would destroy %s.", resourceArn)
+			}
+			log.Infof("Would destroy %d resources of cluster %s, add --disable-dry-run to destroy them.", len(left), clusterName)
+			return utils.ExitPendingDeletions
+		}
+
+		if attempt > attempts {
+			log.Errorf("%d resources of cluster %s are left after %d attempts.", len(left), clusterName, attempts)
+			return utils.ExitDeletionsFailed
+		}
+
+		log.Infof("Destroying cluster %s, attempt %d of %d.", clusterName, attempt, attempts)
+		report, err := pleco.Run(utils.WithTargets(ctx, targets), config)
+		if err != nil {
+			log.Error(err)
+			return utils.ExitError
+		}
+		utils.LogSummary(ctx, "destroy-cluster", report)
+
+		// the resources left have no cleaner (ex: EC2 instances) or are still listed by the tagging API once deleted
+		if len(report.Candidates()) == 0 {
+			for _, resourceArn := range left {
+				log.Warnf("%s of cluster %s is left, pleco doesn't delete it.", resourceArn, clusterName)
+			}
+			return utils.ExitOK
+		}
+
+		select {
+		case <-ctx.Done():
+			return utils.ExitError
+		case <-time.After(time.Duration(interval) * time.Second):
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// Extend pushes back the expiration of the AWS resources picked by ARN, and returns the process exit code: 0 on
+// success and 1 on error
+func Extend(cmd *cobra.Command) int {
+	byValue, _ := cmd.Flags().GetString("by")
+	extension, err := utils.ParseTTL(byValue)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+	if extension <= 0 {
+		log.Errorf("Invalid extension %s, it must be positive", byValue)
+		return 1
+	}
+
+	arns, _ := cmd.Flags().GetStringArray("arn")
+	defaultRegion, _ := cmd.Flags().GetString("aws-region")
+	tagName, _ := cmd.Flags().GetString("tag-name")
+
+	setAWSEndpoints(cmd)
+
+	if defaultRegion == "" {
+		defaultRegion = aws.DefaultRegion()
+	}
+
+	// ARNs are extended in their region, global resources (ex: S3 buckets) from the default one
+	arnsByRegion := make(map[string][]string)
+	for _, arn := range arns {
+		parsedArn, err := awsarn.Parse(arn)
+		if err != nil {
+			log.Errorf("Invalid ARN %s: %s", arn, err)
+			return 1
+		}
+
+		region := parsedArn.Region
+		if region == "" {
+			region = defaultRegion
+		}
+		arnsByRegion[region] = append(arnsByRegion[region], arn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	exitCode := 0
+	for region, regionArns := range arnsByRegion {
+		sess, err := aws.CreateSession(region)
+		if err != nil {
+			return 1
+		}
+
+		err = aws.ExtendResourcesTTL(ctx, sess, region, regionArns, extension, tagName)
+		if err != nil {
+			log.Errorf("Can't extend resources in %s: %s", region, err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
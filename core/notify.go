@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"github.com/Qovery/pleco/audit"
+	"github.com/Qovery/pleco/notification"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"time"
+)
+
+// setNotifications returns a context recording the deletions of every check in the audit log, posting their summary to
+// the notification channels, publishing the deletion events to the events targets and warning the owners of resources
+// scheduled for deletion, if any
+func setNotifications(ctx context.Context, cmd *cobra.Command) context.Context {
+	auditDestination, _ := cmd.Flags().GetString("audit-log")
+	if auditDestination != "" {
+		auditLog, err := audit.NewLog(ctx, auditDestination, getAuditActor())
+		if err != nil {
+			log.Fatalf("Can't open audit log %s: %s", auditDestination, err)
+		}
+		ctx = utils.WithReportHandler(ctx, auditLog.Report, 0)
+	}
+
+	targets, _ := cmd.Flags().GetStringArray("deletion-events")
+	for _, value := range targets {
+		target, err := notification.ParseEventsTarget(value)
+		if err != nil {
+			log.Fatalf("Invalid deletion events target: %s", err)
+		}
+
+		publishEvents, err := notification.PublishEvents(target)
+		if err != nil {
+			log.Fatalf("Can't publish deletion events to %s: %s", target.Arn, err)
+		}
+		ctx = utils.WithReportHandler(ctx, publishEvents, 0)
+	}
+
+	datadogEnabled, _ := cmd.Flags().GetBool("enable-datadog")
+	if datadogEnabled {
+		site := os.Getenv("DD_SITE")
+		if site == "" {
+			site = "datadoghq.com"
+		}
+		tags, _ := cmd.Flags().GetStringSlice("datadog-tags")
+		datadog := notification.NewDatadog(os.Getenv("DD_API_KEY"), site, tags)
+		ctx = utils.WithReportHandler(ctx, datadog.Report, 0)
+	}
+
+	otlpEndpoint, _ := cmd.Flags().GetString("otlp-endpoint")
+	if otlpEndpoint != "" {
+		headers, err := notification.ParseOTLPHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+		if err != nil {
+			log.Fatalf("Invalid OTEL_EXPORTER_OTLP_HEADERS: %s", err)
+		}
+		serviceName := os.Getenv("OTEL_SERVICE_NAME")
+		if serviceName == "" {
+			serviceName = "pleco"
+		}
+		otlp := notification.NewOTLP(notification.GetOTLPTracesUrl(otlpEndpoint), headers, serviceName)
+		ctx = utils.WithTracing(ctx, otlp.Export)
+	}
+
+	ownersWithin, _ := cmd.Flags().GetInt64("notify-owners-within")
+	if ownersWithin > 0 {
+		ctx = utils.WithOwnerNotifier(ctx, getOwnerNotifier(cmd).Notify, time.Duration(ownersWithin)*time.Hour)
+	}
+
+	values, _ := cmd.Flags().GetStringArray("notify")
+	if len(values) == 0 {
+		return ctx
+	}
+
+	var channels []notification.Channel
+	for _, value := range values {
+		channel, err := notification.ParseChannel(value)
+		if err != nil {
+			log.Fatalf("Invalid notification channel: %s", err)
+		}
+		channels = append(channels, channel)
+	}
+
+	expiringWithin, _ := cmd.Flags().GetInt64("notify-expiring-within")
+
+	return utils.WithReportHandler(ctx, notification.Notify(channels), time.Duration(expiringWithin)*time.Hour)
+}
+
+// getOwnerNotifier returns the notifier of owners, by email if a sender is set and by Slack if SLACK_BOT_TOKEN is set
+func getOwnerNotifier(cmd *cobra.Command) *notification.OwnerNotifier {
+	gracePeriod, _ := cmd.Flags().GetInt64("deletion-grace-period")
+	quarantinePeriod, _ := cmd.Flags().GetInt64("quarantine-period")
+	if gracePeriod == 0 && quarantinePeriod == 0 {
+		log.Fatalf("Owners are notified before scheduled deletions only, --notify-owners-within requires --deletion-grace-period or --quarantine-period")
+	}
+
+	emailFrom, _ := cmd.Flags().GetString("owner-email-from")
+	slackToken := os.Getenv("SLACK_BOT_TOKEN")
+	if emailFrom == "" && slackToken == "" {
+		log.Fatalf("Owners can't be notified, set --owner-email-from or the SLACK_BOT_TOKEN environment variable")
+	}
+
+	tagName, _ := cmd.Flags().GetString("tag-name")
+	if emailFrom == "" {
+		return notification.NewOwnerNotifier(nil, "", slackToken, tagName)
+	}
+
+	emailRegion, _ := cmd.Flags().GetString("owner-email-region")
+	sess, err := aws.CreateSession(emailRegion)
+	if err != nil {
+		log.Fatalf("Can't send emails to owners: %s", err)
+	}
+
+	return notification.NewOwnerNotifier(sess, emailFrom, slackToken, tagName)
+}
+
+// getAuditActor identifies the pleco instance in the audit records
+func getAuditActor() string {
+	actor := "pleco"
+
+	hostname, err := os.Hostname()
+	if err == nil {
+		actor += "@" + hostname
+	}
+
+	identifier := os.Getenv("PLECO_IDENTIFIER")
+	if identifier != "" {
+		actor += " (" + identifier + ")"
+	}
+
+	return actor
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"context"
+	"github.com/Qovery/pleco/operator"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"sync"
+	"time"
+)
+
+// StartOperator runs the checks declared by the CleanupPolicies of the cluster until stopped
+func StartOperator(cmd *cobra.Command) {
+	setAWSEndpoints(cmd)
+
+	kubeConn, _ := cmd.Flags().GetString("kube-conn")
+	plecoOperator, err := operator.NewOperator(kubeConn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go plecoOperator.Run(ctx, &wg)
+
+	shutdownTimeout, _ := cmd.Flags().GetInt64("shutdown-timeout")
+	waitForChecks(ctx, &wg, time.Duration(shutdownTimeout)*time.Second)
+}
@@ -0,0 +1,226 @@
+package core
+
+import (
+	"github.com/Qovery/pleco/pleco"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/providers/aws/leaks"
+	"github.com/Qovery/pleco/providers/httpapi"
+	"github.com/Qovery/pleco/providers/k8s"
+	"github.com/Qovery/pleco/utils"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func getDeletionPolicy(cmd *cobra.Command) utils.DeletionPolicy {
+	var policy utils.DeletionPolicy
+
+	exclusions, _ := cmd.Flags().GetStringArray("exclude")
+	for _, exclusion := range exclusions {
+		pattern, err := regexp.Compile(exclusion)
+		if err != nil {
+			log.Fatalf("Exclusion %s is not a valid regex: %s", exclusion, err)
+		}
+		policy.Exclusions = append(policy.Exclusions, pattern)
+	}
+
+	minAge, _ := cmd.Flags().GetInt64("min-age")
+	policy.MinAge = time.Duration(minAge) * time.Minute
+
+	policy.MaxDeletions, _ = cmd.Flags().GetInt("max-deletions")
+	policy.MaxDeletionsPerType, _ = cmd.Flags().GetInt("max-deletions-per-type")
+
+	gracePeriod, _ := cmd.Flags().GetInt64("deletion-grace-period")
+	policy.GracePeriod = time.Duration(gracePeriod) * time.Minute
+
+	quarantinePeriod, _ := cmd.Flags().GetInt64("quarantine-period")
+	policy.QuarantinePeriod = time.Duration(quarantinePeriod) * time.Minute
+
+	windows, _ := cmd.Flags().GetStringArray("deletion-window")
+	for _, value := range windows {
+		window, err := utils.ParseDeletionWindow(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policy.DeletionWindows = append(policy.DeletionWindows, window)
+	}
+
+	markers, _ := cmd.Flags().GetStringArray("production-marker")
+	for _, value := range markers {
+		marker, err := utils.ParseProductionMarker(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policy.ProductionMarkers = append(policy.ProductionMarkers, marker)
+	}
+
+	untaggedRules, _ := cmd.Flags().GetStringArray("untagged-max-age")
+	for _, value := range untaggedRules {
+		rule, err := utils.ParseUntaggedRule(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policy.UntaggedRules = append(policy.UntaggedRules, rule)
+	}
+
+	return policy
+}
+
+// setAWSEndpoints sets the endpoints, the partition and the credentials of the AWS sessions from the flags
+func setAWSEndpoints(cmd *cobra.Command) {
+	endpoints := aws.Endpoints{Services: make(map[string]string)}
+	endpoints.URL, _ = cmd.Flags().GetString("aws-endpoint")
+	endpoints.Partition, _ = cmd.Flags().GetString("aws-partition")
+
+	serviceEndpoints, _ := cmd.Flags().GetStringArray("aws-service-endpoint")
+	for _, serviceEndpoint := range serviceEndpoints {
+		parts := strings.SplitN(serviceEndpoint, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("AWS service endpoint %s is not valid, expected <endpoint prefix>=<url>", serviceEndpoint)
+		}
+		endpoints.Services[parts[0]] = parts[1]
+	}
+
+	err := aws.SetEndpoints(endpoints)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var credentials aws.Credentials
+	credentials.Profile, _ = cmd.Flags().GetString("aws-profile")
+	credentials.SessionName, _ = cmd.Flags().GetString("aws-session-name")
+	aws.SetDefaultCredentials(credentials)
+}
+
+// getAWSAccounts returns the credentials of the accounts checked on top of the roles to assume
+func getAWSAccounts(cmd *cobra.Command) []aws.Credentials {
+	var accounts []aws.Credentials
+
+	values, _ := cmd.Flags().GetStringArray("aws-account")
+	for _, value := range values {
+		credentials, err := aws.ParseCredentials(value)
+		if err != nil {
+			log.Fatal(err)
+		}
+		accounts = append(accounts, credentials)
+	}
+
+	return accounts
+}
+
+// getFinalSnapshotTTL returns the ttl of the final snapshots of the databases and volumes in seconds, 0 if they are skipped
+func getFinalSnapshotTTL(cmd *cobra.Command) int64 {
+	value, _ := cmd.Flags().GetString("final-snapshot-ttl")
+	if value == "" {
+		return 0
+	}
+
+	ttl, err := utils.ParseTTL(value)
+	if err != nil || ttl <= 0 {
+		log.Fatalf("Final snapshot ttl %s is not a valid ttl", value)
+	}
+
+	return ttl
+}
+
+// getLeaksConfig returns the configuration of the leak detection, nil if it's disabled
+func getLeaksConfig(cmd *cobra.Command) *leaks.Config {
+	detectLeaks, _ := cmd.Flags().GetBool("detect-leaks")
+	if !detectLeaks {
+		return nil
+	}
+
+	minAge, _ := cmd.Flags().GetInt64("leak-min-age")
+	config := &leaks.Config{MinAge: time.Duration(minAge) * 24 * time.Hour, Delete: make(map[string]bool)}
+
+	categories, _ := cmd.Flags().GetStringSlice("delete-leaks")
+	for _, category := range categories {
+		if !leaks.IsCategory(category) {
+			log.Fatalf("Unknown leak category %s, choose between : %s", category, strings.Join(leaks.Categories, "/"))
+		}
+		config.Delete[category] = true
+	}
+
+	return config
+}
+
+// getClusterTagPatterns returns the patterns matching the tags of the load balancers of the EKS clusters
+func getClusterTagPatterns(cmd *cobra.Command) utils.ClusterTagPatterns {
+	var patterns utils.ClusterTagPatterns
+	patterns.Key, _ = cmd.Flags().GetString("tag-key-pattern")
+	patterns.Value, _ = cmd.Flags().GetString("tag-value-pattern")
+
+	err := patterns.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return patterns
+}
+
+// getHTTPConfig returns the configuration of the HTTP endpoints checks, nil if no list endpoint is set
+func getHTTPConfig(cmd *cobra.Command, tagName string) *httpapi.Config {
+	listUrl, _ := cmd.Flags().GetString("http-list-url")
+	if listUrl == "" {
+		return nil
+	}
+
+	config := &httpapi.Config{ListUrl: listUrl, Token: os.Getenv("PLECO_HTTP_TOKEN"), TagName: tagName}
+	config.Name, _ = cmd.Flags().GetString("http-name")
+	config.DeleteUrl, _ = cmd.Flags().GetString("http-delete-url")
+	if config.DeleteUrl == "" {
+		log.Fatal("The http-list-url flag needs the http-delete-url one.")
+	}
+
+	return config
+}
+
+// getConfig returns the configuration of the checks set by the flags
+func getConfig(cmd *cobra.Command, dryRun bool) pleco.Config {
+	setAWSEndpoints(cmd)
+
+	tagName, _ := cmd.Flags().GetString("tag-name")
+	kubeConn, _ := cmd.Flags().GetString("kube-conn")
+	config := pleco.Config{
+		DryRun:     dryRun,
+		Policy:     getDeletionPolicy(cmd),
+		Kubernetes: &k8s.Config{Connection: kubeConn, TagName: tagName},
+	}
+	config.ReportFormat, _ = cmd.Flags().GetString("report-format")
+	config.HTTP = getHTTPConfig(cmd, tagName)
+
+	awsConfig := aws.Config{TagName: tagName, Services: make(map[string]bool)}
+	awsConfig.Regions, _ = cmd.Flags().GetStringSlice("aws-regions")
+	awsConfig.AllRegions, _ = cmd.Flags().GetBool("all-regions")
+	awsConfig.RoleArns, _ = cmd.Flags().GetStringSlice("aws-role-arns")
+	awsConfig.Accounts = getAWSAccounts(cmd)
+	awsConfig.ClusterTagKey, _ = cmd.Flags().GetString("cluster-tag-key")
+	awsConfig.ClusterTags = getClusterTagPatterns(cmd)
+	awsConfig.Parallelism, _ = cmd.Flags().GetInt("parallelism")
+	awsConfig.EstimateCosts, _ = cmd.Flags().GetBool("estimate-costs")
+	awsConfig.Plugins, _ = cmd.Flags().GetStringArray("cleaner-plugin")
+	awsConfig.Leaks = getLeaksConfig(cmd)
+	awsConfig.RequiredTags, _ = cmd.Flags().GetStringSlice("required-tags")
+	awsConfig.QuotaThreshold, _ = cmd.Flags().GetFloat64("quota-threshold")
+	awsConfig.OverrideDeletionProtection, _ = cmd.Flags().GetBool("override-deletion-protection")
+	awsConfig.FinalSnapshotTTL = getFinalSnapshotTTL(cmd)
+	cleanerTimeout, _ := cmd.Flags().GetInt64("cleaner-timeout")
+	awsConfig.CleanerTimeout = time.Duration(cleanerTimeout) * time.Minute
+	deletionWaitTimeout, _ := cmd.Flags().GetInt64("deletion-wait-timeout")
+	awsConfig.DeletionWaitTimeout = time.Duration(deletionWaitTimeout) * time.Minute
+	// disable flags take precedence so a service can be turned off without touching the rest of the configuration
+	for _, service := range aws.Services {
+		if enabled, _ := cmd.Flags().GetBool("enable-" + service); enabled {
+			awsConfig.Services[service] = true
+		}
+		if disabled, _ := cmd.Flags().GetBool("disable-" + service); disabled {
+			awsConfig.Services[service] = false
+		}
+	}
+	config.AWS = &awsConfig
+
+	return config
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	awsarn "github.com/aws/aws-sdk-go/aws/arn"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"regexp"
+)
+
+// Tag sets the ttl tag of the AWS resources picked by ARN, name pattern or EKS cluster, and returns the process exit
+// code: 0 on success and 1 on error
+func Tag(cmd *cobra.Command) int {
+	ttlValue, _ := cmd.Flags().GetString("ttl")
+	ttl, err := utils.ParseTTL(ttlValue)
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	arns, _ := cmd.Flags().GetStringArray("arn")
+	namePattern, _ := cmd.Flags().GetString("name-pattern")
+	clusterName, _ := cmd.Flags().GetString("cluster")
+	regions, _ := cmd.Flags().GetStringSlice("aws-regions")
+	tagName, _ := cmd.Flags().GetString("tag-name")
+	clusterTagKey, _ := cmd.Flags().GetString("cluster-tag-key")
+	clusterTags := utils.ClusterTagPatterns{}
+	clusterTags.Key, _ = cmd.Flags().GetString("tag-key-pattern")
+	clusterTags.Value, _ = cmd.Flags().GetString("tag-value-pattern")
+
+	if len(arns) == 0 && namePattern == "" && clusterName == "" {
+		log.Error("Nothing to tag, select resources with --arn, --name-pattern or --cluster")
+		return 1
+	}
+
+	var pattern *regexp.Regexp
+	if namePattern != "" {
+		pattern, err = regexp.Compile(namePattern)
+		if err != nil {
+			log.Errorf("Invalid name pattern %s: %s", namePattern, err)
+			return 1
+		}
+	}
+
+	err = clusterTags.Validate()
+	if err != nil {
+		log.Error(err)
+		return 1
+	}
+
+	if (pattern != nil || clusterName != "") && len(regions) == 0 {
+		log.Error("Name patterns and clusters are looked up in the --aws-regions, set at least one region")
+		return 1
+	}
+
+	setAWSEndpoints(cmd)
+
+	selectors := make(map[string]*aws.TagSelector)
+	getSelector := func(region string) *aws.TagSelector {
+		if _, ok := selectors[region]; !ok {
+			selectors[region] = &aws.TagSelector{NamePattern: pattern, ClusterName: clusterName}
+		}
+		return selectors[region]
+	}
+
+	for _, region := range regions {
+		getSelector(region)
+	}
+
+	// ARNs are tagged in their region, global resources (ex: S3 buckets) from the first region
+	for _, arn := range arns {
+		parsedArn, err := awsarn.Parse(arn)
+		if err != nil {
+			log.Errorf("Invalid ARN %s: %s", arn, err)
+			return 1
+		}
+
+		region := parsedArn.Region
+		if region == "" && len(regions) > 0 {
+			region = regions[0]
+		} else if region == "" {
+			region = aws.DefaultRegion()
+		}
+
+		selector := getSelector(region)
+		selector.Arns = append(selector.Arns, arn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go cancelOnSignal(cancel)
+
+	exitCode := 0
+	for region, selector := range selectors {
+		sess, err := aws.CreateSession(region)
+		if err != nil {
+			return 1
+		}
+
+		err = aws.TagResourcesTTL(ctx, sess, region, *selector, ttl, tagName, clusterTagKey, clusterTags)
+		if err != nil {
+			log.Errorf("Can't tag resources in %s: %s", region, err)
+			exitCode = 1
+		}
+	}
+
+	return exitCode
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/terraform"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+// loadTerraformStates loads the resources managed by Terraform before the checks start, and reloads them on every
+// interval if any. Pleco refuses to start if the states can't be read, a failed reload keeps the previous resources.
+func loadTerraformStates(ctx context.Context, cmd *cobra.Command, interval int64) {
+	sources, _ := cmd.Flags().GetStringArray("terraform-state")
+	if len(sources) == 0 {
+		return
+	}
+
+	managedIds, err := terraform.LoadManagedIds(ctx, sources)
+	if err != nil {
+		log.Fatalf("Can't load Terraform states: %s", err)
+	}
+	utils.SetTerraformManagedIds(managedIds)
+	log.Infof("Resources of %d Terraform states won't be deleted.", len(sources))
+
+	if interval == 0 {
+		return
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(interval) * time.Second):
+			}
+
+			managedIds, err := terraform.LoadManagedIds(ctx, sources)
+			if err != nil {
+				log.Errorf("Can't reload Terraform states, keeping the previous ones: %s", err)
+				continue
+			}
+			utils.SetTerraformManagedIds(managedIds)
+		}
+	}()
+}
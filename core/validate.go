@@ -9,17 +9,54 @@ import (
 func isAwsUsed(cmd *cobra.Command, serviceName string) bool {
 	service, err := cmd.Flags().GetBool("enable-" + serviceName)
 	if err == nil && service {
-		return true
+		disabled, _ := cmd.Flags().GetBool("disable-" + serviceName)
+		return !disabled
 	}
 	return false
 }
 
+func hasCleanerPlugins(cmd *cobra.Command) bool {
+	plugins, err := cmd.Flags().GetStringArray("cleaner-plugin")
+	return err == nil && len(plugins) > 0
+}
+
+func isLeakDetectionEnabled(cmd *cobra.Command) bool {
+	detectLeaks, err := cmd.Flags().GetBool("detect-leaks")
+	return err == nil && detectLeaks
+}
+
+func hasRequiredTags(cmd *cobra.Command) bool {
+	requiredTags, err := cmd.Flags().GetStringSlice("required-tags")
+	return err == nil && len(requiredTags) > 0
+}
+
+func hasQuotaThreshold(cmd *cobra.Command) bool {
+	threshold, err := cmd.Flags().GetFloat64("quota-threshold")
+	return err == nil && threshold > 0
+}
+
+// hasAWSCredentialSource returns true if the AWS credentials don't come from the access key environment variables: a
+// shared configuration profile, a web identity token (IAM Roles for Service Accounts) or the container credentials
+func hasAWSCredentialSource(cmd *cobra.Command) bool {
+	profile, _ := cmd.Flags().GetString("aws-profile")
+	accounts, _ := cmd.Flags().GetStringArray("aws-account")
+
+	return profile != "" || len(accounts) > 0 ||
+		os.Getenv("AWS_PROFILE") != "" ||
+		os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" ||
+		os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" ||
+		os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != ""
+}
+
 func checkEnvVars(cmd *cobra.Command) {
 	var requiredEnvVars []string
 	awsEnvVars := []string{
 		"AWS_ACCESS_KEY_ID",
 		"AWS_SECRET_ACCESS_KEY",
 	}
+	if hasAWSCredentialSource(cmd) {
+		awsEnvVars = nil
+	}
 
 	// if kubernetes is required
 	kubeConn, err := cmd.Flags().GetString("kube-conn")
@@ -40,10 +77,29 @@ func checkEnvVars(cmd *cobra.Command) {
 		isAwsUsed(cmd, "kms") ||
 		isAwsUsed(cmd, "iam") ||
 		isAwsUsed(cmd, "ssh-keys") ||
-		isAwsUsed(cmd, "ecr") {
+		isAwsUsed(cmd, "ecr") ||
+		isAwsUsed(cmd, "glue") ||
+		isAwsUsed(cmd, "elastic-beanstalk") ||
+		isAwsUsed(cmd, "eventbridge") ||
+		isAwsUsed(cmd, "cloudwatch-alarms") ||
+		isAwsUsed(cmd, "db-groups") ||
+		isAwsUsed(cmd, "ecs") ||
+		isAwsUsed(cmd, "apprunner") ||
+		isAwsUsed(cmd, "ec2-instances") ||
+		isAwsUsed(cmd, "autoscaling") ||
+		hasCleanerPlugins(cmd) ||
+		isLeakDetectionEnabled(cmd) ||
+		hasRequiredTags(cmd) ||
+		hasQuotaThreshold(cmd) {
 		requiredEnvVars = append(requiredEnvVars, awsEnvVars...)
 	}
 
+	// if Datadog is enabled
+	datadogEnabled, err := cmd.Flags().GetBool("enable-datadog")
+	if err == nil && datadogEnabled {
+		requiredEnvVars = append(requiredEnvVars, "DD_API_KEY")
+	}
+
 	for _, envVar := range requiredEnvVars {
 		if os.Getenv(envVar) == "" {
 			log.Fatalf("%s environment variable is required and not found", envVar)
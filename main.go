@@ -2,6 +2,8 @@ package main
 
 import (
 	"github.com/Qovery/pleco/cmd"
+	// time zones of the deletion windows, the image has no time zone database
+	_ "time/tzdata"
 )
 
 func main() {
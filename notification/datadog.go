@@ -0,0 +1,152 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Datadog sends deletion events and check metrics to the Datadog API
+type Datadog struct {
+	apiKey string
+	site   string
+	tags   []string
+	client *http.Client
+}
+
+type datadogSeries struct {
+	Metric string      `json:"metric"`
+	Type   string      `json:"type"`
+	Points [][]float64 `json:"points"`
+	Tags   []string    `json:"tags"`
+}
+
+type datadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	AlertType      string   `json:"alert_type"`
+	SourceTypeName string   `json:"source_type_name"`
+	AggregationKey string   `json:"aggregation_key"`
+	Tags           []string `json:"tags"`
+}
+
+// NewDatadog returns a Datadog reporter for the site (ex: datadoghq.eu), tags are added to every event and metric
+func NewDatadog(apiKey string, site string, tags []string) *Datadog {
+	return &Datadog{
+		apiKey: apiKey,
+		site:   site,
+		tags:   tags,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Report is a report handler sending a metric point per check and an event per deleted resource, dry runs only send
+// metrics
+func (d *Datadog) Report(ctx context.Context, source string, report *utils.Report) {
+	now := float64(time.Now().Unix())
+	tags := append(d.tags[:len(d.tags):len(d.tags)], "source:"+source, "dry_run:"+strconv.FormatBool(report.DryRun))
+	entries := report.Entries()
+
+	series := []datadogSeries{
+		{Metric: "pleco.check.duration", Type: "gauge", Points: [][]float64{{now, time.Since(report.StartedAt).Seconds()}}, Tags: tags},
+		{Metric: "pleco.check.errors", Type: "count", Points: [][]float64{{now, float64(len(report.Errors()))}}, Tags: tags},
+		{Metric: "pleco.check.expiring", Type: "gauge", Points: [][]float64{{now, float64(len(report.Expiring()))}}, Tags: tags},
+		{Metric: "pleco.check.deleted", Type: "count", Points: [][]float64{{now, float64(len(entries))}}, Tags: tags},
+	}
+	for _, row := range report.Summary() {
+		if row.Cleaner == "" {
+			continue
+		}
+
+		rowTags := append(tags[:len(tags):len(tags)], "resource_type:"+row.Cleaner, "region:"+row.Region)
+		// dry runs count the resources they would delete
+		deleted := row.Deleted
+		if report.DryRun {
+			deleted = row.Expired
+		}
+
+		counts := []struct {
+			metric string
+			count  int
+			tags   []string
+		}{
+			{"pleco.resources.scanned", row.Scanned, rowTags},
+			{"pleco.resources.skipped", row.NotExpired, append(rowTags[:len(rowTags):len(rowTags)], "reason:not_expired")},
+			{"pleco.resources.skipped", row.Kept, append(rowTags[:len(rowTags):len(rowTags)], "reason:kept")},
+			{"pleco.resources.skipped", row.Scheduled, append(rowTags[:len(rowTags):len(rowTags)], "reason:scheduled")},
+			{"pleco.resources.expired", row.Expired, rowTags},
+			{"pleco.resources.aborted", row.Aborted, rowTags},
+			{"pleco.resources.deleted", deleted, rowTags},
+			{"pleco.resources.failed", row.Failed, rowTags},
+			{"pleco.resources.stuck", row.Stuck, rowTags},
+			{"pleco.resources.errors", row.Errors, rowTags},
+		}
+		for _, count := range counts {
+			if count.count == 0 {
+				continue
+			}
+			series = append(series, datadogSeries{
+				Metric: count.metric,
+				Type:   "count",
+				Points: [][]float64{{now, float64(count.count)}},
+				Tags:   count.tags,
+			})
+		}
+	}
+
+	err := d.post(ctx, "/api/v1/series", map[string]interface{}{"series": series})
+	if err != nil {
+		log.Errorf("Can't send metrics to Datadog: %s", err)
+	}
+
+	if report.DryRun {
+		return
+	}
+
+	for _, entry := range entries {
+		err := d.post(ctx, "/api/v1/events", datadogEvent{
+			Title:          fmt.Sprintf("Pleco deleted %s %s", entry.Type, entry.Name),
+			Text:           fmt.Sprintf("%s %s in %s expired at %s (ttl %d). %s", entry.Type, entry.Name, entry.Region, entry.ExpirationTime.Format(time.RFC3339), entry.TTL, entry.Arn),
+			AlertType:      "info",
+			SourceTypeName: "pleco",
+			AggregationKey: "pleco-" + source,
+			Tags:           append(tags[:len(tags):len(tags)], "resource_type:"+entry.Type, "region:"+entry.Region),
+		})
+		if err != nil {
+			log.Errorf("Can't send deletion event to Datadog: %s", err)
+			return
+		}
+	}
+}
+
+func (d *Datadog) post(ctx context.Context, path string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api."+d.site+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("DD-API-KEY", d.apiKey)
+
+	response, err := d.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("Datadog answered %s", response.Status)
+	}
+
+	return nil
+}
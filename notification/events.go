@@ -0,0 +1,220 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	aws2 "github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/sns"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// eventBridgeBatchSize is the maximum number of entries of an EventBridge PutEvents call
+const eventBridgeBatchSize = 10
+
+// Detail types of the EventBridge events
+const (
+	deletionDetailType        = "Pleco Resource Deletion"
+	deletionFailureDetailType = "Pleco Resource Deletion Failed"
+)
+
+// DeletionEvent is published for every resource deleted, or that a dry run would delete, and for every failed
+// deletion. The deletions aborted by a circuit breaker or skipped are not published.
+type DeletionEvent struct {
+	Source         string            `json:"source"`
+	DryRun         bool              `json:"dry_run"`
+	Outcome        string            `json:"outcome,omitempty"`
+	Error          string            `json:"error,omitempty"`
+	ResourceType   string            `json:"resource_type"`
+	Name           string            `json:"name"`
+	Arn            string            `json:"arn,omitempty"`
+	Region         string            `json:"region,omitempty"`
+	CreationDate   time.Time         `json:"creation_date,omitempty"`
+	TTL            int64             `json:"ttl"`
+	ExpirationTime time.Time         `json:"expiration_time"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+// isFailure returns true if the event is a failed deletion
+func (e DeletionEvent) isFailure() bool {
+	return e.Outcome == utils.OutcomeFailed
+}
+
+// EventsTarget is a SNS topic or an EventBridge bus receiving the deletion events
+type EventsTarget struct {
+	Kind string
+	Arn  string
+}
+
+// ParseEventsTarget parses a <sns|eventbridge>=<topic or bus ARN> target
+func ParseEventsTarget(value string) (EventsTarget, error) {
+	var target EventsTarget
+	for _, kind := range []string{"sns", "eventbridge"} {
+		if strings.HasPrefix(value, kind+"=") {
+			target = EventsTarget{Kind: kind, Arn: strings.TrimPrefix(value, kind+"=")}
+		}
+	}
+
+	if target.Kind == "" {
+		return EventsTarget{}, fmt.Errorf("expected <sns|eventbridge>=<arn>, got %s", value)
+	}
+
+	if !arn.IsARN(target.Arn) {
+		return EventsTarget{}, fmt.Errorf("%s is not a valid ARN", target.Arn)
+	}
+
+	return target, nil
+}
+
+// PublishEvents returns a report handler publishing an event for every deleted resource and failed deletion of a check
+// to the target
+func PublishEvents(target EventsTarget) (utils.ReportHandler, error) {
+	parsedArn, _ := arn.Parse(target.Arn)
+
+	sess, err := aws2.CreateSession(parsedArn.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	var publish func(ctx context.Context, events []DeletionEvent) error
+	switch target.Kind {
+	case "sns":
+		svc := sns.New(sess)
+		publish = func(ctx context.Context, events []DeletionEvent) error {
+			return publishToSns(ctx, svc, target.Arn, events)
+		}
+	case "eventbridge":
+		svc := eventbridge.New(sess)
+		publish = func(ctx context.Context, events []DeletionEvent) error {
+			return publishToEventBridge(ctx, svc, target.Arn, events)
+		}
+	}
+
+	return func(ctx context.Context, source string, report *utils.Report) {
+		var events []DeletionEvent
+		for _, entry := range report.Candidates() {
+			if !report.DryRun && entry.Outcome != utils.OutcomeDeleted && entry.Outcome != utils.OutcomeFailed {
+				continue
+			}
+
+			events = append(events, DeletionEvent{
+				Source:         source,
+				DryRun:         report.DryRun,
+				Outcome:        entry.Outcome,
+				Error:          entry.Error,
+				ResourceType:   entry.Type,
+				Name:           entry.Name,
+				Arn:            entry.Arn,
+				Region:         entry.Region,
+				CreationDate:   entry.CreationDate,
+				TTL:            entry.TTL,
+				ExpirationTime: entry.ExpirationTime,
+				Tags:           entry.Tags,
+			})
+		}
+
+		if len(events) == 0 {
+			return
+		}
+
+		err := publish(ctx, events)
+		if err != nil {
+			log.Errorf("Can't publish deletion events to %s: %s", target.Arn, err)
+		}
+	}, nil
+}
+
+func publishToSns(ctx context.Context, svc *sns.SNS, topicArn string, events []DeletionEvent) error {
+	for _, event := range events {
+		message, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		subject := "Pleco deletion"
+		if event.isFailure() {
+			subject = "Pleco deletion failed"
+		}
+
+		attributes := map[string]*sns.MessageAttributeValue{
+			"resource_type": {
+				DataType:    aws.String("String"),
+				StringValue: aws.String(event.ResourceType),
+			},
+		}
+		if event.Outcome != "" {
+			attributes["outcome"] = &sns.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(event.Outcome),
+			}
+		}
+
+		_, err = svc.PublishWithContext(ctx,
+			&sns.PublishInput{
+				TopicArn:          aws.String(topicArn),
+				Subject:           aws.String(subject),
+				Message:           aws.String(string(message)),
+				MessageAttributes: attributes,
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func publishToEventBridge(ctx context.Context, svc *eventbridge.EventBridge, busArn string, events []DeletionEvent) error {
+	var entries []*eventbridge.PutEventsRequestEntry
+	for _, event := range events {
+		detail, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		var resources []*string
+		if event.Arn != "" {
+			resources = aws.StringSlice([]string{event.Arn})
+		}
+
+		detailType := deletionDetailType
+		if event.isFailure() {
+			detailType = deletionFailureDetailType
+		}
+
+		entries = append(entries, &eventbridge.PutEventsRequestEntry{
+			EventBusName: aws.String(busArn),
+			Source:       aws.String("pleco"),
+			DetailType:   aws.String(detailType),
+			Detail:       aws.String(string(detail)),
+			Resources:    resources,
+		})
+	}
+
+	for start := 0; start < len(entries); start += eventBridgeBatchSize {
+		end := start + eventBridgeBatchSize
+		if end > len(entries) {
+			end = len(entries)
+		}
+
+		result, err := svc.PutEventsWithContext(ctx,
+			&eventbridge.PutEventsInput{
+				Entries: entries[start:end],
+			})
+		if err != nil {
+			return err
+		}
+
+		if aws.Int64Value(result.FailedEntryCount) > 0 {
+			return fmt.Errorf("%d events were rejected", aws.Int64Value(result.FailedEntryCount))
+		}
+	}
+
+	return nil
+}
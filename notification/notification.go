@@ -0,0 +1,214 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Severity of a check summary, channels only receive the summaries at or above their severity
+type Severity int
+
+const (
+	// Info summaries only list resources expiring soon
+	Info Severity = iota
+	// Warning summaries list deleted resources, or resources a dry run would delete, and quotas nearly reached
+	Warning
+	// Error summaries list failures and stuck resources
+	Error
+)
+
+var severities = map[string]Severity{
+	"info":    Info,
+	"warning": Warning,
+	"error":   Error,
+}
+
+func (s Severity) String() string {
+	for name, severity := range severities {
+		if severity == s {
+			return name
+		}
+	}
+
+	return "unknown"
+}
+
+// Channel is a Slack incoming webhook or a generic webhook receiving check summaries as JSON
+type Channel struct {
+	Kind     string
+	URL      string
+	Severity Severity
+}
+
+// Summary is the content posted to generic webhooks after a check
+type Summary struct {
+	Source   string                `json:"source"`
+	DryRun   bool                  `json:"dry_run"`
+	Severity string                `json:"severity"`
+	Deleted  []utils.ReportEntry   `json:"deleted"`
+	Expiring []utils.ReportEntry   `json:"expiring"`
+	Stuck    []utils.StuckResource `json:"stuck"`
+	Quotas   []utils.QuotaUsage    `json:"quotas"`
+	Errors   []string              `json:"errors"`
+}
+
+// ParseChannel parses a <slack|webhook>[:<info|warning|error>]=<url> channel, its severity defaults to info
+func ParseChannel(value string) (Channel, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Channel{}, fmt.Errorf("expected <slack|webhook>[:<severity>]=<url>, got %s", value)
+	}
+
+	channel := Channel{URL: parts[1], Severity: Info}
+
+	kind := strings.SplitN(parts[0], ":", 2)
+	channel.Kind = kind[0]
+	if channel.Kind != "slack" && channel.Kind != "webhook" {
+		return Channel{}, fmt.Errorf("unknown notification channel %s, choose between slack/webhook", channel.Kind)
+	}
+
+	if len(kind) == 2 {
+		severity, ok := severities[kind[1]]
+		if !ok {
+			return Channel{}, fmt.Errorf("unknown notification severity %s, choose between info/warning/error", kind[1])
+		}
+		channel.Severity = severity
+	}
+
+	return channel, nil
+}
+
+func newSummary(source string, report *utils.Report) (Summary, Severity) {
+	summary := Summary{
+		Source:   source,
+		DryRun:   report.DryRun,
+		Deleted:  report.Entries(),
+		Expiring: report.Expiring(),
+		Stuck:    report.Stuck(),
+		Quotas:   report.QuotaUsages(),
+		Errors:   report.Errors(),
+	}
+
+	severity := Info
+	if len(summary.Deleted) > 0 || len(summary.Quotas) > 0 {
+		severity = Warning
+	}
+	if len(summary.Errors) > 0 || len(summary.Stuck) > 0 {
+		severity = Error
+	}
+	summary.Severity = severity.String()
+
+	return summary, severity
+}
+
+// Notify returns a report handler posting the summary of every check to the channels
+func Notify(channels []Channel) utils.ReportHandler {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	return func(ctx context.Context, source string, report *utils.Report) {
+		summary, severity := newSummary(source, report)
+		if len(summary.Deleted) == 0 && len(summary.Expiring) == 0 && len(summary.Stuck) == 0 && len(summary.Quotas) == 0 && len(summary.Errors) == 0 {
+			return
+		}
+
+		for _, channel := range channels {
+			if severity < channel.Severity {
+				continue
+			}
+
+			err := channel.send(ctx, client, summary)
+			if err != nil {
+				log.Errorf("Can't send %s notification: %s", channel.Kind, err)
+			}
+		}
+	}
+}
+
+func (c Channel) send(ctx context.Context, client *http.Client, summary Summary) error {
+	var payload interface{} = summary
+	if c.Kind == "slack" {
+		payload = map[string]string{"text": formatSlackMessage(summary)}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("%s answered %s", c.Kind, response.Status)
+	}
+
+	return nil
+}
+
+func formatSlackMessage(summary Summary) string {
+	var message strings.Builder
+
+	action := "Deleted"
+	if summary.DryRun {
+		action = "Would delete (dry run)"
+	}
+
+	message.WriteString(fmt.Sprintf("*Pleco %s check*\n", summary.Source))
+
+	if len(summary.Deleted) > 0 {
+		message.WriteString(fmt.Sprintf("\n*%s %d resources:*\n", action, len(summary.Deleted)))
+		for _, entry := range summary.Deleted {
+			message.WriteString(fmt.Sprintf("• %s `%s` %s\n", entry.Type, entry.Name, entry.Region))
+		}
+	}
+
+	if len(summary.Stuck) > 0 {
+		message.WriteString(fmt.Sprintf("\n*%d resources stuck:*\n", len(summary.Stuck)))
+		for _, stuck := range summary.Stuck {
+			message.WriteString(fmt.Sprintf("• %s `%s` %s still there after %d deletions\n", stuck.Type, stuck.Name, stuck.Region, stuck.Deletions))
+		}
+	}
+
+	if len(summary.Quotas) > 0 {
+		message.WriteString(fmt.Sprintf("\n*%d quotas nearly reached:*\n", len(summary.Quotas)))
+		for _, quota := range summary.Quotas {
+			resource := ""
+			if quota.Resource != "" {
+				resource = fmt.Sprintf(" `%s`", quota.Resource)
+			}
+			message.WriteString(fmt.Sprintf("• %s%s %s: %d of %.0f (%.0f%%)\n", quota.Quota, resource, quota.Region, quota.Usage, quota.Limit, quota.Percent()))
+		}
+	}
+
+	if len(summary.Errors) > 0 {
+		message.WriteString(fmt.Sprintf("\n*%d failures:*\n", len(summary.Errors)))
+		for _, err := range summary.Errors {
+			message.WriteString(fmt.Sprintf("• %s\n", err))
+		}
+	}
+
+	if len(summary.Expiring) > 0 {
+		message.WriteString(fmt.Sprintf("\n*%d resources expiring soon:*\n", len(summary.Expiring)))
+		for _, entry := range summary.Expiring {
+			message.WriteString(fmt.Sprintf("• %s `%s` %s expires at %s\n", entry.Type, entry.Name, entry.Region, entry.ExpirationTime.Format(time.RFC3339)))
+		}
+	}
+
+	return message.String()
+}
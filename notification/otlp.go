@@ -0,0 +1,222 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otlpBatchSize is the maximum number of spans sent by request
+const otlpBatchSize = 512
+
+// OTLP exports the spans of the checks to an OpenTelemetry collector with the OTLP/HTTP json encoding
+type OTLP struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	client      *http.Client
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string          `json:"timeUnixNano"`
+	Name         string          `json:"name"`
+	Attributes   []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+// OTLP status codes and internal span kind
+const (
+	otlpStatusOk         = 1
+	otlpStatusError      = 2
+	otlpSpanKindInternal = 1
+)
+
+// NewOTLP returns an exporter posting the spans to the traces url of the collector (ex:
+// http://otel-collector:4318/v1/traces) with the headers, under the service name
+func NewOTLP(url string, headers map[string]string, serviceName string) *OTLP {
+	return &OTLP{
+		url:         url,
+		headers:     headers,
+		serviceName: serviceName,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// GetOTLPTracesUrl returns the traces url of a collector endpoint: endpoints without path get the /v1/traces one
+func GetOTLPTracesUrl(endpoint string) string {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if strings.HasSuffix(endpoint, "/v1/traces") {
+		return endpoint
+	}
+
+	return endpoint + "/v1/traces"
+}
+
+// ParseOTLPHeaders parses comma separated key=value headers, the OTEL_EXPORTER_OTLP_HEADERS format
+func ParseOTLPHeaders(value string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, header := range strings.Split(value, ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(header, "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid header %s, expected key=value", header)
+		}
+		headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	return headers, nil
+}
+
+// Export is a span exporter sending the spans of a check to the collector
+func (o *OTLP) Export(spans []utils.Span) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for start := 0; start < len(spans); start += otlpBatchSize {
+		end := start + otlpBatchSize
+		if end > len(spans) {
+			end = len(spans)
+		}
+
+		err := o.post(ctx, spans[start:end])
+		if err != nil {
+			log.Errorf("Can't export %d spans to %s: %s", len(spans), o.url, err)
+			return
+		}
+	}
+
+	log.Debugf("Exported %d spans to %s.", len(spans), o.url)
+}
+
+func (o *OTLP) post(ctx context.Context, spans []utils.Span) error {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, newOTLPSpan(span))
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: o.serviceName}}},
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]string{"name": "pleco"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for key, value := range o.headers {
+		request.Header.Set(key, value)
+	}
+
+	response, err := o.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("collector answered %s", response.Status)
+	}
+
+	return nil
+}
+
+func newOTLPSpan(span utils.Span) otlpSpan {
+	exported := otlpSpan{
+		TraceId:           span.TraceId,
+		SpanId:            span.SpanId,
+		ParentSpanId:      span.ParentId,
+		Name:              span.Name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNano(span.Start),
+		EndTimeUnixNano:   unixNano(span.End),
+		Attributes:        newOTLPAttributes(span.Attributes),
+		Status:            otlpStatus{Code: otlpStatusOk},
+	}
+	if span.Error != "" {
+		exported.Status = otlpStatus{Code: otlpStatusError, Message: span.Error}
+	}
+
+	for _, event := range span.Events {
+		exported.Events = append(exported.Events, otlpEvent{
+			TimeUnixNano: unixNano(event.Time),
+			Name:         event.Name,
+			Attributes:   newOTLPAttributes(event.Attributes),
+		})
+	}
+
+	return exported
+}
+
+// newOTLPAttributes returns the non empty attributes sorted by key
+func newOTLPAttributes(attributes map[string]string) []otlpAttribute {
+	var otlpAttributes []otlpAttribute
+	for key, value := range attributes {
+		if value == "" {
+			continue
+		}
+		otlpAttributes = append(otlpAttributes, otlpAttribute{Key: key, Value: otlpValue{StringValue: value}})
+	}
+
+	sort.Slice(otlpAttributes, func(i, j int) bool {
+		return otlpAttributes[i].Key < otlpAttributes[j].Key
+	})
+
+	return otlpAttributes
+}
+
+// unixNano formats the time as the OTLP json encoding does for 64 bits integers
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
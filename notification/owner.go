@@ -0,0 +1,166 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const slackApiUrl = "https://slack.com/api/"
+
+// OwnerNotifier warns the owners of resources scheduled for deletion, by email through SES or by Slack direct message
+type OwnerNotifier struct {
+	ses        sesiface.SESAPI
+	emailFrom  string
+	slackToken string
+	tagName    string
+	client     *http.Client
+}
+
+type slackResponse struct {
+	Ok    bool   `json:"ok"`
+	Error string `json:"error"`
+	User  struct {
+		Id string `json:"id"`
+	} `json:"user"`
+}
+
+// NewOwnerNotifier returns a notifier sending emails from emailFrom if set, and Slack direct messages with the bot
+// token if set. Owners tagged with an email address get a Slack message when emails aren't enabled.
+func NewOwnerNotifier(sess *session.Session, emailFrom string, slackToken string, tagName string) *OwnerNotifier {
+	notifier := &OwnerNotifier{
+		emailFrom:  emailFrom,
+		slackToken: slackToken,
+		tagName:    tagName,
+		client:     &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if emailFrom != "" {
+		notifier.ses = ses.New(sess)
+	}
+
+	return notifier
+}
+
+func isEmailAddress(owner string) bool {
+	return strings.Contains(owner, "@") && !strings.HasPrefix(owner, "@")
+}
+
+func (n *OwnerNotifier) Notify(ctx context.Context, deletion utils.ScheduledDeletion) error {
+	if isEmailAddress(deletion.Owner) && n.ses != nil {
+		return n.sendEmail(ctx, deletion)
+	}
+
+	if n.slackToken != "" {
+		return n.sendSlackMessage(ctx, deletion)
+	}
+
+	return fmt.Errorf("no notification channel for owner %s", deletion.Owner)
+}
+
+func (n *OwnerNotifier) formatMessage(deletion utils.ScheduledDeletion) (string, string) {
+	resource := fmt.Sprintf("%s %s", deletion.Type, deletion.Name)
+	if deletion.Region != "" {
+		resource += " in " + deletion.Region
+	}
+
+	subject := fmt.Sprintf("Your %s will be deleted at %s", resource, deletion.DeletionDate.Format(time.RFC3339))
+	body := fmt.Sprintf("Your %s has expired and pleco will delete it at %s.\n\n"+
+		"To keep it, extend its ttl before then: increase the %s tag (seconds since its creation, or a duration like 7d) "+
+		"or set the %s tag to a later date (yyyy-mm-dd or RFC3339), add a %s tag with a duration (ex: 48h), or run "+
+		"pleco extend --arn <arn> --by 48h.\n",
+		resource, deletion.DeletionDate.Format(time.RFC3339), n.tagName, utils.ExpirationDateTagName, utils.ExtendTTLTagName)
+
+	return subject, body
+}
+
+func (n *OwnerNotifier) sendEmail(ctx context.Context, deletion utils.ScheduledDeletion) error {
+	subject, body := n.formatMessage(deletion)
+
+	_, err := n.ses.SendEmailWithContext(ctx,
+		&ses.SendEmailInput{
+			Source: aws.String(n.emailFrom),
+			Destination: &ses.Destination{
+				ToAddresses: aws.StringSlice([]string{deletion.Owner}),
+			},
+			Message: &ses.Message{
+				Subject: &ses.Content{Data: aws.String(subject)},
+				Body: &ses.Body{
+					Text: &ses.Content{Data: aws.String(body)},
+				},
+			},
+		})
+
+	return err
+}
+
+// sendSlackMessage sends a direct message to the owner, a Slack user id or the email address of a Slack user
+func (n *OwnerNotifier) sendSlackMessage(ctx context.Context, deletion utils.ScheduledDeletion) error {
+	userId := deletion.Owner
+	if isEmailAddress(deletion.Owner) {
+		response, err := n.callSlack(ctx, http.MethodGet, "users.lookupByEmail?email="+url.QueryEscape(deletion.Owner), nil)
+		if err != nil {
+			return err
+		}
+		userId = response.User.Id
+	}
+
+	subject, body := n.formatMessage(deletion)
+
+	_, err := n.callSlack(ctx, http.MethodPost, "chat.postMessage", map[string]string{
+		"channel": userId,
+		"text":    fmt.Sprintf("*%s*\n%s", subject, body),
+	})
+
+	return err
+}
+
+func (n *OwnerNotifier) callSlack(ctx context.Context, method string, apiMethod string, payload interface{}) (slackResponse, error) {
+	var response slackResponse
+	name := strings.SplitN(apiMethod, "?", 2)[0]
+
+	var body []byte
+	if payload != nil {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			return response, err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, slackApiUrl+apiMethod, bytes.NewReader(body))
+	if err != nil {
+		return response, err
+	}
+	request.Header.Set("Authorization", "Bearer "+n.slackToken)
+	if payload != nil {
+		request.Header.Set("Content-Type", "application/json; charset=utf-8")
+	}
+
+	result, err := n.client.Do(request)
+	if err != nil {
+		return response, err
+	}
+	defer result.Body.Close()
+
+	err = json.NewDecoder(result.Body).Decode(&response)
+	if err != nil {
+		return response, fmt.Errorf("can't read Slack %s response: %s", name, err)
+	}
+
+	if !response.Ok {
+		return response, fmt.Errorf("slack %s failed: %s", name, response.Error)
+	}
+
+	return response, nil
+}
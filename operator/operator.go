@@ -0,0 +1,252 @@
+// Package operator runs the checks declared by CleanupPolicy custom resources and reports their results in the status
+// of the policies.
+package operator
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/pleco"
+	"github.com/Qovery/pleco/providers/k8s"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/dynamic"
+	"strings"
+	"sync"
+	"time"
+)
+
+// expiringWithin is how soon resources expire to be counted in the status
+const expiringWithin = 24 * time.Hour
+
+// resyncInterval is the time between two reconciliations of the policies
+const resyncInterval = 30 * time.Second
+
+// Operator reconciles the CleanupPolicies: it starts the checks of new or updated policies and stops the ones of
+// deleted policies
+type Operator struct {
+	client   dynamic.Interface
+	kubeConn string
+	// checks of different policies don't run at the same time, the deletion policy is shared by the process
+	checkLock sync.Mutex
+	policies  map[string]*runningPolicy
+}
+
+type runningPolicy struct {
+	generation int64
+	cancel     context.CancelFunc
+}
+
+// NewOperator connects to the cluster with the connection method (in or out), the Kubernetes checks of the policies
+// use the same connection
+func NewOperator(kubeConn string) (*Operator, error) {
+	config, err := k8s.GetClientConfig(kubeConn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubernetes client config: %v", err)
+	}
+
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate kubernetes client: %v", err)
+	}
+
+	return &Operator{client: client, kubeConn: kubeConn, policies: make(map[string]*runningPolicy)}, nil
+}
+
+// Run reconciles the policies until the context is done, the wait group is done once their checks are stopped
+func (o *Operator) Run(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		err := o.reconcile(ctx, wg)
+		if err != nil {
+			log.Errorf("Can't reconcile cleanup policies: %s", err)
+		}
+
+		if !utils.WaitUntil(ctx, time.Now().Add(resyncInterval)) {
+			log.Info("Stopping the operator.")
+			return
+		}
+	}
+}
+
+// reconcile starts the checks of the policies created or whose spec changed since the last reconciliation, and stops
+// the ones of deleted policies
+func (o *Operator) reconcile(ctx context.Context, wg *sync.WaitGroup) error {
+	list, err := o.client.Resource(cleanupPolicyResource).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+
+	found := make(map[string]bool)
+	for _, item := range list.Items {
+		name := item.GetName()
+		found[name] = true
+
+		running, isRunning := o.policies[name]
+		if isRunning && running.generation == item.GetGeneration() {
+			continue
+		}
+		if isRunning {
+			log.Infof("Cleanup policy %s changed, restarting its checks.", name)
+			running.cancel()
+		}
+
+		policyCtx, cancel := context.WithCancel(ctx)
+		o.policies[name] = &runningPolicy{generation: item.GetGeneration(), cancel: cancel}
+
+		err := o.startPolicy(policyCtx, item, wg)
+		if err != nil {
+			log.Errorf("Cleanup policy %s is invalid: %s", name, err)
+			o.setStatus(ctx, name, item.GetGeneration(), func(status *CleanupPolicyStatus) {
+				meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionFalse, Reason: "InvalidSpec", Message: err.Error()})
+				status.NextRun = nil
+			})
+		}
+	}
+
+	for name, running := range o.policies {
+		if !found[name] {
+			log.Infof("Cleanup policy %s deleted, stopping its checks.", name)
+			running.cancel()
+			delete(o.policies, name)
+		}
+	}
+
+	return nil
+}
+
+// startPolicy starts the checks of a policy, it returns an error if its spec is invalid
+func (o *Operator) startPolicy(ctx context.Context, item unstructured.Unstructured, wg *sync.WaitGroup) error {
+	var spec CleanupPolicySpec
+	specFields, _, err := unstructured.NestedMap(item.Object, "spec")
+	if err != nil {
+		return err
+	}
+	err = runtime.DefaultUnstructuredConverter.FromUnstructured(specFields, &spec)
+	if err != nil {
+		return err
+	}
+
+	config, err := spec.getConfig(o.kubeConn)
+	if err != nil {
+		return err
+	}
+	schedule, err := spec.getSchedule()
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Starting the checks of cleanup policy %s.", item.GetName())
+	o.setStatus(ctx, item.GetName(), item.GetGeneration(), func(status *CleanupPolicyStatus) {
+		meta.SetStatusCondition(&status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Scheduled", Message: "checks are scheduled"})
+	})
+
+	wg.Add(1)
+	go o.runPolicy(ctx, item.GetName(), item.GetGeneration(), config, schedule, wg)
+
+	return nil
+}
+
+// runPolicy runs the checks of a policy on its schedule until the context is done
+func (o *Operator) runPolicy(ctx context.Context, name string, generation int64, config pleco.Config, schedule utils.CheckSchedule, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	nextCheck := schedule.FirstCheck(time.Now())
+	for {
+		o.setStatus(ctx, name, generation, func(status *CleanupPolicyStatus) {
+			next := metav1.NewTime(nextCheck)
+			status.NextRun = &next
+		})
+
+		if !utils.WaitUntil(ctx, nextCheck) {
+			log.Infof("Stopping the checks of cleanup policy %s.", name)
+			return
+		}
+
+		o.checkLock.Lock()
+		if ctx.Err() != nil {
+			o.checkLock.Unlock()
+			return
+		}
+		log.Infof("Checking cleanup policy %s.", name)
+		startedAt := time.Now()
+		report, err := pleco.Run(ctx, config)
+		o.checkLock.Unlock()
+
+		if ctx.Err() != nil {
+			return
+		}
+
+		o.setStatus(ctx, name, generation, func(status *CleanupPolicyStatus) {
+			status.LastRun = newRunStatus(startedAt, config.DryRun, report, err)
+			condition := metav1.Condition{Type: "LastRunSucceeded", Status: metav1.ConditionTrue, Reason: "Succeeded", Message: "check done without errors"}
+			if len(status.LastRun.Errors) > 0 {
+				condition.Status = metav1.ConditionFalse
+				condition.Reason = "Failed"
+				condition.Message = strings.Join(status.LastRun.Errors, "; ")
+			}
+			meta.SetStatusCondition(&status.Conditions, condition)
+		})
+
+		nextCheck = schedule.NextCheck(time.Now())
+	}
+}
+
+func newRunStatus(startedAt time.Time, dryRun bool, report *utils.Report, err error) *RunStatus {
+	status := &RunStatus{StartedAt: metav1.NewTime(startedAt), FinishedAt: metav1.Now(), DryRun: dryRun}
+	if err != nil {
+		status.Errors = []string{err.Error()}
+		return status
+	}
+
+	status.Deletable = len(report.Entries())
+	status.Expiring = len(report.Expiring())
+	status.Failures = len(report.Failures())
+	status.Errors = report.Errors()
+	if len(status.Errors) > maxStatusErrors {
+		status.Errors = status.Errors[:maxStatusErrors]
+	}
+
+	return status
+}
+
+// setStatus updates the status of a policy, unless its spec changed since the generation
+func (o *Operator) setStatus(ctx context.Context, name string, generation int64, update func(status *CleanupPolicyStatus)) {
+	client := o.client.Resource(cleanupPolicyResource)
+
+	item, err := client.Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Errorf("Can't get cleanup policy %s: %s", name, err)
+		}
+		return
+	}
+	if item.GetGeneration() != generation {
+		return
+	}
+
+	var status CleanupPolicyStatus
+	statusFields, _, _ := unstructured.NestedMap(item.Object, "status")
+	if statusFields != nil {
+		_ = runtime.DefaultUnstructuredConverter.FromUnstructured(statusFields, &status)
+	}
+
+	update(&status)
+	status.ObservedGeneration = generation
+
+	statusFields, err = runtime.DefaultUnstructuredConverter.ToUnstructured(&status)
+	if err != nil {
+		log.Errorf("Can't convert the status of cleanup policy %s: %s", name, err)
+		return
+	}
+	item.Object["status"] = statusFields
+
+	_, err = client.UpdateStatus(ctx, item, metav1.UpdateOptions{})
+	if err != nil && ctx.Err() == nil {
+		log.Errorf("Can't update the status of cleanup policy %s: %s", name, err)
+	}
+}
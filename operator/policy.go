@@ -0,0 +1,196 @@
+package operator
+
+import (
+	"fmt"
+	"github.com/Qovery/pleco/pleco"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/providers/k8s"
+	"github.com/Qovery/pleco/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"regexp"
+	"time"
+)
+
+// cleanupPolicyResource is the CleanupPolicy custom resource, defined by the CRD of the chart
+var cleanupPolicyResource = schema.GroupVersionResource{Group: "pleco.qovery.com", Version: "v1alpha1", Resource: "cleanuppolicies"}
+
+// CleanupPolicySpec declares the checks of a CleanupPolicy, like the flags of the start command
+type CleanupPolicySpec struct {
+	// DryRun only reports the expired resources, true if not set
+	DryRun *bool `json:"dryRun,omitempty"`
+	// Schedule is a cron expression, the checks run every CheckInterval seconds without it
+	Schedule        string   `json:"schedule,omitempty"`
+	CheckInterval   int64    `json:"checkInterval,omitempty"`
+	TagName         string   `json:"tagName,omitempty"`
+	Exclusions      []string `json:"exclusions,omitempty"`
+	DeletionWindows []string `json:"deletionWindows,omitempty"`
+	// MinAge is in minutes
+	MinAge       int64 `json:"minAge,omitempty"`
+	MaxDeletions int   `json:"maxDeletions,omitempty"`
+	// Kubernetes checks the namespaces of the cluster running the operator
+	Kubernetes bool           `json:"kubernetes,omitempty"`
+	AWS        *AWSPolicySpec `json:"aws,omitempty"`
+}
+
+// AWSPolicySpec declares the AWS checks of a CleanupPolicy
+type AWSPolicySpec struct {
+	Regions    []string `json:"regions,omitempty"`
+	AllRegions bool     `json:"allRegions,omitempty"`
+	RoleArns   []string `json:"roleArns,omitempty"`
+	// Accounts are the credentials of other checked accounts, see --aws-account
+	Accounts []string `json:"accounts,omitempty"`
+	// Services are the checked services (ex: eks, rds)
+	Services      []string `json:"services,omitempty"`
+	ClusterTagKey string   `json:"clusterTagKey,omitempty"`
+	// TagKeyPattern and TagValuePattern match the tags of the load balancers of the EKS clusters, see --tag-key-pattern
+	TagKeyPattern   string `json:"tagKeyPattern,omitempty"`
+	TagValuePattern string `json:"tagValuePattern,omitempty"`
+	Parallelism     int    `json:"parallelism,omitempty"`
+	// OverrideDeletionProtection disables the deletion protection of expired load balancers before deleting them
+	OverrideDeletionProtection bool `json:"overrideDeletionProtection,omitempty"`
+	// FinalSnapshotTTL is the ttl of the final snapshots of the deleted databases and volumes (ex: 7d), none are taken if not set
+	FinalSnapshotTTL string `json:"finalSnapshotTTL,omitempty"`
+}
+
+// CleanupPolicyStatus reports the last run of a CleanupPolicy
+type CleanupPolicyStatus struct {
+	ObservedGeneration int64              `json:"observedGeneration,omitempty"`
+	Conditions         []metav1.Condition `json:"conditions,omitempty"`
+	LastRun            *RunStatus         `json:"lastRun,omitempty"`
+	NextRun            *metav1.Time       `json:"nextRun,omitempty"`
+}
+
+// RunStatus is the result of a check of a CleanupPolicy
+type RunStatus struct {
+	StartedAt  metav1.Time `json:"startedAt"`
+	FinishedAt metav1.Time `json:"finishedAt"`
+	DryRun     bool        `json:"dryRun"`
+	// Deletable is the number of resources deleted, or that a dry run would delete
+	Deletable int `json:"deletable"`
+	Expiring  int `json:"expiring"`
+	Failures  int `json:"failures"`
+	// Errors are the first errors of the check
+	Errors []string `json:"errors,omitempty"`
+}
+
+// maxStatusErrors is the number of errors kept in the status, the logs have all of them
+const maxStatusErrors = 10
+
+// getConfig returns the configuration of the checks declared by the spec
+func (spec CleanupPolicySpec) getConfig(kubeConn string) (pleco.Config, error) {
+	config := pleco.Config{DryRun: spec.DryRun == nil || *spec.DryRun, ExpiringWithin: expiringWithin}
+
+	tagName := spec.TagName
+	if tagName == "" {
+		tagName = "ttl"
+	}
+
+	for _, exclusion := range spec.Exclusions {
+		pattern, err := regexp.Compile(exclusion)
+		if err != nil {
+			return config, fmt.Errorf("exclusion %s is not a valid regex: %s", exclusion, err)
+		}
+		config.Policy.Exclusions = append(config.Policy.Exclusions, pattern)
+	}
+
+	for _, value := range spec.DeletionWindows {
+		window, err := utils.ParseDeletionWindow(value)
+		if err != nil {
+			return config, err
+		}
+		config.Policy.DeletionWindows = append(config.Policy.DeletionWindows, window)
+	}
+
+	config.Policy.MinAge = time.Duration(spec.MinAge) * time.Minute
+	config.Policy.MaxDeletions = spec.MaxDeletions
+
+	if spec.Kubernetes {
+		config.Kubernetes = &k8s.Config{Connection: kubeConn, TagName: tagName}
+	}
+
+	if spec.AWS != nil {
+		awsConfig := aws.Config{
+			Regions:                    spec.AWS.Regions,
+			AllRegions:                 spec.AWS.AllRegions,
+			RoleArns:                   spec.AWS.RoleArns,
+			Services:                   make(map[string]bool),
+			TagName:                    tagName,
+			ClusterTagKey:              spec.AWS.ClusterTagKey,
+			ClusterTags:                utils.ClusterTagPatterns{Key: spec.AWS.TagKeyPattern, Value: spec.AWS.TagValuePattern},
+			Parallelism:                spec.AWS.Parallelism,
+			OverrideDeletionProtection: spec.AWS.OverrideDeletionProtection,
+		}
+		if awsConfig.ClusterTagKey == "" {
+			awsConfig.ClusterTagKey = "ClusterName"
+		}
+		if awsConfig.Parallelism == 0 {
+			awsConfig.Parallelism = 4
+		}
+		err := awsConfig.ClusterTags.Validate()
+		if err != nil {
+			return config, err
+		}
+
+		if spec.AWS.FinalSnapshotTTL != "" {
+			ttl, err := utils.ParseTTL(spec.AWS.FinalSnapshotTTL)
+			if err != nil || ttl <= 0 {
+				return config, fmt.Errorf("final snapshot ttl %s is not a valid ttl", spec.AWS.FinalSnapshotTTL)
+			}
+			awsConfig.FinalSnapshotTTL = ttl
+		}
+
+		for _, value := range spec.AWS.Accounts {
+			credentials, err := aws.ParseCredentials(value)
+			if err != nil {
+				return config, err
+			}
+			awsConfig.Accounts = append(awsConfig.Accounts, credentials)
+		}
+
+		for _, service := range spec.AWS.Services {
+			if !isAWSService(service) {
+				return config, fmt.Errorf("unknown AWS service %s", service)
+			}
+			awsConfig.Services[service] = true
+		}
+		config.AWS = &awsConfig
+	}
+
+	if config.Kubernetes == nil && config.AWS == nil {
+		return config, fmt.Errorf("no provider to check, set kubernetes or aws")
+	}
+
+	return config, nil
+}
+
+// getSchedule returns the schedule of the checks declared by the spec
+func (spec CleanupPolicySpec) getSchedule() (utils.CheckSchedule, error) {
+	if spec.Schedule != "" {
+		schedule, err := utils.ParseCronSchedule(spec.Schedule)
+		if err != nil {
+			return nil, err
+		}
+		return schedule, nil
+	}
+
+	interval := spec.CheckInterval
+	if interval == 0 {
+		interval = 120
+	}
+	if interval < 0 {
+		return nil, fmt.Errorf("invalid check interval %d", interval)
+	}
+
+	return utils.IntervalSchedule(time.Duration(interval) * time.Second), nil
+}
+
+func isAWSService(name string) bool {
+	for _, service := range aws.Services {
+		if service == name {
+			return true
+		}
+	}
+
+	return false
+}
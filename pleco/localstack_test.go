@@ -0,0 +1,299 @@
+//go:build localstack
+// +build localstack
+
+package pleco
+
+import (
+	"context"
+	"fmt"
+	aws2 "github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"os"
+	"testing"
+	"time"
+)
+
+// The tests of this file run the cleaners against LocalStack, started with:
+//   docker run -d -p 4566:4566 -e SERVICES=ec2,s3,logs,kms,elbv2,iam,sts,resourcegroupstaggingapi localstack/localstack:0.12.5
+//   go test -tags localstack ./pleco/
+// AWS_ENDPOINT_URL overrides the LocalStack endpoint.
+
+const (
+	localstackEndpoint = "http://localhost:4566"
+	localstackRegion   = "eu-west-3"
+	// expiredTTL is elapsed once the clock is advanced by checkAdvance, keptTTL is not
+	expiredTTL   = "3600"
+	keptTTL      = "86400"
+	checkAdvance = 2 * time.Hour
+)
+
+// localstackResource creates a resource with a ttl tag and returns a function telling if it still exists
+type localstackResource func(t *testing.T, sess *session.Session, name string, ttl string) func() bool
+
+func TestMain(m *testing.M) {
+	endpoint := os.Getenv(aws2.EndpointEnvName)
+	if endpoint == "" {
+		endpoint = localstackEndpoint
+	}
+	err := aws2.SetEndpoints(aws2.Endpoints{URL: endpoint})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	// LocalStack accepts any credentials
+	for name, value := range map[string]string{"AWS_ACCESS_KEY_ID": "test", "AWS_SECRET_ACCESS_KEY": "test"} {
+		if os.Getenv(name) == "" {
+			os.Setenv(name, value)
+		}
+	}
+
+	os.Exit(m.Run())
+}
+
+func TestLocalStackCleaners(t *testing.T) {
+	sess, err := aws2.CreateSession(localstackRegion)
+	if err != nil {
+		t.Fatalf("Can't connect to LocalStack: %s", err)
+	}
+
+	tests := []struct {
+		service string
+		create  localstackResource
+	}{
+		{service: "ebs", create: createVolume},
+		{service: "ec2-instances", create: createInstance},
+		{service: "s3", create: createBucket},
+		{service: "cloudwatch-logs", create: createLogGroup},
+		{service: "kms", create: createKey},
+		{service: "ssh-keys", create: createKeyPair},
+		{service: "elb", create: createLoadBalancer},
+		{service: "iam", create: createUser},
+	}
+
+	// names are unique to run the tests again against the same LocalStack
+	suffix := time.Now().Format("20060102150405")
+
+	for _, test := range tests {
+		t.Run(test.service, func(t *testing.T) {
+			expiredExists := test.create(t, sess, fmt.Sprintf("pleco-expired-%s", suffix), expiredTTL)
+			keptExists := test.create(t, sess, fmt.Sprintf("pleco-kept-%s", suffix), keptTTL)
+
+			config := Config{
+				AWS: &aws2.Config{
+					Regions:  []string{localstackRegion},
+					Services: map[string]bool{test.service: true},
+					TagName:  "ttl",
+				},
+			}
+
+			// nothing is expired yet
+			runCheck(t, config, 0)
+			if !expiredExists() || !keptExists() {
+				t.Fatalf("%s resources were deleted before their ttl", test.service)
+			}
+
+			runCheck(t, config, checkAdvance)
+			if expiredExists() {
+				t.Errorf("expired %s resource wasn't deleted", test.service)
+			}
+			if !keptExists() {
+				t.Errorf("%s resource whose ttl isn't elapsed was deleted", test.service)
+			}
+		})
+	}
+}
+
+// runCheck runs a check without dry run with the clock advanced
+func runCheck(t *testing.T, config Config, advance time.Duration) {
+	ctx := utils.WithClock(context.Background(), func() time.Time { return time.Now().Add(advance) })
+
+	report, err := Run(ctx, config)
+	if err != nil {
+		t.Fatalf("Check failed: %s", err)
+	}
+	for _, checkErr := range report.Errors() {
+		t.Errorf("Check error: %s", checkErr)
+	}
+}
+
+func createVolume(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := ec2.New(sess)
+	volume, err := svc.CreateVolume(&ec2.CreateVolumeInput{
+		AvailabilityZone:  aws.String(localstackRegion + "a"),
+		Size:              aws.Int64(1),
+		TagSpecifications: []*ec2.TagSpecification{ec2TagSpecification(ec2.ResourceTypeVolume, name, ttl)},
+	})
+	if err != nil {
+		t.Fatalf("Can't create volume %s: %s", name, err)
+	}
+
+	return func() bool {
+		result, err := svc.DescribeVolumes(&ec2.DescribeVolumesInput{
+			Filters: []*ec2.Filter{{Name: aws.String("volume-id"), Values: []*string{volume.VolumeId}}},
+		})
+		return err == nil && len(result.Volumes) > 0
+	}
+}
+
+func createInstance(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := ec2.New(sess)
+	images, err := svc.DescribeImages(&ec2.DescribeImagesInput{})
+	if err != nil || len(images.Images) == 0 {
+		t.Fatalf("Can't find an image to run instance %s: %v", name, err)
+	}
+
+	reservation, err := svc.RunInstances(&ec2.RunInstancesInput{
+		ImageId:           images.Images[0].ImageId,
+		InstanceType:      aws.String(ec2.InstanceTypeT3Micro),
+		MinCount:          aws.Int64(1),
+		MaxCount:          aws.Int64(1),
+		TagSpecifications: []*ec2.TagSpecification{ec2TagSpecification(ec2.ResourceTypeInstance, name, ttl)},
+	})
+	if err != nil {
+		t.Fatalf("Can't run instance %s: %s", name, err)
+	}
+	instanceId := reservation.Instances[0].InstanceId
+
+	return func() bool {
+		result, err := svc.DescribeInstances(&ec2.DescribeInstancesInput{InstanceIds: []*string{instanceId}})
+		if err != nil || len(result.Reservations) == 0 {
+			return false
+		}
+		state := aws.StringValue(result.Reservations[0].Instances[0].State.Name)
+		return state != ec2.InstanceStateNameShuttingDown && state != ec2.InstanceStateNameTerminated
+	}
+}
+
+func createBucket(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := s3.New(sess)
+	_, err := svc.CreateBucket(&s3.CreateBucketInput{
+		Bucket:                    aws.String(name),
+		CreateBucketConfiguration: &s3.CreateBucketConfiguration{LocationConstraint: aws.String(localstackRegion)},
+	})
+	if err != nil {
+		t.Fatalf("Can't create bucket %s: %s", name, err)
+	}
+	_, err = svc.PutBucketTagging(&s3.PutBucketTaggingInput{
+		Bucket:  aws.String(name),
+		Tagging: &s3.Tagging{TagSet: []*s3.Tag{{Key: aws.String("ttl"), Value: aws.String(ttl)}}},
+	})
+	if err != nil {
+		t.Fatalf("Can't tag bucket %s: %s", name, err)
+	}
+
+	return func() bool {
+		_, err := svc.HeadBucket(&s3.HeadBucketInput{Bucket: aws.String(name)})
+		return err == nil
+	}
+}
+
+func createLogGroup(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := cloudwatchlogs.New(sess)
+	_, err := svc.CreateLogGroup(&cloudwatchlogs.CreateLogGroupInput{
+		LogGroupName: aws.String(name),
+		Tags:         aws.StringMap(map[string]string{"ttl": ttl}),
+	})
+	if err != nil {
+		t.Fatalf("Can't create log group %s: %s", name, err)
+	}
+
+	return func() bool {
+		result, err := svc.DescribeLogGroups(&cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String(name)})
+		return err == nil && len(result.LogGroups) > 0
+	}
+}
+
+func createKey(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := kms.New(sess)
+	key, err := svc.CreateKey(&kms.CreateKeyInput{
+		Description: aws.String(name),
+		Tags:        []*kms.Tag{{TagKey: aws.String("ttl"), TagValue: aws.String(ttl)}},
+	})
+	if err != nil {
+		t.Fatalf("Can't create key %s: %s", name, err)
+	}
+
+	// keys are scheduled for deletion
+	return func() bool {
+		result, err := svc.DescribeKey(&kms.DescribeKeyInput{KeyId: key.KeyMetadata.KeyId})
+		return err == nil && aws.StringValue(result.KeyMetadata.KeyState) != kms.KeyStatePendingDeletion
+	}
+}
+
+func createKeyPair(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := ec2.New(sess)
+	_, err := svc.CreateKeyPair(&ec2.CreateKeyPairInput{
+		KeyName:           aws.String(name),
+		TagSpecifications: []*ec2.TagSpecification{ec2TagSpecification(ec2.ResourceTypeKeyPair, name, ttl)},
+	})
+	if err != nil {
+		t.Fatalf("Can't create key pair %s: %s", name, err)
+	}
+
+	return func() bool {
+		result, err := svc.DescribeKeyPairs(&ec2.DescribeKeyPairsInput{
+			Filters: []*ec2.Filter{{Name: aws.String("key-name"), Values: aws.StringSlice([]string{name})}},
+		})
+		return err == nil && len(result.KeyPairs) > 0
+	}
+}
+
+func createLoadBalancer(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	subnets, err := ec2.New(sess).DescribeSubnets(&ec2.DescribeSubnetsInput{
+		Filters: []*ec2.Filter{{Name: aws.String("default-for-az"), Values: aws.StringSlice([]string{"true"})}},
+	})
+	if err != nil || len(subnets.Subnets) == 0 {
+		t.Fatalf("Can't find a subnet for load balancer %s: %v", name, err)
+	}
+
+	svc := elbv2.New(sess)
+	_, err = svc.CreateLoadBalancer(&elbv2.CreateLoadBalancerInput{
+		Name:    aws.String(name),
+		Type:    aws.String(elbv2.LoadBalancerTypeEnumNetwork),
+		Subnets: []*string{subnets.Subnets[0].SubnetId},
+		Tags:    []*elbv2.Tag{{Key: aws.String("ttl"), Value: aws.String(ttl)}},
+	})
+	if err != nil {
+		t.Fatalf("Can't create load balancer %s: %s", name, err)
+	}
+
+	return func() bool {
+		result, err := svc.DescribeLoadBalancers(&elbv2.DescribeLoadBalancersInput{Names: aws.StringSlice([]string{name})})
+		return err == nil && len(result.LoadBalancers) > 0
+	}
+}
+
+func createUser(t *testing.T, sess *session.Session, name string, ttl string) func() bool {
+	svc := iam.New(sess)
+	_, err := svc.CreateUser(&iam.CreateUserInput{
+		UserName: aws.String(name),
+		Tags:     []*iam.Tag{{Key: aws.String("ttl"), Value: aws.String(ttl)}},
+	})
+	if err != nil {
+		t.Fatalf("Can't create user %s: %s", name, err)
+	}
+
+	return func() bool {
+		_, err := svc.GetUser(&iam.GetUserInput{UserName: aws.String(name)})
+		return err == nil
+	}
+}
+
+func ec2TagSpecification(resourceType string, name string, ttl string) *ec2.TagSpecification {
+	return &ec2.TagSpecification{
+		ResourceType: aws.String(resourceType),
+		Tags: []*ec2.Tag{
+			{Key: aws.String("Name"), Value: aws.String(name)},
+			{Key: aws.String("ttl"), Value: aws.String(ttl)},
+		},
+	}
+}
@@ -0,0 +1,108 @@
+// Package pleco runs the pleco checks from Go code: platform tools can embed the cleanup of expired resources and read
+// its results from the returned report instead of the logs.
+package pleco
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws"
+	"github.com/Qovery/pleco/providers/httpapi"
+	"github.com/Qovery/pleco/providers/k8s"
+	"github.com/Qovery/pleco/utils"
+	"sync"
+	"time"
+)
+
+// Config configures the checks, providers left nil aren't checked
+type Config struct {
+	// DryRun only reports the expired resources, without scheduling nor deleting them
+	DryRun bool
+	// Policy holds the rules of the cleaners, each run applies its own
+	Policy     utils.DeletionPolicy
+	AWS        *aws.Config
+	Kubernetes *k8s.Config
+	// HTTP checks the resources of an internal platform through its HTTP endpoints
+	HTTP *httpapi.Config
+	// ExpiringWithin adds to the reports of Run the resources expiring within this duration
+	ExpiringWithin time.Duration
+	// ReportFormat prints the reports of dry runs started by Start on the standard output (table or json), empty to
+	// not print them
+	ReportFormat string
+}
+
+// NewCleaners returns the cleaners of the configured providers, connected to their APIs. Their checks apply the deletion
+// policy of the context they are given (see utils.WithDeletionPolicy).
+func NewCleaners(ctx context.Context, config Config) ([]utils.Cleaner, error) {
+	ctx = utils.WithDeletionPolicy(ctx, config.Policy)
+
+	var cleaners []utils.Cleaner
+
+	if config.Kubernetes != nil {
+		cleaner, err := k8s.NewCleaner(*config.Kubernetes)
+		if err != nil {
+			return nil, err
+		}
+		if cleaner != nil {
+			cleaners = append(cleaners, cleaner)
+		}
+	}
+
+	if config.HTTP != nil {
+		cleaner, err := httpapi.NewCleaner(*config.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		if cleaner != nil {
+			cleaners = append(cleaners, cleaner)
+		}
+	}
+
+	if config.AWS != nil {
+		cleaner, err := aws.NewCleaner(ctx, *config.AWS, config.DryRun)
+		if err != nil {
+			return nil, err
+		}
+		if cleaner != nil {
+			cleaners = append(cleaners, cleaner)
+		}
+	}
+
+	return cleaners, nil
+}
+
+// Run runs a single check of every configured provider and returns its report: the resources deleted, or that would
+// be deleted by a dry run, and the failures of the check
+func Run(ctx context.Context, config Config) (*utils.Report, error) {
+	ctx = utils.WithDeletionPolicy(ctx, config.Policy)
+	cleaners, err := NewCleaners(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+
+	report := utils.NewReport()
+	report.DryRun = config.DryRun
+	report.StartedAt = time.Now()
+	report.ExpiringWithin = config.ExpiringWithin
+
+	// every cleaner records in the same report
+	var wg sync.WaitGroup
+	wg.Add(1)
+	utils.RunCleaners(utils.WithReport(ctx, report), cleaners, nil, config.DryRun, "", &wg)
+
+	return report, ctx.Err()
+}
+
+// Start runs the checks of every configured provider on the schedule until the context is done, or a single check
+// without schedule. The wait group is done once the checks are stopped. Reports of the checks are handed to the report
+// handlers of the context (see utils.WithReportHandler).
+func Start(ctx context.Context, config Config, schedule utils.CheckSchedule, wg *sync.WaitGroup) error {
+	ctx = utils.WithDeletionPolicy(ctx, config.Policy)
+	cleaners, err := NewCleaners(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	wg.Add(1)
+	go utils.RunCleaners(ctx, cleaners, schedule, config.DryRun, config.ReportFormat, wg)
+
+	return nil
+}
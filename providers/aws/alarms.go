@@ -0,0 +1,99 @@
+package aws
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// cloudwatchAlarmCleaner deletes the expired CloudWatch metric and composite alarms of a region
+type cloudwatchAlarmCleaner struct {
+	svc cloudwatchiface.CloudWatchAPI
+}
+
+func init() {
+	cleaner.Register("cloudwatch-alarms", func(sess *session.Session, region string) cleaner.Cleaner {
+		return cloudwatchAlarmCleaner{svc: cloudwatch.New(sess)}
+	})
+}
+
+func (c cloudwatchAlarmCleaner) Name() string {
+	return resources.CloudWatchAlarm
+}
+
+// List returns the metric and composite alarms, composite ones first as the alarms they watch can't be deleted before
+// them. Alarms don't have a creation date, their creationDate tag is used.
+func (c cloudwatchAlarmCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	var compositeAlarmArns, metricAlarmArns []*string
+	alarmNames := make(map[string]string)
+	err := c.svc.DescribeAlarmsPagesWithContext(ctx,
+		&cloudwatch.DescribeAlarmsInput{
+			AlarmTypes: aws.StringSlice([]string{cloudwatch.AlarmTypeCompositeAlarm, cloudwatch.AlarmTypeMetricAlarm}),
+			MaxRecords: aws.Int64(100),
+		},
+		func(page *cloudwatch.DescribeAlarmsOutput, lastPage bool) bool {
+			for _, alarm := range page.CompositeAlarms {
+				compositeAlarmArns = append(compositeAlarmArns, alarm.AlarmArn)
+				alarmNames[aws.StringValue(alarm.AlarmArn)] = aws.StringValue(alarm.AlarmName)
+			}
+			for _, alarm := range page.MetricAlarms {
+				metricAlarmArns = append(metricAlarmArns, alarm.AlarmArn)
+				alarmNames[aws.StringValue(alarm.AlarmArn)] = aws.StringValue(alarm.AlarmName)
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []cleaner.Resource
+	for _, alarmArn := range append(compositeAlarmArns, metricAlarmArns...) {
+		result, err := c.svc.ListTagsForResourceWithContext(ctx, &cloudwatch.ListTagsForResourceInput{ResourceARN: alarmArn})
+		if err != nil {
+			log.Errorf("Can't get the tags of CloudWatch alarm %s: %s", aws.StringValue(alarmArn), err)
+			continue
+		}
+
+		tags := make(map[string]string)
+		for _, tag := range result.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+
+		resources = append(resources, cleaner.Resource{
+			Id:   alarmNames[aws.StringValue(alarmArn)],
+			Arn:  aws.StringValue(alarmArn),
+			Tags: tags,
+		})
+	}
+
+	return resources, nil
+}
+
+func (c cloudwatchAlarmCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.DeleteAlarmsWithContext(ctx,
+		&cloudwatch.DeleteAlarmsInput{
+			AlarmNames: aws.StringSlice([]string{resource.Id}),
+		})
+
+	return err
+}
+
+func (c cloudwatchAlarmCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var cloudwatchTags []*cloudwatch.Tag
+	for key, value := range tags {
+		cloudwatchTags = append(cloudwatchTags, &cloudwatch.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	_, err := c.svc.TagResourceWithContext(ctx,
+		&cloudwatch.TagResourceInput{
+			ResourceARN: aws.String(resource.Arn),
+			Tags:        cloudwatchTags,
+		})
+
+	return err
+}
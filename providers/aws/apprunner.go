@@ -0,0 +1,89 @@
+package aws
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/apprunner"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	log "github.com/sirupsen/logrus"
+)
+
+// appRunnerServiceCleaner deletes the expired App Runner services of a region
+type appRunnerServiceCleaner struct {
+	svc *apprunner.AppRunner
+}
+
+func init() {
+	cleaner.Register("apprunner", func(sess *session.Session, region string) cleaner.Cleaner {
+		return appRunnerServiceCleaner{svc: apprunner.New(sess)}
+	})
+}
+
+func (c appRunnerServiceCleaner) Name() string {
+	return resources.AppRunnerService
+}
+
+// List returns the services, except the ones being created, updated or deleted which can't be deleted yet
+func (c appRunnerServiceCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	var services []*apprunner.ServiceSummary
+	err := c.svc.ListServicesPagesWithContext(ctx,
+		&apprunner.ListServicesInput{MaxResults: aws.Int64(20)},
+		func(page *apprunner.ListServicesOutput, lastPage bool) bool {
+			services = append(services, page.ServiceSummaryList...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []cleaner.Resource
+	for _, service := range services {
+		if aws.StringValue(service.Status) == apprunner.ServiceStatusOperationInProgress {
+			continue
+		}
+
+		result, err := c.svc.ListTagsForResourceWithContext(ctx, &apprunner.ListTagsForResourceInput{ResourceArn: service.ServiceArn})
+		if err != nil {
+			log.Errorf("Can't get the tags of App Runner service %s: %s", aws.StringValue(service.ServiceName), err)
+			continue
+		}
+
+		tags := make(map[string]string)
+		for _, tag := range result.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+		}
+
+		resources = append(resources, cleaner.Resource{
+			Id:           aws.StringValue(service.ServiceArn),
+			Name:         aws.StringValue(service.ServiceName),
+			Arn:          aws.StringValue(service.ServiceArn),
+			CreationDate: aws.TimeValue(service.CreatedAt),
+			Tags:         tags,
+		})
+	}
+
+	return resources, nil
+}
+
+func (c appRunnerServiceCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.DeleteServiceWithContext(ctx, &apprunner.DeleteServiceInput{ServiceArn: aws.String(resource.Arn)})
+
+	return err
+}
+
+func (c appRunnerServiceCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var appRunnerTags []*apprunner.Tag
+	for key, value := range tags {
+		appRunnerTags = append(appRunnerTags, &apprunner.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	_, err := c.svc.TagResourceWithContext(ctx,
+		&apprunner.TagResourceInput{
+			ResourceArn: aws.String(resource.Arn),
+			Tags:        appRunnerTags,
+		})
+
+	return err
+}
@@ -0,0 +1,149 @@
+// Package apprunner is a minimal App Runner client: the version of the AWS SDK pleco uses predates the service. It
+// only has the operations the App Runner cleaner needs and is built on the SDK request plumbing (signing, retries,
+// endpoints).
+package apprunner
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+	"github.com/aws/aws-sdk-go/private/protocol"
+	"github.com/aws/aws-sdk-go/private/protocol/jsonrpc"
+	"time"
+)
+
+const (
+	ServiceName = "apprunner"
+	ServiceID   = "AppRunner"
+)
+
+// ServiceStatusOperationInProgress is the status of services being created, updated or deleted, they can't be deleted
+const ServiceStatusOperationInProgress = "OPERATION_IN_PROGRESS"
+
+// AppRunner is the App Runner client of a region
+type AppRunner struct {
+	*client.Client
+}
+
+// New returns the App Runner client of the region of the session
+func New(p client.ConfigProvider, cfgs ...*aws.Config) *AppRunner {
+	c := p.ClientConfig(ServiceName, cfgs...)
+	svc := &AppRunner{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   ServiceName,
+				ServiceID:     ServiceID,
+				SigningName:   c.SigningName,
+				SigningRegion: c.SigningRegion,
+				PartitionID:   c.PartitionID,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2020-05-15",
+				JSONVersion:   "1.0",
+				TargetPrefix:  "AppRunner",
+			},
+			c.Handlers,
+		),
+	}
+
+	svc.Handlers.Sign.PushBackNamed(v4.SignRequestHandler)
+	svc.Handlers.Build.PushBackNamed(jsonrpc.BuildHandler)
+	svc.Handlers.Unmarshal.PushBackNamed(jsonrpc.UnmarshalHandler)
+	svc.Handlers.UnmarshalMeta.PushBackNamed(jsonrpc.UnmarshalMetaHandler)
+	svc.Handlers.UnmarshalError.PushBackNamed(
+		protocol.NewUnmarshalErrorHandler(jsonrpc.NewUnmarshalTypedError(nil)).NamedHandler(),
+	)
+
+	return svc
+}
+
+type ServiceSummary struct {
+	ServiceArn  *string
+	ServiceId   *string
+	ServiceName *string
+	ServiceUrl  *string
+	Status      *string
+	CreatedAt   *time.Time
+	UpdatedAt   *time.Time
+}
+
+type ListServicesInput struct {
+	MaxResults *int64
+	NextToken  *string
+}
+
+type ListServicesOutput struct {
+	NextToken          *string
+	ServiceSummaryList []*ServiceSummary
+}
+
+type Tag struct {
+	Key   *string
+	Value *string
+}
+
+type ListTagsForResourceInput struct {
+	ResourceArn *string
+}
+
+type ListTagsForResourceOutput struct {
+	Tags []*Tag
+}
+
+type TagResourceInput struct {
+	ResourceArn *string
+	Tags        []*Tag
+}
+
+type TagResourceOutput struct{}
+
+type DeleteServiceInput struct {
+	ServiceArn *string
+}
+
+type DeleteServiceOutput struct {
+	OperationId *string
+}
+
+func (c *AppRunner) send(ctx context.Context, operation string, input interface{}, output interface{}) error {
+	req := c.NewRequest(&request.Operation{Name: operation, HTTPMethod: "POST", HTTPPath: "/"}, input, output)
+	req.SetContext(ctx)
+
+	return req.Send()
+}
+
+// ListServicesPagesWithContext calls fn with every page of services until it returns false
+func (c *AppRunner) ListServicesPagesWithContext(ctx context.Context, input *ListServicesInput, fn func(*ListServicesOutput, bool) bool) error {
+	for {
+		output := &ListServicesOutput{}
+		err := c.send(ctx, "ListServices", input, output)
+		if err != nil {
+			return err
+		}
+
+		lastPage := aws.StringValue(output.NextToken) == ""
+		if !fn(output, lastPage) || lastPage {
+			return nil
+		}
+		input.NextToken = output.NextToken
+	}
+}
+
+func (c *AppRunner) ListTagsForResourceWithContext(ctx context.Context, input *ListTagsForResourceInput) (*ListTagsForResourceOutput, error) {
+	output := &ListTagsForResourceOutput{}
+	return output, c.send(ctx, "ListTagsForResource", input, output)
+}
+
+func (c *AppRunner) TagResourceWithContext(ctx context.Context, input *TagResourceInput) (*TagResourceOutput, error) {
+	output := &TagResourceOutput{}
+	return output, c.send(ctx, "TagResource", input, output)
+}
+
+// DeleteServiceWithContext starts the deletion of the service, it's asynchronous
+func (c *AppRunner) DeleteServiceWithContext(ctx context.Context, input *DeleteServiceInput) (*DeleteServiceOutput, error) {
+	output := &DeleteServiceOutput{}
+	return output, c.send(ctx, "DeleteService", input, output)
+}
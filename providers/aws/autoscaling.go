@@ -0,0 +1,107 @@
+package aws
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/autoscaling"
+	"github.com/aws/aws-sdk-go/service/autoscaling/autoscalingiface"
+)
+
+// eksNodeGroupTagName is set by EKS on the auto scaling groups of its managed node groups
+const eksNodeGroupTagName = "eks:nodegroup-name"
+
+// autoScalingGroupCleaner deletes the expired auto scaling groups of a region with their instances, and scales them to
+// zero during the quarantine
+type autoScalingGroupCleaner struct {
+	svc autoscalingiface.AutoScalingAPI
+}
+
+func init() {
+	cleaner.Register("autoscaling", func(sess *session.Session, region string) cleaner.Cleaner {
+		return autoScalingGroupCleaner{svc: autoscaling.New(sess)}
+	})
+}
+
+func (c autoScalingGroupCleaner) Name() string {
+	return resources.AutoScalingGroup
+}
+
+// List returns the auto scaling groups, except the ones being deleted and the ones of EKS node groups, deleted with
+// their cluster
+func (c autoScalingGroupCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	var resources []cleaner.Resource
+	err := c.svc.DescribeAutoScalingGroupsPagesWithContext(ctx,
+		&autoscaling.DescribeAutoScalingGroupsInput{MaxRecords: aws.Int64(100)},
+		func(page *autoscaling.DescribeAutoScalingGroupsOutput, lastPage bool) bool {
+			for _, group := range page.AutoScalingGroups {
+				if group.Status != nil {
+					continue
+				}
+
+				tags := make(map[string]string)
+				for _, tag := range group.Tags {
+					tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+				}
+
+				if _, isNodeGroup := tags[eksNodeGroupTagName]; isNodeGroup {
+					continue
+				}
+
+				resources = append(resources, cleaner.Resource{
+					Id:           aws.StringValue(group.AutoScalingGroupName),
+					Arn:          aws.StringValue(group.AutoScalingGroupARN),
+					CreationDate: aws.TimeValue(group.CreatedTime),
+					Tags:         tags,
+				})
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// Delete deletes the group and terminates its instances
+func (c autoScalingGroupCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.DeleteAutoScalingGroupWithContext(ctx,
+		&autoscaling.DeleteAutoScalingGroupInput{
+			AutoScalingGroupName: aws.String(resource.Id),
+			ForceDelete:          aws.Bool(true),
+		})
+
+	return err
+}
+
+// Quarantine scales the group to zero, its instances are terminated but its launch configuration is kept
+func (c autoScalingGroupCleaner) Quarantine(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.UpdateAutoScalingGroupWithContext(ctx,
+		&autoscaling.UpdateAutoScalingGroupInput{
+			AutoScalingGroupName: aws.String(resource.Id),
+			MinSize:              aws.Int64(0),
+			DesiredCapacity:      aws.Int64(0),
+		})
+
+	return err
+}
+
+func (c autoScalingGroupCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var autoScalingTags []*autoscaling.Tag
+	for key, value := range tags {
+		autoScalingTags = append(autoScalingTags, &autoscaling.Tag{
+			Key:               aws.String(key),
+			Value:             aws.String(value),
+			ResourceId:        aws.String(resource.Id),
+			ResourceType:      aws.String("auto-scaling-group"),
+			PropagateAtLaunch: aws.Bool(false),
+		})
+	}
+
+	_, err := c.svc.CreateOrUpdateTagsWithContext(ctx, &autoscaling.CreateOrUpdateTagsInput{Tags: autoScalingTags})
+
+	return err
+}
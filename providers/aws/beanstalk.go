@@ -0,0 +1,353 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elasticbeanstalk"
+	"github.com/aws/aws-sdk-go/service/elasticbeanstalk/elasticbeanstalkiface"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type beanstalkEnvironment struct {
+	EnvironmentId     string
+	EnvironmentName   string
+	Arn               string
+	ApplicationName   string
+	VersionLabel      string
+	CreationDate      time.Time
+	Status            string
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+type beanstalkApplicationVersion struct {
+	ApplicationName   string
+	VersionLabel      string
+	Arn               string
+	CreationDate      time.Time
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+func getBeanstalkEnvironments(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI) ([]*elasticbeanstalk.EnvironmentDescription, error) {
+	var environments []*elasticbeanstalk.EnvironmentDescription
+
+	// the SDK doesn't provide a pages helper for environments, follow the tokens manually
+	input := &elasticbeanstalk.DescribeEnvironmentsInput{
+		IncludeDeleted: aws.Bool(false),
+		MaxRecords:     aws.Int64(1000),
+	}
+	for {
+		result, err := svc.DescribeEnvironmentsWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		environments = append(environments, result.Environments...)
+
+		if aws.StringValue(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return environments, nil
+}
+
+func listTaggedBeanstalkEnvironments(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, taggedResources tagging.TaggedResources, tagName string) ([]beanstalkEnvironment, error) {
+	var taggedEnvironments []beanstalkEnvironment
+
+	if len(taggedResources.ByType(tagging.BeanstalkEnvironment)) == 0 && !utils.HasUntaggedRule(ctx, resources.BeanstalkEnvironment) {
+		return nil, nil
+	}
+
+	environments, err := getBeanstalkEnvironments(ctx, svc)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, environment := range environments {
+		resource, isTagged := taggedResources.Get(*environment.EnvironmentArn)
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.BeanstalkEnvironment) {
+			continue
+		}
+
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
+
+		taggedEnvironments = append(taggedEnvironments, beanstalkEnvironment{
+			EnvironmentId:     *environment.EnvironmentId,
+			EnvironmentName:   *environment.EnvironmentName,
+			Arn:               *environment.EnvironmentArn,
+			ApplicationName:   aws.StringValue(environment.ApplicationName),
+			VersionLabel:      aws.StringValue(environment.VersionLabel),
+			CreationDate:      aws.TimeValue(environment.DateCreated),
+			Status:            *environment.Status,
+			TTL:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(resource.Tags),
+		})
+	}
+
+	return taggedEnvironments, nil
+}
+
+func getBeanstalkApplicationVersions(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, input *elasticbeanstalk.DescribeApplicationVersionsInput) ([]*elasticbeanstalk.ApplicationVersionDescription, error) {
+	var versions []*elasticbeanstalk.ApplicationVersionDescription
+
+	for {
+		result, err := svc.DescribeApplicationVersionsWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		versions = append(versions, result.ApplicationVersions...)
+
+		if aws.StringValue(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return versions, nil
+}
+
+func listTaggedBeanstalkApplicationVersions(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, taggedResources tagging.TaggedResources, tagName string) ([]beanstalkApplicationVersion, error) {
+	var taggedVersions []beanstalkApplicationVersion
+
+	if len(taggedResources.ByType(tagging.BeanstalkApplicationVersion)) == 0 && !utils.HasUntaggedRule(ctx, resources.BeanstalkApplicationVersion) {
+		return nil, nil
+	}
+
+	versions, err := getBeanstalkApplicationVersions(ctx, svc, &elasticbeanstalk.DescribeApplicationVersionsInput{
+		MaxRecords: aws.Int64(1000),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range versions {
+		resource, isTagged := taggedResources.Get(*version.ApplicationVersionArn)
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.BeanstalkApplicationVersion) {
+			continue
+		}
+
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
+
+		taggedVersions = append(taggedVersions, beanstalkApplicationVersion{
+			ApplicationName:   *version.ApplicationName,
+			VersionLabel:      *version.VersionLabel,
+			Arn:               *version.ApplicationVersionArn,
+			CreationDate:      aws.TimeValue(version.DateCreated),
+			TTL:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(resource.Tags),
+		})
+	}
+
+	return taggedVersions, nil
+}
+
+// tagBeanstalkApplicationVersionForDeletion flags the version deployed on an expired environment, so it gets
+// deleted once the environment is terminated and the version is not in use anymore
+func tagBeanstalkApplicationVersionForDeletion(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, region string, environment beanstalkEnvironment, tagName string) error {
+	if environment.VersionLabel == "" {
+		return nil
+	}
+
+	versions, err := getBeanstalkApplicationVersions(ctx, svc, &elasticbeanstalk.DescribeApplicationVersionsInput{
+		ApplicationName: aws.String(environment.ApplicationName),
+		VersionLabels:   aws.StringSlice([]string{environment.VersionLabel}),
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		_, err := svc.UpdateTagsForResourceWithContext(ctx,
+			&elasticbeanstalk.UpdateTagsForResourceInput{
+				ResourceArn: version.ApplicationVersionArn,
+				TagsToAdd: []*elasticbeanstalk.Tag{
+					{
+						Key:   aws.String(tagName),
+						Value: aws.String("1"),
+					},
+				},
+			})
+		if err != nil {
+			return fmt.Errorf("Can't tag application version %s of environment %s in region %s: %s", environment.VersionLabel, environment.EnvironmentName, region, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func terminateBeanstalkEnvironment(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, region string, environment beanstalkEnvironment, tagName string) error {
+	switch environment.Status {
+	case elasticbeanstalk.EnvironmentStatusTerminating, elasticbeanstalk.EnvironmentStatusTerminated:
+		log.Infof("Elastic Beanstalk environment %s in %s is already in termination process, skipping...", environment.EnvironmentName, region)
+		return nil
+	case elasticbeanstalk.EnvironmentStatusLaunching, elasticbeanstalk.EnvironmentStatusUpdating:
+		log.Infof("Elastic Beanstalk environment %s in %s is %s, skipping...", environment.EnvironmentName, region, environment.Status)
+		return nil
+	}
+
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.BeanstalkEnvironment, region, environment.EnvironmentName).Infof("Terminating Elastic Beanstalk environment %s in %s, expired after %d seconds",
+		environment.EnvironmentName, region, environment.TTL)
+
+	err := tagBeanstalkApplicationVersionForDeletion(ctx, svc, region, environment, tagName)
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.TerminateEnvironmentWithContext(ctx,
+		&elasticbeanstalk.TerminateEnvironmentInput{
+			EnvironmentId:      aws.String(environment.EnvironmentId),
+			TerminateResources: aws.Bool(true),
+		})
+	if err != nil {
+		return err
+	}
+	utils.ReportDeleted(ctx, resources.BeanstalkEnvironment, region, environment.EnvironmentName)
+
+	return nil
+}
+
+func deleteBeanstalkApplicationVersion(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, region string, version beanstalkApplicationVersion) error {
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.BeanstalkApplicationVersion, region, version.ApplicationName+"/"+version.VersionLabel).Infof("Deleting Elastic Beanstalk application version %s/%s in %s, expired after %d seconds",
+		version.ApplicationName, version.VersionLabel, region, version.TTL)
+
+	_, err := svc.DeleteApplicationVersionWithContext(ctx,
+		&elasticbeanstalk.DeleteApplicationVersionInput{
+			ApplicationName:    aws.String(version.ApplicationName),
+			VersionLabel:       aws.String(version.VersionLabel),
+			DeleteSourceBundle: aws.Bool(true),
+		})
+
+	return err
+}
+
+func DeleteExpiredBeanstalkEnvironments(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, region string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	environments, err := listTaggedBeanstalkEnvironments(ctx, svc, taggedResources, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list Elastic Beanstalk environments: %s", err)
+	}
+
+	var expiredEnvironments []beanstalkEnvironment
+	for _, environment := range environments {
+		if utils.CheckIfDeletable(ctx, environment.CreationDate, environment.TTL, environment.ExpirationDate, environment.DeletionScheduled, environment.IsProtected, resources.BeanstalkEnvironment, region, environment.EnvironmentName, environment.Arn) {
+			utils.SetReportTags(ctx, resources.BeanstalkEnvironment, region, environment.EnvironmentName, environment.Tags)
+			expiredEnvironments = append(expiredEnvironments, environment)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired Elastic Beanstalk environment", len(expiredEnvironments), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredEnvironments) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.BeanstalkEnvironment, region, len(expiredEnvironments))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, environment := range expiredEnvironments {
+		deletionErr := terminateBeanstalkEnvironment(ctx, svc, region, environment, tagName)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.BeanstalkEnvironment, region, environment.EnvironmentName).Errorf("Termination Elastic Beanstalk environment error %s/%s: %s",
+				environment.EnvironmentName, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.BeanstalkEnvironment, region, environment.EnvironmentName, deletionErr)
+		}
+	}
+
+	return nil
+}
+
+func DeleteExpiredBeanstalkApplicationVersions(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, region string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	versions, err := listTaggedBeanstalkApplicationVersions(ctx, svc, taggedResources, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list Elastic Beanstalk application versions: %s", err)
+	}
+
+	environments, err := getBeanstalkEnvironments(ctx, svc)
+	if err != nil {
+		return fmt.Errorf("can't list Elastic Beanstalk environments: %s", err)
+	}
+
+	// a version deployed on a live environment can't be deleted
+	deployedVersions := make(map[string]bool)
+	for _, environment := range environments {
+		if *environment.Status != elasticbeanstalk.EnvironmentStatusTerminated && environment.VersionLabel != nil {
+			deployedVersions[*environment.ApplicationName+"/"+*environment.VersionLabel] = true
+		}
+	}
+
+	var expiredVersions []beanstalkApplicationVersion
+	for _, version := range versions {
+		if deployedVersions[version.ApplicationName+"/"+version.VersionLabel] {
+			continue
+		}
+
+		if utils.CheckIfDeletable(ctx, version.CreationDate, version.TTL, version.ExpirationDate, version.DeletionScheduled, version.IsProtected, resources.BeanstalkApplicationVersion, region, version.ApplicationName+"/"+version.VersionLabel, version.Arn) {
+			utils.SetReportTags(ctx, resources.BeanstalkApplicationVersion, region, version.ApplicationName+"/"+version.VersionLabel, version.Tags)
+			expiredVersions = append(expiredVersions, version)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired Elastic Beanstalk application version", len(expiredVersions), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredVersions) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.BeanstalkApplicationVersion, region, len(expiredVersions))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, version := range expiredVersions {
+		deletionErr := deleteBeanstalkApplicationVersion(ctx, svc, region, version)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.BeanstalkApplicationVersion, region, version.ApplicationName+"/"+version.VersionLabel).Errorf("Deletion Elastic Beanstalk application version error %s/%s/%s: %s",
+				version.ApplicationName, version.VersionLabel, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.BeanstalkApplicationVersion, region, version.ApplicationName+"/"+version.VersionLabel, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.BeanstalkApplicationVersion, region, version.ApplicationName+"/"+version.VersionLabel)
+	}
+
+	return nil
+}
+
+func DeleteExpiredBeanstalk(ctx context.Context, svc elasticbeanstalkiface.ElasticBeanstalkAPI, region string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	log.Debugf("Listing all Elastic Beanstalk environments in region %s.", region)
+	environmentsErr := DeleteExpiredBeanstalkEnvironments(ctx, svc, region, taggedResources, tagName, dryRun)
+
+	log.Debugf("Listing all Elastic Beanstalk application versions in region %s.", region)
+	versionsErr := DeleteExpiredBeanstalkApplicationVersions(ctx, svc, region, taggedResources, tagName, dryRun)
+
+	return utils.JoinErrors(environmentsErr, versionsErr)
+}
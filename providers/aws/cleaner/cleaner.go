@@ -0,0 +1,339 @@
+package cleaner
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	log "github.com/sirupsen/logrus"
+	"plugin"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Resource is a resource listed by a cleaner
+type Resource struct {
+	// Id identifies the resource for the cleaner
+	Id string
+	// Name is shown in logs and reports instead of the id if set
+	Name string
+	// Arn is optional, it's recorded in the reports
+	Arn string
+	// CreationDate is returned by the API of the resource, zero if unknown, the creationDate tag is used otherwise
+	CreationDate time.Time
+	Tags         map[string]string
+}
+
+// Cleaner lists, tags and deletes the resources of a type in a region. Pleco decides which ones are deleted from their
+// tags, like for every other resource.
+type Cleaner interface {
+	// Name is the resource type (ex: EC2 key pair)
+	Name() string
+	// List returns the resources of the region with their tags
+	List(ctx context.Context) ([]Resource, error)
+	Delete(ctx context.Context, resource Resource) error
+	// Tag adds or updates tags of the resource
+	Tag(ctx context.Context, resource Resource, tags map[string]string) error
+}
+
+// Quarantiner is implemented by the cleaners of resources which can be stopped (ex: EC2 instances), expired resources
+// are stopped until their deletion when the deletion policy has a quarantine period
+type Quarantiner interface {
+	// Quarantine stops the resource, it does nothing if the resource is already stopped
+	Quarantine(ctx context.Context, resource Resource) error
+}
+
+// Factory returns the cleaner of a region
+type Factory func(sess *session.Session, region string) Cleaner
+
+// Options are the settings of the checks given to the jobs of the services
+type Options struct {
+	Account       string
+	TagName       string
+	ClusterTagKey string
+	ClusterTags   utils.ClusterTagPatterns
+	// FinalSnapshotTTL is the ttl of the final snapshots taken before deleting resources, 0 to skip them
+	FinalSnapshotTTL           int64
+	OverrideDeletionProtection bool
+	// IsEnabled returns true if a service is checked (ex: the VPC job tags the resources of the EKS clusters if EKS is)
+	IsEnabled func(service string) bool
+}
+
+// Job checks the resources of a service in a region, it deletes the expired ones unless it's a dry run
+type Job func(ctx context.Context, dryRun bool) error
+
+// JobFactory returns the job of a service in a region, nil if the service can't be checked there
+type JobFactory func(ctx context.Context, sess *session.Session, region string, options Options) Job
+
+// ServiceJob checks a service whose resources don't fit the Cleaner interface (ex: resources deleted with their
+// dependencies)
+type ServiceJob struct {
+	// Name is the name of the job in the logs and reports (ex: S3)
+	Name string
+	// Global services (ex: IAM) are checked once per account instead of in every region
+	Global bool
+	New    JobFactory
+}
+
+// registration is the cleaner or the job of a service
+type registration struct {
+	factory Factory
+	job     *ServiceJob
+}
+
+var registry = struct {
+	sync.Mutex
+	services map[string]registration
+	// order is the registration order of the services, the order of their jobs in a check
+	order []string
+	// services registered by each loaded plugin, plugins are only loaded once
+	plugins map[string][]string
+}{services: make(map[string]registration), plugins: make(map[string][]string)}
+
+// Register adds the cleaner of a service, run in every region once the service is enabled. It replaces the cleaner or
+// the job already registered for the service, if any. Plugins call it from their init function.
+func Register(service string, factory Factory) {
+	register(service, registration{factory: factory})
+}
+
+// RegisterJob adds the job of a service, run in every region (or once per account if global) once the service is
+// enabled. It replaces the cleaner or the job already registered for the service, if any.
+func RegisterJob(service string, job ServiceJob) {
+	register(service, registration{job: &job})
+}
+
+func register(service string, entry registration) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if _, isRegistered := registry.services[service]; !isRegistered {
+		registry.order = append(registry.order, service)
+	}
+	registry.services[service] = entry
+}
+
+// Unregister removes the cleaner or the job of a service, so a plugin can disable a built-in service
+func Unregister(service string) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	delete(registry.services, service)
+	for i, registered := range registry.order {
+		if registered == service {
+			registry.order = append(registry.order[:i:i], registry.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// Services returns the registered services sorted by name
+func Services() []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	var services []string
+	for service := range registry.services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+
+	return services
+}
+
+// OrderedServices returns the registered services in their registration order, the order their jobs run in
+func OrderedServices() []string {
+	registry.Lock()
+	defer registry.Unlock()
+
+	return append([]string(nil), registry.order...)
+}
+
+// GetFactory returns the factory of the cleaner of a service, nil if none is registered
+func GetFactory(service string) Factory {
+	registry.Lock()
+	defer registry.Unlock()
+
+	return registry.services[service].factory
+}
+
+// GetJob returns the job of a service, false if the service has none (not registered or registered with a cleaner)
+func GetJob(service string) (ServiceJob, bool) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	job := registry.services[service].job
+	if job == nil {
+		return ServiceJob{}, false
+	}
+
+	return *job, true
+}
+
+// LoadPlugins opens Go plugins (built with go build -buildmode=plugin against the same pleco version) and returns the
+// services their init functions registered
+func LoadPlugins(paths []string) ([]string, error) {
+	var services []string
+
+	for _, path := range paths {
+		registry.Lock()
+		pluginServices, isLoaded := registry.plugins[path]
+		registry.Unlock()
+		if isLoaded {
+			services = append(services, pluginServices...)
+			continue
+		}
+
+		before := make(map[string]bool)
+		for _, service := range Services() {
+			before[service] = true
+		}
+
+		_, err := plugin.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("can't load cleaner plugin %s: %s", path, err)
+		}
+
+		pluginServices = nil
+		for _, service := range Services() {
+			if !before[service] {
+				pluginServices = append(pluginServices, service)
+			}
+		}
+		services = append(services, pluginServices...)
+
+		registry.Lock()
+		registry.plugins[path] = pluginServices
+		registry.Unlock()
+		log.Infof("Loaded cleaner plugin %s.", path)
+	}
+
+	return services, nil
+}
+
+// tagScheduler schedules the deletions with the Tag method of the cleaner
+type tagScheduler struct {
+	cleaner Cleaner
+	// resources by name, the first identifier
+	resources map[string]Resource
+}
+
+func (s tagScheduler) ScheduleDeletion(ctx context.Context, resourceType string, identifiers []string, deletionDate time.Time) error {
+	return s.cleaner.Tag(ctx, s.resources[identifiers[0]], map[string]string{
+		utils.DeletionScheduledTagName: deletionDate.Format(time.RFC3339),
+	})
+}
+
+func (s tagScheduler) GetOwner(ctx context.Context, resourceType string, identifiers []string) (string, error) {
+	return utils.GetOwnerFromTags(s.resources[identifiers[0]].Tags), nil
+}
+
+// getName returns the name shown in logs and reports
+func (r Resource) getName() string {
+	if r.Name == "" {
+		return r.Id
+	}
+
+	return r.Name
+}
+
+// identifiers returns the name, id and ARN of the resource given to CheckIfDeletable
+func (r Resource) identifiers() []string {
+	identifiers := []string{r.getName()}
+	if r.Name != "" {
+		identifiers = append(identifiers, r.Id)
+	}
+	if r.Arn != "" {
+		identifiers = append(identifiers, r.Arn)
+	}
+
+	return identifiers
+}
+
+// tagCreationDate sets the creationDate tag of a resource without creation date to now and returns it, its ttl counts
+// from the first check seeing it. It returns a zero time if the tag can't be set.
+func tagCreationDate(ctx context.Context, c Cleaner, region string, resource Resource) time.Time {
+	now := utils.Now(ctx)
+
+	tagCtx, endTag := utils.StartSpan(ctx, "tag", map[string]string{
+		"resource_type": c.Name(),
+		"region":        region,
+		"resource_id":   resource.getName(),
+	})
+	err := c.Tag(tagCtx, resource, map[string]string{utils.CreationDateTagName: utils.FormatCreationDate(now)})
+	endTag(err)
+	if err != nil {
+		log.Errorf("Can't set the creation date of %s %s in %s: %s", c.Name(), resource.getName(), region, err)
+		return time.Time{}
+	}
+
+	return now
+}
+
+// DeleteExpired deletes the expired resources listed by the cleaner, or only reports them in dry run
+func DeleteExpired(ctx context.Context, c Cleaner, region string, tagName string, dryRun bool) error {
+	resources, err := c.List(ctx)
+	if err != nil {
+		return fmt.Errorf("can't list %ss: %s", c.Name(), err)
+	}
+
+	resourcesByName := make(map[string]Resource)
+	for _, resource := range resources {
+		resourcesByName[resource.getName()] = resource
+	}
+
+	checkCtx := ctx
+	if utils.HasDeletionScheduler(ctx) {
+		checkCtx = utils.WithDeletionScheduler(ctx, tagScheduler{cleaner: c, resources: resourcesByName})
+	}
+	if quarantiner, canQuarantine := c.(Quarantiner); canQuarantine {
+		checkCtx = utils.WithQuarantiner(checkCtx, c.Name(), func(ctx context.Context, identifiers []string) error {
+			return quarantiner.Quarantine(ctx, resourcesByName[identifiers[0]])
+		})
+	}
+
+	var expiredResources []Resource
+	for _, resource := range resources {
+		creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, aws.StringMap(resource.Tags), tagName)
+		creationDate = utils.GetCreationTime(resource.CreationDate, creationDate)
+		if creationDate.IsZero() && ttl != 0 && !dryRun {
+			creationDate = tagCreationDate(ctx, c, region, resource)
+		}
+
+		if utils.CheckIfDeletable(checkCtx, creationDate, ttl, expirationDate, deletionScheduled, isProtected, c.Name(), region, resource.identifiers()...) {
+			utils.SetReportTags(ctx, c.Name(), region, resource.getName(), resource.Tags)
+			expiredResources = append(expiredResources, resource)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired "+c.Name(), len(expiredResources), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredResources) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, c.Name(), region, len(expiredResources))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, resource := range expiredResources {
+		name := resource.getName()
+		utils.ResourceLog(ctx, utils.ActionDelete, c.Name(), region, name).Infof("Deleting %s %s in %s.", c.Name(), name, region)
+		deletionErr := c.Delete(ctx, resource)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, c.Name(), region, name).Errorf("Deletion %s error %s/%s: %s",
+				c.Name(), name, region, deletionErr)
+			utils.ReportDeletionError(ctx, c.Name(), region, name, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, c.Name(), region, name)
+	}
+
+	return nil
+}
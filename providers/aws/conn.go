@@ -1,27 +1,285 @@
 package aws
 
 import (
+	"context"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sts"
 	"github.com/sirupsen/logrus"
+	"os"
+	"strings"
 )
 
+// EndpointEnvName is the environment variable overriding the endpoint of every AWS service, to run against LocalStack
+// for instance (ex: http://localhost:4566)
+const EndpointEnvName = "AWS_ENDPOINT_URL"
+
+// Partitions are the AWS partitions pleco can check
+var Partitions = []string{endpoints.AwsPartitionID, endpoints.AwsCnPartitionID, endpoints.AwsUsGovPartitionID}
+
+// Endpoints configures the endpoints of every AWS session
+type Endpoints struct {
+	// URL overrides the endpoint of every service, the EndpointEnvName environment variable is used if it's empty
+	URL string
+	// Services overrides the endpoint of services by endpoint prefix (ex: s3, ec2, elasticloadbalancing)
+	Services map[string]string
+	// Partition holds the default region of the sessions without region and of the global services, it's the
+	// standard partition if empty
+	Partition string
+}
+
+var sessionEndpoints Endpoints
+
+// Credentials configures the credentials of the sessions of an account. Without profile, the default credential chain
+// is used: environment variables, web identity token of IAM Roles for Service Accounts (AWS_WEB_IDENTITY_TOKEN_FILE and
+// AWS_ROLE_ARN), shared configuration, container or instance role.
+type Credentials struct {
+	// Profile is the profile of the shared configuration and credentials files holding the credentials
+	Profile string
+	// RoleArn is a role to assume with the credentials, pleco checks the account of the role
+	RoleArn string
+	// SessionName names the sessions of the assumed role, in CloudTrail for instance
+	SessionName string
+	// ExternalId is the external id required by the trust policy of the role, if any
+	ExternalId string
+	// WebIdentityTokenFile holds a web identity token (ex: a Kubernetes service account token) assuming the role
+	// instead of the credentials
+	WebIdentityTokenFile string
+}
+
+// DefaultSessionName names the sessions of the assumed roles without session name
+const DefaultSessionName = "pleco"
+
+var sessionCredentials = Credentials{SessionName: DefaultSessionName}
+
+// SetDefaultCredentials sets the credentials of the sessions created from now on, and the profile and session name of
+// the accounts without one
+func SetDefaultCredentials(credentials Credentials) {
+	if credentials.SessionName == "" {
+		credentials.SessionName = DefaultSessionName
+	}
+
+	sessionCredentials = credentials
+}
+
+// withDefaults returns the credentials with the default profile and session name if they have none
+func (c Credentials) withDefaults() Credentials {
+	if c.Profile == "" {
+		c.Profile = sessionCredentials.Profile
+	}
+	if c.SessionName == "" {
+		c.SessionName = sessionCredentials.SessionName
+	}
+
+	return c
+}
+
+// ParseCredentials parses the credentials of an account as comma separated key=value pairs, among role-arn, profile,
+// session-name, external-id and web-identity-token-file (ex: role-arn=arn:aws:iam::123456789012:role/pleco,external-id=1234)
+func ParseCredentials(value string) (Credentials, error) {
+	var credentials Credentials
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			return credentials, fmt.Errorf("invalid AWS account %s, expected comma separated key=value pairs", value)
+		}
+
+		switch strings.TrimSpace(parts[0]) {
+		case "role-arn":
+			credentials.RoleArn = parts[1]
+		case "profile":
+			credentials.Profile = parts[1]
+		case "session-name":
+			credentials.SessionName = parts[1]
+		case "external-id":
+			credentials.ExternalId = parts[1]
+		case "web-identity-token-file":
+			credentials.WebIdentityTokenFile = parts[1]
+		default:
+			return credentials, fmt.Errorf("unknown AWS account key %s, choose between : role-arn/profile/session-name/external-id/web-identity-token-file", parts[0])
+		}
+	}
+
+	if credentials.RoleArn == "" && (credentials.ExternalId != "" || credentials.WebIdentityTokenFile != "") {
+		return credentials, fmt.Errorf("invalid AWS account %s, external-id and web-identity-token-file need a role-arn", value)
+	}
+
+	return credentials, nil
+}
+
+// SetEndpoints sets the endpoints of the sessions created from now on
+func SetEndpoints(config Endpoints) error {
+	if config.Partition != "" {
+		if _, ok := getPartition(config.Partition); !ok {
+			return fmt.Errorf("unknown AWS partition %s, choose between : %s", config.Partition, strings.Join(Partitions, "/"))
+		}
+	}
+
+	sessionEndpoints = config
+	return nil
+}
+
+func getPartition(id string) (endpoints.Partition, bool) {
+	for _, partition := range endpoints.DefaultPartitions() {
+		if partition.ID() == id {
+			return partition, true
+		}
+	}
+
+	return endpoints.Partition{}, false
+}
+
+// DefaultRegion returns the region of the global services (IAM, STS, Pricing) of the partition
+func DefaultRegion() string {
+	switch sessionEndpoints.Partition {
+	case endpoints.AwsCnPartitionID:
+		return endpoints.CnNorth1RegionID
+	case endpoints.AwsUsGovPartitionID:
+		return endpoints.UsGovWest1RegionID
+	default:
+		return endpoints.UsEast1RegionID
+	}
+}
+
+// GetPartitionId returns the partition of the region, the configured one if the region is unknown, for ARNs
+func GetPartitionId(region string) string {
+	if partition, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		return partition.ID()
+	}
+	if sessionEndpoints.Partition != "" {
+		return sessionEndpoints.Partition
+	}
+
+	return endpoints.AwsPartitionID
+}
+
+// withEndpoint overrides the endpoints of the configuration if they are set
+func withEndpoint(config *aws.Config) *aws.Config {
+	endpoint := sessionEndpoints.URL
+	if endpoint == "" {
+		endpoint = os.Getenv(EndpointEnvName)
+	}
+	if endpoint != "" {
+		config.Endpoint = aws.String(endpoint)
+		// bucket names can't be resolved as subdomains of a custom endpoint
+		config.S3ForcePathStyle = aws.Bool(true)
+		return config
+	}
+
+	if len(sessionEndpoints.Services) == 0 {
+		return config
+	}
+
+	services := sessionEndpoints.Services
+	config.EndpointResolver = endpoints.ResolverFunc(func(service string, region string, options ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+		if url, ok := services[service]; ok {
+			return endpoints.ResolvedEndpoint{URL: url, SigningRegion: region}, nil
+		}
+
+		return endpoints.DefaultResolver().EndpointFor(service, region, options...)
+	})
+	if _, ok := services[s3.EndpointsID]; ok {
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+
+	return config
+}
+
 func CreateSession(region string) (*session.Session, error) {
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(region)},
-	)
+	return CreateSessionWithCredentials(region, sessionCredentials)
+}
+
+// CreateSessionWithCredentials creates a session in the region using the credentials of an account, the default
+// profile and session name are used if it has none
+func CreateSessionWithCredentials(region string, credentials Credentials) (*session.Session, error) {
+	credentials = credentials.withDefaults()
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *withEndpoint(&aws.Config{Region: aws.String(region)}),
+		Profile:           credentials.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
 	if err != nil {
 		logrus.Errorf("Can't connect to AWS: %s", err)
 		return nil, err
 	}
-	return sess, nil
+	if credentials.RoleArn == "" {
+		return sess, nil
+	}
+
+	if credentials.WebIdentityTokenFile != "" {
+		return sess.Copy(&aws.Config{
+			Credentials: stscreds.NewWebIdentityCredentials(sess, credentials.RoleArn, credentials.SessionName, credentials.WebIdentityTokenFile),
+		}), nil
+	}
+
+	return sess.Copy(&aws.Config{
+		Credentials: stscreds.NewCredentials(sess, credentials.RoleArn, func(provider *stscreds.AssumeRoleProvider) {
+			provider.RoleSessionName = credentials.SessionName
+			if credentials.ExternalId != "" {
+				provider.ExternalID = aws.String(credentials.ExternalId)
+			}
+		}),
+	}), nil
+}
+
+// GetRoleAccountId returns the id of the account owning the role, empty if it's not a valid role ARN
+func GetRoleAccountId(roleArn string) string {
+	parsedArn, err := arn.Parse(roleArn)
+	if err != nil {
+		return ""
+	}
+
+	return parsedArn.AccountID
 }
 
 func CreateSessionWithoutRegion() (*session.Session, error) {
-	sess, err := session.NewSession()
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *withEndpoint(&aws.Config{}),
+		Profile:           sessionCredentials.Profile,
+		SharedConfigState: session.SharedConfigEnable,
+	})
 	if err != nil {
 		logrus.Errorf("Can't connect to AWS: %s", err)
 		return nil, err
 	}
 	return sess, nil
-}
\ No newline at end of file
+}
+
+func GetAccountId(ctx context.Context, sess *session.Session) (string, error) {
+	result, err := sts.New(sess).GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	return *result.Account, nil
+}
+
+// GetEnabledRegions returns the regions enabled on the account, opt-in regions are only returned once opted in
+func GetEnabledRegions(ctx context.Context, sess *session.Session) ([]string, error) {
+	result, err := ec2.New(sess).DescribeRegionsWithContext(ctx,
+		&ec2.DescribeRegionsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("opt-in-status"),
+					Values: aws.StringSlice([]string{"opt-in-not-required", "opted-in"}),
+				},
+			},
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	var regions []string
+	for _, region := range result.Regions {
+		regions = append(regions, *region.RegionName)
+	}
+
+	return regions, nil
+}
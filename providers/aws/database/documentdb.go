@@ -1,11 +1,14 @@
 package database
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -16,29 +19,37 @@ type documentDBCluster struct {
 	ClusterCreateTime time.Time
 	Status string
 	TTL int64
+	ExpirationDate time.Time
+	DeletionScheduled time.Time
 	IsProtected bool
+	Tags map[string]string
 }
 
-func listTaggedDocumentDBClusters(svc rds.RDS, tagName string) ([]documentDBCluster, error) {
+func listTaggedDocumentDBClusters(ctx context.Context, svc rdsiface.RDSAPI, tagName string) ([]documentDBCluster, error) {
 	var taggedClusters []documentDBCluster
-	var instances []string
 
 	// unfortunately AWS doesn't support tag filtering for RDS
-	result, err := svc.DescribeDBClusters(nil)
+	var clusters []*rds.DBCluster
+	err := svc.DescribeDBClustersPagesWithContext(ctx, &rds.DescribeDBClustersInput{},
+		func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+			clusters = append(clusters, page.DBClusters...)
+			return true
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result.DBClusters) == 0 {
+	if len(clusters) == 0 {
 		return nil, nil
 	}
 
-	for _, cluster := range result.DBClusters {
+	for _, cluster := range clusters {
+		var instances []string
 		for _, instance := range cluster.DBClusterMembers {
 			instances = append(instances, *instance.DBInstanceIdentifier)
 		}
 
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(cluster.TagList,tagName)
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, cluster.TagList,tagName)
 
 		taggedClusters = append(taggedClusters, documentDBCluster{
 			DBClusterIdentifier:  *cluster.DBClusterIdentifier,
@@ -46,27 +57,30 @@ func listTaggedDocumentDBClusters(svc rds.RDS, tagName string) ([]documentDBClus
 			ClusterCreateTime:    *cluster.ClusterCreateTime,
 			Status:               *cluster.Status,
 			TTL:                  ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: 		  isProtected,
+			Tags: 		  utils.TagsToMap(cluster.TagList),
 		})
 	}
 
 	return taggedClusters, nil
 }
 
-func deleteDocumentDBCluster(svc rds.RDS, cluster documentDBCluster, dryRun bool) error {
+func deleteDocumentDBCluster(ctx context.Context, svc rdsiface.RDSAPI, region string, cluster documentDBCluster, finalSnapshotTTL int64, dryRun bool) error {
 	deleteInstancesErrors := 0
 
 	if cluster.Status == "deleting" {
 		log.Infof("DocumentDB cluster %s is already in deletion process, skipping...", cluster.DBClusterIdentifier)
 		return nil
 	} else {
-		log.Infof("Deleting DocumentDB cluster %s in %s, expired after %d seconds",
-			cluster.DBClusterIdentifier, *svc.Config.Region, cluster.TTL)
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.DocumentDBCluster, region, cluster.DBClusterIdentifier).Infof("Deleting DocumentDB cluster %s in %s, expired after %d seconds",
+			cluster.DBClusterIdentifier, region, cluster.TTL)
 	}
 
 	// delete instance before deleting the cluster (otherwise it fails)
 	for _, instance := range cluster.DBClusterMembers {
-		rdsInstanceInfo, err := GetRDSInstanceInfos(svc, instance)
+		rdsInstanceInfo, err := GetRDSInstanceInfos(ctx, svc, instance)
 		if err != nil {
 			log.Errorf("Can't access RDS instance %s information for DocumentDB cluster %s: %s",
 				instance, cluster.DBClusterIdentifier, err)
@@ -78,10 +92,10 @@ func deleteDocumentDBCluster(svc rds.RDS, cluster documentDBCluster, dryRun bool
 			continue
 		}
 
-		err = DeleteRDSDatabase(svc, rdsInstanceInfo)
+		err = DeleteRDSDatabase(ctx, svc, region, rdsInstanceInfo, "")
 		if err != nil {
 			log.Errorf("Deletion error on DocumentDB instance %s/%s/%s: %s",
-				instance, cluster.DBClusterIdentifier, *svc.Config.Region, err)
+				instance, cluster.DBClusterIdentifier, region, err)
 			deleteInstancesErrors++
 		}
 	}
@@ -96,51 +110,63 @@ func deleteDocumentDBCluster(svc rds.RDS, cluster documentDBCluster, dryRun bool
 	}
 
 	// delete cluster
-	_, err := svc.DeleteDBCluster(
-		&rds.DeleteDBClusterInput{
-			DBClusterIdentifier:       aws.String(cluster.DBClusterIdentifier),
-			SkipFinalSnapshot:         aws.Bool(true),
-		},
-	)
+	input := &rds.DeleteDBClusterInput{
+		DBClusterIdentifier:       aws.String(cluster.DBClusterIdentifier),
+		SkipFinalSnapshot:         aws.Bool(true),
+	}
+	if finalSnapshotIdentifier := getFinalSnapshotIdentifier(cluster.DBClusterIdentifier, finalSnapshotTTL); finalSnapshotIdentifier != "" {
+		log.Infof("Taking final snapshot %s of DocumentDB cluster %s in %s.", finalSnapshotIdentifier, cluster.DBClusterIdentifier, region)
+		input.SkipFinalSnapshot = aws.Bool(false)
+		input.FinalDBSnapshotIdentifier = aws.String(finalSnapshotIdentifier)
+	}
+
+	_, err := svc.DeleteDBClusterWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
+	utils.ReportDeleted(ctx, resources.DocumentDBCluster, region, cluster.DBClusterIdentifier)
 
 	return nil
 }
 
-func DeleteExpiredDocumentDBClusters(svc rds.RDS, tagName string, dryRun bool) {
-	clusters, err := listTaggedDocumentDBClusters(svc, tagName)
-	region := svc.Config.Region
+func DeleteExpiredDocumentDBClusters(ctx context.Context, svc rdsiface.RDSAPI, region string, tagName string, finalSnapshotTTL int64, dryRun bool) error {
+	clusters, err := listTaggedDocumentDBClusters(ctx, svc, tagName)
 	if err != nil {
-		log.Errorf("can't list DocumentDB databases: %s\n", err)
-		return
+		return fmt.Errorf("can't list DocumentDB databases: %s", err)
 	}
 
 	var expiredClusters []documentDBCluster
 	for _, cluster := range clusters {
-		if utils.CheckIfExpired(cluster.ClusterCreateTime, cluster.TTL)  && !cluster.IsProtected {
+		if utils.CheckIfDeletable(ctx, cluster.ClusterCreateTime, cluster.TTL, cluster.ExpirationDate, cluster.DeletionScheduled, cluster.IsProtected, resources.DocumentDBCluster, region, cluster.DBClusterIdentifier) {
+			utils.SetReportTags(ctx, resources.DocumentDBCluster, region, cluster.DBClusterIdentifier, cluster.Tags)
 			expiredClusters = append(expiredClusters, cluster)
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired DocumentDB database", len(expiredClusters), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired DocumentDB database", len(expiredClusters), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredClusters) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.DocumentDBCluster, region, len(expiredClusters))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
 
 	for _, cluster := range expiredClusters {
-		deletionErr := deleteDocumentDBCluster(svc, cluster, dryRun)
+		deletionErr := deleteDocumentDBCluster(ctx, svc, region, cluster, finalSnapshotTTL, dryRun)
 		if deletionErr != nil {
-			log.Errorf("Deletion DocumentDB cluster error %s/%s: %s",
-				cluster.DBClusterIdentifier, *svc.Config.Region, err)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.DocumentDBCluster, region, cluster.DBClusterIdentifier).Errorf("Deletion DocumentDB cluster error %s/%s: %s",
+				cluster.DBClusterIdentifier, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.DocumentDBCluster, region, cluster.DBClusterIdentifier, deletionErr)
 		}
 	}
 
+	return nil
 }
@@ -1,10 +1,16 @@
 package database
 
 import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/pricing"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/providers/aws/tagging"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -12,38 +18,46 @@ import (
 type elasticacheCluster struct {
 	ClusterIdentifier  string
 	ReplicationGroupId string
+	CacheNodeType      string
+	Engine             string
+	NumCacheNodes      int64
 	ClusterCreateTime  time.Time
 	ClusterStatus      string
 	TTL                int64
+	ExpirationDate     time.Time
+	DeletionScheduled  time.Time
 	IsProtected        bool
+	Tags               map[string]string
 }
 
 func ElasticacheSession(sess session.Session, region string) *elasticache.ElastiCache {
 	return elasticache.New(&sess, &aws.Config{Region: aws.String(region)})
 }
 
-func listTaggedElasticacheDatabases(svc elasticache.ElastiCache, tagName string) ([]elasticacheCluster, error) {
+func listTaggedElasticacheDatabases(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, taggedResources tagging.TaggedResources, tagName string) ([]elasticacheCluster, error) {
 	var taggedClusters []elasticacheCluster
 
-	result, err := svc.DescribeCacheClusters(nil)
+	if len(taggedResources.ByType(tagging.ElasticacheCluster)) == 0 && !utils.HasUntaggedRule(ctx, resources.ElasticacheCluster) {
+		return nil, nil
+	}
+
+	var clusters []*elasticache.CacheCluster
+	err := svc.DescribeCacheClustersPagesWithContext(ctx, &elasticache.DescribeCacheClustersInput{},
+		func(page *elasticache.DescribeCacheClustersOutput, lastPage bool) bool {
+			clusters = append(clusters, page.CacheClusters...)
+			return true
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result.CacheClusters) == 0 {
+	if len(clusters) == 0 {
 		return nil, nil
 	}
 
-	for _, cluster := range result.CacheClusters {
-		tags, err := svc.ListTagsForResource(
-			&elasticache.ListTagsForResourceInput{
-				ResourceName: aws.String(*cluster.ARN),
-			},
-		)
-		if err != nil {
-			if *cluster.CacheClusterStatus == "available" {
-				log.Errorf("Can't get tags for Elasticache cluster: %s", *cluster.CacheClusterId)
-			}
+	for _, cluster := range clusters {
+		resource, isTagged := taggedResources.Get(*cluster.ARN)
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.ElasticacheCluster) {
 			continue
 		}
 
@@ -53,15 +67,21 @@ func listTaggedElasticacheDatabases(svc elasticache.ElastiCache, tagName string)
 			replicationGroupId = *cluster.ReplicationGroupId
 		}
 
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(tags.TagList, tagName)
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
 
 		taggedClusters = append(taggedClusters, elasticacheCluster{
 			ClusterIdentifier:    *cluster.CacheClusterId,
 			ReplicationGroupId:	  replicationGroupId,
+			CacheNodeType:        aws.StringValue(cluster.CacheNodeType),
+			Engine:               aws.StringValue(cluster.Engine),
+			NumCacheNodes:        aws.Int64Value(cluster.NumCacheNodes),
 			ClusterCreateTime:    *cluster.CacheClusterCreateTime,
 			ClusterStatus:        *cluster.CacheClusterStatus,
 			TTL:                  ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isProtected,
+			Tags: utils.TagsToMap(resource.Tags),
 		})
 
 	}
@@ -69,18 +89,18 @@ func listTaggedElasticacheDatabases(svc elasticache.ElastiCache, tagName string)
 	return taggedClusters, nil
 }
 
-func deleteElasticacheCluster(svc elasticache.ElastiCache, cluster elasticacheCluster) error {
+func deleteElasticacheCluster(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, region string, cluster elasticacheCluster) error {
 	if cluster.ClusterStatus == "deleting" {
 		log.Infof("Elasticache cluster %s is already in deletion process, skipping...", cluster.ClusterIdentifier)
 		return nil
 	} else {
-		log.Infof("Deleting Elasticache cluster %s in %s, expired after %d seconds",
-			cluster.ClusterIdentifier, *svc.Config.Region, cluster.TTL)
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.ElasticacheCluster, region, cluster.ClusterIdentifier).Infof("Deleting Elasticache cluster %s in %s, expired after %d seconds",
+			cluster.ClusterIdentifier, region, cluster.TTL)
 	}
 
 	// with replicas
 	if cluster.ReplicationGroupId != "" {
-		_, err := svc.DeleteReplicationGroup(
+		_, err := svc.DeleteReplicationGroupWithContext(ctx,
 			&elasticache.DeleteReplicationGroupInput{
 				ReplicationGroupId:      aws.String(cluster.ReplicationGroupId),
 				RetainPrimaryCluster:    aws.Bool(false),
@@ -91,7 +111,7 @@ func deleteElasticacheCluster(svc elasticache.ElastiCache, cluster elasticacheCl
 		}
 	}
 
-	_, err := svc.DeleteCacheCluster(
+	_, err := svc.DeleteCacheClusterWithContext(ctx,
 		&elasticache.DeleteCacheClusterInput{
 			CacheClusterId: aws.String(cluster.ClusterIdentifier),
 		},
@@ -99,41 +119,49 @@ func deleteElasticacheCluster(svc elasticache.ElastiCache, cluster elasticacheCl
 	if err != nil {
 		return err
 	}
+	utils.ReportDeleted(ctx, resources.ElasticacheCluster, region, cluster.ClusterIdentifier)
 
 	return nil
 }
 
-func DeleteExpiredElasticacheDatabases(svc elasticache.ElastiCache, tagName string, dryRun bool) {
-	clusters, err := listTaggedElasticacheDatabases(svc, tagName)
-	region := svc.Config.Region
+func DeleteExpiredElasticacheDatabases(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, region string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	clusters, err := listTaggedElasticacheDatabases(ctx, svc, taggedResources, tagName)
 	if err != nil {
-		log.Errorf("can't list Elasticache databases: %s\n", err)
-		return
+		return fmt.Errorf("can't list Elasticache databases: %s", err)
 	}
 
 	var expiredClusters []elasticacheCluster
 	for _, cluster := range clusters {
-		if utils.CheckIfExpired(cluster.ClusterCreateTime, cluster.TTL) && !cluster.IsProtected{
+		if utils.CheckIfDeletable(ctx, cluster.ClusterCreateTime, cluster.TTL, cluster.ExpirationDate, cluster.DeletionScheduled, cluster.IsProtected, resources.ElasticacheCluster, region, cluster.ClusterIdentifier){
+			utils.SetReportTags(ctx, resources.ElasticacheCluster, region, cluster.ClusterIdentifier, cluster.Tags)
 			expiredClusters = append(expiredClusters, cluster)
+			pricing.ReportCost(ctx, resources.ElasticacheCluster, region, cluster.ClusterIdentifier, pricing.ElasticacheNodes(cluster.CacheNodeType, cluster.Engine, cluster.NumCacheNodes))
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired Elasticache database", len(expiredClusters), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired Elasticache database", len(expiredClusters), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredClusters) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.ElasticacheCluster, region, len(expiredClusters))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
-	for _, cluster := range clusters {
-		deletionErr := deleteElasticacheCluster(svc, cluster)
+	for _, cluster := range expiredClusters {
+		deletionErr := deleteElasticacheCluster(ctx, svc, region, cluster)
 		if deletionErr != nil {
-			log.Errorf("Deletion Elasticache cluster error %s/%s: %s",
-					cluster.ClusterIdentifier, *svc.Config.Region, err)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.ElasticacheCluster, region, cluster.ClusterIdentifier).Errorf("Deletion Elasticache cluster error %s/%s: %s",
+					cluster.ClusterIdentifier, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.ElasticacheCluster, region, cluster.ClusterIdentifier, deletionErr)
 			}
 	}
 
+	return nil
 }
\ No newline at end of file
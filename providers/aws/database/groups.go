@@ -0,0 +1,335 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// dbGroup is a subnet group or a parameter group of RDS, DocumentDB or Elasticache databases
+type dbGroup struct {
+	Name              string
+	Arn               string
+	CreationDate      time.Time
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+// usedDBGroups are the groups of the existing databases, they can't be deleted before them
+type usedDBGroups struct {
+	subnetGroups           map[string]bool
+	parameterGroups        map[string]bool
+	clusterParameterGroups map[string]bool
+}
+
+// isDefaultGroup returns true for the groups created by AWS, they can't be deleted
+func isDefaultGroup(name string) bool {
+	return name == "default" || strings.HasPrefix(name, "default.")
+}
+
+// getUsedRDSGroups returns the groups of the RDS and DocumentDB instances and clusters of the region, deleting ones
+// included
+func getUsedRDSGroups(ctx context.Context, svc rdsiface.RDSAPI) (usedDBGroups, error) {
+	used := usedDBGroups{
+		subnetGroups:           make(map[string]bool),
+		parameterGroups:        make(map[string]bool),
+		clusterParameterGroups: make(map[string]bool),
+	}
+
+	err := svc.DescribeDBInstancesPagesWithContext(ctx, &rds.DescribeDBInstancesInput{},
+		func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+			for _, instance := range page.DBInstances {
+				if instance.DBSubnetGroup != nil {
+					used.subnetGroups[aws.StringValue(instance.DBSubnetGroup.DBSubnetGroupName)] = true
+				}
+				for _, parameterGroup := range instance.DBParameterGroups {
+					used.parameterGroups[aws.StringValue(parameterGroup.DBParameterGroupName)] = true
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return used, fmt.Errorf("can't list RDS databases: %s", err)
+	}
+
+	err = svc.DescribeDBClustersPagesWithContext(ctx, &rds.DescribeDBClustersInput{},
+		func(page *rds.DescribeDBClustersOutput, lastPage bool) bool {
+			for _, cluster := range page.DBClusters {
+				used.subnetGroups[aws.StringValue(cluster.DBSubnetGroup)] = true
+				used.clusterParameterGroups[aws.StringValue(cluster.DBClusterParameterGroup)] = true
+			}
+			return true
+		})
+	if err != nil {
+		return used, fmt.Errorf("can't list RDS clusters: %s", err)
+	}
+
+	return used, nil
+}
+
+// getUsedElasticacheGroups returns the groups of the Elasticache clusters of the region, deleting ones included
+func getUsedElasticacheGroups(ctx context.Context, svc elasticacheiface.ElastiCacheAPI) (usedDBGroups, error) {
+	used := usedDBGroups{
+		subnetGroups:    make(map[string]bool),
+		parameterGroups: make(map[string]bool),
+	}
+
+	err := svc.DescribeCacheClustersPagesWithContext(ctx, &elasticache.DescribeCacheClustersInput{},
+		func(page *elasticache.DescribeCacheClustersOutput, lastPage bool) bool {
+			for _, cluster := range page.CacheClusters {
+				used.subnetGroups[aws.StringValue(cluster.CacheSubnetGroupName)] = true
+				if cluster.CacheParameterGroup != nil {
+					used.parameterGroups[aws.StringValue(cluster.CacheParameterGroup.CacheParameterGroupName)] = true
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return used, fmt.Errorf("can't list Elasticache clusters: %s", err)
+	}
+
+	return used, nil
+}
+
+// newRDSGroup returns the group with its tags, setting its creationDate tag if it has a ttl but no creation date
+func newRDSGroup(ctx context.Context, svc rdsiface.RDSAPI, region string, name string, arn string, tagName string) dbGroup {
+	tags := getRDSSubnetGroupsTags(ctx, svc, region, arn)
+	creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, tags, tagName)
+	utils.AddMissingCreationDateTag(ctx, svc, region, arn, creationDate, ttl, tagName)
+
+	return dbGroup{
+		Name:              name,
+		Arn:               arn,
+		CreationDate:      creationDate,
+		TTL:               ttl,
+		ExpirationDate:    expirationDate,
+		DeletionScheduled: deletionScheduled,
+		IsProtected:       isProtected,
+		Tags:              utils.TagsToMap(tags),
+	}
+}
+
+func listUnusedRDSParameterGroups(ctx context.Context, svc rdsiface.RDSAPI, region string, used usedDBGroups, tagName string) ([]dbGroup, error) {
+	var groups []dbGroup
+	err := svc.DescribeDBParameterGroupsPagesWithContext(ctx, &rds.DescribeDBParameterGroupsInput{},
+		func(page *rds.DescribeDBParameterGroupsOutput, lastPage bool) bool {
+			for _, group := range page.DBParameterGroups {
+				name := aws.StringValue(group.DBParameterGroupName)
+				if isDefaultGroup(name) || used.parameterGroups[name] {
+					continue
+				}
+				groups = append(groups, dbGroup{Name: name, Arn: aws.StringValue(group.DBParameterGroupArn)})
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, group := range groups {
+		groups[i] = newRDSGroup(ctx, svc, region, group.Name, group.Arn, tagName)
+	}
+
+	return groups, nil
+}
+
+func listUnusedRDSClusterParameterGroups(ctx context.Context, svc rdsiface.RDSAPI, region string, used usedDBGroups, tagName string) ([]dbGroup, error) {
+	var groups []dbGroup
+	err := svc.DescribeDBClusterParameterGroupsPagesWithContext(ctx, &rds.DescribeDBClusterParameterGroupsInput{},
+		func(page *rds.DescribeDBClusterParameterGroupsOutput, lastPage bool) bool {
+			for _, group := range page.DBClusterParameterGroups {
+				name := aws.StringValue(group.DBClusterParameterGroupName)
+				if isDefaultGroup(name) || used.clusterParameterGroups[name] {
+					continue
+				}
+				groups = append(groups, dbGroup{Name: name, Arn: aws.StringValue(group.DBClusterParameterGroupArn)})
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, group := range groups {
+		groups[i] = newRDSGroup(ctx, svc, region, group.Name, group.Arn, tagName)
+	}
+
+	return groups, nil
+}
+
+// newElasticacheGroup returns the group with the tags found by the tagging API, setting its creationDate tag if it has
+// a ttl but no creation date. Groups unknown to the tagging API don't carry the tag.
+func newElasticacheGroup(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, region string, name string, arn string, taggedResources tagging.TaggedResources, tagName string) (dbGroup, bool) {
+	resource, isTagged := taggedResources.Get(arn)
+	if !isTagged {
+		return dbGroup{}, false
+	}
+
+	creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
+	utils.AddMissingCreationDateTag(ctx, svc, region, arn, creationDate, ttl, tagName)
+
+	return dbGroup{
+		Name:              name,
+		Arn:               arn,
+		CreationDate:      creationDate,
+		TTL:               ttl,
+		ExpirationDate:    expirationDate,
+		DeletionScheduled: deletionScheduled,
+		IsProtected:       isProtected,
+		Tags:              utils.TagsToMap(resource.Tags),
+	}, true
+}
+
+func listUnusedElasticacheSubnetGroups(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, region string, used usedDBGroups, taggedResources tagging.TaggedResources, tagName string) ([]dbGroup, error) {
+	var groups []dbGroup
+	err := svc.DescribeCacheSubnetGroupsPagesWithContext(ctx, &elasticache.DescribeCacheSubnetGroupsInput{},
+		func(page *elasticache.DescribeCacheSubnetGroupsOutput, lastPage bool) bool {
+			for _, group := range page.CacheSubnetGroups {
+				name := aws.StringValue(group.CacheSubnetGroupName)
+				if isDefaultGroup(name) || used.subnetGroups[name] {
+					continue
+				}
+				if taggedGroup, isTagged := newElasticacheGroup(ctx, svc, region, name, aws.StringValue(group.ARN), taggedResources, tagName); isTagged {
+					groups = append(groups, taggedGroup)
+				}
+			}
+			return true
+		})
+
+	return groups, err
+}
+
+func listUnusedElasticacheParameterGroups(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, region string, used usedDBGroups, taggedResources tagging.TaggedResources, tagName string) ([]dbGroup, error) {
+	var groups []dbGroup
+	err := svc.DescribeCacheParameterGroupsPagesWithContext(ctx, &elasticache.DescribeCacheParameterGroupsInput{},
+		func(page *elasticache.DescribeCacheParameterGroupsOutput, lastPage bool) bool {
+			for _, group := range page.CacheParameterGroups {
+				name := aws.StringValue(group.CacheParameterGroupName)
+				// global datastores own their parameter groups
+				if isDefaultGroup(name) || aws.BoolValue(group.IsGlobal) || used.parameterGroups[name] {
+					continue
+				}
+				if taggedGroup, isTagged := newElasticacheGroup(ctx, svc, region, name, aws.StringValue(group.ARN), taggedResources, tagName); isTagged {
+					groups = append(groups, taggedGroup)
+				}
+			}
+			return true
+		})
+
+	return groups, err
+}
+
+// deleteExpiredGroups deletes the expired groups of a type, or only reports them in dry run
+func deleteExpiredGroups(ctx context.Context, region string, resourceType string, groups []dbGroup, deleteGroup func(ctx context.Context, name string) error, dryRun bool) error {
+	var expiredGroups []dbGroup
+	for _, group := range groups {
+		if utils.CheckIfDeletable(ctx, group.CreationDate, group.TTL, group.ExpirationDate, group.DeletionScheduled, group.IsProtected, resourceType, region, group.Name, group.Arn) {
+			utils.SetReportTags(ctx, resourceType, region, group.Name, group.Tags)
+			expiredGroups = append(expiredGroups, group)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired "+resourceType, len(expiredGroups), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredGroups) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resourceType, region, len(expiredGroups))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, group := range expiredGroups {
+		utils.ResourceLog(ctx, utils.ActionDelete, resourceType, region, group.Name).Infof("Deleting %s %s in %s.", resourceType, group.Name, region)
+		deletionErr := deleteGroup(ctx, group.Name)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resourceType, region, group.Name).Errorf("Deletion %s error %s/%s: %s",
+				resourceType, group.Name, region, deletionErr)
+			utils.ReportDeletionError(ctx, resourceType, region, group.Name, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resourceType, region, group.Name)
+	}
+
+	return nil
+}
+
+// DeleteExpiredRDSParameterGroups deletes the expired parameter groups and cluster parameter groups of RDS and
+// DocumentDB no database uses anymore, the ones of deleting databases are deleted by the next checks
+func DeleteExpiredRDSParameterGroups(ctx context.Context, svc rdsiface.RDSAPI, region string, tagName string, dryRun bool) error {
+	used, err := getUsedRDSGroups(ctx, svc)
+	if err != nil {
+		return err
+	}
+
+	parameterGroups, err := listUnusedRDSParameterGroups(ctx, svc, region, used, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list RDS parameter groups: %s", err)
+	}
+	parameterGroupsErr := deleteExpiredGroups(ctx, region, resources.RDSParameterGroup, parameterGroups,
+		func(ctx context.Context, name string) error {
+			_, err := svc.DeleteDBParameterGroupWithContext(ctx, &rds.DeleteDBParameterGroupInput{DBParameterGroupName: aws.String(name)})
+			return err
+		}, dryRun)
+
+	clusterParameterGroups, err := listUnusedRDSClusterParameterGroups(ctx, svc, region, used, tagName)
+	if err != nil {
+		return utils.JoinErrors(parameterGroupsErr, fmt.Errorf("can't list RDS cluster parameter groups: %s", err))
+	}
+	clusterParameterGroupsErr := deleteExpiredGroups(ctx, region, resources.RDSClusterParameterGroup, clusterParameterGroups,
+		func(ctx context.Context, name string) error {
+			_, err := svc.DeleteDBClusterParameterGroupWithContext(ctx, &rds.DeleteDBClusterParameterGroupInput{DBClusterParameterGroupName: aws.String(name)})
+			return err
+		}, dryRun)
+
+	return utils.JoinErrors(parameterGroupsErr, clusterParameterGroupsErr)
+}
+
+// DeleteExpiredElasticacheGroups deletes the expired subnet groups and parameter groups no Elasticache cluster uses
+// anymore, the ones of deleting clusters are deleted by the next checks
+func DeleteExpiredElasticacheGroups(ctx context.Context, svc elasticacheiface.ElastiCacheAPI, region string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	used, err := getUsedElasticacheGroups(ctx, svc)
+	if err != nil {
+		return err
+	}
+
+	subnetGroups, err := listUnusedElasticacheSubnetGroups(ctx, svc, region, used, taggedResources, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list Elasticache subnet groups: %s", err)
+	}
+	subnetGroupsErr := deleteExpiredGroups(ctx, region, resources.ElasticacheSubnetGroup, subnetGroups,
+		func(ctx context.Context, name string) error {
+			_, err := svc.DeleteCacheSubnetGroupWithContext(ctx, &elasticache.DeleteCacheSubnetGroupInput{CacheSubnetGroupName: aws.String(name)})
+			return err
+		}, dryRun)
+
+	parameterGroups, err := listUnusedElasticacheParameterGroups(ctx, svc, region, used, taggedResources, tagName)
+	if err != nil {
+		return utils.JoinErrors(subnetGroupsErr, fmt.Errorf("can't list Elasticache parameter groups: %s", err))
+	}
+	parameterGroupsErr := deleteExpiredGroups(ctx, region, resources.ElasticacheParameterGroup, parameterGroups,
+		func(ctx context.Context, name string) error {
+			_, err := svc.DeleteCacheParameterGroupWithContext(ctx, &elasticache.DeleteCacheParameterGroupInput{CacheParameterGroupName: aws.String(name)})
+			return err
+		}, dryRun)
+
+	return utils.JoinErrors(subnetGroupsErr, parameterGroupsErr)
+}
@@ -1,11 +1,15 @@
 package database
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/pricing"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 	"strings"
 	"time"
@@ -13,39 +17,59 @@ import (
 
 type rdsDatabase struct {
 	DBInstanceIdentifier string
+	// DBClusterIdentifier is set for the members of a cluster, they have no final snapshot of their own
+	DBClusterIdentifier  string
+	DBInstanceClass      string
+	Engine               string
+	MultiAZ              bool
 	InstanceCreateTime   time.Time
 	DBInstanceStatus     string
 	TTL                  int64
+	ExpirationDate       time.Time
+	DeletionScheduled    time.Time
 	IsProtected          bool
+	Tags                 map[string]string
 }
 
 func RdsSession(sess session.Session, region string) *rds.RDS {
 	return rds.New(&sess, &aws.Config{Region: aws.String(region)})
 }
 
-func listTaggedRDSDatabases(svc rds.RDS, tagName string) ([]rdsDatabase, error) {
+func listTaggedRDSDatabases(ctx context.Context, svc rdsiface.RDSAPI, tagName string) ([]rdsDatabase, error) {
 	var taggedDatabases []rdsDatabase
 
 	// unfortunately AWS doesn't support tag filtering for RDS
-	result, err := svc.DescribeDBInstances(nil)
+	var instances []*rds.DBInstance
+	err := svc.DescribeDBInstancesPagesWithContext(ctx, &rds.DescribeDBInstancesInput{},
+		func(page *rds.DescribeDBInstancesOutput, lastPage bool) bool {
+			instances = append(instances, page.DBInstances...)
+			return true
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result.DBInstances) == 0 {
+	if len(instances) == 0 {
 		return []rdsDatabase{}, nil
 	}
 
-	for _, instance := range result.DBInstances {
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(instance.TagList,tagName)
+	for _, instance := range instances {
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, instance.TagList,tagName)
 
 		if instance.InstanceCreateTime != nil {
 			taggedDatabases = append(taggedDatabases, rdsDatabase{
 				DBInstanceIdentifier: *instance.DBInstanceIdentifier,
+				DBClusterIdentifier:  aws.StringValue(instance.DBClusterIdentifier),
+				DBInstanceClass:      aws.StringValue(instance.DBInstanceClass),
+				Engine:               aws.StringValue(instance.Engine),
+				MultiAZ:              aws.BoolValue(instance.MultiAZ),
 				InstanceCreateTime:   *instance.InstanceCreateTime,
 				DBInstanceStatus:     *instance.DBInstanceStatus,
 				TTL:                  int64(ttl),
+				ExpirationDate: expirationDate,
+				DeletionScheduled: deletionScheduled,
 				IsProtected: isProtected,
+				Tags: utils.TagsToMap(instance.TagList),
 			})
 		}
 	}
@@ -53,36 +77,59 @@ func listTaggedRDSDatabases(svc rds.RDS, tagName string) ([]rdsDatabase, error)
 	return taggedDatabases, nil
 }
 
-func DeleteRDSDatabase(svc rds.RDS, database rdsDatabase) error {
+// DeleteRDSDatabase deletes a database, with a final snapshot unless its identifier is empty
+func DeleteRDSDatabase(ctx context.Context, svc rdsiface.RDSAPI, region string, database rdsDatabase, finalSnapshotIdentifier string) error {
 	if database.DBInstanceStatus == "deleting" {
 		log.Infof("RDS instance %s is already in deletion process, skipping...", database.DBInstanceIdentifier)
 		return nil
 	} else {
-		log.Infof("Deleting RDS database %s in %s, expired after %d seconds",
-			database.DBInstanceIdentifier, *svc.Config.Region, database.TTL)
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.RDSDatabase, region, database.DBInstanceIdentifier).Infof("Deleting RDS database %s in %s, expired after %d seconds",
+			database.DBInstanceIdentifier, region, database.TTL)
 	}
 
 
-	_, err := svc.DeleteDBInstance(
-		&rds.DeleteDBInstanceInput{
-			DBInstanceIdentifier:      aws.String(database.DBInstanceIdentifier),
-			DeleteAutomatedBackups:    aws.Bool(true),
-			SkipFinalSnapshot:         aws.Bool(true),
-		},
-	)
+	input := &rds.DeleteDBInstanceInput{
+		DBInstanceIdentifier:      aws.String(database.DBInstanceIdentifier),
+		DeleteAutomatedBackups:    aws.Bool(true),
+		SkipFinalSnapshot:         aws.Bool(true),
+	}
+	if finalSnapshotIdentifier != "" {
+		log.Infof("Taking final snapshot %s of RDS database %s in %s.", finalSnapshotIdentifier, database.DBInstanceIdentifier, region)
+		input.SkipFinalSnapshot = aws.Bool(false)
+		input.FinalDBSnapshotIdentifier = aws.String(finalSnapshotIdentifier)
+	}
+
+	_, err := svc.DeleteDBInstanceWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
+	utils.ReportDeleted(ctx, resources.RDSDatabase, region, database.DBInstanceIdentifier)
 
 	return nil
 }
 
-func GetRDSInstanceInfos(svc rds.RDS, databaseIdentifier string) (rdsDatabase, error) {
+// stopRDSDatabase stops a running database to quarantine it, members of a cluster can't be stopped on their own
+func stopRDSDatabase(ctx context.Context, svc rdsiface.RDSAPI, region string, database rdsDatabase) error {
+	if database.DBInstanceStatus != "available" {
+		return nil
+	}
+	if database.DBClusterIdentifier != "" {
+		return fmt.Errorf("member of cluster %s", database.DBClusterIdentifier)
+	}
+
+	utils.ResourceLog(ctx, utils.ActionQuarantine, resources.RDSDatabase, region, database.DBInstanceIdentifier).Infof("Stopping RDS database %s in %s until its deletion.",
+		database.DBInstanceIdentifier, region)
+	_, err := svc.StopDBInstanceWithContext(ctx, &rds.StopDBInstanceInput{DBInstanceIdentifier: aws.String(database.DBInstanceIdentifier)})
+
+	return err
+}
+
+func GetRDSInstanceInfos(ctx context.Context, svc rdsiface.RDSAPI, databaseIdentifier string) (rdsDatabase, error) {
 	input := rds.DescribeDBInstancesInput{
 		DBInstanceIdentifier: aws.String(databaseIdentifier),
 	}
 
-	result, err := svc.DescribeDBInstances(&input)
+	result, err := svc.DescribeDBInstancesWithContext(ctx, &input)
 	// ignore if creation is in progress to avoid nil fields
 	if err != nil || *result.DBInstances[0].DBInstanceStatus == "creating" {
 		return rdsDatabase{
@@ -101,61 +148,88 @@ func GetRDSInstanceInfos(svc rds.RDS, databaseIdentifier string) (rdsDatabase, e
 	}, nil
 }
 
-func DeleteExpiredRDSDatabases(svc rds.RDS, tagName string, dryRun bool) {
-	databases, err := listTaggedRDSDatabases(svc, tagName)
-	region := svc.Config.Region
+func DeleteExpiredRDSDatabases(ctx context.Context, svc rdsiface.RDSAPI, region string, tagName string, finalSnapshotTTL int64, dryRun bool) error {
+	databases, err := listTaggedRDSDatabases(ctx, svc, tagName)
 	if err != nil {
-		log.Errorf("can't list RDS databases: %s\n", err)
-		return
+		return fmt.Errorf("can't list RDS databases: %s", err)
 	}
 
+	ctx = utils.WithQuarantiner(ctx, resources.RDSDatabase, func(ctx context.Context, identifiers []string) error {
+		for _, database := range databases {
+			if database.DBInstanceIdentifier == identifiers[0] {
+				return stopRDSDatabase(ctx, svc, region, database)
+			}
+		}
+		return nil
+	})
+
 	var expiredDatabases []rdsDatabase
 	for _, database := range databases {
-		if utils.CheckIfExpired(database.InstanceCreateTime, database.TTL) && !database.IsProtected {
+		if utils.CheckIfDeletable(ctx, database.InstanceCreateTime, database.TTL, database.ExpirationDate, database.DeletionScheduled, database.IsProtected, resources.RDSDatabase, region, database.DBInstanceIdentifier) {
+			utils.SetReportTags(ctx, resources.RDSDatabase, region, database.DBInstanceIdentifier, database.Tags)
 			expiredDatabases = append(expiredDatabases, database)
+			pricing.ReportCost(ctx, resources.RDSDatabase, region, database.DBInstanceIdentifier, pricing.RDSInstance(database.DBInstanceClass, database.Engine, database.MultiAZ))
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired RDS database", len(expiredDatabases), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired RDS database", len(expiredDatabases), region)
 
 	log.Debug(count)
 
-	if dryRun || len(expiredDatabases) == 0 || expiredDatabases != nil{
-		return
+	if dryRun || len(expiredDatabases) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.RDSDatabase, region, len(expiredDatabases))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
 	for _, database := range expiredDatabases {
-		deletionErr := DeleteRDSDatabase(svc, database)
+		finalSnapshotIdentifier := ""
+		if database.DBClusterIdentifier == "" {
+			finalSnapshotIdentifier = getFinalSnapshotIdentifier(database.DBInstanceIdentifier, finalSnapshotTTL)
+		}
+
+		deletionErr := DeleteRDSDatabase(ctx, svc, region, database, finalSnapshotIdentifier)
 			if deletionErr != nil {
-				log.Errorf("Deletion RDS database error %s/%s: %s",
-					database.DBInstanceIdentifier, *svc.Config.Region, err)
+				utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.RDSDatabase, region, database.DBInstanceIdentifier).Errorf("Deletion RDS database error %s/%s: %s",
+					database.DBInstanceIdentifier, region, deletionErr)
+				utils.ReportDeletionError(ctx, resources.RDSDatabase, region, database.DBInstanceIdentifier, deletionErr)
 			}
 	}
+
+	return nil
 }
 
-func AddCreationDateTagToRdsSubnetGroups(svc rds.RDS, vpcIds []*string, creationDate time.Time, ttl int64) error {
-	RDSIds := getRDSIdsByVpcIds(svc, vpcIds)
+func AddCreationDateTagToRdsSubnetGroups(ctx context.Context, svc rdsiface.RDSAPI, region string, vpcIds []*string, creationDate time.Time, ttl int64, tagName string) error {
+	RDSIds := getRDSIdsByVpcIds(ctx, svc, region, vpcIds)
 
-	return utils.AddCreationDateTag(svc, RDSIds, creationDate, ttl)
+	return utils.AddCreationDateTag(ctx, svc, region, RDSIds, creationDate, ttl, tagName)
 }
 
-func getRDSSubnetGroups(svc rds.RDS) []*rds.DBSubnetGroup {
-	result, err := svc.DescribeDBSubnetGroups(
+func getRDSSubnetGroups(ctx context.Context, svc rdsiface.RDSAPI, region string) []*rds.DBSubnetGroup {
+	var subnetGroups []*rds.DBSubnetGroup
+	err := svc.DescribeDBSubnetGroupsPagesWithContext(ctx,
 		&rds.DescribeDBSubnetGroupsInput{
 			MaxRecords: aws.Int64(100),
+		},
+		func(page *rds.DescribeDBSubnetGroupsOutput, lastPage bool) bool {
+			subnetGroups = append(subnetGroups, page.DBSubnetGroups...)
+			return true
 		})
 
 	if err != nil {
-		log.Errorf("Can't get DocumentDB subnet groups in region %s: %s", *svc.Config.Region, err.Error())
+		log.Errorf("Can't get DocumentDB subnet groups in region %s: %s", region, err.Error())
 	}
 
-	return result.DBSubnetGroups
+	return subnetGroups
 }
 
-func getRDSIdsByVpcIds(svc rds.RDS, VpcIds []*string) []*string {
-	RDSSubnetGroups := getRDSSubnetGroups(svc)
+func getRDSIdsByVpcIds(ctx context.Context, svc rdsiface.RDSAPI, region string, VpcIds []*string) []*string {
+	RDSSubnetGroups := getRDSSubnetGroups(ctx, svc, region)
 
 	var RDSIds []*string
 
@@ -170,29 +244,36 @@ func getRDSIdsByVpcIds(svc rds.RDS, VpcIds []*string) []*string {
 	return RDSIds
 }
 
-func getRDSSubnetGroupsTags(svc rds.RDS, dbSubnetGroupArn string) []*rds.Tag {
-	result, err := svc.ListTagsForResource(
+func getRDSSubnetGroupsTags(ctx context.Context, svc rdsiface.RDSAPI, region string, dbSubnetGroupArn string) []*rds.Tag {
+	result, err := svc.ListTagsForResourceWithContext(ctx,
 		&rds.ListTagsForResourceInput{
 			ResourceName: aws.String(dbSubnetGroupArn),
 		})
 
 	if err != nil {
-		log.Errorf("Can't get tags for %s in region %s: %s", dbSubnetGroupArn, *svc.Config.Region, err.Error())
+		log.Errorf("Can't get tags for %s in region %s: %s", dbSubnetGroupArn, region, err.Error())
 		return []*rds.Tag{}
 	}
 
 	return result.TagList
 }
 
-func getExpiredRDSSubnetGroups(svc rds.RDS, tagName string) []*rds.DBSubnetGroup {
-	RDSSubnetGroups := getRDSSubnetGroups(svc)
+func getExpiredRDSSubnetGroups(ctx context.Context, svc rdsiface.RDSAPI, region string, used usedDBGroups, tagName string) []*rds.DBSubnetGroup {
+	RDSSubnetGroups := getRDSSubnetGroups(ctx, svc, region)
 	var expiredRDSSubnetGroups []*rds.DBSubnetGroup
 
 	for _, RDSSubnetGroup := range RDSSubnetGroups {
-		tags := getRDSSubnetGroupsTags(svc, *RDSSubnetGroup.DBSubnetGroupArn)
-		creationDate, ttl, isProtected, _, _ := utils.GetEssentialTags(tags, tagName)
+		// subnet groups can't be deleted before their databases
+		if isDefaultGroup(*RDSSubnetGroup.DBSubnetGroupName) || used.subnetGroups[*RDSSubnetGroup.DBSubnetGroupName] {
+			continue
+		}
 
-		if utils.CheckIfExpired(creationDate,ttl) && !isProtected {
+		tags := getRDSSubnetGroupsTags(ctx, svc, region, *RDSSubnetGroup.DBSubnetGroupArn)
+		creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, tags, tagName)
+		utils.AddMissingCreationDateTag(ctx, svc, region, *RDSSubnetGroup.DBSubnetGroupArn, creationDate, ttl, tagName)
+
+		if utils.CheckIfDeletable(ctx, creationDate, ttl, expirationDate, deletionScheduled, isProtected, resources.RDSSubnetGroup, region, *RDSSubnetGroup.DBSubnetGroupName, *RDSSubnetGroup.DBSubnetGroupArn) {
+			utils.SetReportTags(ctx, resources.RDSSubnetGroup, region, *RDSSubnetGroup.DBSubnetGroupName, utils.TagsToMap(tags))
 			expiredRDSSubnetGroups = append(expiredRDSSubnetGroups, RDSSubnetGroup)
 		}
 	}
@@ -200,36 +281,53 @@ func getExpiredRDSSubnetGroups(svc rds.RDS, tagName string) []*rds.DBSubnetGroup
 	return expiredRDSSubnetGroups
 }
 
-func deleteRDSSubnetGroup(svc rds.RDS, dbSubnetGroupName string) error {
-	_, err := svc.DeleteDBSubnetGroup(
+func deleteRDSSubnetGroup(ctx context.Context, svc rdsiface.RDSAPI, region string, dbSubnetGroupName string) error {
+	_, err := svc.DeleteDBSubnetGroupWithContext(ctx,
 		&rds.DeleteDBSubnetGroupInput{
 			DBSubnetGroupName: aws.String(dbSubnetGroupName),
 		})
 
 	if err != nil {
-		return fmt.Errorf("Can't delete DocumentDB %s in region %s: %s", dbSubnetGroupName, *svc.Config.Region, err.Error())
+		return fmt.Errorf("Can't delete DocumentDB %s in region %s: %s", dbSubnetGroupName, region, err.Error())
 	}
 
 	return nil
 }
 
-func DeleteExpiredRDSSubnetGroups(svc rds.RDS, tagName string ,dryRun bool) {
-	expiredRDSSubnetGroups :=  getExpiredRDSSubnetGroups(svc, tagName)
+// DeleteExpiredRDSSubnetGroups deletes the expired subnet groups of RDS and DocumentDB no database uses anymore, the ones
+// of deleting databases are deleted by the next checks
+func DeleteExpiredRDSSubnetGroups(ctx context.Context, svc rdsiface.RDSAPI, region string, tagName string ,dryRun bool) error {
+	used, err := getUsedRDSGroups(ctx, svc)
+	if err != nil {
+		return err
+	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired RDS subnet group", len(expiredRDSSubnetGroups), *svc.Config.Region)
+	expiredRDSSubnetGroups :=  getExpiredRDSSubnetGroups(ctx, svc, region, used, tagName)
+
+	count, start:= utils.ElemToDeleteFormattedInfos("expired RDS subnet group", len(expiredRDSSubnetGroups), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredRDSSubnetGroups) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.RDSSubnetGroup, region, len(expiredRDSSubnetGroups))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
 	for _, expiredRDSSubnetGroup := range expiredRDSSubnetGroups {
-		err := deleteRDSSubnetGroup(svc, *expiredRDSSubnetGroup.DBSubnetGroupName)
+		err := deleteRDSSubnetGroup(ctx, svc, region, *expiredRDSSubnetGroup.DBSubnetGroupName)
 		if err != nil {
-			log.Errorf("Deletion RDS subnet group error %s/%s: %s", *expiredRDSSubnetGroup.DBSubnetGroupName, *svc.Config.Region, err)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.RDSSubnetGroup, region, *expiredRDSSubnetGroup.DBSubnetGroupName).Errorf("Deletion RDS subnet group error %s/%s: %s", *expiredRDSSubnetGroup.DBSubnetGroupName, region, err)
+			utils.ReportDeletionError(ctx, resources.RDSSubnetGroup, region, *expiredRDSSubnetGroup.DBSubnetGroupName, err)
+			continue
 		}
+		utils.ReportDeleted(ctx, resources.RDSSubnetGroup, region, *expiredRDSSubnetGroup.DBSubnetGroupName)
 	}
+
+	return nil
 }
\ No newline at end of file
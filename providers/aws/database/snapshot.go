@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// FinalSnapshotTagName marks the final snapshots taken before deleting a database once their own ttl is set
+const FinalSnapshotTagName = "pleco-final-snapshot"
+
+// finalSnapshotPattern matches the identifiers of the final snapshots: the database identifier, -final- and the
+// deletion time
+var finalSnapshotPattern = regexp.MustCompile(`-final-\d{14}$`)
+
+// getFinalSnapshotIdentifier returns the identifier of the final snapshot of a database, empty if final snapshots are
+// skipped (no ttl)
+func getFinalSnapshotIdentifier(identifier string, finalSnapshotTTL int64) string {
+	if finalSnapshotTTL <= 0 {
+		return ""
+	}
+
+	return identifier + "-final-" + time.Now().UTC().Format("20060102150405")
+}
+
+// finalSnapshot is a final snapshot of a RDS database or of a DocumentDB cluster
+type finalSnapshot struct {
+	Identifier string
+	Arn        string
+	CreateTime time.Time
+	Status     string
+	Tags       []*rds.Tag
+}
+
+// isTagged returns true once the ttl of the snapshot replaced the tags copied from its database
+func (s finalSnapshot) isTagged() bool {
+	for _, tag := range s.Tags {
+		if aws.StringValue(tag.Key) == FinalSnapshotTagName {
+			return true
+		}
+	}
+
+	return false
+}
+
+func listFinalDBSnapshots(ctx context.Context, svc rdsiface.RDSAPI) ([]finalSnapshot, error) {
+	var snapshots []finalSnapshot
+
+	err := svc.DescribeDBSnapshotsPagesWithContext(ctx, &rds.DescribeDBSnapshotsInput{SnapshotType: aws.String("manual")},
+		func(page *rds.DescribeDBSnapshotsOutput, lastPage bool) bool {
+			for _, snapshot := range page.DBSnapshots {
+				if !finalSnapshotPattern.MatchString(aws.StringValue(snapshot.DBSnapshotIdentifier)) {
+					continue
+				}
+
+				snapshots = append(snapshots, finalSnapshot{
+					Identifier: aws.StringValue(snapshot.DBSnapshotIdentifier),
+					Arn:        aws.StringValue(snapshot.DBSnapshotArn),
+					CreateTime: aws.TimeValue(snapshot.SnapshotCreateTime),
+					Status:     aws.StringValue(snapshot.Status),
+					Tags:       snapshot.TagList,
+				})
+			}
+			return true
+		})
+
+	return snapshots, err
+}
+
+func listFinalDBClusterSnapshots(ctx context.Context, svc rdsiface.RDSAPI) ([]finalSnapshot, error) {
+	var snapshots []finalSnapshot
+
+	err := svc.DescribeDBClusterSnapshotsPagesWithContext(ctx, &rds.DescribeDBClusterSnapshotsInput{SnapshotType: aws.String("manual")},
+		func(page *rds.DescribeDBClusterSnapshotsOutput, lastPage bool) bool {
+			for _, snapshot := range page.DBClusterSnapshots {
+				if !finalSnapshotPattern.MatchString(aws.StringValue(snapshot.DBClusterSnapshotIdentifier)) {
+					continue
+				}
+
+				snapshots = append(snapshots, finalSnapshot{
+					Identifier: aws.StringValue(snapshot.DBClusterSnapshotIdentifier),
+					Arn:        aws.StringValue(snapshot.DBClusterSnapshotArn),
+					CreateTime: aws.TimeValue(snapshot.SnapshotCreateTime),
+					Status:     aws.StringValue(snapshot.Status),
+					Tags:       snapshot.TagList,
+				})
+			}
+			return true
+		})
+
+	return snapshots, err
+}
+
+// setFinalSnapshotTags replaces the expiration tags copied from the database by the ttl of the final snapshots, so
+// they don't expire with it
+func setFinalSnapshotTags(ctx context.Context, svc rdsiface.RDSAPI, snapshot finalSnapshot, tagName string, finalSnapshotTTL int64) error {
+	_, err := svc.RemoveTagsFromResourceWithContext(ctx,
+		&rds.RemoveTagsFromResourceInput{
+			ResourceName: aws.String(snapshot.Arn),
+			TagKeys:      aws.StringSlice([]string{utils.CreationDateTagName, utils.ExpirationDateTagName, utils.DeletionScheduledTagName}),
+		})
+	if err != nil {
+		return err
+	}
+
+	_, err = svc.AddTagsToResourceWithContext(ctx,
+		&rds.AddTagsToResourceInput{
+			ResourceName: aws.String(snapshot.Arn),
+			Tags: []*rds.Tag{
+				{Key: aws.String(tagName), Value: aws.String(strconv.FormatInt(finalSnapshotTTL, 10))},
+				{Key: aws.String(FinalSnapshotTagName), Value: aws.String("true")},
+			},
+		})
+
+	return err
+}
+
+func deleteFinalSnapshot(ctx context.Context, svc rdsiface.RDSAPI, snapshot finalSnapshot, isCluster bool) error {
+	var err error
+	if isCluster {
+		_, err = svc.DeleteDBClusterSnapshotWithContext(ctx,
+			&rds.DeleteDBClusterSnapshotInput{DBClusterSnapshotIdentifier: aws.String(snapshot.Identifier)})
+	} else {
+		_, err = svc.DeleteDBSnapshotWithContext(ctx,
+			&rds.DeleteDBSnapshotInput{DBSnapshotIdentifier: aws.String(snapshot.Identifier)})
+	}
+
+	return err
+}
+
+// DeleteExpiredFinalSnapshots sets the ttl of the final snapshots taken since the last check, then deletes the expired
+// ones. Snapshots of DocumentDB (and other) clusters are checked with isCluster.
+func DeleteExpiredFinalSnapshots(ctx context.Context, svc rdsiface.RDSAPI, region string, tagName string, finalSnapshotTTL int64, isCluster bool, dryRun bool) error {
+	resourceType := resources.RDSSnapshot
+	listSnapshots := listFinalDBSnapshots
+	if isCluster {
+		resourceType = resources.DocumentDBClusterSnapshot
+		listSnapshots = listFinalDBClusterSnapshots
+	}
+
+	snapshots, err := listSnapshots(ctx, svc)
+	if err != nil {
+		return fmt.Errorf("can't list %ss: %s", resourceType, err)
+	}
+
+	var expiredSnapshots []finalSnapshot
+	for _, snapshot := range snapshots {
+		if !snapshot.isTagged() {
+			if dryRun || finalSnapshotTTL <= 0 {
+				log.Debugf("Final snapshot %s in %s has no ttl of its own yet, skipping.", snapshot.Identifier, region)
+				continue
+			}
+
+			err := setFinalSnapshotTags(ctx, svc, snapshot, tagName, finalSnapshotTTL)
+			if err != nil {
+				log.Errorf("Can't set the ttl of final snapshot %s in %s: %s", snapshot.Identifier, region, err)
+			}
+			continue
+		}
+
+		if snapshot.Status != "available" {
+			continue
+		}
+
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, snapshot.Tags, tagName)
+		if utils.CheckIfDeletable(ctx, snapshot.CreateTime, ttl, expirationDate, deletionScheduled, isProtected, resourceType, region, snapshot.Identifier, snapshot.Arn) {
+			utils.SetReportTags(ctx, resourceType, region, snapshot.Identifier, utils.TagsToMap(snapshot.Tags))
+			expiredSnapshots = append(expiredSnapshots, snapshot)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired "+resourceType, len(expiredSnapshots), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredSnapshots) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resourceType, region, len(expiredSnapshots))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, snapshot := range expiredSnapshots {
+		utils.ResourceLog(ctx, utils.ActionDelete, resourceType, region, snapshot.Identifier).Infof("Deleting %s %s in %s.", resourceType, snapshot.Identifier, region)
+		deletionErr := deleteFinalSnapshot(ctx, svc, snapshot, isCluster)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resourceType, region, snapshot.Identifier).Errorf("Deletion %s error %s/%s: %s",
+				resourceType, snapshot.Identifier, region, deletionErr)
+			utils.ReportDeletionError(ctx, resourceType, region, snapshot.Identifier, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resourceType, region, snapshot.Identifier)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	ec22 "github.com/Qovery/pleco/providers/aws/ec2"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"github.com/sirupsen/logrus"
+	"sort"
+)
+
+// ListClusterResources returns the ARNs of the EKS cluster and of the resources of the region tagged with its name, by
+// the cluster tag key (ex: its VPCs) or by the kubernetes.io/cluster/<name> tag (ex: its volumes and security groups),
+// and of its load balancers. The tagging API only knows the resources that were tagged at least once.
+func ListClusterResources(ctx context.Context, sess *session.Session, region string, clusterName string, clusterTagKey string, clusterTags utils.ClusterTagPatterns) ([]string, error) {
+	taggingSession := resourcegroupstaggingapi.New(sess)
+	arns := make(map[string]bool)
+
+	cluster, err := eks.New(sess).DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err == nil {
+		arns[aws.StringValue(cluster.Cluster.Arn)] = true
+	} else if aerr, ok := err.(awserr.Error); !ok || aerr.Code() != eks.ErrCodeResourceNotFoundException {
+		return nil, fmt.Errorf("can't get EKS cluster %s in %s: %s", clusterName, region, err)
+	}
+
+	filters := []*resourcegroupstaggingapi.TagFilter{
+		{Key: aws.String(clusterTagKey), Values: aws.StringSlice([]string{clusterName})},
+		{Key: aws.String("kubernetes.io/cluster/" + clusterName)},
+	}
+	// tag filters of a request are all matched, each one has its own requests
+	for _, filter := range filters {
+		err := listTaggedArns(ctx, taggingSession, filter, arns)
+		if err != nil {
+			return nil, fmt.Errorf("can't list the resources of cluster %s in %s: %s", clusterName, region, err)
+		}
+	}
+
+	lbMatcher, err := clusterTags.ForCluster(clusterName)
+	if err != nil {
+		return nil, err
+	}
+	loadBalancers, err := ec22.ListTaggedLoadBalancersMatching(ctx, elbv2.New(sess), region, lbMatcher)
+	if err != nil {
+		return nil, err
+	}
+	for _, lb := range loadBalancers {
+		arns[lb.Arn] = true
+	}
+
+	var clusterArns []string
+	for resourceArn := range arns {
+		clusterArns = append(clusterArns, resourceArn)
+	}
+	sort.Strings(clusterArns)
+
+	return clusterArns, nil
+}
+
+func listTaggedArns(ctx context.Context, svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, filter *resourcegroupstaggingapi.TagFilter, arns map[string]bool) error {
+	return svc.GetResourcesPagesWithContext(ctx,
+		&resourcegroupstaggingapi.GetResourcesInput{TagFilters: []*resourcegroupstaggingapi.TagFilter{filter}},
+		func(page *resourcegroupstaggingapi.GetResourcesOutput, lastPage bool) bool {
+			for _, resource := range page.ResourceTagMappingList {
+				arns[aws.StringValue(resource.ResourceARN)] = true
+			}
+			return true
+		})
+}
+
+// TargetClusterResources sets the ttl tag of the resources of a cluster listed by ListClusterResources, so the cleaners
+// list them, and adds their ARNs and names to the targets of the check destroying them
+func TargetClusterResources(ctx context.Context, sess *session.Session, region string, clusterName string, arns []string, tagName string, targets *utils.Targets) error {
+	err := tagArnsTTL(ctx, resourcegroupstaggingapi.New(sess), arns, 1, tagName)
+	if err != nil {
+		return err
+	}
+
+	// the control plane logs of the cluster are tagged once it's deleted
+	targets.Add(clusterName, "/aws/eks/"+clusterName+"/cluster")
+	for _, resourceArn := range arns {
+		targets.Add(resourceArn)
+		if parsedArn, err := arn.Parse(resourceArn); err == nil {
+			targets.Add(getArnResourceName(parsedArn))
+		}
+	}
+
+	logrus.Infof("Targeted %d resources of cluster %s in %s.", len(arns), clusterName, region)
+
+	return nil
+}
@@ -1,23 +1,32 @@
 package ec2
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/pricing"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
 
 type EBSVolume struct {
-	VolumeId    string
-	CreatedTime time.Time
-	Status      string
-	TTL         int64
-	IsProtected bool
+	VolumeId          string
+	VolumeType        string
+	Size              int64
+	CreatedTime       time.Time
+	Status            string
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              []*ec2.Tag
 }
 
-func TagVolumesFromEksClusterForDeletion(ec2Session ec2.EC2, tagKey string, clusterName string) error {
+func TagVolumesFromEksClusterForDeletion(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagKey string, clusterName string) error {
 	var volumesIds []*string
 
 	input := &ec2.DescribeVolumesInput{
@@ -31,21 +40,26 @@ func TagVolumesFromEksClusterForDeletion(ec2Session ec2.EC2, tagKey string, clus
 		},
 	}
 
-	result, err := ec2Session.DescribeVolumes(input)
+	err := ec2Session.DescribeVolumesPagesWithContext(ctx, input,
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, currentVolume := range page.Volumes {
+				if utils.IsExcluded(ctx, *currentVolume.VolumeId) {
+					continue
+				}
+				volumesIds = append(volumesIds, currentVolume.VolumeId)
+			}
+			return true
+		})
 	if err != nil {
-		return fmt.Errorf("Can't get volumes for cluster %s in region %s: %s", clusterName, *ec2Session.Config.Region, err.Error())
+		return fmt.Errorf("Can't get volumes for cluster %s in region %s: %s", clusterName, region, err.Error())
 	}
 
-	if len(result.Volumes) == 0 {
+	if len(volumesIds) == 0 {
 		log.Debugf("No volume to tag for cluster %s", clusterName)
 		return nil
 	}
 
-	for _, currentVolume := range result.Volumes {
-		volumesIds = append(volumesIds, currentVolume.VolumeId)
-	}
-
-	_, err = ec2Session.CreateTags(
+	_, err = ec2Session.CreateTagsWithContext(ctx,
 		&ec2.CreateTagsInput{
 			Resources: volumesIds,
 			Tags: []*ec2.Tag{
@@ -56,39 +70,43 @@ func TagVolumesFromEksClusterForDeletion(ec2Session ec2.EC2, tagKey string, clus
 			},
 		})
 	if err != nil {
-		return fmt.Errorf("Can't tag volumes for cluster %s in region %s: %s", clusterName, *ec2Session.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag volumes for cluster %s in region %s: %s", clusterName, region, err.Error())
 	}
 
 	return nil
 }
 
-func deleteVolumes(ec2Session ec2.EC2, VolumesList []EBSVolume) error {
-	for _, volume := range VolumesList {
-		switch volume.Status {
-		case "deleting":
-			log.Infof("Volume %s in region %s is already in deletion process, skipping...", volume.VolumeId, *ec2Session.Config.Region)
-			continue
-		case "creating":
-			continue
-		case "deleted":
-			continue
-		case "in-use":
-			continue
-		}
+// deleteVolume deletes the volume, after starting its final snapshot if final snapshots have a ttl
+func deleteVolume(ctx context.Context, ec2Session ec2iface.EC2API, region string, volume EBSVolume, tagName string, finalSnapshotTTL int64) error {
+	switch volume.Status {
+	case "deleting":
+		log.Infof("Volume %s in region %s is already in deletion process, skipping...", volume.VolumeId, region)
+		return nil
+	case "creating", "deleted", "in-use":
+		return nil
+	}
 
-		_, err := ec2Session.DeleteVolume(
-			&ec2.DeleteVolumeInput{
-				VolumeId: &volume.VolumeId,
-			},
-		)
+	if finalSnapshotTTL > 0 {
+		err := createFinalSnapshot(ctx, ec2Session, region, volume, tagName, finalSnapshotTTL)
 		if err != nil {
-			return err
+			return fmt.Errorf("can't take the final snapshot, volume kept: %s", err)
 		}
 	}
+
+	_, err := ec2Session.DeleteVolumeWithContext(ctx,
+		&ec2.DeleteVolumeInput{
+			VolumeId: aws.String(volume.VolumeId),
+		},
+	)
+	if err != nil {
+		return err
+	}
+	utils.ReportDeleted(ctx, resources.EBSVolume, region, volume.VolumeId)
+
 	return nil
 }
 
-func listTaggedVolumes(ec2Session ec2.EC2, tagName string) ([]EBSVolume, error) {
+func listTaggedVolumes(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) ([]EBSVolume, error) {
 	var taggedVolumes []EBSVolume
 
 	input := &ec2.DescribeVolumesInput{
@@ -99,59 +117,72 @@ func listTaggedVolumes(ec2Session ec2.EC2, tagName string) ([]EBSVolume, error)
 		//},
 	}
 
-	result, err := ec2Session.DescribeVolumes(input)
+	err := ec2Session.DescribeVolumesPagesWithContext(ctx, input,
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, currentVolume := range page.Volumes {
+				_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, currentVolume.Tags, tagName)
+
+				taggedVolumes = append(taggedVolumes, EBSVolume{
+					VolumeId:          *currentVolume.VolumeId,
+					VolumeType:        aws.StringValue(currentVolume.VolumeType),
+					Size:              aws.Int64Value(currentVolume.Size),
+					CreatedTime:       *currentVolume.CreateTime,
+					Status:            *currentVolume.State,
+					TTL:               ttl,
+					ExpirationDate:    expirationDate,
+					DeletionScheduled: deletionScheduled,
+					IsProtected:       isProtected,
+					Tags:              currentVolume.Tags,
+				})
+			}
+			return true
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result.Volumes) == 0 {
-		return nil, nil
-	}
-
-	for _, currentVolume := range result.Volumes {
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(currentVolume.Tags, tagName)
-
-		taggedVolumes = append(taggedVolumes, EBSVolume{
-			VolumeId:    *currentVolume.VolumeId,
-			CreatedTime: *currentVolume.CreateTime,
-			Status:      *currentVolume.State,
-			TTL:        ttl,
-			IsProtected: isProtected,
-		})
-	}
-
 	return taggedVolumes, nil
 }
 
-func DeleteExpiredVolumes(ec2Session ec2.EC2, tagName string, dryRun bool) {
-	volumes, err := listTaggedVolumes(ec2Session, tagName)
-	region := ec2Session.Config.Region
+// DeleteExpiredVolumes deletes the expired volumes, after starting their final snapshot if final snapshots have a ttl
+// (finalSnapshotTTL in seconds)
+func DeleteExpiredVolumes(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, finalSnapshotTTL int64, dryRun bool) error {
+	volumes, err := listTaggedVolumes(ctx, ec2Session, tagName)
 	if err != nil {
-		log.Errorf("Can't list volumes: %s\n", err)
-		return
+		return fmt.Errorf("Can't list volumes: %s", err)
 	}
 
 	var expiredVolumes []EBSVolume
 	for _, volume := range volumes {
-		if utils.CheckIfExpired(volume.CreatedTime, volume.TTL) && !volume.IsProtected {
+		if utils.CheckIfDeletable(ctx, volume.CreatedTime, volume.TTL, volume.ExpirationDate, volume.DeletionScheduled, volume.IsProtected, resources.EBSVolume, region, volume.VolumeId) {
+			utils.SetReportTags(ctx, resources.EBSVolume, region, volume.VolumeId, utils.TagsToMap(volume.Tags))
 			expiredVolumes = append(expiredVolumes, volume)
+			pricing.ReportCost(ctx, resources.EBSVolume, region, volume.VolumeId, pricing.EBSVolume(volume.VolumeType, volume.Size))
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired EBS volume", len(expiredVolumes), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired EBS volume", len(expiredVolumes), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredVolumes) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.EBSVolume, region, len(expiredVolumes))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
-	for _, volume := range volumes {
-		deletionErr := deleteVolumes(ec2Session, volumes)
+	for _, volume := range expiredVolumes {
+		deletionErr := deleteVolume(ctx, ec2Session, region, volume, tagName, finalSnapshotTTL)
 			if deletionErr != nil {
-				log.Errorf("Deletion EBS %s (%s) error: %s",
-					volume.VolumeId, *ec2Session.Config.Region, deletionErr.Error())
+				utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.EBSVolume, region, volume.VolumeId).Errorf("Deletion EBS %s (%s) error: %s",
+					volume.VolumeId, region, deletionErr.Error())
+				utils.ReportDeletionError(ctx, resources.EBSVolume, region, volume.VolumeId, deletionErr)
 			}
 	}
+
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,225 @@
+package ec2
+
+import (
+	"context"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+// mockEC2 returns its volumes and records the volumes tagged and deleted
+type mockEC2 struct {
+	ec2iface.EC2API
+	volumes []*ec2.Volume
+	filters []*ec2.Filter
+	tagged  []string
+	tags    []*ec2.Tag
+	deleted []string
+}
+
+func (m *mockEC2) DescribeVolumesPagesWithContext(ctx aws.Context, input *ec2.DescribeVolumesInput, fn func(*ec2.DescribeVolumesOutput, bool) bool, opts ...request.Option) error {
+	m.filters = input.Filters
+	fn(&ec2.DescribeVolumesOutput{Volumes: m.volumes}, true)
+	return nil
+}
+
+func (m *mockEC2) CreateTagsWithContext(ctx aws.Context, input *ec2.CreateTagsInput, opts ...request.Option) (*ec2.CreateTagsOutput, error) {
+	m.tagged = append(m.tagged, aws.StringValueSlice(input.Resources)...)
+	m.tags = append(m.tags, input.Tags...)
+	return &ec2.CreateTagsOutput{}, nil
+}
+
+func (m *mockEC2) DeleteVolumeWithContext(ctx aws.Context, input *ec2.DeleteVolumeInput, opts ...request.Option) (*ec2.DeleteVolumeOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValue(input.VolumeId))
+	return &ec2.DeleteVolumeOutput{}, nil
+}
+
+func newVolume(id string, state string, createTime time.Time, tags map[string]string) *ec2.Volume {
+	volume := &ec2.Volume{
+		VolumeId:   aws.String(id),
+		VolumeType: aws.String("gp2"),
+		Size:       aws.Int64(8),
+		State:      aws.String(state),
+		CreateTime: aws.Time(createTime),
+	}
+	for key, value := range tags {
+		volume.Tags = append(volume.Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return volume
+}
+
+func TestListTaggedVolumes(t *testing.T) {
+	created := time.Now().Add(-time.Hour)
+	expiration := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		tags           map[string]string
+		wantTTL        int64
+		wantProtected  bool
+		wantExpiration time.Time
+	}{
+		{name: "without tags"},
+		{name: "ttl in seconds", tags: map[string]string{"ttl": "7200"}, wantTTL: 7200},
+		{name: "ttl with unit", tags: map[string]string{"ttl": "2h"}, wantTTL: 7200},
+		{name: "invalid ttl", tags: map[string]string{"ttl": "soon"}},
+		{name: "expiration date", tags: map[string]string{utils.ExpirationDateTagName: "2021-03-01"}, wantExpiration: expiration},
+		{name: "protected", tags: map[string]string{"ttl": "1", "do_not_delete": "true"}, wantTTL: 1, wantProtected: true},
+		{name: "protection disabled", tags: map[string]string{"ttl": "1", "do_not_delete": "false"}, wantTTL: 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockEC2{volumes: []*ec2.Volume{newVolume("vol-1", "available", created, test.tags)}}
+
+			volumes, err := listTaggedVolumes(context.Background(), svc, "ttl")
+			if err != nil {
+				t.Fatalf("listTaggedVolumes failed: %s", err)
+			}
+			if len(volumes) != 1 {
+				t.Fatalf("listTaggedVolumes returned %d volumes, want 1", len(volumes))
+			}
+
+			volume := volumes[0]
+			if volume.VolumeId != "vol-1" || !volume.CreatedTime.Equal(created) || volume.Status != "available" {
+				t.Errorf("listTaggedVolumes returned %+v", volume)
+			}
+			if volume.TTL != test.wantTTL {
+				t.Errorf("TTL = %d, want %d", volume.TTL, test.wantTTL)
+			}
+			if volume.IsProtected != test.wantProtected {
+				t.Errorf("IsProtected = %t, want %t", volume.IsProtected, test.wantProtected)
+			}
+			if !volume.ExpirationDate.Equal(test.wantExpiration) {
+				t.Errorf("ExpirationDate = %s, want %s", volume.ExpirationDate, test.wantExpiration)
+			}
+		})
+	}
+}
+
+func TestDeleteExpiredVolumes(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name        string
+		volumes     []*ec2.Volume
+		policy      utils.DeletionPolicy
+		dryRun      bool
+		wantDeleted []string
+	}{
+		{
+			name: "only expired volumes",
+			volumes: []*ec2.Volume{
+				newVolume("vol-expired", "available", old, map[string]string{"ttl": "3600"}),
+				newVolume("vol-alive", "available", recent, map[string]string{"ttl": "3600"}),
+				newVolume("vol-untagged", "available", old, nil),
+				newVolume("vol-past-expiration", "available", recent, map[string]string{utils.ExpirationDateTagName: "2021-03-01"}),
+			},
+			wantDeleted: []string{"vol-expired", "vol-past-expiration"},
+		},
+		{
+			name: "protected volumes",
+			volumes: []*ec2.Volume{
+				newVolume("vol-protected", "available", old, map[string]string{"ttl": "3600", "pleco-protected": "true"}),
+			},
+		},
+		{
+			name: "volumes in use or already deleting",
+			volumes: []*ec2.Volume{
+				newVolume("vol-in-use", "in-use", old, map[string]string{"ttl": "3600"}),
+				newVolume("vol-deleting", "deleting", old, map[string]string{"ttl": "3600"}),
+			},
+		},
+		{
+			name: "excluded volumes",
+			volumes: []*ec2.Volume{
+				newVolume("vol-excluded", "available", old, map[string]string{"ttl": "3600"}),
+				newVolume("vol-expired", "available", old, map[string]string{"ttl": "3600"}),
+			},
+			policy:      utils.DeletionPolicy{Exclusions: []*regexp.Regexp{regexp.MustCompile("^vol-excluded$")}},
+			wantDeleted: []string{"vol-expired"},
+		},
+		{
+			name: "dry run",
+			volumes: []*ec2.Volume{
+				newVolume("vol-expired", "available", old, map[string]string{"ttl": "3600"}),
+			},
+			dryRun: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockEC2{volumes: test.volumes}
+			ctx := utils.WithDeletionPolicy(context.Background(), test.policy)
+
+			err := DeleteExpiredVolumes(ctx, svc, "eu-west-3", "ttl", 0, test.dryRun)
+			if err != nil {
+				t.Fatalf("DeleteExpiredVolumes failed: %s", err)
+			}
+
+			sort.Strings(svc.deleted)
+			if !reflect.DeepEqual(svc.deleted, test.wantDeleted) {
+				t.Errorf("deleted %v, want %v", svc.deleted, test.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestTagVolumesFromEksClusterForDeletion(t *testing.T) {
+	tests := []struct {
+		name       string
+		volumes    []*ec2.Volume
+		policy     utils.DeletionPolicy
+		wantTagged []string
+	}{
+		{
+			name:       "cluster volumes",
+			volumes:    []*ec2.Volume{newVolume("vol-1", "in-use", time.Now(), nil), newVolume("vol-2", "available", time.Now(), nil)},
+			wantTagged: []string{"vol-1", "vol-2"},
+		},
+		{
+			name:       "excluded volumes",
+			volumes:    []*ec2.Volume{newVolume("vol-1", "in-use", time.Now(), nil), newVolume("vol-2", "available", time.Now(), nil)},
+			policy:     utils.DeletionPolicy{Exclusions: []*regexp.Regexp{regexp.MustCompile("^vol-2$")}},
+			wantTagged: []string{"vol-1"},
+		},
+		{
+			name: "no volume",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockEC2{volumes: test.volumes}
+			ctx := utils.WithDeletionPolicy(context.Background(), test.policy)
+
+			err := TagVolumesFromEksClusterForDeletion(ctx, svc, "eu-west-3", "ttl", "my-cluster")
+			if err != nil {
+				t.Fatalf("TagVolumesFromEksClusterForDeletion failed: %s", err)
+			}
+
+			wantFilters := []*ec2.Filter{{Name: aws.String("tag:kubernetes.io/cluster/my-cluster"), Values: aws.StringSlice([]string{"owned"})}}
+			if !reflect.DeepEqual(svc.filters, wantFilters) {
+				t.Errorf("volumes listed with filters %v, want %v", svc.filters, wantFilters)
+			}
+			if !reflect.DeepEqual(svc.tagged, test.wantTagged) {
+				t.Errorf("tagged %v, want %v", svc.tagged, test.wantTagged)
+			}
+			for _, tag := range svc.tags {
+				if aws.StringValue(tag.Key) != "ttl" || aws.StringValue(tag.Value) != "1" {
+					t.Errorf("tagged with %s=%s, want ttl=1", aws.StringValue(tag.Key), aws.StringValue(tag.Value))
+				}
+			}
+		})
+	}
+}
@@ -1,25 +1,41 @@
 package ec2
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/pricing"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/providers/aws/tagging"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
 	log "github.com/sirupsen/logrus"
-	"strings"
+	"strconv"
 	"time"
 )
 
+// deletionProtectionAttribute is the attribute of the load balancers preventing their deletion
+const deletionProtectionAttribute = "deletion_protection.enabled"
+
+// describeTagsBatchSize is the maximum number of resources of a DescribeTags request
+const describeTagsBatchSize = 20
+
 type ElasticLoadBalancer struct {
 	Arn string
 	Name string
+	Type string
 	CreatedTime time.Time
 	Status string
 	TTL int64
+	ExpirationDate time.Time
+	DeletionScheduled time.Time
 	IsProtected bool
+	Tags map[string]string
 }
 
-func TagLoadBalancersForDeletion(lbSession elbv2.ELBV2, tagKey string, loadBalancersList []ElasticLoadBalancer, clusterName string) error {
+// TagLoadBalancersForDeletion sets the ttl tag of the load balancers, in seconds, to have them deleted once expired
+func TagLoadBalancersForDeletion(ctx context.Context, lbSession elbv2iface.ELBV2API, region string, tagKey string, ttl int64, loadBalancersList []ElasticLoadBalancer, clusterName string) error {
 	var lbArns []*string
 
 	if len(loadBalancersList) == 0 {
@@ -27,6 +43,9 @@ func TagLoadBalancersForDeletion(lbSession elbv2.ELBV2, tagKey string, loadBalan
 	}
 
 	for _, lb := range loadBalancersList {
+		if utils.IsExcluded(ctx, lb.Name, lb.Arn) {
+			continue
+		}
 		lbArns = append(lbArns, aws.String(lb.Arn))
 	}
 
@@ -35,19 +54,22 @@ func TagLoadBalancersForDeletion(lbSession elbv2.ELBV2, tagKey string, loadBalan
 	}
 
 	for _, lbArn := range lbArns {
-		_, err := lbSession.AddTags(
+		_, err := lbSession.AddTagsWithContext(ctx,
 			&elbv2.AddTagsInput{
 				ResourceArns: aws.StringSlice([]string{*lbArn}),
 				Tags:         []*elbv2.Tag{
 					{
 						Key: aws.String(tagKey),
-						Value: aws.String("1"),
+						Value: aws.String(strconv.FormatInt(ttl, 10)),
 					},
 				},
 			},
 		)
 		if err != nil {
-			return fmt.Errorf("Can't tag load balancer %s for cluster %s in region %s: %s", *lbArn, clusterName, *lbSession.Config.Region, err.Error())
+			if clusterName == "" {
+				return fmt.Errorf("Can't tag load balancer %s in region %s: %s", *lbArn, region, err.Error())
+			}
+			return fmt.Errorf("Can't tag load balancer %s for cluster %s in region %s: %s", *lbArn, clusterName, region, err.Error())
 		}
 	}
 
@@ -55,41 +77,75 @@ func TagLoadBalancersForDeletion(lbSession elbv2.ELBV2, tagKey string, loadBalan
 	return nil
 }
 
-func ListTaggedLoadBalancersWithKeyContains(lbSession elbv2.ELBV2, tagContains string) ([]ElasticLoadBalancer, error) {
+// ListTaggedLoadBalancersMatching returns the load balancers with a tag matched by the matcher, the load balancers of a
+// cluster for instance. The load balancers matched before a tags error are returned with it.
+func ListTaggedLoadBalancersMatching(ctx context.Context, lbSession elbv2iface.ELBV2API, region string, matcher utils.TagMatcher) ([]ElasticLoadBalancer, error) {
 	var taggedLoadBalancers []ElasticLoadBalancer
 
-	allLoadBalancers, err := ListLoadBalancers(lbSession)
+	allLoadBalancers, err := ListLoadBalancers(ctx, lbSession)
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting loadbalancer list on region %s\n", *lbSession.Config.Region)
+		return nil, fmt.Errorf("Error while getting loadbalancer list on region %s\n", region)
 	}
 
-	// get lb tags and identify if one belongs to
+	var arns []string
 	for _, currentLb := range allLoadBalancers {
-		input := elbv2.DescribeTagsInput{ResourceArns: []*string{&currentLb.Arn}}
+		arns = append(arns, currentLb.Arn)
+	}
+
+	tags, tagsErr := DescribeTags(ctx, lbSession, arns)
+	if tagsErr != nil {
+		tagsErr = fmt.Errorf("Error while getting load balancer tags on region %s: %s", region, tagsErr)
+	}
+
+	// identify the load balancers with a matching tag
+	for _, currentLb := range allLoadBalancers {
+		for _, contentTag := range tags[currentLb.Arn] {
+			if matcher.Match(aws.StringValue(contentTag.Key), aws.StringValue(contentTag.Value)) {
+				taggedLoadBalancers = append(taggedLoadBalancers, currentLb)
+				break
+			}
+		}
+	}
+
+	return taggedLoadBalancers, tagsErr
+}
+
+// DescribeTags returns the tags of load balancers or target groups by ARN, described by batches of 20 resources. The
+// tags of the batches described before an error are returned with it.
+func DescribeTags(ctx context.Context, lbSession elbv2iface.ELBV2API, arns []string) (map[string][]*elbv2.Tag, error) {
+	tags := make(map[string][]*elbv2.Tag)
+	var errs []error
+
+	for i := 0; i < len(arns); i += describeTagsBatchSize {
+		end := i + describeTagsBatchSize
+		if end > len(arns) {
+			end = len(arns)
+		}
 
-		result, err := lbSession.DescribeTags(&input)
+		result, err := lbSession.DescribeTagsWithContext(ctx, &elbv2.DescribeTagsInput{ResourceArns: aws.StringSlice(arns[i:end])})
 		if err != nil {
-			log.Errorf("Error while getting load balancer tags from %s", currentLb.Name)
+			errs = append(errs, err)
 			continue
 		}
 
-		for _, contentTag := range result.TagDescriptions[0].Tags {
-			if strings.Contains(*contentTag.Key, tagContains) || strings.Contains(*contentTag.Value, tagContains) {
-				taggedLoadBalancers = append(taggedLoadBalancers, currentLb)
-			}
+		for _, description := range result.TagDescriptions {
+			tags[aws.StringValue(description.ResourceArn)] = description.Tags
 		}
 	}
 
-	return taggedLoadBalancers, nil
+	return tags, utils.JoinErrors(errs...)
 }
 
-func listTaggedLoadBalancers(lbSession elbv2.ELBV2, tagName string) ([]ElasticLoadBalancer, error) {
+func listTaggedLoadBalancers(ctx context.Context, lbSession elbv2iface.ELBV2API, region string, taggedResources tagging.TaggedResources, tagName string) ([]ElasticLoadBalancer, error) {
 	var taggedLoadBalancers []ElasticLoadBalancer
-	region := *lbSession.Config.Region
 
-	allLoadBalancers, err := ListLoadBalancers(lbSession)
+	if len(taggedResources.ByType(tagging.ElasticLoadBalancer)) == 0 && !utils.HasUntaggedRule(ctx, resources.LoadBalancer) {
+		return nil, nil
+	}
+
+	allLoadBalancers, err := ListLoadBalancers(ctx, lbSession)
 	if err != nil {
-		return nil, fmt.Errorf("Error while getting loadbalancer list on region %s\n", *lbSession.Config.Region)
+		return nil, fmt.Errorf("Error while getting loadbalancer list on region %s\n", region)
 	}
 
 	if len(allLoadBalancers) == 0 {
@@ -98,18 +154,18 @@ func listTaggedLoadBalancers(lbSession elbv2.ELBV2, tagName string) ([]ElasticLo
 
 	// get tag with ttl
 	for _, currentLb := range allLoadBalancers {
-		input := elbv2.DescribeTagsInput{ResourceArns: []*string{&currentLb.Arn}}
-
-		result, err := lbSession.DescribeTags(&input)
-		if err != nil {
-			log.Errorf("Error while getting load balancer tags from %s in %s", currentLb.Name, region)
+		resource, isTagged := taggedResources.Get(currentLb.Arn)
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.LoadBalancer) {
 			continue
 		}
 
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(result.TagDescriptions[0].Tags, tagName)
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
 
 		currentLb.IsProtected = isProtected
+		currentLb.Tags = utils.TagsToMap(resource.Tags)
 		currentLb.TTL = ttl
+		currentLb.ExpirationDate = expirationDate
+		currentLb.DeletionScheduled = deletionScheduled
 
 		taggedLoadBalancers = append(taggedLoadBalancers, currentLb)
 	}
@@ -117,85 +173,135 @@ func listTaggedLoadBalancers(lbSession elbv2.ELBV2, tagName string) ([]ElasticLo
 	return taggedLoadBalancers, nil
 }
 
-func ListLoadBalancers(lbSession elbv2.ELBV2) ([]ElasticLoadBalancer, error) {
+func ListLoadBalancers(ctx context.Context, lbSession elbv2iface.ELBV2API) ([]ElasticLoadBalancer, error) {
 	var allLoadBalancers []ElasticLoadBalancer
 
 	input := elbv2.DescribeLoadBalancersInput{}
 
-	result, err := lbSession.DescribeLoadBalancers(&input)
+	err := lbSession.DescribeLoadBalancersPagesWithContext(ctx, &input,
+		func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+			for _, currentLb := range page.LoadBalancers {
+				allLoadBalancers = append(allLoadBalancers, ElasticLoadBalancer{
+					Arn:         *currentLb.LoadBalancerArn,
+					Name:        *currentLb.LoadBalancerName,
+					Type:        aws.StringValue(currentLb.Type),
+					CreatedTime: *currentLb.CreatedTime,
+					Status:      *currentLb.State.Code,
+					TTL:         int64(-1),
+				})
+			}
+			return true
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result.LoadBalancers) == 0 {
-		return nil, nil
+	return allLoadBalancers, nil
+}
+
+// isDeletionProtected returns true if the deletion protection attribute of the load balancer is enabled
+func isDeletionProtected(ctx context.Context, lbSession elbv2iface.ELBV2API, lb ElasticLoadBalancer) (bool, error) {
+	result, err := lbSession.DescribeLoadBalancerAttributesWithContext(ctx,
+		&elbv2.DescribeLoadBalancerAttributesInput{LoadBalancerArn: aws.String(lb.Arn)},
+	)
+	if err != nil {
+		return false, err
 	}
 
-	for _, currentLb := range result.LoadBalancers {
-		allLoadBalancers = append(allLoadBalancers, ElasticLoadBalancer{
-			Arn:         *currentLb.LoadBalancerArn,
-			Name:        *currentLb.LoadBalancerName,
-			CreatedTime: *currentLb.CreatedTime,
-			Status:      *currentLb.State.Code,
-			TTL: 		 int64(-1),
-		})
+	for _, attribute := range result.Attributes {
+		if aws.StringValue(attribute.Key) == deletionProtectionAttribute {
+			return aws.StringValue(attribute.Value) == "true", nil
+		}
 	}
 
-	return allLoadBalancers, nil
+	return false, nil
 }
 
-func deleteLoadBalancers(lbSession elbv2.ELBV2, loadBalancersList []ElasticLoadBalancer, dryRun bool) error {
-	if dryRun {
-		return nil
-	}
+func disableDeletionProtection(ctx context.Context, lbSession elbv2iface.ELBV2API, lb ElasticLoadBalancer) error {
+	_, err := lbSession.ModifyLoadBalancerAttributesWithContext(ctx,
+		&elbv2.ModifyLoadBalancerAttributesInput{
+			LoadBalancerArn: aws.String(lb.Arn),
+			Attributes: []*elbv2.LoadBalancerAttribute{
+				{
+					Key:   aws.String(deletionProtectionAttribute),
+					Value: aws.String("false"),
+				},
+			},
+		},
+	)
 
-	if len(loadBalancersList) == 0 {
-		return nil
+	return err
+}
+
+// deleteLoadBalancer deletes a load balancer, its deletion protection is disabled first if overridden, otherwise the
+// deletion fails
+func deleteLoadBalancer(ctx context.Context, lbSession elbv2iface.ELBV2API, region string, lb ElasticLoadBalancer, overrideDeletionProtection bool) error {
+	isProtected, err := isDeletionProtected(ctx, lbSession, lb)
+	if err != nil {
+		return fmt.Errorf("can't get the deletion protection: %s", err)
 	}
 
-	for _, lb := range loadBalancersList {
-		log.Infof("Deleting ELB %s in %s, expired after %d seconds",
-			lb.Name, *lbSession.Config.Region, lb.TTL)
-		_, err := lbSession.DeleteLoadBalancer(
-			&elbv2.DeleteLoadBalancerInput{LoadBalancerArn: &lb.Arn},
-		)
+	if isProtected {
+		if !overrideDeletionProtection {
+			return fmt.Errorf("deletion protection is enabled, use --override-deletion-protection to disable it before deletion")
+		}
+
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.LoadBalancer, region, lb.Name).Infof("Disabling the deletion protection of ELB %s in %s.", lb.Name, region)
+		err = disableDeletionProtection(ctx, lbSession, lb)
 		if err != nil {
-			return err
+			return fmt.Errorf("can't disable the deletion protection: %s", err)
 		}
 	}
-	return nil
+
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.LoadBalancer, region, lb.Name).Infof("Deleting ELB %s in %s, expired after %d seconds",
+		lb.Name, region, lb.TTL)
+	_, err = lbSession.DeleteLoadBalancerWithContext(ctx,
+		&elbv2.DeleteLoadBalancerInput{LoadBalancerArn: aws.String(lb.Arn)},
+	)
+
+	return err
 }
 
-func DeleteExpiredLoadBalancers(elbSession elbv2.ELBV2, tagName string, dryRun bool) {
-	lbs, err := listTaggedLoadBalancers(elbSession, tagName)
-	region := elbSession.Config.Region
+func DeleteExpiredLoadBalancers(ctx context.Context, elbSession elbv2iface.ELBV2API, region string, taggedResources tagging.TaggedResources, tagName string, overrideDeletionProtection bool, dryRun bool) error {
+	lbs, err := listTaggedLoadBalancers(ctx, elbSession, region, taggedResources, tagName)
 	if err != nil {
-		log.Errorf("can't list Load Balancers: %s\n", err)
-		return
+		return fmt.Errorf("can't list Load Balancers: %s", err)
 	}
 
 	var expiredLoadBalancers []ElasticLoadBalancer
 	for _, lb := range lbs{
-		if utils.CheckIfExpired(lb.CreatedTime, lb.TTL) && !lb.IsProtected {
+		if utils.CheckIfDeletable(ctx, lb.CreatedTime, lb.TTL, lb.ExpirationDate, lb.DeletionScheduled, lb.IsProtected, resources.LoadBalancer, region, lb.Name, lb.Arn) {
+			utils.SetReportTags(ctx, resources.LoadBalancer, region, lb.Name, lb.Tags)
 			expiredLoadBalancers = append(expiredLoadBalancers, lb)
+			pricing.ReportCost(ctx, resources.LoadBalancer, region, lb.Name, pricing.LoadBalancer(lb.Type))
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired ELB load balancer", len(expiredLoadBalancers), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired ELB load balancer", len(expiredLoadBalancers), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredLoadBalancers) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.LoadBalancer, region, len(expiredLoadBalancers))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
-	for _, lb := range lbs {
-		deletionErr := deleteLoadBalancers(elbSession, lbs, dryRun)
+	for _, lb := range expiredLoadBalancers {
+		deletionErr := deleteLoadBalancer(ctx, elbSession, region, lb, overrideDeletionProtection)
 		if deletionErr != nil {
-			log.Errorf("Deletion ELB %s (%s) error: %s",
-					lb.Name, *elbSession.Config.Region, err)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.LoadBalancer, region, lb.Name).Errorf("Deletion ELB %s (%s) error: %s",
+					lb.Name, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.LoadBalancer, region, lb.Name, deletionErr)
+			continue
 		}
+		utils.ReportDeleted(ctx, resources.LoadBalancer, region, lb.Name)
 	}
+
+	return nil
 }
\ No newline at end of file
@@ -0,0 +1,315 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+// mockELBV2 returns its load balancers by pages of 10 and records the DescribeTags batches and the deletions
+type mockELBV2 struct {
+	elbv2iface.ELBV2API
+	loadBalancers []*elbv2.LoadBalancer
+	tags          map[string][]*elbv2.Tag
+	protected     map[string]bool
+	failingArn    string
+	batches       [][]string
+	unprotected   []string
+	deleted       []string
+}
+
+func (m *mockELBV2) DescribeLoadBalancersPagesWithContext(ctx aws.Context, input *elbv2.DescribeLoadBalancersInput, fn func(*elbv2.DescribeLoadBalancersOutput, bool) bool, opts ...request.Option) error {
+	for i := 0; i < len(m.loadBalancers); i += 10 {
+		end := i + 10
+		if end > len(m.loadBalancers) {
+			end = len(m.loadBalancers)
+		}
+		if !fn(&elbv2.DescribeLoadBalancersOutput{LoadBalancers: m.loadBalancers[i:end]}, end == len(m.loadBalancers)) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (m *mockELBV2) DescribeTagsWithContext(ctx aws.Context, input *elbv2.DescribeTagsInput, opts ...request.Option) (*elbv2.DescribeTagsOutput, error) {
+	arns := aws.StringValueSlice(input.ResourceArns)
+	m.batches = append(m.batches, arns)
+
+	// the API rejects the requests of more than 20 resources
+	if len(arns) > describeTagsBatchSize {
+		return nil, fmt.Errorf("ValidationError: %d resources, the maximum is %d", len(arns), describeTagsBatchSize)
+	}
+
+	output := &elbv2.DescribeTagsOutput{}
+	for _, resourceArn := range arns {
+		if resourceArn == m.failingArn {
+			return nil, errors.New("Throttling: rate exceeded")
+		}
+		output.TagDescriptions = append(output.TagDescriptions, &elbv2.TagDescription{
+			ResourceArn: aws.String(resourceArn),
+			Tags:        m.tags[resourceArn],
+		})
+	}
+
+	return output, nil
+}
+
+func (m *mockELBV2) DescribeLoadBalancerAttributesWithContext(ctx aws.Context, input *elbv2.DescribeLoadBalancerAttributesInput, opts ...request.Option) (*elbv2.DescribeLoadBalancerAttributesOutput, error) {
+	return &elbv2.DescribeLoadBalancerAttributesOutput{
+		Attributes: []*elbv2.LoadBalancerAttribute{{
+			Key:   aws.String(deletionProtectionAttribute),
+			Value: aws.String(fmt.Sprint(m.protected[aws.StringValue(input.LoadBalancerArn)])),
+		}},
+	}, nil
+}
+
+func (m *mockELBV2) ModifyLoadBalancerAttributesWithContext(ctx aws.Context, input *elbv2.ModifyLoadBalancerAttributesInput, opts ...request.Option) (*elbv2.ModifyLoadBalancerAttributesOutput, error) {
+	m.unprotected = append(m.unprotected, aws.StringValue(input.LoadBalancerArn))
+	return &elbv2.ModifyLoadBalancerAttributesOutput{}, nil
+}
+
+func (m *mockELBV2) DeleteLoadBalancerWithContext(ctx aws.Context, input *elbv2.DeleteLoadBalancerInput, opts ...request.Option) (*elbv2.DeleteLoadBalancerOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValue(input.LoadBalancerArn))
+	return &elbv2.DeleteLoadBalancerOutput{}, nil
+}
+
+func loadBalancerArn(name string) string {
+	return fmt.Sprintf("arn:aws:elasticloadbalancing:eu-west-3:123456789012:loadbalancer/net/%s/50dc6c495c0c9188", name)
+}
+
+func newLoadBalancer(name string, createdTime time.Time) *elbv2.LoadBalancer {
+	return &elbv2.LoadBalancer{
+		LoadBalancerArn:  aws.String(loadBalancerArn(name)),
+		LoadBalancerName: aws.String(name),
+		Type:             aws.String(elbv2.LoadBalancerTypeEnumNetwork),
+		CreatedTime:      aws.Time(createdTime),
+		State:            &elbv2.LoadBalancerState{Code: aws.String(elbv2.LoadBalancerStateEnumActive)},
+	}
+}
+
+func newTaggedLoadBalancer(name string, tags map[string]string) tagging.TaggedResource {
+	return tagging.TaggedResource{
+		Arn:  loadBalancerArn(name),
+		Type: tagging.ElasticLoadBalancer,
+		Tags: aws.StringMap(tags),
+	}
+}
+
+func TestDeleteExpiredLoadBalancers(t *testing.T) {
+	old := time.Now().Add(-2 * time.Hour)
+	recent := time.Now().Add(-time.Minute)
+
+	// 25 load balancers with a ttl, every third one expired
+	var loadBalancers []*elbv2.LoadBalancer
+	taggedResources := tagging.TaggedResources{}
+	var expired []string
+	for i := 0; i < 25; i++ {
+		name := fmt.Sprintf("lb-%02d", i)
+		createdTime := recent
+		if i%3 == 0 {
+			createdTime = old
+			expired = append(expired, loadBalancerArn(name))
+		}
+		loadBalancers = append(loadBalancers, newLoadBalancer(name, createdTime))
+		taggedResources[loadBalancerArn(name)] = newTaggedLoadBalancer(name, map[string]string{"ttl": "3600"})
+	}
+	loadBalancers = append(loadBalancers, newLoadBalancer("lb-untagged", old))
+
+	tests := []struct {
+		name                       string
+		loadBalancers              []*elbv2.LoadBalancer
+		taggedResources            tagging.TaggedResources
+		protected                  map[string]bool
+		policy                     utils.DeletionPolicy
+		overrideDeletionProtection bool
+		dryRun                     bool
+		wantDeleted                []string
+		wantUnprotected            []string
+	}{
+		{
+			name:            "only expired load balancers",
+			loadBalancers:   loadBalancers,
+			taggedResources: taggedResources,
+			wantDeleted:     expired,
+		},
+		{
+			name:            "dry run",
+			loadBalancers:   loadBalancers,
+			taggedResources: taggedResources,
+			dryRun:          true,
+		},
+		{
+			name:          "expiration date",
+			loadBalancers: []*elbv2.LoadBalancer{newLoadBalancer("lb-past", recent), newLoadBalancer("lb-future", recent)},
+			taggedResources: tagging.TaggedResources{
+				loadBalancerArn("lb-past"):   newTaggedLoadBalancer("lb-past", map[string]string{utils.ExpirationDateTagName: "2021-03-01"}),
+				loadBalancerArn("lb-future"): newTaggedLoadBalancer("lb-future", map[string]string{utils.ExpirationDateTagName: time.Now().Add(time.Hour).Format(time.RFC3339)}),
+			},
+			wantDeleted: []string{loadBalancerArn("lb-past")},
+		},
+		{
+			name:          "protected by tag",
+			loadBalancers: []*elbv2.LoadBalancer{newLoadBalancer("lb-protected", old)},
+			taggedResources: tagging.TaggedResources{
+				loadBalancerArn("lb-protected"): newTaggedLoadBalancer("lb-protected", map[string]string{"ttl": "3600", "do_not_delete": "true"}),
+			},
+		},
+		{
+			name:          "excluded",
+			loadBalancers: []*elbv2.LoadBalancer{newLoadBalancer("lb-excluded", old)},
+			taggedResources: tagging.TaggedResources{
+				loadBalancerArn("lb-excluded"): newTaggedLoadBalancer("lb-excluded", map[string]string{"ttl": "3600"}),
+			},
+			policy: utils.DeletionPolicy{Exclusions: []*regexp.Regexp{regexp.MustCompile("^lb-excluded$")}},
+		},
+		{
+			name:          "deletion protection",
+			loadBalancers: []*elbv2.LoadBalancer{newLoadBalancer("lb-protected", old)},
+			taggedResources: tagging.TaggedResources{
+				loadBalancerArn("lb-protected"): newTaggedLoadBalancer("lb-protected", map[string]string{"ttl": "3600"}),
+			},
+			protected: map[string]bool{loadBalancerArn("lb-protected"): true},
+		},
+		{
+			name:          "deletion protection overridden",
+			loadBalancers: []*elbv2.LoadBalancer{newLoadBalancer("lb-protected", old)},
+			taggedResources: tagging.TaggedResources{
+				loadBalancerArn("lb-protected"): newTaggedLoadBalancer("lb-protected", map[string]string{"ttl": "3600"}),
+			},
+			protected:                  map[string]bool{loadBalancerArn("lb-protected"): true},
+			overrideDeletionProtection: true,
+			wantDeleted:                []string{loadBalancerArn("lb-protected")},
+			wantUnprotected:            []string{loadBalancerArn("lb-protected")},
+		},
+		{
+			name:          "no tagged load balancer",
+			loadBalancers: loadBalancers,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockELBV2{loadBalancers: test.loadBalancers, protected: test.protected}
+			ctx := utils.WithDeletionPolicy(context.Background(), test.policy)
+
+			err := DeleteExpiredLoadBalancers(ctx, svc, "eu-west-3", test.taggedResources, "ttl", test.overrideDeletionProtection, test.dryRun)
+			if err != nil {
+				t.Fatalf("DeleteExpiredLoadBalancers failed: %s", err)
+			}
+
+			sort.Strings(svc.deleted)
+			if !reflect.DeepEqual(svc.deleted, test.wantDeleted) {
+				t.Errorf("deleted %v, want %v", svc.deleted, test.wantDeleted)
+			}
+			if !reflect.DeepEqual(svc.unprotected, test.wantUnprotected) {
+				t.Errorf("disabled the deletion protection of %v, want %v", svc.unprotected, test.wantUnprotected)
+			}
+		})
+	}
+}
+
+func TestDescribeTags(t *testing.T) {
+	tests := []struct {
+		name        string
+		count       int
+		failingArn  string
+		wantBatches []int
+		wantTagged  int
+		wantErr     bool
+	}{
+		{name: "no resource", count: 0},
+		{name: "one batch", count: 20, wantBatches: []int{20}, wantTagged: 20},
+		{name: "one more than a batch", count: 21, wantBatches: []int{20, 1}, wantTagged: 21},
+		{name: "several batches", count: 45, wantBatches: []int{20, 20, 5}, wantTagged: 45},
+		{name: "failing batch", count: 45, failingArn: loadBalancerArn("lb-25"), wantBatches: []int{20, 20, 5}, wantTagged: 25, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var arns []string
+			tags := make(map[string][]*elbv2.Tag)
+			for i := 0; i < test.count; i++ {
+				resourceArn := loadBalancerArn(fmt.Sprintf("lb-%02d", i))
+				arns = append(arns, resourceArn)
+				tags[resourceArn] = []*elbv2.Tag{{Key: aws.String("name"), Value: aws.String(resourceArn)}}
+			}
+			svc := &mockELBV2{tags: tags, failingArn: test.failingArn}
+
+			got, err := DescribeTags(context.Background(), svc, arns)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("DescribeTags error = %v, want an error: %t", err, test.wantErr)
+			}
+
+			var batches []int
+			for _, batch := range svc.batches {
+				batches = append(batches, len(batch))
+			}
+			if !reflect.DeepEqual(batches, test.wantBatches) {
+				t.Errorf("described tags by batches of %v, want %v", batches, test.wantBatches)
+			}
+			if len(got) != test.wantTagged {
+				t.Errorf("DescribeTags returned the tags of %d resources, want %d", len(got), test.wantTagged)
+			}
+			for resourceArn, resourceTags := range got {
+				if len(resourceTags) != 1 || aws.StringValue(resourceTags[0].Value) != resourceArn {
+					t.Errorf("DescribeTags returned the tags %v for %s", resourceTags, resourceArn)
+				}
+			}
+		})
+	}
+}
+
+func TestListTaggedLoadBalancersMatching(t *testing.T) {
+	// the load balancers of the cluster come after the first batch of tags
+	var loadBalancers []*elbv2.LoadBalancer
+	tags := make(map[string][]*elbv2.Tag)
+	for i := 0; i < 30; i++ {
+		name := fmt.Sprintf("lb-%02d", i)
+		loadBalancers = append(loadBalancers, newLoadBalancer(name, time.Now()))
+		if i >= 25 {
+			tags[loadBalancerArn(name)] = []*elbv2.Tag{{Key: aws.String("kubernetes.io/cluster/my-cluster"), Value: aws.String("owned")}}
+		}
+	}
+
+	tests := []struct {
+		name       string
+		failingArn string
+		want       []string
+		wantErr    bool
+	}{
+		{name: "every batch described", want: []string{"lb-25", "lb-26", "lb-27", "lb-28", "lb-29"}},
+		{name: "failing batch", failingArn: loadBalancerArn("lb-05"), want: []string{"lb-25", "lb-26", "lb-27", "lb-28", "lb-29"}, wantErr: true},
+		{name: "failing batch of the cluster", failingArn: loadBalancerArn("lb-25"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockELBV2{loadBalancers: loadBalancers, tags: tags, failingArn: test.failingArn}
+
+			got, err := ListTaggedLoadBalancersMatching(context.Background(), svc, "eu-west-3", utils.TagMatcher{Contains: "my-cluster"})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ListTaggedLoadBalancersMatching error = %v, want an error: %t", err, test.wantErr)
+			}
+
+			var names []string
+			for _, lb := range got {
+				names = append(names, lb.Name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("ListTaggedLoadBalancersMatching returned %v, want %v", names, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package ec2
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"time"
+)
+
+// autoScalingGroupTagName is set by AWS on the instances of auto scaling groups
+const autoScalingGroupTagName = "aws:autoscaling:groupName"
+
+// instanceCleaner deletes the expired EC2 instances of a region, and stops them during the quarantine
+type instanceCleaner struct {
+	svc ec2iface.EC2API
+}
+
+func init() {
+	cleaner.Register("ec2-instances", func(sess *session.Session, region string) cleaner.Cleaner {
+		return instanceCleaner{svc: ec2.New(sess)}
+	})
+}
+
+func (c instanceCleaner) Name() string {
+	return resources.EC2Instance
+}
+
+// List returns the instances not terminated yet. Instances of auto scaling groups are left to their group, which would
+// replace them.
+func (c instanceCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	var resources []cleaner.Resource
+	err := c.svc.DescribeInstancesPagesWithContext(ctx,
+		&ec2.DescribeInstancesInput{
+			Filters: []*ec2.Filter{{
+				Name:   aws.String("instance-state-name"),
+				Values: aws.StringSlice([]string{ec2.InstanceStateNamePending, ec2.InstanceStateNameRunning, ec2.InstanceStateNameStopping, ec2.InstanceStateNameStopped}),
+			}},
+		},
+		func(page *ec2.DescribeInstancesOutput, lastPage bool) bool {
+			for _, reservation := range page.Reservations {
+				for _, instance := range reservation.Instances {
+					tags := make(map[string]string)
+					for _, tag := range instance.Tags {
+						tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+					}
+
+					if _, isInGroup := tags[autoScalingGroupTagName]; isInGroup {
+						continue
+					}
+
+					resources = append(resources, cleaner.Resource{
+						Id:           aws.StringValue(instance.InstanceId),
+						CreationDate: getInstanceCreationTime(instance),
+						Tags:         tags,
+					})
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, nil
+}
+
+// getInstanceCreationTime returns when the instance was created: its launch time changes every time it's started
+// again, the attach time of its root volume doesn't
+func getInstanceCreationTime(instance *ec2.Instance) time.Time {
+	for _, device := range instance.BlockDeviceMappings {
+		if aws.StringValue(device.DeviceName) == aws.StringValue(instance.RootDeviceName) && device.Ebs != nil && device.Ebs.AttachTime != nil {
+			return aws.TimeValue(device.Ebs.AttachTime)
+		}
+	}
+
+	return aws.TimeValue(instance.LaunchTime)
+}
+
+func (c instanceCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.TerminateInstancesWithContext(ctx,
+		&ec2.TerminateInstancesInput{
+			InstanceIds: aws.StringSlice([]string{resource.Id}),
+		})
+
+	return err
+}
+
+// Quarantine stops the instance, stopping an instance already stopped does nothing
+func (c instanceCleaner) Quarantine(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.StopInstancesWithContext(ctx,
+		&ec2.StopInstancesInput{
+			InstanceIds: aws.StringSlice([]string{resource.Id}),
+		})
+
+	return err
+}
+
+func (c instanceCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var ec2Tags []*ec2.Tag
+	for key, value := range tags {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	_, err := c.svc.CreateTagsWithContext(ctx,
+		&ec2.CreateTagsInput{
+			Resources: aws.StringSlice([]string{resource.Id}),
+			Tags:      ec2Tags,
+		})
+
+	return err
+}
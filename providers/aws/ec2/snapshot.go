@@ -0,0 +1,158 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FinalSnapshotTagName marks the final snapshots taken before deleting a volume, its value is the volume id
+const FinalSnapshotTagName = "pleco-final-snapshot"
+
+// finalSnapshot is a final snapshot of an EBS volume
+type finalSnapshot struct {
+	SnapshotId        string
+	VolumeId          string
+	StartTime         time.Time
+	State             string
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+// getFinalSnapshotTags returns the tags of the volume for its final snapshot, with the ttl of final snapshots instead of
+// the expiration tags of the volume so the snapshot doesn't expire with it
+func getFinalSnapshotTags(volume EBSVolume, tagName string, finalSnapshotTTL int64) []*ec2.Tag {
+	tags := []*ec2.Tag{
+		{Key: aws.String(tagName), Value: aws.String(strconv.FormatInt(finalSnapshotTTL, 10))},
+		{Key: aws.String(FinalSnapshotTagName), Value: aws.String(volume.VolumeId)},
+	}
+
+	for _, tag := range volume.Tags {
+		switch aws.StringValue(tag.Key) {
+		case tagName, FinalSnapshotTagName, utils.CreationDateTagName, utils.ExpirationDateTagName, utils.ExtendTTLTagName, utils.DeletionScheduledTagName:
+			continue
+		}
+		// tags starting with aws: are reserved
+		if strings.HasPrefix(aws.StringValue(tag.Key), "aws:") {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// createFinalSnapshot starts the final snapshot of the volume, the volume can be deleted right away as the snapshot is
+// taken from the moment of the call
+func createFinalSnapshot(ctx context.Context, ec2Session ec2iface.EC2API, region string, volume EBSVolume, tagName string, finalSnapshotTTL int64) error {
+	result, err := ec2Session.CreateSnapshotWithContext(ctx,
+		&ec2.CreateSnapshotInput{
+			VolumeId:    aws.String(volume.VolumeId),
+			Description: aws.String(fmt.Sprintf("Final snapshot of %s taken by pleco before deleting it", volume.VolumeId)),
+			TagSpecifications: []*ec2.TagSpecification{
+				{
+					ResourceType: aws.String(ec2.ResourceTypeSnapshot),
+					Tags:         getFinalSnapshotTags(volume, tagName, finalSnapshotTTL),
+				},
+			},
+		})
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Taking final snapshot %s of EBS volume %s in %s.", aws.StringValue(result.SnapshotId), volume.VolumeId, region)
+
+	return nil
+}
+
+func listFinalSnapshots(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) ([]finalSnapshot, error) {
+	var snapshots []finalSnapshot
+
+	err := ec2Session.DescribeSnapshotsPagesWithContext(ctx,
+		&ec2.DescribeSnapshotsInput{
+			OwnerIds: aws.StringSlice([]string{"self"}),
+			Filters: []*ec2.Filter{
+				{Name: aws.String("tag-key"), Values: aws.StringSlice([]string{FinalSnapshotTagName})},
+			},
+		},
+		func(page *ec2.DescribeSnapshotsOutput, lastPage bool) bool {
+			for _, snapshot := range page.Snapshots {
+				_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, snapshot.Tags, tagName)
+
+				snapshots = append(snapshots, finalSnapshot{
+					SnapshotId:        aws.StringValue(snapshot.SnapshotId),
+					VolumeId:          aws.StringValue(snapshot.VolumeId),
+					StartTime:         aws.TimeValue(snapshot.StartTime),
+					State:             aws.StringValue(snapshot.State),
+					TTL:               ttl,
+					ExpirationDate:    expirationDate,
+					DeletionScheduled: deletionScheduled,
+					IsProtected:       isProtected,
+					Tags:              utils.TagsToMap(snapshot.Tags),
+				})
+			}
+			return true
+		})
+
+	return snapshots, err
+}
+
+// DeleteExpiredFinalSnapshots deletes the expired final snapshots of the deleted volumes
+func DeleteExpiredFinalSnapshots(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, dryRun bool) error {
+	snapshots, err := listFinalSnapshots(ctx, ec2Session, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list EBS snapshots: %s", err)
+	}
+
+	var expiredSnapshots []finalSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.State != ec2.SnapshotStateCompleted {
+			continue
+		}
+
+		if utils.CheckIfDeletable(ctx, snapshot.StartTime, snapshot.TTL, snapshot.ExpirationDate, snapshot.DeletionScheduled, snapshot.IsProtected, resources.EBSSnapshot, region, snapshot.SnapshotId) {
+			utils.SetReportTags(ctx, resources.EBSSnapshot, region, snapshot.SnapshotId, snapshot.Tags)
+			expiredSnapshots = append(expiredSnapshots, snapshot)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired EBS snapshot", len(expiredSnapshots), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredSnapshots) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.EBSSnapshot, region, len(expiredSnapshots))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, snapshot := range expiredSnapshots {
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.EBSSnapshot, region, snapshot.SnapshotId).Infof("Deleting EBS snapshot %s of volume %s in %s.", snapshot.SnapshotId, snapshot.VolumeId, region)
+		_, deletionErr := ec2Session.DeleteSnapshotWithContext(ctx, &ec2.DeleteSnapshotInput{SnapshotId: aws.String(snapshot.SnapshotId)})
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.EBSSnapshot, region, snapshot.SnapshotId).Errorf("Deletion EBS snapshot error %s/%s: %s",
+				snapshot.SnapshotId, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.EBSSnapshot, region, snapshot.SnapshotId, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.EBSSnapshot, region, snapshot.SnapshotId)
+	}
+
+	return nil
+}
@@ -1,24 +1,31 @@
 package ec2
 
 import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
 
 type KeyPair struct {
-	KeyName      string
-	KeyId        string
-	CreationDate time.Time
-	Tag          string
-	ttl          int64
-	IsProtected  bool
+	KeyName           string
+	KeyId             string
+	CreationDate      time.Time
+	Tag               string
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
 }
 
-func getSshKeys (ec2session *ec2.EC2, tagName string) []KeyPair {
-	result, err := ec2session.DescribeKeyPairs(
+func getSshKeys (ctx context.Context, ec2session ec2iface.EC2API, tagName string) []KeyPair {
+	result, err := ec2session.DescribeKeyPairsWithContext(ctx,
 		&ec2.DescribeKeyPairsInput{
 
 		})
@@ -30,12 +37,14 @@ func getSshKeys (ec2session *ec2.EC2, tagName string) []KeyPair {
 
 	var keys []KeyPair
 	for _, key := range result.KeyPairs {
-		creationTime, ttl, isProtected, _, _ := utils.GetEssentialTags(key.Tags, tagName)
+		creationTime, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, key.Tags, tagName)
 		newKey := KeyPair{
 			KeyName: *key.KeyName,
 			KeyId: *key.KeyPairId,
 			CreationDate: creationTime,
 			ttl: ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isProtected,
 		}
 
@@ -45,8 +54,8 @@ func getSshKeys (ec2session *ec2.EC2, tagName string) []KeyPair {
 	return keys
 }
 
-func TagSshKeys(ec2session ec2.EC2, clusterName string, clusterCreationTime time.Time, clusterTtl int64) error {
-	keys := getSshKeys(&ec2session, "ttl")
+func TagSshKeys(ctx context.Context, ec2session ec2iface.EC2API, region string, clusterName string, clusterCreationTime time.Time, clusterTtl int64, tagName string) error {
+	keys := getSshKeys(ctx, ec2session, tagName)
 	var keysIds []*string
 	for _, key := range keys {
 		if key.KeyName == clusterName {
@@ -54,43 +63,67 @@ func TagSshKeys(ec2session ec2.EC2, clusterName string, clusterCreationTime time
 		}
 	}
 
-	return utils.AddCreationDateTag(ec2session, keysIds, clusterCreationTime, clusterTtl)
+	return utils.AddCreationDateTag(ctx, ec2session, region, keysIds, clusterCreationTime, clusterTtl, tagName)
 }
 
-func deleteKey (ec2session *ec2.EC2, keyId string) error {
-	_, err := ec2session.DeleteKeyPair(
-		&ec2.DeleteKeyPairInput{
-			KeyPairId: aws.String(keyId),
-		})
+// keyPairCleaner deletes the expired EC2 key pairs of a region
+type keyPairCleaner struct {
+	svc ec2iface.EC2API
+}
 
-	return err
+func init() {
+	cleaner.Register("ssh-keys", func(sess *session.Session, region string) cleaner.Cleaner {
+		return keyPairCleaner{svc: ec2.New(sess)}
+	})
 }
 
-func DeleteExpiredKeys (ec2session *ec2.EC2, tagName string, dryRun bool) {
-	keys := getSshKeys(ec2session, tagName)
-	region := ec2session.Config.Region
-	var expiredKeys []KeyPair
-	for _, key := range keys {
-		if utils.CheckIfExpired(key.CreationDate, key.ttl) && !key.IsProtected {
-			expiredKeys = append(expiredKeys, key)
+func (c keyPairCleaner) Name() string {
+	return resources.EC2KeyPair
+}
+
+func (c keyPairCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	result, err := c.svc.DescribeKeyPairsWithContext(ctx, &ec2.DescribeKeyPairsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []cleaner.Resource
+	for _, key := range result.KeyPairs {
+		tags := make(map[string]string)
+		for _, tag := range key.Tags {
+			tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
 		}
+
+		resources = append(resources, cleaner.Resource{
+			Id:   aws.StringValue(key.KeyPairId),
+			Name: aws.StringValue(key.KeyName),
+			Tags: tags,
+		})
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired ELB load balancer", len(expiredKeys), *region)
+	return resources, nil
+}
 
-	log.Debug(count)
+func (c keyPairCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.DeleteKeyPairWithContext(ctx,
+		&ec2.DeleteKeyPairInput{
+			KeyPairId: aws.String(resource.Id),
+		})
 
-	if dryRun || len(expiredKeys) == 0 {
-		return
+	return err
+}
+
+func (c keyPairCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var ec2Tags []*ec2.Tag
+	for key, value := range tags {
+		ec2Tags = append(ec2Tags, &ec2.Tag{Key: aws.String(key), Value: aws.String(value)})
 	}
 
-	log.Debug(start)
+	_, err := c.svc.CreateTagsWithContext(ctx,
+		&ec2.CreateTagsInput{
+			Resources: aws.StringSlice([]string{resource.Id}),
+			Tags:      ec2Tags,
+		})
 
-	for _, key := range expiredKeys {
-		deletionErr := deleteKey(ec2session, key.KeyId)
-		if deletionErr != nil {
-			log.Errorf("Deletion EC2 key pair error %s/%s: %s",
-				key.KeyName, *region, deletionErr)
-		}
-	}
+	return err
 }
@@ -0,0 +1,358 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"strings"
+	"time"
+)
+
+// serviceWaitInterval is the time between two checks of the services being deleted, when waiting for them
+const serviceWaitInterval = 15 * time.Second
+
+// ecsService is a service listed by the service cleaner
+type ecsService struct {
+	name               string
+	tags               map[string]string
+	clusterArn         string
+	taskDefinition     string
+	schedulingStrategy string
+}
+
+// serviceCleaner deletes the expired ECS services of a region, it's a cleaner of the cleaner package without being
+// registered as services have to be deleted before the clusters
+type serviceCleaner struct {
+	svc ecsiface.ECSAPI
+	// services by ARN, and the number of services using each task definition, set by List
+	services        map[string]ecsService
+	taskDefinitions map[string]int
+}
+
+// clusterCleaner deletes the expired ECS clusters of a region with their services
+type clusterCleaner struct {
+	svc      ecsiface.ECSAPI
+	services *serviceCleaner
+	region   string
+	tagName  string
+}
+
+func getClusterArns(ctx context.Context, svc ecsiface.ECSAPI) ([]*string, error) {
+	var clusterArns []*string
+	err := svc.ListClustersPagesWithContext(ctx,
+		&ecs.ListClustersInput{MaxResults: aws.Int64(100)},
+		func(page *ecs.ListClustersOutput, lastPage bool) bool {
+			clusterArns = append(clusterArns, page.ClusterArns...)
+			return true
+		})
+
+	return clusterArns, err
+}
+
+// getClusterServices returns the services of the cluster, with their tags
+func getClusterServices(ctx context.Context, svc ecsiface.ECSAPI, clusterArn *string) ([]*ecs.Service, error) {
+	var serviceArns []*string
+	err := svc.ListServicesPagesWithContext(ctx,
+		&ecs.ListServicesInput{Cluster: clusterArn, MaxResults: aws.Int64(100)},
+		func(page *ecs.ListServicesOutput, lastPage bool) bool {
+			serviceArns = append(serviceArns, page.ServiceArns...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	// DescribeServices accepts at most 10 services per call
+	var services []*ecs.Service
+	for start := 0; start < len(serviceArns); start += 10 {
+		end := start + 10
+		if end > len(serviceArns) {
+			end = len(serviceArns)
+		}
+
+		result, err := svc.DescribeServicesWithContext(ctx,
+			&ecs.DescribeServicesInput{
+				Cluster:  clusterArn,
+				Services: serviceArns[start:end],
+				Include:  aws.StringSlice([]string{ecs.ServiceFieldTags}),
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		services = append(services, result.Services...)
+	}
+
+	return services, nil
+}
+
+// areServicesInactive returns true once the deleted services of the cluster are inactive, their tasks are stopped
+func areServicesInactive(ctx context.Context, svc ecsiface.ECSAPI, clusterArn string, serviceArns []*string) (bool, error) {
+	// DescribeServices accepts at most 10 services per call
+	for start := 0; start < len(serviceArns); start += 10 {
+		end := start + 10
+		if end > len(serviceArns) {
+			end = len(serviceArns)
+		}
+
+		result, err := svc.DescribeServicesWithContext(ctx,
+			&ecs.DescribeServicesInput{
+				Cluster:  aws.String(clusterArn),
+				Services: serviceArns[start:end],
+			})
+		if err != nil {
+			return false, err
+		}
+
+		for _, service := range result.Services {
+			if aws.StringValue(service.Status) != "INACTIVE" {
+				return false, nil
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func getTags(ecsTags []*ecs.Tag) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range ecsTags {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return tags
+}
+
+func tagResource(ctx context.Context, svc ecsiface.ECSAPI, resourceArn string, tags map[string]string) error {
+	var ecsTags []*ecs.Tag
+	for key, value := range tags {
+		ecsTags = append(ecsTags, &ecs.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	_, err := svc.TagResourceWithContext(ctx,
+		&ecs.TagResourceInput{
+			ResourceArn: aws.String(resourceArn),
+			Tags:        ecsTags,
+		})
+
+	return err
+}
+
+func (c *serviceCleaner) Name() string {
+	return resources.ECSService
+}
+
+// List returns the active services of every cluster
+func (c *serviceCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	clusterArns, err := getClusterArns(ctx, c.svc)
+	if err != nil {
+		return nil, err
+	}
+
+	c.services = make(map[string]ecsService)
+	c.taskDefinitions = make(map[string]int)
+
+	var resources []cleaner.Resource
+	for _, clusterArn := range clusterArns {
+		clusterName := aws.StringValue(clusterArn)[strings.LastIndex(aws.StringValue(clusterArn), "/")+1:]
+		services, err := getClusterServices(ctx, c.svc, clusterArn)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, service := range services {
+			// deleted services stay listed while their tasks stop
+			if aws.StringValue(service.Status) != "ACTIVE" {
+				continue
+			}
+
+			// services of different clusters can have the same name
+			name := clusterName + "/" + aws.StringValue(service.ServiceName)
+			tags := getTags(service.Tags)
+
+			c.taskDefinitions[aws.StringValue(service.TaskDefinition)]++
+			c.services[aws.StringValue(service.ServiceArn)] = ecsService{
+				name:               name,
+				tags:               tags,
+				clusterArn:         aws.StringValue(clusterArn),
+				taskDefinition:     aws.StringValue(service.TaskDefinition),
+				schedulingStrategy: aws.StringValue(service.SchedulingStrategy),
+			}
+
+			resources = append(resources, cleaner.Resource{
+				Id:           aws.StringValue(service.ServiceArn),
+				Name:         name,
+				Arn:          aws.StringValue(service.ServiceArn),
+				CreationDate: aws.TimeValue(service.CreatedAt),
+				Tags:         tags,
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+// Delete scales the service to zero, so its tasks stop, deletes it then deregisters its task definition if no other
+// service uses it
+func (c *serviceCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	service := c.services[resource.Arn]
+
+	// daemon services can't be scaled, their tasks are stopped by the deletion
+	if service.schedulingStrategy != ecs.SchedulingStrategyDaemon {
+		_, err := c.svc.UpdateServiceWithContext(ctx,
+			&ecs.UpdateServiceInput{
+				Cluster:      aws.String(service.clusterArn),
+				Service:      aws.String(resource.Arn),
+				DesiredCount: aws.Int64(0),
+			})
+		if err != nil {
+			return fmt.Errorf("can't scale to zero: %s", err)
+		}
+	}
+
+	_, err := c.svc.DeleteServiceWithContext(ctx,
+		&ecs.DeleteServiceInput{
+			Cluster: aws.String(service.clusterArn),
+			Service: aws.String(resource.Arn),
+			Force:   aws.Bool(true),
+		})
+	if err != nil {
+		return err
+	}
+
+	delete(c.services, resource.Arn)
+	c.taskDefinitions[service.taskDefinition]--
+	if service.taskDefinition == "" || c.taskDefinitions[service.taskDefinition] > 0 {
+		return nil
+	}
+
+	_, err = c.svc.DeregisterTaskDefinitionWithContext(ctx,
+		&ecs.DeregisterTaskDefinitionInput{TaskDefinition: aws.String(service.taskDefinition)})
+	if err != nil {
+		return fmt.Errorf("can't deregister task definition %s: %s", service.taskDefinition, err)
+	}
+
+	return nil
+}
+
+func (c *serviceCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	return tagResource(ctx, c.svc, resource.Arn, tags)
+}
+
+func (c *clusterCleaner) Name() string {
+	return resources.ECSCluster
+}
+
+// List returns the active clusters. Clusters don't have a creation date, their creationDate tag is used.
+func (c *clusterCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	clusterArns, err := getClusterArns(ctx, c.svc)
+	if err != nil {
+		return nil, err
+	}
+
+	// DescribeClusters accepts at most 100 clusters per call
+	var resources []cleaner.Resource
+	for start := 0; start < len(clusterArns); start += 100 {
+		end := start + 100
+		if end > len(clusterArns) {
+			end = len(clusterArns)
+		}
+
+		result, err := c.svc.DescribeClustersWithContext(ctx,
+			&ecs.DescribeClustersInput{
+				Clusters: clusterArns[start:end],
+				Include:  aws.StringSlice([]string{ecs.ClusterFieldTags}),
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, cluster := range result.Clusters {
+			if aws.StringValue(cluster.Status) != "ACTIVE" {
+				continue
+			}
+
+			resources = append(resources, cleaner.Resource{
+				Id:   aws.StringValue(cluster.ClusterArn),
+				Name: aws.StringValue(cluster.ClusterName),
+				Arn:  aws.StringValue(cluster.ClusterArn),
+				Tags: getTags(cluster.Tags),
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+// Delete deletes the services left in the cluster like expired ones, waits for their tasks to stop if waits are
+// enabled, then deletes the cluster. Nothing is deleted if the deletion policy keeps one of the services. The cluster
+// can't be deleted while the tasks of its services are stopping or container instances are registered to it, its
+// deletion is retried by the next checks.
+func (c *clusterCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	var services []cleaner.Resource
+	for serviceArn, service := range c.services.services {
+		if service.clusterArn != resource.Arn {
+			continue
+		}
+
+		_, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, aws.StringMap(service.tags), c.tagName)
+		if !utils.CheckIfDependencyDeletable(ctx, isProtected, "expired ECS cluster "+resource.Name, resources.ECSService, c.region, service.name, serviceArn) {
+			return fmt.Errorf("service %s is kept by the deletion policy", service.name)
+		}
+		services = append(services, cleaner.Resource{Id: serviceArn, Name: service.name, Arn: serviceArn})
+	}
+
+	if len(services) > 0 {
+		err := utils.ReserveDeletions(ctx, resources.ECSService, c.region, len(services))
+		if err != nil {
+			return err
+		}
+	}
+
+	var serviceArns []*string
+	for _, service := range services {
+		utils.ReportDependency(ctx, resources.ECSService, c.region, service.Name, service.Arn)
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.ECSService, c.region, service.Name).Infof("Deleting %s %s of ECS cluster %s in %s.", resources.ECSService, service.Name, resource.Name, c.region)
+		err := c.services.Delete(ctx, service)
+		if err != nil {
+			utils.ReportDeletionError(ctx, resources.ECSService, c.region, service.Name, err)
+			return fmt.Errorf("can't delete service %s: %s", service.Name, err)
+		}
+		utils.ReportDeleted(ctx, resources.ECSService, c.region, service.Name)
+		serviceArns = append(serviceArns, aws.String(service.Arn))
+	}
+
+	if len(serviceArns) > 0 {
+		err := utils.WaitFor(ctx, "the tasks of the services of ECS cluster "+resource.Name+" to stop", serviceWaitInterval, func(ctx context.Context) (bool, error) {
+			return areServicesInactive(ctx, c.svc, resource.Arn, serviceArns)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := c.svc.DeleteClusterWithContext(ctx, &ecs.DeleteClusterInput{Cluster: aws.String(resource.Arn)})
+
+	return err
+}
+
+func (c *clusterCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	return tagResource(ctx, c.svc, resource.Arn, tags)
+}
+
+// DeleteExpiredServicesAndClusters deletes the expired services, then the expired clusters with their remaining
+// services
+func DeleteExpiredServicesAndClusters(ctx context.Context, svc ecsiface.ECSAPI, region string, tagName string, dryRun bool) error {
+	services := &serviceCleaner{svc: svc}
+	err := cleaner.DeleteExpired(ctx, services, region, tagName, dryRun)
+	if err != nil {
+		return err
+	}
+
+	return cleaner.DeleteExpired(ctx, &clusterCleaner{svc: svc, services: services, region: region, tagName: tagName}, region, tagName, dryRun)
+}
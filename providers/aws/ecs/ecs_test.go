@@ -0,0 +1,185 @@
+package ecs
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+const clusterArn = "arn:aws:ecs:eu-west-3:123456789012:cluster/my-cluster"
+
+// mockECS returns a cluster with its services and records the services and clusters deleted
+type mockECS struct {
+	ecsiface.ECSAPI
+	clusterTags     map[string]string
+	services        []*ecs.Service
+	deletedServices []string
+	deletedClusters []string
+}
+
+func (m *mockECS) ListClustersPagesWithContext(ctx aws.Context, input *ecs.ListClustersInput, fn func(*ecs.ListClustersOutput, bool) bool, opts ...request.Option) error {
+	fn(&ecs.ListClustersOutput{ClusterArns: aws.StringSlice([]string{clusterArn})}, true)
+	return nil
+}
+
+func (m *mockECS) DescribeClustersWithContext(ctx aws.Context, input *ecs.DescribeClustersInput, opts ...request.Option) (*ecs.DescribeClustersOutput, error) {
+	return &ecs.DescribeClustersOutput{Clusters: []*ecs.Cluster{{
+		ClusterArn:  aws.String(clusterArn),
+		ClusterName: aws.String("my-cluster"),
+		Status:      aws.String("ACTIVE"),
+		Tags:        newTags(m.clusterTags),
+	}}}, nil
+}
+
+func (m *mockECS) ListServicesPagesWithContext(ctx aws.Context, input *ecs.ListServicesInput, fn func(*ecs.ListServicesOutput, bool) bool, opts ...request.Option) error {
+	var serviceArns []*string
+	for _, service := range m.services {
+		serviceArns = append(serviceArns, service.ServiceArn)
+	}
+	fn(&ecs.ListServicesOutput{ServiceArns: serviceArns}, true)
+	return nil
+}
+
+// DescribeServicesWithContext returns the services deleted as inactive
+func (m *mockECS) DescribeServicesWithContext(ctx aws.Context, input *ecs.DescribeServicesInput, opts ...request.Option) (*ecs.DescribeServicesOutput, error) {
+	output := &ecs.DescribeServicesOutput{}
+	for _, service := range m.services {
+		for _, serviceArn := range input.Services {
+			if aws.StringValue(service.ServiceArn) != aws.StringValue(serviceArn) {
+				continue
+			}
+
+			described := *service
+			for _, deleted := range m.deletedServices {
+				if deleted == aws.StringValue(serviceArn) {
+					described.Status = aws.String("INACTIVE")
+				}
+			}
+			output.Services = append(output.Services, &described)
+		}
+	}
+
+	return output, nil
+}
+
+func (m *mockECS) UpdateServiceWithContext(ctx aws.Context, input *ecs.UpdateServiceInput, opts ...request.Option) (*ecs.UpdateServiceOutput, error) {
+	return &ecs.UpdateServiceOutput{}, nil
+}
+
+func (m *mockECS) DeleteServiceWithContext(ctx aws.Context, input *ecs.DeleteServiceInput, opts ...request.Option) (*ecs.DeleteServiceOutput, error) {
+	m.deletedServices = append(m.deletedServices, aws.StringValue(input.Service))
+	return &ecs.DeleteServiceOutput{}, nil
+}
+
+func (m *mockECS) DeregisterTaskDefinitionWithContext(ctx aws.Context, input *ecs.DeregisterTaskDefinitionInput, opts ...request.Option) (*ecs.DeregisterTaskDefinitionOutput, error) {
+	return &ecs.DeregisterTaskDefinitionOutput{}, nil
+}
+
+func (m *mockECS) DeleteClusterWithContext(ctx aws.Context, input *ecs.DeleteClusterInput, opts ...request.Option) (*ecs.DeleteClusterOutput, error) {
+	m.deletedClusters = append(m.deletedClusters, aws.StringValue(input.Cluster))
+	return &ecs.DeleteClusterOutput{}, nil
+}
+
+func newTags(tags map[string]string) []*ecs.Tag {
+	var ecsTags []*ecs.Tag
+	for key, value := range tags {
+		ecsTags = append(ecsTags, &ecs.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return ecsTags
+}
+
+func newService(name string, tags map[string]string) *ecs.Service {
+	return &ecs.Service{
+		ServiceArn:     aws.String("arn:aws:ecs:eu-west-3:123456789012:service/my-cluster/" + name),
+		ServiceName:    aws.String(name),
+		Status:         aws.String("ACTIVE"),
+		TaskDefinition: aws.String("arn:aws:ecs:eu-west-3:123456789012:task-definition/" + name + ":1"),
+		CreatedAt:      aws.Time(time.Now()),
+		Tags:           newTags(tags),
+	}
+}
+
+func TestDeleteExpiredClusterServices(t *testing.T) {
+	expiredCluster := map[string]string{"ttl": "3600", utils.CreationDateTagName: utils.FormatCreationDate(time.Now().Add(-2 * time.Hour))}
+
+	tests := []struct {
+		name            string
+		services        []*ecs.Service
+		policy          utils.DeletionPolicy
+		wantServices    []string
+		wantCluster     bool
+		wantReported    []string
+		wantReportError bool
+	}{
+		{
+			name:         "services of the expired cluster",
+			services:     []*ecs.Service{newService("api", nil), newService("worker", nil)},
+			wantServices: []string{"api", "worker"},
+			wantCluster:  true,
+			wantReported: []string{"my-cluster/api", "my-cluster/worker"},
+		},
+		{
+			name:            "protected service",
+			services:        []*ecs.Service{newService("api", nil), newService("db", map[string]string{"do_not_delete": "true"})},
+			wantReportError: true,
+		},
+		{
+			name:            "excluded service",
+			services:        []*ecs.Service{newService("api", nil), newService("db", nil)},
+			policy:          utils.DeletionPolicy{Exclusions: []*regexp.Regexp{regexp.MustCompile("^my-cluster/db$")}},
+			wantReportError: true,
+		},
+		{
+			name:        "cluster without service",
+			wantCluster: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockECS{clusterTags: expiredCluster, services: test.services}
+			report := utils.NewReport()
+			ctx := utils.WithReport(utils.WithDeletionPolicy(context.Background(), test.policy), report)
+
+			err := DeleteExpiredServicesAndClusters(ctx, svc, "eu-west-3", "ttl", false)
+			if err != nil {
+				t.Fatalf("DeleteExpiredServicesAndClusters failed: %s", err)
+			}
+
+			var deletedServices []string
+			for _, serviceArn := range svc.deletedServices {
+				deletedServices = append(deletedServices, serviceArn[len("arn:aws:ecs:eu-west-3:123456789012:service/my-cluster/"):])
+			}
+			sort.Strings(deletedServices)
+			if !reflect.DeepEqual(deletedServices, test.wantServices) {
+				t.Errorf("deleted services %v, want %v", deletedServices, test.wantServices)
+			}
+			if (len(svc.deletedClusters) > 0) != test.wantCluster {
+				t.Errorf("deleted clusters %v, want the cluster deleted: %t", svc.deletedClusters, test.wantCluster)
+			}
+
+			var reported []string
+			for _, entry := range report.Entries() {
+				if entry.Type == resources.ECSService {
+					reported = append(reported, entry.Name)
+				}
+			}
+			if !reflect.DeepEqual(reported, test.wantReported) {
+				t.Errorf("reported deleted services %v, want %v", reported, test.wantReported)
+			}
+			if hasFailures := len(report.Failures()) > 0; hasFailures != test.wantReportError {
+				t.Errorf("reported failures %v, want failures: %t", report.Failures(), test.wantReportError)
+			}
+		})
+	}
+}
@@ -1,75 +1,88 @@
 package eks
 
 import (
+	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecr/ecriface"
 	log "github.com/sirupsen/logrus"
 )
 
-func getRepositories(ecrSession *ecr.ECR) []*ecr.Repository {
-	result, err := ecrSession.DescribeRepositories(
+func getRepositories(ctx context.Context, ecrSession ecriface.ECRAPI) []*ecr.Repository {
+	var repositories []*ecr.Repository
+	err := ecrSession.DescribeRepositoriesPagesWithContext(ctx,
 		&ecr.DescribeRepositoriesInput{
 			MaxResults: aws.Int64(1000),
+		},
+		func(page *ecr.DescribeRepositoriesOutput, lastPage bool) bool {
+			repositories = append(repositories, page.Repositories...)
+			return true
 		})
 
 	if err != nil {
 		log.Error(err)
 	}
 
-	return result.Repositories
+	return repositories
 }
 
-func getRepositoryImages(ecrSession *ecr.ECR, repositoryName string) []*ecr.ImageDetail {
-	result, err := ecrSession.DescribeImages(
+func getRepositoryImages(ctx context.Context, ecrSession ecriface.ECRAPI, repositoryName string) []*ecr.ImageDetail {
+	var images []*ecr.ImageDetail
+	err := ecrSession.DescribeImagesPagesWithContext(ctx,
 		&ecr.DescribeImagesInput{
 			MaxResults: aws.Int64(1000),
 			RepositoryName: aws.String(repositoryName),
+		},
+		func(page *ecr.DescribeImagesOutput, lastPage bool) bool {
+			images = append(images, page.ImageDetails...)
+			return true
 		})
 
 	if err != nil {
 		log.Error(err)
 	}
 
-	return result.ImageDetails
+	return images
 }
 
-func DeleteEmptyRepositories(ecrSession *ecr.ECR, drynRun bool) {
-	repositories := getRepositories(ecrSession)
-	region := ecrSession.Config.Region
+func DeleteEmptyRepositories(ctx context.Context, ecrSession ecriface.ECRAPI, region string, drynRun bool) error {
+	repositories := getRepositories(ctx, ecrSession)
 	var emptyRepositoryNames []string
 	for _, repository := range repositories {
-		images := getRepositoryImages(ecrSession, *repository.RepositoryName)
+		images := getRepositoryImages(ctx, ecrSession, *repository.RepositoryName)
 		if len(images) == 0 {
 			emptyRepositoryNames = append(emptyRepositoryNames, *repository.RepositoryName)
 		}
 	}
 
-	s := fmt.Sprintf("There is no empty ECR repository to delete in region %s.", *region)
+	s := fmt.Sprintf("There is no empty ECR repository to delete in region %s.", region)
 	if len(emptyRepositoryNames) == 1 {
-		s = fmt.Sprintf("There is 1 empty ECR repository to delete in region %s.", *region)
+		s = fmt.Sprintf("There is 1 empty ECR repository to delete in region %s.", region)
 	}
 	if len(emptyRepositoryNames) > 1 {
-		s = fmt.Sprintf("There are %d empty ECR repositories to delete in region %s.", len(emptyRepositoryNames), *region)
+		s = fmt.Sprintf("There are %d empty ECR repositories to delete in region %s.", len(emptyRepositoryNames), region)
 	}
 
 	log.Debug(s)
 
 	if drynRun || len(emptyRepositoryNames) == 0 {
-		return
+		return nil
 	}
 
-	log.Debugf("Starting ECR repositories deletion for region %s.", *region)
+	log.Debugf("Starting ECR repositories deletion for region %s.", region)
 
 	for _, repositoryName := range emptyRepositoryNames {
-		_, err := ecrSession.DeleteRepository(
+		_, err := ecrSession.DeleteRepositoryWithContext(ctx,
 			&ecr.DeleteRepositoryInput{
 				RepositoryName: aws.String(repositoryName),
 			})
 
 		if err != nil {
 			log.Errorf("Deletion ECR repository error %s/%s: %s",
-				repositoryName, *region, err)
+				repositoryName, region, err)
 		}
 	}
+
+	return nil
 }
\ No newline at end of file
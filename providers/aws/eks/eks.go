@@ -1,18 +1,22 @@
 package eks
 
 import (
+	"context"
 	"fmt"
 	ec22 "github.com/Qovery/pleco/providers/aws/ec2"
 	"github.com/Qovery/pleco/providers/aws/logs"
+	"github.com/Qovery/pleco/providers/aws/pricing"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/providers/aws/vpc"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
-	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/eks"
-	"github.com/aws/aws-sdk-go/service/elbv2"
-	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/eks/eksiface"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
@@ -21,6 +25,9 @@ import (
 	"time"
 )
 
+// nodeGroupWaitInterval is the time between two checks of the node groups being deleted, when waiting for them
+const nodeGroupWaitInterval = 30 * time.Second
+
 type eksCluster struct {
 	ClusterCreateTime time.Time
 	ClusterName string
@@ -28,7 +35,10 @@ type eksCluster struct {
 	ClusterNodeGroupsName []*string
 	Status string
 	TTL int64
+	ExpirationDate time.Time
+	DeletionScheduled time.Time
 	IsProtected bool
+	Tags map[string]string
 }
 
 func AuthenticateToEks(clusterName string, clusterUrl string, roleArn string, session *session.Session) (*kubernetes.Clientset, error) {
@@ -58,33 +68,37 @@ func AuthenticateToEks(clusterName string, clusterUrl string, roleArn string, se
 	return clientSet, nil
 }
 
-func listTaggedEKSClusters(svc eks.EKS, tagName string) ([]eksCluster, error) {
+func listTaggedEKSClusters(ctx context.Context, svc eksiface.EKSAPI, region string, tagName string) ([]eksCluster, error) {
 	var taggedClusters []eksCluster
-	region := *svc.Config.Region
 
+	var clusters []*string
 	input := &eks.ListClustersInput{}
-	result, err := svc.ListClusters(input)
+	err := svc.ListClustersPagesWithContext(ctx, input,
+		func(page *eks.ListClustersOutput, lastPage bool) bool {
+			clusters = append(clusters, page.Clusters...)
+			return true
+		})
 	if err != nil {
 		return nil, err
 	}
 
-	if len(result.Clusters) == 0 {
+	if len(clusters) == 0 {
 		return nil, nil
 	}
 
-	for _, cluster := range result.Clusters {
+	for _, cluster := range clusters {
 		currentCluster := eks.DescribeClusterInput{
 			Name: aws.String(*cluster),
 		}
 		clusterName := *currentCluster.Name
 
-		clusterInfo, err := svc.DescribeCluster(&currentCluster)
+		clusterInfo, err := svc.DescribeClusterWithContext(ctx, &currentCluster)
 		if err != nil {
 			log.Errorf("Error while trying to get info from cluster %v (%s)", clusterName, region)
 			continue
 		}
 
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(clusterInfo.Cluster.Tags, tagName)
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, clusterInfo.Cluster.Tags, tagName)
 
 		// ignore if creation is in progress to avoid nil fields
 		if *clusterInfo.Cluster.Status == "CREATING" {
@@ -93,8 +107,12 @@ func listTaggedEKSClusters(svc eks.EKS, tagName string) ([]eksCluster, error) {
 		}
 
 		// get node groups
-		nodeGroups, err := svc.ListNodegroups(&eks.ListNodegroupsInput{
+		var nodeGroups []*string
+		err = svc.ListNodegroupsPagesWithContext(ctx, &eks.ListNodegroupsInput{
 			ClusterName: &clusterName,
+		}, func(page *eks.ListNodegroupsOutput, lastPage bool) bool {
+			nodeGroups = append(nodeGroups, page.Nodegroups...)
+			return true
 		})
 		if err != nil {
 			log.Errorf("Error while trying to get node groups from cluster %s (%s): %s", clusterName, region, err)
@@ -103,28 +121,31 @@ func listTaggedEKSClusters(svc eks.EKS, tagName string) ([]eksCluster, error) {
 
 		taggedClusters = append(taggedClusters, eksCluster{
 			ClusterCreateTime: *clusterInfo.Cluster.CreatedAt,
-			ClusterNodeGroupsName: nodeGroups.Nodegroups,
+			ClusterNodeGroupsName: nodeGroups,
 			ClusterName:       clusterName,
 			ClusterId:			utils.AwsStringChecker(clusterInfo.Cluster.Identity),
 			Status:            *clusterInfo.Cluster.Status,
 			TTL:               ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isProtected,
+			Tags: utils.TagsToMap(clusterInfo.Cluster.Tags),
 		})
 	}
 
 	return taggedClusters, nil
 }
 
-func deleteEKSCluster(svc eks.EKS, ec2Session ec2.EC2, elbSession elbv2.ELBV2, cloudwatchLogsSession cloudwatchlogs.CloudWatchLogs, rdsSession rds.RDS, cluster eksCluster, tagName string, dryRun bool) error {
+func deleteEKSCluster(ctx context.Context, svc eksiface.EKSAPI, region string, ec2Session ec2iface.EC2API, elbSession elbv2iface.ELBV2API, cloudwatchLogsSession cloudwatchlogsiface.CloudWatchLogsAPI, rdsSession rdsiface.RDSAPI, cluster eksCluster, tagName string, clusterTagKey string, clusterTags utils.ClusterTagPatterns, dryRun bool) error {
 	if cluster.Status == "DELETING" {
-		log.Infof("EKS cluster %s (%s) is already in deletion process, skipping...", cluster.ClusterName, *svc.Config.Region)
+		log.Infof("EKS cluster %s (%s) is already in deletion process, skipping...", cluster.ClusterName, region)
 		return nil
 	} else if cluster.Status == "CREATING" {
-		log.Infof("EKS cluster %s (%s) is in creating process, skipping...", cluster.ClusterName, *svc.Config.Region)
+		log.Infof("EKS cluster %s (%s) is in creating process, skipping...", cluster.ClusterName, region)
 		return nil
 	} else {
-		log.Infof("Deleting EKS cluster %s (%s), expired after %d seconds",
-			cluster.ClusterName, *svc.Config.Region, cluster.TTL)
+		utils.ResourceLog(ctx, utils.ActionDelete, resources.EKSCluster, region, cluster.ClusterName).Infof("Deleting EKS cluster %s (%s), expired after %d seconds",
+			cluster.ClusterName, region, cluster.TTL)
 	}
 
 	if dryRun {
@@ -132,63 +153,83 @@ func deleteEKSCluster(svc eks.EKS, ec2Session ec2.EC2, elbSession elbv2.ELBV2, c
 	}
 
 	// delete node groups
+	hasCreatingNodeGroups := false
 	if len(cluster.ClusterNodeGroupsName) > 0 {
 		for _, nodeGroupName := range cluster.ClusterNodeGroupsName {
-			nodeGroupStatus, _ := getNodeGroupStatus(svc, cluster, *nodeGroupName)
+			nodeGroupStatus, _ := getNodeGroupStatus(ctx, svc, cluster, *nodeGroupName)
 
 			if nodeGroupStatus == "DELETING" {
 				log.Infof("EKS cluster nodegroup %v (%s) is already in deletion process, skipping...", *nodeGroupName, cluster.ClusterName)
 				continue
 			} else if nodeGroupStatus == "CREATING" {
 				log.Infof("EKS cluster nodegroup %v (%s) is in creating process, skipping...", *nodeGroupName, cluster.ClusterName)
+				hasCreatingNodeGroups = true
 				continue
 			} else {
 				log.Infof("Deleting EKS cluster nodegroup %v (%s)", *nodeGroupName, cluster.ClusterName)
 			}
 
-			err := deleteNodeGroupStatus(svc, cluster, *nodeGroupName, dryRun)
+			err := deleteNodeGroupStatus(ctx, svc, cluster, *nodeGroupName, dryRun)
 			if err != nil {
 				return fmt.Errorf("Error while deleting node group %v: %s\n", *nodeGroupName, err)
 			}
 		}
 	}
 
-	// as requests are asynchronous, we'll wait next run to perform delete and avoid obvious failure
-	// because of nodes groups are not yet deleted
+	// as requests are asynchronous, we wait for the node groups to be deleted, or for the next run if waits are
+	// disabled, to perform delete and avoid obvious failure because of nodes groups are not yet deleted
 	if len(cluster.ClusterNodeGroupsName) > 0 {
-		return nil
+		if hasCreatingNodeGroups || !utils.IsWaitEnabled(ctx) {
+			return nil
+		}
+
+		err := utils.WaitFor(ctx, "the deletion of the node groups of EKS cluster "+cluster.ClusterName, nodeGroupWaitInterval, func(ctx context.Context) (bool, error) {
+			result, err := svc.ListNodegroupsWithContext(ctx, &eks.ListNodegroupsInput{ClusterName: &cluster.ClusterName})
+			if err != nil {
+				return false, err
+			}
+			return len(result.Nodegroups) == 0, nil
+		})
+		if err != nil {
+			log.Warnf("EKS cluster %s (%s) deletion postponed: %s", cluster.ClusterName, region, err)
+			return nil
+		}
 	}
 
 	// tag associated load balancers for deletion
-	lbsAssociatedToThisEksCluster, err := ec22.ListTaggedLoadBalancersWithKeyContains(elbSession, cluster.ClusterName)
+	lbMatcher, err := clusterTags.ForCluster(cluster.ClusterName)
 	if err != nil {
 		return err
 	}
-	err = ec22.TagLoadBalancersForDeletion(elbSession, tagName, lbsAssociatedToThisEksCluster, cluster.ClusterName)
+	lbsAssociatedToThisEksCluster, err := ec22.ListTaggedLoadBalancersMatching(ctx, elbSession, region, lbMatcher)
+	if err != nil {
+		return err
+	}
+	err = ec22.TagLoadBalancersForDeletion(ctx, elbSession, region, tagName, 1, lbsAssociatedToThisEksCluster, cluster.ClusterName)
 	if err != nil {
 		return err
 	}
 
 	// tag associated ebs for deletion
-	err = ec22.TagVolumesFromEksClusterForDeletion(ec2Session, tagName, cluster.ClusterName)
+	err = ec22.TagVolumesFromEksClusterForDeletion(ctx, ec2Session, region, tagName, cluster.ClusterName)
 	if err != nil {
 		return err
 	}
 
 	// tag cloudwatch logs for deletion
-	err = logs.TagLogsForDeletion(cloudwatchLogsSession, tagName, cluster.ClusterId)
+	err = logs.TagLogsForDeletion(ctx, cloudwatchLogsSession, tagName, cluster.ClusterId)
 	if err != nil {
 		return err
 	}
 
 	// add cluster creation date vpc for deletion
-	err = vpc.TagVPCsForDeletion(ec2Session, rdsSession, cluster.ClusterName, cluster.ClusterCreateTime, cluster.TTL)
+	err = vpc.TagVPCsForDeletion(ctx, ec2Session, region, rdsSession, cluster.ClusterName, cluster.ClusterCreateTime, cluster.TTL, tagName, clusterTagKey)
 	if err != nil {
 		return err
 	}
 
 	// delete EKS cluster
-	_, err = svc.DeleteCluster(
+	_, err = svc.DeleteClusterWithContext(ctx,
 		&eks.DeleteClusterInput{
 			Name: &cluster.ClusterName,
 		},
@@ -196,12 +237,13 @@ func deleteEKSCluster(svc eks.EKS, ec2Session ec2.EC2, elbSession elbv2.ELBV2, c
 	if err != nil {
 		return err
 	}
+	utils.ReportDeleted(ctx, resources.EKSCluster, region, cluster.ClusterName)
 
 	return nil
 }
 
-func getNodeGroupStatus(svc eks.EKS, cluster eksCluster, nodeGroupName string) (string, error) {
-	result, err := svc.DescribeNodegroup(&eks.DescribeNodegroupInput{
+func getNodeGroupStatus(ctx context.Context, svc eksiface.EKSAPI, cluster eksCluster, nodeGroupName string) (string, error) {
+	result, err := svc.DescribeNodegroupWithContext(ctx, &eks.DescribeNodegroupInput{
 		ClusterName:   &cluster.ClusterName,
 		NodegroupName: &nodeGroupName,
 	})
@@ -212,12 +254,49 @@ func getNodeGroupStatus(svc eks.EKS, cluster eksCluster, nodeGroupName string) (
 	return *result.Nodegroup.Status, nil
 }
 
-func deleteNodeGroupStatus(svc eks.EKS, cluster eksCluster, nodeGroupName string, dryRun bool) error {
+// scaleNodeGroupsToZero stops the nodes of a cluster to quarantine it, the maximum size of node groups can't be 0
+func scaleNodeGroupsToZero(ctx context.Context, svc eksiface.EKSAPI, region string, cluster eksCluster) error {
+	var errs []error
+	for _, nodeGroupName := range cluster.ClusterNodeGroupsName {
+		result, err := svc.DescribeNodegroupWithContext(ctx, &eks.DescribeNodegroupInput{
+			ClusterName:   aws.String(cluster.ClusterName),
+			NodegroupName: nodeGroupName,
+		})
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		scaling := result.Nodegroup.ScalingConfig
+		if aws.StringValue(result.Nodegroup.Status) != "ACTIVE" || scaling == nil || aws.Int64Value(scaling.DesiredSize) == 0 {
+			continue
+		}
+
+		utils.ResourceLog(ctx, utils.ActionQuarantine, resources.EKSCluster, region, cluster.ClusterName).Infof("Scaling node group %s of EKS cluster %s in %s to zero until its deletion.",
+			*nodeGroupName, cluster.ClusterName, region)
+		_, err = svc.UpdateNodegroupConfigWithContext(ctx, &eks.UpdateNodegroupConfigInput{
+			ClusterName:   aws.String(cluster.ClusterName),
+			NodegroupName: nodeGroupName,
+			ScalingConfig: &eks.NodegroupScalingConfig{
+				MinSize:     aws.Int64(0),
+				DesiredSize: aws.Int64(0),
+				MaxSize:     scaling.MaxSize,
+			},
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return utils.JoinErrors(errs...)
+}
+
+func deleteNodeGroupStatus(ctx context.Context, svc eksiface.EKSAPI, cluster eksCluster, nodeGroupName string, dryRun bool) error {
 	if dryRun {
 		return nil
 	}
 
-	_, err := svc.DeleteNodegroup(&eks.DeleteNodegroupInput{
+	_, err := svc.DeleteNodegroupWithContext(ctx, &eks.DeleteNodegroupInput{
 		ClusterName:   &cluster.ClusterName,
 		NodegroupName: &nodeGroupName,
 	})
@@ -228,50 +307,67 @@ func deleteNodeGroupStatus(svc eks.EKS, cluster eksCluster, nodeGroupName string
 	return nil
 }
 
-func DeleteExpiredEKSClusters(svc eks.EKS, ec2Session ec2.EC2, elbSession elbv2.ELBV2, cloudwatchLogsSession cloudwatchlogs.CloudWatchLogs, rdsSession rds.RDS, tagName string, dryRun bool) {
-	clusters, err := listTaggedEKSClusters(svc, tagName)
-	region := svc.Config.Region
+func DeleteExpiredEKSClusters(ctx context.Context, svc eksiface.EKSAPI, region string, ec2Session ec2iface.EC2API, elbSession elbv2iface.ELBV2API, cloudwatchLogsSession cloudwatchlogsiface.CloudWatchLogsAPI, rdsSession rdsiface.RDSAPI, tagName string, clusterTagKey string, clusterTags utils.ClusterTagPatterns, dryRun bool) error {
+	clusters, err := listTaggedEKSClusters(ctx, svc, region, tagName)
 	if err != nil {
-		log.Errorf("can't list EKS clusters: %s\n", err)
-		return
+		return fmt.Errorf("can't list EKS clusters: %s", err)
 	}
 
+	ctx = utils.WithQuarantiner(ctx, resources.EKSCluster, func(ctx context.Context, identifiers []string) error {
+		for _, cluster := range clusters {
+			if cluster.ClusterName == identifiers[0] {
+				return scaleNodeGroupsToZero(ctx, svc, region, cluster)
+			}
+		}
+		return nil
+	})
+
 	var expiredCluster []eksCluster
 	for _, cluster := range clusters {
-		if utils.CheckIfExpired(cluster.ClusterCreateTime, cluster.TTL) && !cluster.IsProtected {
+		if utils.CheckIfDeletable(ctx, cluster.ClusterCreateTime, cluster.TTL, cluster.ExpirationDate, cluster.DeletionScheduled, cluster.IsProtected, resources.EKSCluster, region, cluster.ClusterName) {
+			utils.SetReportTags(ctx, resources.EKSCluster, region, cluster.ClusterName, cluster.Tags)
 			expiredCluster = append(expiredCluster, cluster)
+			pricing.ReportCost(ctx, resources.EKSCluster, region, cluster.ClusterName, pricing.EKSCluster())
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired EKS cluster", len(expiredCluster), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired EKS cluster", len(expiredCluster), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredCluster) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.EKSCluster, region, len(expiredCluster))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
-	for _, cluster := range clusters {
-		deletionErr := deleteEKSCluster(svc, ec2Session, elbSession,cloudwatchLogsSession, rdsSession, cluster, tagName, dryRun)
+	for _, cluster := range expiredCluster {
+		deletionErr := deleteEKSCluster(ctx, svc, region, ec2Session, elbSession,cloudwatchLogsSession, rdsSession, cluster, tagName, clusterTagKey, clusterTags, dryRun)
 		if deletionErr != nil {
-			log.Errorf("Deletion EKS cluster error %s/%s: %s",
-					cluster.ClusterName, *region, deletionErr)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.EKSCluster, region, cluster.ClusterName).Errorf("Deletion EKS cluster error %s/%s: %s",
+					cluster.ClusterName, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.EKSCluster, region, cluster.ClusterName, deletionErr)
 		}
 
 	}
+
+	return nil
 }
 
-func TagClustersResources(svc eks.EKS, ec2Session ec2.EC2, rdsSession rds.RDS, tagName string) error {
-	clusters, err := listTaggedEKSClusters(svc, tagName)
+func TagClustersResources(ctx context.Context, svc eksiface.EKSAPI, region string, ec2Session ec2iface.EC2API, rdsSession rdsiface.RDSAPI, tagName string, clusterTagKey string) error {
+	clusters, err := listTaggedEKSClusters(ctx, svc, region, tagName)
 	if err != nil {
 		return fmt.Errorf("can't list EKS clusters: %s\n", err)
 	}
 
 	var tagErrs error
 	for _, cluster := range clusters {
-		tagErr := vpc.TagVPCsForDeletion(ec2Session, rdsSession, cluster.ClusterName, cluster.ClusterCreateTime, cluster.TTL)
+		tagErr := vpc.TagVPCsForDeletion(ctx, ec2Session, region, rdsSession, cluster.ClusterName, cluster.ClusterCreateTime, cluster.TTL, tagName, clusterTagKey)
 		if tagErr != nil {
 			tagErrs = fmt.Errorf("%s ; %s", tagErrs, tagErr)
 		}
@@ -279,7 +375,7 @@ func TagClustersResources(svc eks.EKS, ec2Session ec2.EC2, rdsSession rds.RDS, t
 
 
 		//TODO : find why tagging key pair make them disappear
-		tagErr = ec22.TagSshKeys(ec2Session, cluster.ClusterName, cluster.ClusterCreateTime, cluster.TTL)
+		tagErr = ec22.TagSshKeys(ctx, ec2Session, region, cluster.ClusterName, cluster.ClusterCreateTime, cluster.TTL, tagName)
 		if tagErr != nil {
 			tagErrs = fmt.Errorf("%s ; %s", tagErrs, tagErr)
 		}
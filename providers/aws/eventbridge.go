@@ -0,0 +1,211 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// eventBridgeRuleCleaner deletes the expired EventBridge rules of every event bus of a region, with their targets
+type eventBridgeRuleCleaner struct {
+	svc eventbridgeiface.EventBridgeAPI
+}
+
+func init() {
+	cleaner.Register("eventbridge", func(sess *session.Session, region string) cleaner.Cleaner {
+		return eventBridgeRuleCleaner{svc: eventbridge.New(sess)}
+	})
+}
+
+func getEventBuses(ctx context.Context, svc eventbridgeiface.EventBridgeAPI) ([]*eventbridge.EventBus, error) {
+	var buses []*eventbridge.EventBus
+
+	// the SDK doesn't provide a pages helper for event buses, follow the tokens manually
+	input := &eventbridge.ListEventBusesInput{Limit: aws.Int64(100)}
+	for {
+		result, err := svc.ListEventBusesWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		buses = append(buses, result.EventBuses...)
+
+		if aws.StringValue(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return buses, nil
+}
+
+func getEventBusRules(ctx context.Context, svc eventbridgeiface.EventBridgeAPI, busName string) ([]*eventbridge.Rule, error) {
+	var rules []*eventbridge.Rule
+
+	input := &eventbridge.ListRulesInput{EventBusName: aws.String(busName), Limit: aws.Int64(100)}
+	for {
+		result, err := svc.ListRulesWithContext(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+
+		rules = append(rules, result.Rules...)
+
+		if aws.StringValue(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	return rules, nil
+}
+
+// getRuleLocation returns the event bus and the name of a rule from its ARN: rule/<bus>/<name> on custom buses,
+// rule/<name> on the default one
+func getRuleLocation(ruleArn string) (string, string) {
+	parsedArn, err := arn.Parse(ruleArn)
+	if err != nil {
+		return "default", ""
+	}
+
+	parts := strings.Split(parsedArn.Resource, "/")
+	if len(parts) == 3 {
+		return parts[1], parts[2]
+	}
+
+	return "default", parts[len(parts)-1]
+}
+
+func (c eventBridgeRuleCleaner) Name() string {
+	return resources.EventBridgeRule
+}
+
+// List returns the rules of every event bus, except the ones managed by other AWS services which delete them
+func (c eventBridgeRuleCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	buses, err := getEventBuses(ctx, c.svc)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []cleaner.Resource
+	for _, bus := range buses {
+		rules, err := getEventBusRules(ctx, c.svc, aws.StringValue(bus.Name))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, rule := range rules {
+			if aws.StringValue(rule.ManagedBy) != "" {
+				continue
+			}
+
+			result, err := c.svc.ListTagsForResourceWithContext(ctx, &eventbridge.ListTagsForResourceInput{ResourceARN: rule.Arn})
+			if err != nil {
+				log.Errorf("Can't get the tags of EventBridge rule %s: %s", aws.StringValue(rule.Name), err)
+				continue
+			}
+
+			tags := make(map[string]string)
+			for _, tag := range result.Tags {
+				tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+			}
+
+			// rules of different buses can have the same name
+			name := aws.StringValue(rule.Name)
+			if busName := aws.StringValue(bus.Name); busName != "default" {
+				name = busName + "/" + name
+			}
+
+			resources = append(resources, cleaner.Resource{
+				Id:   aws.StringValue(rule.Arn),
+				Name: name,
+				Arn:  aws.StringValue(rule.Arn),
+				Tags: tags,
+			})
+		}
+	}
+
+	return resources, nil
+}
+
+// Delete removes the targets of the rule, a rule with targets can't be deleted, then the rule
+func (c eventBridgeRuleCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	busName, ruleName := getRuleLocation(resource.Arn)
+
+	var targetIds []*string
+	input := &eventbridge.ListTargetsByRuleInput{
+		EventBusName: aws.String(busName),
+		Rule:         aws.String(ruleName),
+		Limit:        aws.Int64(100),
+	}
+	for {
+		result, err := c.svc.ListTargetsByRuleWithContext(ctx, input)
+		if err != nil {
+			return err
+		}
+
+		for _, target := range result.Targets {
+			targetIds = append(targetIds, target.Id)
+		}
+
+		if aws.StringValue(result.NextToken) == "" {
+			break
+		}
+		input.NextToken = result.NextToken
+	}
+
+	// RemoveTargets accepts at most 100 targets per call
+	for len(targetIds) > 0 {
+		batchSize := len(targetIds)
+		if batchSize > 100 {
+			batchSize = 100
+		}
+
+		result, err := c.svc.RemoveTargetsWithContext(ctx,
+			&eventbridge.RemoveTargetsInput{
+				EventBusName: aws.String(busName),
+				Rule:         aws.String(ruleName),
+				Ids:          targetIds[:batchSize],
+			})
+		if err != nil {
+			return err
+		}
+		if aws.Int64Value(result.FailedEntryCount) > 0 {
+			failedEntry := result.FailedEntries[0]
+			return fmt.Errorf("can't remove target %s: %s", aws.StringValue(failedEntry.TargetId), aws.StringValue(failedEntry.ErrorMessage))
+		}
+
+		targetIds = targetIds[batchSize:]
+	}
+
+	_, err := c.svc.DeleteRuleWithContext(ctx,
+		&eventbridge.DeleteRuleInput{
+			EventBusName: aws.String(busName),
+			Name:         aws.String(ruleName),
+		})
+
+	return err
+}
+
+func (c eventBridgeRuleCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var eventBridgeTags []*eventbridge.Tag
+	for key, value := range tags {
+		eventBridgeTags = append(eventBridgeTags, &eventbridge.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	_, err := c.svc.TagResourceWithContext(ctx,
+		&eventbridge.TagResourceInput{
+			ResourceARN: aws.String(resource.Arn),
+			Tags:        eventBridgeTags,
+		})
+
+	return err
+}
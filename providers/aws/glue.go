@@ -0,0 +1,382 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/glue/glueiface"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type glueResource struct {
+	Name              string
+	Arn               string
+	CreationDate      time.Time
+	Status            string
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+func getGlueResourceArn(region string, accountId string, resourceType string, name string) string {
+	return fmt.Sprintf("arn:%s:glue:%s:%s:%s/%s", GetPartitionId(region), region, accountId, resourceType, name)
+}
+
+func listTaggedGlueDatabases(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string) ([]glueResource, error) {
+	var taggedDatabases []glueResource
+
+	if len(taggedResources.ByType(tagging.GlueDatabase)) == 0 && !utils.HasUntaggedRule(ctx, resources.GlueDatabase) {
+		return nil, nil
+	}
+
+	var databases []*glue.Database
+	err := svc.GetDatabasesPagesWithContext(ctx,
+		&glue.GetDatabasesInput{
+			MaxResults: aws.Int64(100),
+		},
+		func(page *glue.GetDatabasesOutput, lastPage bool) bool {
+			databases = append(databases, page.DatabaseList...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, database := range databases {
+		resource, isTagged := taggedResources.Get(getGlueResourceArn(region, accountId, "database", *database.Name))
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.GlueDatabase) {
+			continue
+		}
+
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
+
+		taggedDatabases = append(taggedDatabases, glueResource{
+			Name:              *database.Name,
+			Arn:               resource.Arn,
+			CreationDate:      aws.TimeValue(database.CreateTime),
+			TTL:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(resource.Tags),
+		})
+	}
+
+	return taggedDatabases, nil
+}
+
+func listTaggedGlueCrawlers(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string) ([]glueResource, error) {
+	var taggedCrawlers []glueResource
+
+	if len(taggedResources.ByType(tagging.GlueCrawler)) == 0 && !utils.HasUntaggedRule(ctx, resources.GlueCrawler) {
+		return nil, nil
+	}
+
+	var crawlers []*glue.Crawler
+	err := svc.GetCrawlersPagesWithContext(ctx,
+		&glue.GetCrawlersInput{
+			MaxResults: aws.Int64(100),
+		},
+		func(page *glue.GetCrawlersOutput, lastPage bool) bool {
+			crawlers = append(crawlers, page.Crawlers...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, crawler := range crawlers {
+		resource, isTagged := taggedResources.Get(getGlueResourceArn(region, accountId, "crawler", *crawler.Name))
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.GlueCrawler) {
+			continue
+		}
+
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
+
+		taggedCrawlers = append(taggedCrawlers, glueResource{
+			Name:              *crawler.Name,
+			Arn:               resource.Arn,
+			CreationDate:      aws.TimeValue(crawler.CreationTime),
+			Status:            aws.StringValue(crawler.State),
+			TTL:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(resource.Tags),
+		})
+	}
+
+	return taggedCrawlers, nil
+}
+
+func listTaggedGlueJobs(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string) ([]glueResource, error) {
+	var taggedJobs []glueResource
+
+	if len(taggedResources.ByType(tagging.GlueJob)) == 0 && !utils.HasUntaggedRule(ctx, resources.GlueJob) {
+		return nil, nil
+	}
+
+	var jobs []*glue.Job
+	err := svc.GetJobsPagesWithContext(ctx,
+		&glue.GetJobsInput{
+			MaxResults: aws.Int64(1000),
+		},
+		func(page *glue.GetJobsOutput, lastPage bool) bool {
+			jobs = append(jobs, page.Jobs...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, job := range jobs {
+		resource, isTagged := taggedResources.Get(getGlueResourceArn(region, accountId, "job", *job.Name))
+		if !isTagged && !utils.HasUntaggedRule(ctx, resources.GlueJob) {
+			continue
+		}
+
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.Tags, tagName)
+
+		taggedJobs = append(taggedJobs, glueResource{
+			Name:              *job.Name,
+			Arn:               resource.Arn,
+			CreationDate:      aws.TimeValue(job.CreatedOn),
+			TTL:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(resource.Tags),
+		})
+	}
+
+	return taggedJobs, nil
+}
+
+func deleteGlueDatabase(ctx context.Context, svc glueiface.GlueAPI, region string, database glueResource) error {
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.GlueDatabase, region, database.Name).Infof("Deleting Glue database %s in %s, expired after %d seconds",
+		database.Name, region, database.TTL)
+
+	// tables are removed asynchronously by AWS otherwise, delete them first to avoid leftovers
+	var tablesNames []*string
+	err := svc.GetTablesPagesWithContext(ctx,
+		&glue.GetTablesInput{
+			DatabaseName: aws.String(database.Name),
+			MaxResults:   aws.Int64(100),
+		},
+		func(page *glue.GetTablesOutput, lastPage bool) bool {
+			for _, table := range page.TableList {
+				tablesNames = append(tablesNames, table.Name)
+			}
+			return true
+		})
+	if err != nil {
+		return err
+	}
+
+	// BatchDeleteTable accepts at most 100 tables per call
+	for len(tablesNames) > 0 {
+		batchSize := len(tablesNames)
+		if batchSize > 100 {
+			batchSize = 100
+		}
+
+		_, err = svc.BatchDeleteTableWithContext(ctx,
+			&glue.BatchDeleteTableInput{
+				DatabaseName:   aws.String(database.Name),
+				TablesToDelete: tablesNames[:batchSize],
+			})
+		if err != nil {
+			return err
+		}
+
+		tablesNames = tablesNames[batchSize:]
+	}
+
+	_, err = svc.DeleteDatabaseWithContext(ctx,
+		&glue.DeleteDatabaseInput{
+			Name: aws.String(database.Name),
+		})
+
+	return err
+}
+
+func deleteGlueCrawler(ctx context.Context, svc glueiface.GlueAPI, region string, crawler glueResource) error {
+	switch crawler.Status {
+	case glue.CrawlerStateStopping:
+		log.Infof("Glue crawler %s in %s is stopping, will delete it on next run", crawler.Name, region)
+		return nil
+	case glue.CrawlerStateRunning:
+		// a running crawler can't be deleted, stop it and wait next run to delete it
+		log.Infof("Stopping Glue crawler %s in %s before deletion", crawler.Name, region)
+		_, err := svc.StopCrawlerWithContext(ctx,
+			&glue.StopCrawlerInput{
+				Name: aws.String(crawler.Name),
+			})
+		return err
+	}
+
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.GlueCrawler, region, crawler.Name).Infof("Deleting Glue crawler %s in %s, expired after %d seconds",
+		crawler.Name, region, crawler.TTL)
+
+	_, err := svc.DeleteCrawlerWithContext(ctx,
+		&glue.DeleteCrawlerInput{
+			Name: aws.String(crawler.Name),
+		})
+
+	return err
+}
+
+func deleteGlueJob(ctx context.Context, svc glueiface.GlueAPI, region string, job glueResource) error {
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.GlueJob, region, job.Name).Infof("Deleting Glue job %s in %s, expired after %d seconds",
+		job.Name, region, job.TTL)
+
+	_, err := svc.DeleteJobWithContext(ctx,
+		&glue.DeleteJobInput{
+			JobName: aws.String(job.Name),
+		})
+
+	return err
+}
+
+func getExpiredGlueResources(ctx context.Context, region string, resourceType string, resources []glueResource) []glueResource {
+	var expiredResources []glueResource
+	for _, resource := range resources {
+		if utils.CheckIfDeletable(ctx, resource.CreationDate, resource.TTL, resource.ExpirationDate, resource.DeletionScheduled, resource.IsProtected, resourceType, region, resource.Name, resource.Arn) {
+			utils.SetReportTags(ctx, resourceType, region, resource.Name, resource.Tags)
+			expiredResources = append(expiredResources, resource)
+		}
+	}
+
+	return expiredResources
+}
+
+func DeleteExpiredGlueDatabases(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	databases, err := listTaggedGlueDatabases(ctx, svc, region, accountId, taggedResources, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list Glue databases: %s", err)
+	}
+
+	expiredDatabases := getExpiredGlueResources(ctx, region, resources.GlueDatabase, databases)
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired Glue database", len(expiredDatabases), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredDatabases) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.GlueDatabase, region, len(expiredDatabases))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, database := range expiredDatabases {
+		deletionErr := deleteGlueDatabase(ctx, svc, region, database)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.GlueDatabase, region, database.Name).Errorf("Deletion Glue database error %s/%s: %s",
+				database.Name, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.GlueDatabase, region, database.Name, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.GlueDatabase, region, database.Name)
+	}
+
+	return nil
+}
+
+func DeleteExpiredGlueCrawlers(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	crawlers, err := listTaggedGlueCrawlers(ctx, svc, region, accountId, taggedResources, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list Glue crawlers: %s", err)
+	}
+
+	expiredCrawlers := getExpiredGlueResources(ctx, region, resources.GlueCrawler, crawlers)
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired Glue crawler", len(expiredCrawlers), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredCrawlers) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.GlueCrawler, region, len(expiredCrawlers))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, crawler := range expiredCrawlers {
+		deletionErr := deleteGlueCrawler(ctx, svc, region, crawler)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.GlueCrawler, region, crawler.Name).Errorf("Deletion Glue crawler error %s/%s: %s",
+				crawler.Name, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.GlueCrawler, region, crawler.Name, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.GlueCrawler, region, crawler.Name)
+	}
+
+	return nil
+}
+
+func DeleteExpiredGlueJobs(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	jobs, err := listTaggedGlueJobs(ctx, svc, region, accountId, taggedResources, tagName)
+	if err != nil {
+		return fmt.Errorf("can't list Glue jobs: %s", err)
+	}
+
+	expiredJobs := getExpiredGlueResources(ctx, region, resources.GlueJob, jobs)
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired Glue job", len(expiredJobs), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredJobs) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.GlueJob, region, len(expiredJobs))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, job := range expiredJobs {
+		deletionErr := deleteGlueJob(ctx, svc, region, job)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.GlueJob, region, job.Name).Errorf("Deletion Glue job error %s/%s: %s",
+				job.Name, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.GlueJob, region, job.Name, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.GlueJob, region, job.Name)
+	}
+
+	return nil
+}
+
+func DeleteExpiredGlue(ctx context.Context, svc glueiface.GlueAPI, region string, accountId string, taggedResources tagging.TaggedResources, tagName string, dryRun bool) error {
+	log.Debugf("Listing all Glue jobs in region %s.", region)
+	jobsErr := DeleteExpiredGlueJobs(ctx, svc, region, accountId, taggedResources, tagName, dryRun)
+
+	log.Debugf("Listing all Glue crawlers in region %s.", region)
+	crawlersErr := DeleteExpiredGlueCrawlers(ctx, svc, region, accountId, taggedResources, tagName, dryRun)
+
+	log.Debugf("Listing all Glue databases in region %s.", region)
+	databasesErr := DeleteExpiredGlueDatabases(ctx, svc, region, accountId, taggedResources, tagName, dryRun)
+
+	return utils.JoinErrors(jobsErr, crawlersErr, databasesErr)
+}
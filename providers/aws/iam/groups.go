@@ -1,16 +1,23 @@
 package iam
 
 import (
+	"context"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	log "github.com/sirupsen/logrus"
 	"strconv"
 )
 
-func getGroups(iamSession *iam.IAM) []*iam.Group {
-	result, err := iamSession.ListGroups(
+func getGroups(ctx context.Context, iamSession iamiface.IAMAPI) []*iam.Group {
+	var groups []*iam.Group
+	err := iamSession.ListGroupsPagesWithContext(ctx,
 		&iam.ListGroupsInput{
 			MaxItems: aws.Int64(1000),
+		},
+		func(page *iam.ListGroupsOutput, lastPage bool) bool {
+			groups = append(groups, page.Groups...)
+			return true
 		})
 
 	if err != nil {
@@ -18,19 +25,19 @@ func getGroups(iamSession *iam.IAM) []*iam.Group {
 		return nil
 	}
 
-	return result.Groups
+	return groups
 }
 
-func DeleteGroups(iamSession *iam.IAM, dryRun bool) {
-	groups := getGroups(iamSession)
+func DeleteGroups(ctx context.Context, iamSession iamiface.IAMAPI, dryRun bool) error {
+	groups := getGroups(ctx, iamSession)
 	log.Info("There is " + strconv.FormatInt(int64(len(groups)), 10) + " expired roles to delete.")
 
 	if dryRun {
-		return
+		return nil
 	}
 
 	for _, group := range groups {
-		_, err := iamSession.DeleteGroup(
+		_, err := iamSession.DeleteGroupWithContext(ctx,
 			&iam.DeleteGroupInput{
 				GroupName: aws.String(*group.GroupName),
 				})
@@ -40,5 +47,6 @@ func DeleteGroups(iamSession *iam.IAM, dryRun bool) {
 		}
 	}
 
+	return nil
 }
 
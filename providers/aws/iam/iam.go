@@ -1,17 +1,21 @@
 package iam
 
 import (
-	"github.com/aws/aws-sdk-go/service/iam"
+	"context"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	log "github.com/sirupsen/logrus"
 )
 
-func DeleteExpiredIAM (iamSession *iam.IAM, tagName string, dryRun bool) {
+func DeleteExpiredIAM (ctx context.Context, iamSession iamiface.IAMAPI, tagName string, dryRun bool) error {
 	log.Debug("Listing all IAM users.")
-	DeleteExpiredUsers(iamSession, tagName, dryRun)
+	usersErr := DeleteExpiredUsers(ctx, iamSession, tagName, dryRun)
 
 	log.Debug("Listing all IAM roles.")
-	DeleteExpiredRoles(iamSession, tagName, dryRun)
+	rolesErr := DeleteExpiredRoles(ctx, iamSession, tagName, dryRun)
 
 	log.Debug("Listing all IAM policies.")
-	DeleteDetachedPolicies(iamSession, dryRun)
+	policiesErr := DeleteDetachedPolicies(ctx, iamSession, dryRun)
+
+	return utils.JoinErrors(usersErr, rolesErr, policiesErr)
 }
\ No newline at end of file
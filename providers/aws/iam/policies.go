@@ -1,9 +1,13 @@
 package iam
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	log "github.com/sirupsen/logrus"
 	"strings"
 )
@@ -13,10 +17,15 @@ type Policy struct {
 	Arn string
 }
 
-func getPolicies(iamSession *iam.IAM) []*iam.Policy {
-	result, err := iamSession.ListPolicies(
+func getPolicies(ctx context.Context, iamSession iamiface.IAMAPI) []*iam.Policy {
+	var policies []*iam.Policy
+	err := iamSession.ListPoliciesPagesWithContext(ctx,
 		&iam.ListPoliciesInput{
 			MaxItems: aws.Int64(1000),
+		},
+		func(page *iam.ListPoliciesOutput, lastPage bool) bool {
+			policies = append(policies, page.Policies...)
+			return true
 		})
 
 	if err != nil {
@@ -24,30 +33,35 @@ func getPolicies(iamSession *iam.IAM) []*iam.Policy {
 		return nil
 	}
 
-	return result.Policies
+	return policies
 }
 
-func getPolicyVersions(iamSession *iam.IAM, policy iam.Policy) []*iam.PolicyVersion {
-	result, err := iamSession.ListPolicyVersions(
+func getPolicyVersions(ctx context.Context, iamSession iamiface.IAMAPI, policy iam.Policy) []*iam.PolicyVersion {
+	var versions []*iam.PolicyVersion
+	err := iamSession.ListPolicyVersionsPagesWithContext(ctx,
 		&iam.ListPolicyVersionsInput{
 			MaxItems: aws.Int64(1000),
 			PolicyArn: aws.String(*policy.Arn),
+		},
+		func(page *iam.ListPolicyVersionsOutput, lastPage bool) bool {
+			versions = append(versions, page.Versions...)
+			return true
 		})
 
 	if err != nil {
 		log.Errorf("Can't get versions of policy %s : %s", *policy.PolicyName, err.Error())
 	}
 
-	return result.Versions
+	return versions
 
 }
 
-func deletePolicyVersions(iamSession *iam.IAM, policy iam.Policy) {
-	versions := getPolicyVersions(iamSession, policy)
+func deletePolicyVersions(ctx context.Context, iamSession iamiface.IAMAPI, policy iam.Policy) {
+	versions := getPolicyVersions(ctx, iamSession, policy)
 
 	for _, version := range versions {
 		if !*version.IsDefaultVersion {
-			_, err := iamSession.DeletePolicyVersion(
+			_, err := iamSession.DeletePolicyVersionWithContext(ctx,
 				&iam.DeletePolicyVersionInput{
 					PolicyArn: aws.String(*policy.Arn),
 					VersionId: aws.String(*version.VersionId),
@@ -60,8 +74,8 @@ func deletePolicyVersions(iamSession *iam.IAM, policy iam.Policy) {
 	}
 }
 
-func DeleteDetachedPolicies(iamSession *iam.IAM, dryRun bool) {
-	policies := getPolicies(iamSession)
+func DeleteDetachedPolicies(ctx context.Context, iamSession iamiface.IAMAPI, dryRun bool) error {
+	policies := getPolicies(ctx, iamSession)
 	var detachedPolicies []iam.Policy
 
 	for _, policy := range policies {
@@ -82,15 +96,20 @@ func DeleteDetachedPolicies(iamSession *iam.IAM, dryRun bool) {
 	log.Debug(s)
 
 	if dryRun || len(detachedPolicies) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.IAMPolicy, "", len(detachedPolicies))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug("Starting detached policies deletion.")
 
 	for _, expiredPolicy := range detachedPolicies {
-		deletePolicyVersions(iamSession, expiredPolicy)
+		deletePolicyVersions(ctx, iamSession, expiredPolicy)
 
-		_, err := iamSession.DeletePolicy(
+		_, err := iamSession.DeletePolicyWithContext(ctx,
 			&iam.DeletePolicyInput{
 				PolicyArn: aws.String(*expiredPolicy.Arn),
 			})
@@ -99,19 +118,31 @@ func DeleteDetachedPolicies(iamSession *iam.IAM, dryRun bool) {
 			log.Errorf("Can't delete policy %s : %s", *expiredPolicy.PolicyName, err.Error())
 		}
 	}
+
+	return nil
 }
 
-func getUserPolicies(iamSession *iam.IAM, userName string) []Policy {
-	attachedPolicies, policyErr := iamSession.ListAttachedUserPolicies(
+func getUserPolicies(ctx context.Context, iamSession iamiface.IAMAPI, userName string) []Policy {
+	var attachedPolicies []*iam.AttachedPolicy
+	policyErr := iamSession.ListAttachedUserPoliciesPagesWithContext(ctx,
 		&iam.ListAttachedUserPoliciesInput{
 			MaxItems: aws.Int64(1000),
 			UserName: aws.String(userName),
+		},
+		func(page *iam.ListAttachedUserPoliciesOutput, lastPage bool) bool {
+			attachedPolicies = append(attachedPolicies, page.AttachedPolicies...)
+			return true
 		})
 
-	policyNames, namesErr := iamSession.ListUserPolicies(
+	var policyNames []*string
+	namesErr := iamSession.ListUserPoliciesPagesWithContext(ctx,
 		&iam.ListUserPoliciesInput{
 			MaxItems: aws.Int64(1000),
 			UserName: aws.String(userName),
+		},
+		func(page *iam.ListUserPoliciesOutput, lastPage bool) bool {
+			policyNames = append(policyNames, page.PolicyNames...)
+			return true
 		})
 
 	if policyErr != nil {
@@ -125,7 +156,7 @@ func getUserPolicies(iamSession *iam.IAM, userName string) []Policy {
 	}
 
 	var userPolicies []Policy
-	for _, policy := range attachedPolicies.AttachedPolicies{
+	for _, policy := range attachedPolicies {
 		userPolicy := Policy{
 			Arn: *policy.PolicyArn,
 			Name: *policy.PolicyName,
@@ -133,7 +164,7 @@ func getUserPolicies(iamSession *iam.IAM, userName string) []Policy {
 		userPolicies = append(userPolicies, userPolicy)
 	}
 
-	for _, policyName := range policyNames.PolicyNames{
+	for _, policyName := range policyNames {
 		userPolicy := Policy{
 			Arn: "",
 			Name: *policyName,
@@ -144,10 +175,10 @@ func getUserPolicies(iamSession *iam.IAM, userName string) []Policy {
 	return userPolicies
 }
 
-func detachUserPolicies(iamSession *iam.IAM, userName string, policies []Policy) {
+func detachUserPolicies(ctx context.Context, iamSession iamiface.IAMAPI, userName string, policies []Policy) {
 	for _, policy := range policies {
 		if policy.Arn != "" {
-			_, err :=iamSession.DetachUserPolicy(
+			_, err :=iamSession.DetachUserPolicyWithContext(ctx,
 				&iam.DetachUserPolicyInput{
 					UserName: aws.String(userName),
 					PolicyArn: aws.String(policy.Arn),
@@ -160,10 +191,10 @@ func detachUserPolicies(iamSession *iam.IAM, userName string, policies []Policy)
 	}
 }
 
-func deleteUserPolicies(iamSession *iam.IAM, userName string, policies []Policy) {
+func deleteUserPolicies(ctx context.Context, iamSession iamiface.IAMAPI, userName string, policies []Policy) {
 	for _, policy := range policies {
 		if !strings.Contains(policy.Arn, ":aws:policy") {
-			_, err := iamSession.DeleteUserPolicy(
+			_, err := iamSession.DeleteUserPolicyWithContext(ctx,
 				&iam.DeleteUserPolicyInput{
 					UserName:   aws.String(userName),
 					PolicyName: aws.String(policy.Name),
@@ -176,23 +207,33 @@ func deleteUserPolicies(iamSession *iam.IAM, userName string, policies []Policy)
 	}
 }
 
-func HandleUserPolicies(iamSession *iam.IAM, userName string) {
-	policies := getUserPolicies(iamSession, userName)
-	deleteUserPolicies(iamSession, userName, policies)
-	detachUserPolicies(iamSession, userName, policies)
+func HandleUserPolicies(ctx context.Context, iamSession iamiface.IAMAPI, userName string) {
+	policies := getUserPolicies(ctx, iamSession, userName)
+	deleteUserPolicies(ctx, iamSession, userName, policies)
+	detachUserPolicies(ctx, iamSession, userName, policies)
 }
 
-func getRolePolicies(iamSession *iam.IAM, roleName string) []Policy {
-	attachedPolicies, policyErr := iamSession.ListAttachedRolePolicies(
+func getRolePolicies(ctx context.Context, iamSession iamiface.IAMAPI, roleName string) []Policy {
+	var attachedPolicies []*iam.AttachedPolicy
+	policyErr := iamSession.ListAttachedRolePoliciesPagesWithContext(ctx,
 		&iam.ListAttachedRolePoliciesInput{
 			MaxItems: aws.Int64(1000),
 			RoleName: aws.String(roleName),
+		},
+		func(page *iam.ListAttachedRolePoliciesOutput, lastPage bool) bool {
+			attachedPolicies = append(attachedPolicies, page.AttachedPolicies...)
+			return true
 		})
 
-	policyNames, namesErr := iamSession.ListRolePolicies(
+	var policyNames []*string
+	namesErr := iamSession.ListRolePoliciesPagesWithContext(ctx,
 		&iam.ListRolePoliciesInput{
 			MaxItems: aws.Int64(1000),
 			RoleName: aws.String(roleName),
+		},
+		func(page *iam.ListRolePoliciesOutput, lastPage bool) bool {
+			policyNames = append(policyNames, page.PolicyNames...)
+			return true
 		})
 
 	if policyErr != nil {
@@ -206,7 +247,7 @@ func getRolePolicies(iamSession *iam.IAM, roleName string) []Policy {
 	}
 
 	var rolePolicies []Policy
-	for _, policy := range attachedPolicies.AttachedPolicies{
+	for _, policy := range attachedPolicies {
 		userPolicy := Policy{
 			Arn: *policy.PolicyArn,
 			Name: *policy.PolicyName,
@@ -214,7 +255,7 @@ func getRolePolicies(iamSession *iam.IAM, roleName string) []Policy {
 		rolePolicies = append(rolePolicies, userPolicy)
 	}
 
-	for _, policyName := range policyNames.PolicyNames{
+	for _, policyName := range policyNames {
 		userPolicy := Policy{
 			Arn: "",
 			Name: *policyName,
@@ -226,10 +267,10 @@ func getRolePolicies(iamSession *iam.IAM, roleName string) []Policy {
 }
 
 
-func detachRolePolicies(iamSession *iam.IAM, roleName string, policies []Policy) {
+func detachRolePolicies(ctx context.Context, iamSession iamiface.IAMAPI, roleName string, policies []Policy) {
 	for _, policy := range policies {
 		if policy.Arn != "" {
-			_, err := iamSession.DetachRolePolicy(
+			_, err := iamSession.DetachRolePolicyWithContext(ctx,
 				 &iam.DetachRolePolicyInput{
 					 RoleName: aws.String(roleName),
 					 PolicyArn: aws.String(policy.Arn),
@@ -241,10 +282,10 @@ func detachRolePolicies(iamSession *iam.IAM, roleName string, policies []Policy)
 	}
 }
 
-func deleteRolePolicies(iamSession *iam.IAM, roleName string, policies []Policy) {
+func deleteRolePolicies(ctx context.Context, iamSession iamiface.IAMAPI, roleName string, policies []Policy) {
 	for _, policy := range policies {
 		if !strings.Contains(policy.Arn, ":aws:policy") {
-			_, err := iamSession.DeleteRolePolicy(
+			_, err := iamSession.DeleteRolePolicyWithContext(ctx,
 				&iam.DeleteRolePolicyInput{
 					RoleName: aws.String(roleName),
 					PolicyName: aws.String(policy.Name),
@@ -256,8 +297,8 @@ func deleteRolePolicies(iamSession *iam.IAM, roleName string, policies []Policy)
 	}
 }
 
-func HandleRolePolicies(iamSession *iam.IAM, roleName string) {
-	policies := getRolePolicies(iamSession, roleName)
-	deleteRolePolicies(iamSession, roleName, policies)
-	detachRolePolicies(iamSession, roleName, policies)
+func HandleRolePolicies(ctx context.Context, iamSession iamiface.IAMAPI, roleName string) {
+	policies := getRolePolicies(ctx, iamSession, roleName)
+	deleteRolePolicies(ctx, iamSession, roleName, policies)
+	detachRolePolicies(ctx, iamSession, roleName, policies)
 }
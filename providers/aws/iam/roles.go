@@ -1,27 +1,38 @@
 package iam
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
 
 type Role struct {
-	RoleName        string
-	CreationDate    time.Time
-	ttl             int64
-	Tag             string
-	InstanceProfile []*iam.InstanceProfile
-	IsProtected     bool
+	RoleName          string
+	CreationDate      time.Time
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	Tag               string
+	InstanceProfile   []*iam.InstanceProfile
+	IsProtected       bool
+	Tags              map[string]string
 }
 
-func getRoles(iamSession *iam.IAM, tagName string) []Role {
-	result, err := iamSession.ListRoles(
+func getRoles(ctx context.Context, iamSession iamiface.IAMAPI, tagName string) []Role {
+	var allRoles []*iam.Role
+	err := iamSession.ListRolesPagesWithContext(ctx,
 		&iam.ListRolesInput{
 			MaxItems: aws.Int64(1000),
+		},
+		func(page *iam.ListRolesOutput, lastPage bool) bool {
+			allRoles = append(allRoles, page.Roles...)
+			return true
 		})
 
 	if err != nil {
@@ -31,16 +42,19 @@ func getRoles(iamSession *iam.IAM, tagName string) []Role {
 
 	var roles []Role
 
-	for _, role := range result.Roles {
-		tags := getRoleTags(iamSession, *role.RoleName)
-		instanceProfiles := getRoleInstanceProfile(iamSession, *role.RoleName)
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(tags, tagName)
+	for _, role := range allRoles {
+		tags := getRoleTags(ctx, iamSession, *role.RoleName)
+		instanceProfiles := getRoleInstanceProfile(ctx, iamSession, *role.RoleName)
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, tags, tagName)
 		newRole := Role{
 			RoleName: *role.RoleName,
 			CreationDate: *role.CreateDate,
 			InstanceProfile: instanceProfiles,
 			ttl: ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isProtected,
+			Tags: utils.TagsToMap(tags),
 		}
 
 
@@ -50,8 +64,8 @@ func getRoles(iamSession *iam.IAM, tagName string) []Role {
 	return roles
 }
 
-func getRoleTags(iamSession *iam.IAM, roleName string) []*iam.Tag {
-	tags, err := iamSession.ListRoleTags(
+func getRoleTags(ctx context.Context, iamSession iamiface.IAMAPI, roleName string) []*iam.Tag {
+	tags, err := iamSession.ListRoleTagsWithContext(ctx,
 		&iam.ListRoleTagsInput{
 			RoleName: aws.String(roleName),
 		})
@@ -64,28 +78,34 @@ func getRoleTags(iamSession *iam.IAM, roleName string) []*iam.Tag {
 	return tags.Tags
 }
 
-func getRoleInstanceProfile(iamSession *iam.IAM, roleName string) []*iam.InstanceProfile{
-	result, err := iamSession.ListInstanceProfilesForRole(
+func getRoleInstanceProfile(ctx context.Context, iamSession iamiface.IAMAPI, roleName string) []*iam.InstanceProfile{
+	var instanceProfiles []*iam.InstanceProfile
+	err := iamSession.ListInstanceProfilesForRolePagesWithContext(ctx,
 		&iam.ListInstanceProfilesForRoleInput{
 			MaxItems: aws.Int64(1000),
 			RoleName: aws.String(roleName),
+		},
+		func(page *iam.ListInstanceProfilesForRoleOutput, lastPage bool) bool {
+			instanceProfiles = append(instanceProfiles, page.InstanceProfiles...)
+			return true
 		})
 
 	if err != nil {
 		log.Errorf("Can't get instance profiles for role %s : %s", roleName, err)
 	}
 
-	return result.InstanceProfiles
+	return instanceProfiles
 }
 
 
 
-func DeleteExpiredRoles(iamSession *iam.IAM, tagName string, dryRun bool) {
-	roles := getRoles(iamSession, tagName)
+func DeleteExpiredRoles(ctx context.Context, iamSession iamiface.IAMAPI, tagName string, dryRun bool) error {
+	roles := getRoles(ctx, iamSession, tagName)
 	var expiredRoles []Role
 
 	for _, role := range roles {
-		if utils.CheckIfExpired(role.CreationDate, role.ttl) && !role.IsProtected {
+		if utils.CheckIfDeletable(ctx, role.CreationDate, role.ttl, role.ExpirationDate, role.DeletionScheduled, role.IsProtected, resources.IAMRole, "", role.RoleName) {
+			utils.SetReportTags(ctx, resources.IAMRole, "", role.RoleName, role.Tags)
 			expiredRoles = append(expiredRoles, role)
 		}
 	}
@@ -101,25 +121,35 @@ func DeleteExpiredRoles(iamSession *iam.IAM, tagName string, dryRun bool) {
 	log.Debug(s)
 
 	if dryRun || len(expiredRoles) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.IAMRole, "", len(expiredRoles))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug("Starting expired IAM roles deletion.")
 
 
 	for _, role := range expiredRoles {
-		HandleRolePolicies(iamSession, role.RoleName)
-		removeRoleFromInstanceProfile(iamSession, role.InstanceProfile, role.RoleName)
+		HandleRolePolicies(ctx, iamSession, role.RoleName)
+		removeRoleFromInstanceProfile(ctx, iamSession, role.InstanceProfile, role.RoleName)
 
-		_, err := iamSession.DeleteRole(
+		_, err := iamSession.DeleteRoleWithContext(ctx,
 			&iam.DeleteRoleInput{
 				RoleName: aws.String(role.RoleName),
 			})
 
 		if err != nil {
-			log.Errorf("Can't delete role %s : %s", role.RoleName, err)
-			}
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.IAMRole, "", role.RoleName).Errorf("Can't delete role %s : %s", role.RoleName, err)
+			utils.ReportDeletionError(ctx, resources.IAMRole, "", role.RoleName, err)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.IAMRole, "", role.RoleName)
 	}
+
+	return nil
 }
 
 //func deleteRoleInstanceProfiles(iamSession *iam.IAM, roleInstanceProfiles []*iam.InstanceProfile) {
@@ -136,9 +166,9 @@ func DeleteExpiredRoles(iamSession *iam.IAM, tagName string, dryRun bool) {
 //
 //}
 
-func removeRoleFromInstanceProfile(iamSession *iam.IAM, roleInstanceProfiles []*iam.InstanceProfile, roleName string) {
+func removeRoleFromInstanceProfile(ctx context.Context, iamSession iamiface.IAMAPI, roleInstanceProfiles []*iam.InstanceProfile, roleName string) {
 	for _, instanceProfile := range roleInstanceProfiles {
-		_, err := iamSession.RemoveRoleFromInstanceProfile(
+		_, err := iamSession.RemoveRoleFromInstanceProfileWithContext(ctx,
 			&iam.RemoveRoleFromInstanceProfileInput{
 				InstanceProfileName: aws.String(*instanceProfile.InstanceProfileName),
 				RoleName: aws.String(roleName),
@@ -0,0 +1,86 @@
+package iam
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
+	"time"
+)
+
+// DeletionScheduler tags expired IAM users and roles with their deletion date, IAM isn't covered by the Resource
+// Groups Tagging API
+type DeletionScheduler struct {
+	svc iamiface.IAMAPI
+}
+
+func NewDeletionScheduler(svc iamiface.IAMAPI) *DeletionScheduler {
+	return &DeletionScheduler{svc: svc}
+}
+
+func (s *DeletionScheduler) ScheduleDeletion(ctx context.Context, resourceType string, identifiers []string, deletionDate time.Time) error {
+	if len(identifiers) == 0 {
+		return fmt.Errorf("no identifier for %s", resourceType)
+	}
+
+	tags := []*iam.Tag{
+		{
+			Key:   aws.String(utils.DeletionScheduledTagName),
+			Value: aws.String(deletionDate.Format(time.RFC3339)),
+		},
+	}
+
+	var err error
+	switch resourceType {
+	case resources.IAMRole:
+		_, err = s.svc.TagRoleWithContext(ctx,
+			&iam.TagRoleInput{
+				RoleName: aws.String(identifiers[0]),
+				Tags:     tags,
+			})
+	case resources.IAMUser:
+		_, err = s.svc.TagUserWithContext(ctx,
+			&iam.TagUserInput{
+				UserName: aws.String(identifiers[0]),
+				Tags:     tags,
+			})
+	default:
+		err = fmt.Errorf("can't schedule the deletion of %s %s", resourceType, identifiers[0])
+	}
+
+	return err
+}
+
+func (s *DeletionScheduler) GetOwner(ctx context.Context, resourceType string, identifiers []string) (string, error) {
+	if len(identifiers) == 0 {
+		return "", fmt.Errorf("no identifier for %s", resourceType)
+	}
+
+	var tags []*iam.Tag
+	switch resourceType {
+	case resources.IAMRole:
+		result, err := s.svc.ListRoleTagsWithContext(ctx, &iam.ListRoleTagsInput{RoleName: aws.String(identifiers[0])})
+		if err != nil {
+			return "", err
+		}
+		tags = result.Tags
+	case resources.IAMUser:
+		result, err := s.svc.ListUserTagsWithContext(ctx, &iam.ListUserTagsInput{UserName: aws.String(identifiers[0])})
+		if err != nil {
+			return "", err
+		}
+		tags = result.Tags
+	default:
+		return "", fmt.Errorf("can't get the tags of %s %s", resourceType, identifiers[0])
+	}
+
+	tagsMap := make(map[string]string)
+	for _, tag := range tags {
+		tagsMap[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+
+	return utils.GetOwnerFromTags(tagsMap), nil
+}
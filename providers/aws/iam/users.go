@@ -1,26 +1,37 @@
 package iam
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/iam/iamiface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
 
 type User struct {
-	UserName     string
-	CreationDate time.Time
-	ttl          int64
-	Tag          string
-	IsProtected  bool
+	UserName          string
+	CreationDate      time.Time
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	Tag               string
+	IsProtected       bool
+	Tags              map[string]string
 }
 
-func getUsers(iamSession *iam.IAM, tagName string) []User {
-	result, err := iamSession.ListUsers(
+func getUsers(ctx context.Context, iamSession iamiface.IAMAPI, tagName string) []User {
+	var allUsers []*iam.User
+	err := iamSession.ListUsersPagesWithContext(ctx,
 		&iam.ListUsersInput{
 			MaxItems: aws.Int64(1000),
+		},
+		func(page *iam.ListUsersOutput, lastPage bool) bool {
+			allUsers = append(allUsers, page.Users...)
+			return true
 		})
 
 	if err != nil {
@@ -30,14 +41,17 @@ func getUsers(iamSession *iam.IAM, tagName string) []User {
 
 	var users []User
 
-	for _, user := range result.Users {
-		tags := getUserTags(iamSession, *user.UserName)
-		_ , ttl, isProtected, _, _ := utils.GetEssentialTags(tags, tagName)
+	for _, user := range allUsers {
+		tags := getUserTags(ctx, iamSession, *user.UserName)
+		_ , ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, tags, tagName)
 		newUser := User{
 			UserName: *user.UserName,
 			CreationDate: *user.CreateDate,
 			ttl: ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isProtected,
+			Tags: utils.TagsToMap(tags),
 		}
 
 		users = append(users, newUser)
@@ -47,8 +61,8 @@ func getUsers(iamSession *iam.IAM, tagName string) []User {
 	return users
 }
 
-func getUserTags(iamSession *iam.IAM, roleName string) []*iam.Tag {
-	tags, err := iamSession.ListUserTags(
+func getUserTags(ctx context.Context, iamSession iamiface.IAMAPI, roleName string) []*iam.Tag {
+	tags, err := iamSession.ListUserTagsWithContext(ctx,
 		&iam.ListUserTagsInput{
 			UserName: aws.String(roleName),
 		})
@@ -61,10 +75,17 @@ func getUserTags(iamSession *iam.IAM, roleName string) []*iam.Tag {
 	return tags.Tags
 }
 
-func getUserAccessKeysIds(iamSession *iam.IAM, userName string) []*string {
-	result, err := iamSession.ListAccessKeys(
+func getUserAccessKeysIds(ctx context.Context, iamSession iamiface.IAMAPI, userName string) []*string {
+	var accessKeysIds []*string
+	err := iamSession.ListAccessKeysPagesWithContext(ctx,
 		&iam.ListAccessKeysInput{
 			UserName: aws.String(userName),
+		},
+		func(page *iam.ListAccessKeysOutput, lastPage bool) bool {
+			for _, accessKey := range page.AccessKeyMetadata {
+				accessKeysIds = append(accessKeysIds, accessKey.AccessKeyId)
+			}
+			return true
 		})
 
 	if err != nil {
@@ -72,16 +93,11 @@ func getUserAccessKeysIds(iamSession *iam.IAM, userName string) []*string {
 		return nil
 	}
 
-	var accessKeysIds []*string
-	for _, accessKey := range result.AccessKeyMetadata {
-		accessKeysIds = append(accessKeysIds, accessKey.AccessKeyId)
-	}
-
 	return accessKeysIds
 }
 
-func deleteUserAccessKey(iamSession *iam.IAM, userName string, accessKeyId string) {
-	_, err := iamSession.DeleteAccessKey(
+func deleteUserAccessKey(ctx context.Context, iamSession iamiface.IAMAPI, userName string, accessKeyId string) {
+	_, err := iamSession.DeleteAccessKeyWithContext(ctx,
 		&iam.DeleteAccessKeyInput{
 			UserName: aws.String(userName),
 			AccessKeyId: aws.String(accessKeyId),
@@ -92,20 +108,21 @@ func deleteUserAccessKey(iamSession *iam.IAM, userName string, accessKeyId strin
 	}
 }
 
-func deleteExpiredUserAccessKeys(iamSession *iam.IAM, userName string) {
-	accessKeysIds := getUserAccessKeysIds(iamSession, userName)
+func deleteExpiredUserAccessKeys(ctx context.Context, iamSession iamiface.IAMAPI, userName string) {
+	accessKeysIds := getUserAccessKeysIds(ctx, iamSession, userName)
 
 	for _, accessKeyId := range accessKeysIds {
-		deleteUserAccessKey(iamSession, userName, *accessKeyId)
+		deleteUserAccessKey(ctx, iamSession, userName, *accessKeyId)
 	}
 }
 
-func DeleteExpiredUsers(iamSession *iam.IAM, tagName string, dryRun bool) {
-	users := getUsers(iamSession, tagName)
+func DeleteExpiredUsers(ctx context.Context, iamSession iamiface.IAMAPI, tagName string, dryRun bool) error {
+	users := getUsers(ctx, iamSession, tagName)
 	var expiredUsers []User
 
 	for _, user := range users {
-		if utils.CheckIfExpired(user.CreationDate, user.ttl) && !user.IsProtected {
+		if utils.CheckIfDeletable(ctx, user.CreationDate, user.ttl, user.ExpirationDate, user.DeletionScheduled, user.IsProtected, resources.IAMUser, "", user.UserName) {
+			utils.SetReportTags(ctx, resources.IAMUser, "", user.UserName, user.Tags)
 			expiredUsers = append(expiredUsers, user)
 		}
 	}
@@ -121,21 +138,31 @@ func DeleteExpiredUsers(iamSession *iam.IAM, tagName string, dryRun bool) {
 	log.Debug(s)
 
 	if dryRun || len(expiredUsers) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.IAMUser, "", len(expiredUsers))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug("Starting expired IAM users deletion.")
 
 	for _, user := range expiredUsers {
-		HandleUserPolicies(iamSession, user.UserName)
-		deleteExpiredUserAccessKeys(iamSession, user.UserName)
+		HandleUserPolicies(ctx, iamSession, user.UserName)
+		deleteExpiredUserAccessKeys(ctx, iamSession, user.UserName)
 
-		_, userErr := iamSession.DeleteUser(
+		_, userErr := iamSession.DeleteUserWithContext(ctx,
 			&iam.DeleteUserInput{
 				UserName: aws.String(user.UserName),
 			})
 		if userErr != nil {
-			log.Errorf("Can't delete user %s : %s", user.UserName, userErr.Error())
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.IAMUser, "", user.UserName).Errorf("Can't delete user %s : %s", user.UserName, userErr.Error())
+			utils.ReportDeletionError(ctx, resources.IAMUser, "", user.UserName, userErr)
+			continue
 		}
+		utils.ReportDeleted(ctx, resources.IAMUser, "", user.UserName)
 	}
+
+	return nil
 }
@@ -1,81 +1,42 @@
 package aws
 
 import (
-	"github.com/Qovery/pleco/utils"
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
 	log "github.com/sirupsen/logrus"
-	"time"
 )
 
-type CompleteKey struct {
-	KeyId        string
-	TTL          int64
-	Tag          string
-	Status       string
-	CreationDate time.Time
-	IsProtected  bool
+// kmsKeyCleaner deletes the expired KMS keys of a region
+type kmsKeyCleaner struct {
+	svc kmsiface.KMSAPI
 }
 
+func init() {
+	cleaner.Register("kms", func(sess *session.Session, region string) cleaner.Cleaner {
+		return kmsKeyCleaner{svc: kms.New(sess)}
+	})
+}
 
-func getKeys(svc kms.KMS) []*kms.KeyListEntry{
+func getKeys(ctx context.Context, svc kmsiface.KMSAPI) []*kms.KeyListEntry{
 	input := &kms.ListKeysInput{
 		Limit: aws.Int64(1000),
 	}
 
-	keys, err := svc.ListKeys(input)
-	handleKMSError(err)
-
-	return keys.Keys
-}
-
-func getCompleteKey(svc kms.KMS, keyId *string, tagName string) CompleteKey {
-	tags := getKeyTags(svc,keyId)
-	metaData := getKeyMetadata(svc,keyId)
-
-	_, ttl, isProtected, _, _ := utils.GetEssentialTags(tags, tagName)
-
-
-	return CompleteKey{
-		KeyId: *keyId,
-		Status: *metaData.KeyMetadata.KeyState,
-		CreationDate: *metaData.KeyMetadata.CreationDate,
-		TTL: ttl,
-		IsProtected: isProtected,
-	}
-}
-
-func deleteKey(svc kms.KMS, keyId string) (*kms.ScheduleKeyDeletionOutput,error){
-	input := &kms.ScheduleKeyDeletionInput{
-		KeyId:               aws.String(keyId),
-		PendingWindowInDays: aws.Int64(7),
-	}
-
-	result, err := svc.ScheduleKeyDeletion(input)
-	handleKMSError(err)
-
-	return result,err
-}
-
-func getKeyTags (svc kms.KMS, keyId *string) []*kms.Tag {
-	input := &kms.ListResourceTagsInput{
-		KeyId: aws.String(*keyId),
-	}
-
-	tags, err := svc.ListResourceTags(input)
-	handleKMSError(err)
-
-	return tags.Tags
-}
-
-func getKeyMetadata (svc kms.KMS,keyId *string) *kms.DescribeKeyOutput{
-	input := &kms.DescribeKeyInput{KeyId: keyId}
-
-	data, err := svc.DescribeKey(input)
+	var keys []*kms.KeyListEntry
+	err := svc.ListKeysPagesWithContext(ctx, input,
+		func(page *kms.ListKeysOutput, lastPage bool) bool {
+			keys = append(keys, page.Keys...)
+			return true
+		})
 	handleKMSError(err)
 
-	return data
+	return keys
 }
 
 func handleKMSError (error error) {
@@ -102,36 +63,68 @@ func handleKMSError (error error) {
 	}
 }
 
-func DeleteExpiredKeys(svc kms.KMS, tagName string, dryRun bool) {
-	keys := getKeys(svc)
-	region := svc.Config.Region
-	var expiredKeys []CompleteKey
-	for _, key := range keys {
-		completeKey := getCompleteKey(svc, key.KeyId, tagName)
-
-		if completeKey.Status != "PendingDeletion" && completeKey.Status != "Disabled" &&
-			utils.CheckIfExpired(completeKey.CreationDate,  completeKey.TTL) && !completeKey.IsProtected {
-			if completeKey.Tag == tagName || tagName == "ttl"{
-				expiredKeys = append(expiredKeys, completeKey)
-			}
+func (c kmsKeyCleaner) Name() string {
+	return resources.KMSKey
+}
+
+// List returns the keys not pending deletion nor disabled, keys whose details can't be read are skipped
+func (c kmsKeyCleaner) List(ctx context.Context) ([]cleaner.Resource, error) {
+	var resources []cleaner.Resource
+	for _, key := range getKeys(ctx, c.svc) {
+		metaData, err := c.svc.DescribeKeyWithContext(ctx, &kms.DescribeKeyInput{KeyId: key.KeyId})
+		if err != nil {
+			handleKMSError(err)
+			continue
+		}
+
+		status := aws.StringValue(metaData.KeyMetadata.KeyState)
+		if status == kms.KeyStatePendingDeletion || status == kms.KeyStateDisabled {
+			continue
 		}
-	}
 
-	count, start := utils.ElemToDeleteFormattedInfos("expired KMS key", len(expiredKeys), *region)
+		result, err := c.svc.ListResourceTagsWithContext(ctx, &kms.ListResourceTagsInput{KeyId: key.KeyId})
+		if err != nil {
+			handleKMSError(err)
+			continue
+		}
 
-	log.Debug(count)
+		tags := make(map[string]string)
+		for _, tag := range result.Tags {
+			tags[aws.StringValue(tag.TagKey)] = aws.StringValue(tag.TagValue)
+		}
 
-	if dryRun || len(expiredKeys) == 0 {
-		return
+		resources = append(resources, cleaner.Resource{
+			Id:           aws.StringValue(key.KeyId),
+			Arn:          aws.StringValue(key.KeyArn),
+			CreationDate: aws.TimeValue(metaData.KeyMetadata.CreationDate),
+			Tags:         tags,
+		})
 	}
 
-	log.Debug(start)
+	return resources, nil
+}
 
-	for _, key := range expiredKeys {
-		_, deletionErr := deleteKey(svc, key.KeyId)
-		if deletionErr != nil {
-			log.Errorf("Deletion KMS key error %s/%s: %s",
-				key.KeyId, *region, deletionErr)
-		}
+func (c kmsKeyCleaner) Delete(ctx context.Context, resource cleaner.Resource) error {
+	_, err := c.svc.ScheduleKeyDeletionWithContext(ctx,
+		&kms.ScheduleKeyDeletionInput{
+			KeyId:               aws.String(resource.Id),
+			PendingWindowInDays: aws.Int64(7),
+		})
+
+	return err
+}
+
+func (c kmsKeyCleaner) Tag(ctx context.Context, resource cleaner.Resource, tags map[string]string) error {
+	var kmsTags []*kms.Tag
+	for key, value := range tags {
+		kmsTags = append(kmsTags, &kms.Tag{TagKey: aws.String(key), TagValue: aws.String(value)})
 	}
-}
\ No newline at end of file
+
+	_, err := c.svc.TagResourceWithContext(ctx,
+		&kms.TagResourceInput{
+			KeyId: aws.String(resource.Id),
+			Tags:  kmsTags,
+		})
+
+	return err
+}
@@ -0,0 +1,389 @@
+// Package leaks detects resources without ttl showing clear orphan signals, left behind by failed or partial
+// teardowns, and deletes the categories enabled for deletion.
+package leaks
+
+import (
+	"context"
+	"fmt"
+	ec22 "github.com/Qovery/pleco/providers/aws/ec2"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// Categories are the kinds of orphaned resources detected
+var Categories = []string{"eip", "ebs", "target-group", "security-group", "vpc"}
+
+// Config configures the leak detection
+type Config struct {
+	// MinAge is how long volumes stay detached and VPCs empty before being reported, other categories have no
+	// creation date
+	MinAge time.Duration
+	// Delete enables the deletion of the categories, leaks are only reported otherwise
+	Delete map[string]bool
+}
+
+// IsCategory returns true if the name is a leak category
+func IsCategory(name string) bool {
+	for _, category := range Categories {
+		if category == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// leakedResource is an orphaned resource with what's needed to delete it
+type leakedResource struct {
+	utils.Leak
+	isProtected bool
+	delete      func(ctx context.Context) error
+}
+
+// isTTLManaged returns true if the resource has a ttl or expiration date tag, pleco deletes it once expired anyway
+func isTTLManaged(ctx context.Context, tags interface{}, tagName string) bool {
+	_, _, _, _, ttlTag, expirationDate, _ := utils.GetEssentialTags(ctx, tags, tagName)
+	return ttlTag != "" || !expirationDate.IsZero()
+}
+
+// DetectLeaks reports the orphaned resources of the region and deletes the ones of the categories enabled for
+// deletion, unless it's a dry run
+func DetectLeaks(ctx context.Context, ec2Session ec2iface.EC2API, elbSession elbv2iface.ELBV2API, region string, config Config, tagName string, dryRun bool) error {
+	interfaces, err := getNetworkInterfaces(ctx, ec2Session)
+	if err != nil {
+		return fmt.Errorf("can't list network interfaces: %s", err)
+	}
+
+	var leaks []leakedResource
+	var errs []error
+	for _, detect := range []func() ([]leakedResource, error){
+		func() ([]leakedResource, error) { return getUnassociatedAddresses(ctx, ec2Session, region, tagName) },
+		func() ([]leakedResource, error) {
+			return getDetachedVolumes(ctx, ec2Session, region, tagName, config.MinAge)
+		},
+		func() ([]leakedResource, error) { return getUnusedTargetGroups(ctx, elbSession, region, tagName) },
+		func() ([]leakedResource, error) {
+			return getUnusedSecurityGroups(ctx, ec2Session, region, tagName, interfaces)
+		},
+		func() ([]leakedResource, error) {
+			return getEmptyVPCs(ctx, ec2Session, region, tagName, config.MinAge, interfaces)
+		},
+	} {
+		detected, err := detect()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		leaks = append(leaks, detected...)
+	}
+
+	deletable := make(map[string][]leakedResource)
+	deletableCount := 0
+	for _, leak := range leaks {
+		utils.ReportLeak(ctx, leak.Leak)
+
+		if config.Delete[leak.Category] && utils.CheckIfLeakDeletable(ctx, leak.CreationDate, leak.isProtected, leak.Type, region, leak.Name) {
+			deletable[leak.Category] = append(deletable[leak.Category], leak)
+			deletableCount++
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("leaked resource", deletableCount, region)
+
+	log.Debug(count)
+
+	if dryRun || deletableCount == 0 {
+		return utils.JoinErrors(errs...)
+	}
+
+	log.Debug(start)
+
+	for _, category := range Categories {
+		categoryLeaks := deletable[category]
+		if len(categoryLeaks) == 0 {
+			continue
+		}
+
+		limitErr := utils.ReserveDeletions(ctx, categoryLeaks[0].Type, region, len(categoryLeaks))
+		if limitErr != nil {
+			errs = append(errs, limitErr)
+			continue
+		}
+
+		for _, leak := range categoryLeaks {
+			utils.ResourceLog(ctx, utils.ActionDelete, leak.Type, region, leak.Name).Infof("Deleting leaked %s %s in %s.", leak.Type, leak.Name, region)
+			deletionErr := leak.delete(ctx)
+			if deletionErr != nil {
+				utils.ResourceLog(ctx, utils.ActionDeleteFailed, leak.Type, region, leak.Name).Errorf("Deletion %s error %s/%s: %s",
+					leak.Type, leak.Name, region, deletionErr)
+				utils.ReportDeletionError(ctx, leak.Type, region, leak.Name, deletionErr)
+				continue
+			}
+			utils.ReportDeleted(ctx, leak.Type, region, leak.Name)
+		}
+	}
+
+	return utils.JoinErrors(errs...)
+}
+
+func getNetworkInterfaces(ctx context.Context, ec2Session ec2iface.EC2API) ([]*ec2.NetworkInterface, error) {
+	var interfaces []*ec2.NetworkInterface
+	err := ec2Session.DescribeNetworkInterfacesPagesWithContext(ctx, &ec2.DescribeNetworkInterfacesInput{},
+		func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+			interfaces = append(interfaces, page.NetworkInterfaces...)
+			return true
+		})
+
+	return interfaces, err
+}
+
+// getUnassociatedAddresses returns the elastic IPs associated with no instance nor network interface
+func getUnassociatedAddresses(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string) ([]leakedResource, error) {
+	output, err := ec2Session.DescribeAddressesWithContext(ctx, &ec2.DescribeAddressesInput{})
+	if err != nil {
+		return nil, fmt.Errorf("can't list elastic IPs: %s", err)
+	}
+
+	var leaks []leakedResource
+	for _, address := range output.Addresses {
+		if address.AssociationId != nil || address.NetworkInterfaceId != nil || address.InstanceId != nil || isTTLManaged(ctx, address.Tags, tagName) {
+			continue
+		}
+
+		input := &ec2.ReleaseAddressInput{AllocationId: address.AllocationId}
+		name := aws.StringValue(address.AllocationId)
+		if address.AllocationId == nil {
+			input = &ec2.ReleaseAddressInput{PublicIp: address.PublicIp}
+			name = aws.StringValue(address.PublicIp)
+		}
+
+		_, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, address.Tags, tagName)
+		leaks = append(leaks, leakedResource{
+			Leak:        utils.Leak{Category: "eip", Type: resources.ElasticIP, Name: name, Region: region, Reason: "not associated"},
+			isProtected: isProtected,
+			delete: func(ctx context.Context) error {
+				_, err := ec2Session.ReleaseAddressWithContext(ctx, input)
+				return err
+			},
+		})
+	}
+
+	return leaks, nil
+}
+
+// getDetachedVolumes returns the volumes available, attached to no instance, created more than minAge ago
+func getDetachedVolumes(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, minAge time.Duration) ([]leakedResource, error) {
+	input := &ec2.DescribeVolumesInput{
+		Filters: []*ec2.Filter{{Name: aws.String("status"), Values: []*string{aws.String("available")}}},
+	}
+
+	var leaks []leakedResource
+	err := ec2Session.DescribeVolumesPagesWithContext(ctx, input,
+		func(page *ec2.DescribeVolumesOutput, lastPage bool) bool {
+			for _, volume := range page.Volumes {
+				if utils.Now(ctx).Sub(aws.TimeValue(volume.CreateTime)) < minAge || isTTLManaged(ctx, volume.Tags, tagName) {
+					continue
+				}
+
+				volumeId := volume.VolumeId
+				_, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, volume.Tags, tagName)
+				leaks = append(leaks, leakedResource{
+					Leak: utils.Leak{Category: "ebs", Type: resources.EBSVolume, Name: *volumeId, Region: region,
+						Reason: "detached", CreationDate: aws.TimeValue(volume.CreateTime)},
+					isProtected: isProtected,
+					delete: func(ctx context.Context) error {
+						_, err := ec2Session.DeleteVolumeWithContext(ctx, &ec2.DeleteVolumeInput{VolumeId: volumeId})
+						return err
+					},
+				})
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't list EBS volumes: %s", err)
+	}
+
+	return leaks, nil
+}
+
+// getUnusedTargetGroups returns the target groups of no load balancer
+func getUnusedTargetGroups(ctx context.Context, elbSession elbv2iface.ELBV2API, region string, tagName string) ([]leakedResource, error) {
+	var targetGroups []*elbv2.TargetGroup
+	err := elbSession.DescribeTargetGroupsPagesWithContext(ctx, &elbv2.DescribeTargetGroupsInput{},
+		func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+			for _, targetGroup := range page.TargetGroups {
+				if len(targetGroup.LoadBalancerArns) == 0 {
+					targetGroups = append(targetGroups, targetGroup)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't list target groups: %s", err)
+	}
+
+	var arns []string
+	for _, targetGroup := range targetGroups {
+		arns = append(arns, aws.StringValue(targetGroup.TargetGroupArn))
+	}
+
+	tags, err := ec22.DescribeTags(ctx, elbSession, arns)
+	if err != nil {
+		return nil, fmt.Errorf("can't get target groups tags: %s", err)
+	}
+
+	var leaks []leakedResource
+	for _, targetGroup := range targetGroups {
+		targetGroupArn := targetGroup.TargetGroupArn
+		if isTTLManaged(ctx, tags[*targetGroupArn], tagName) {
+			continue
+		}
+
+		_, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, tags[*targetGroupArn], tagName)
+		leaks = append(leaks, leakedResource{
+			Leak:        utils.Leak{Category: "target-group", Type: resources.TargetGroup, Name: aws.StringValue(targetGroup.TargetGroupName), Region: region, Reason: "not used by any load balancer"},
+			isProtected: isProtected,
+			delete: func(ctx context.Context) error {
+				_, err := elbSession.DeleteTargetGroupWithContext(ctx, &elbv2.DeleteTargetGroupInput{TargetGroupArn: targetGroupArn})
+				return err
+			},
+		})
+	}
+
+	return leaks, nil
+}
+
+// getUnusedSecurityGroups returns the security groups of no network interface nor referenced by another security group,
+// default security groups can't be deleted
+func getUnusedSecurityGroups(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, interfaces []*ec2.NetworkInterface) ([]leakedResource, error) {
+	var securityGroups []*ec2.SecurityGroup
+	err := ec2Session.DescribeSecurityGroupsPagesWithContext(ctx, &ec2.DescribeSecurityGroupsInput{},
+		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+			securityGroups = append(securityGroups, page.SecurityGroups...)
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't list security groups: %s", err)
+	}
+
+	used := make(map[string]bool)
+	for _, networkInterface := range interfaces {
+		for _, group := range networkInterface.Groups {
+			used[aws.StringValue(group.GroupId)] = true
+		}
+	}
+	for _, securityGroup := range securityGroups {
+		for _, permission := range append(securityGroup.IpPermissions, securityGroup.IpPermissionsEgress...) {
+			for _, pair := range permission.UserIdGroupPairs {
+				if aws.StringValue(pair.GroupId) != aws.StringValue(securityGroup.GroupId) {
+					used[aws.StringValue(pair.GroupId)] = true
+				}
+			}
+		}
+	}
+
+	var leaks []leakedResource
+	for _, securityGroup := range securityGroups {
+		groupId := securityGroup.GroupId
+		if used[*groupId] || aws.StringValue(securityGroup.GroupName) == "default" || isTTLManaged(ctx, securityGroup.Tags, tagName) {
+			continue
+		}
+
+		_, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, securityGroup.Tags, tagName)
+		leaks = append(leaks, leakedResource{
+			Leak:        utils.Leak{Category: "security-group", Type: resources.SecurityGroup, Name: *groupId, Region: region, Reason: "not used by any network interface nor security group"},
+			isProtected: isProtected,
+			delete: func(ctx context.Context) error {
+				_, err := ec2Session.DeleteSecurityGroupWithContext(ctx, &ec2.DeleteSecurityGroupInput{GroupId: groupId})
+				return err
+			},
+		})
+	}
+
+	return leaks, nil
+}
+
+// getEmptyVPCs returns the non default VPCs without subnet nor network interface whose creationDate tag is older than
+// minAge, VPCs have no creation date otherwise
+func getEmptyVPCs(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, minAge time.Duration, interfaces []*ec2.NetworkInterface) ([]leakedResource, error) {
+	var vpcs []*ec2.Vpc
+	err := ec2Session.DescribeVpcsPagesWithContext(ctx, &ec2.DescribeVpcsInput{},
+		func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+			vpcs = append(vpcs, page.Vpcs...)
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't list VPCs: %s", err)
+	}
+
+	var subnets []*ec2.Subnet
+	err = ec2Session.DescribeSubnetsPagesWithContext(ctx, &ec2.DescribeSubnetsInput{},
+		func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+			subnets = append(subnets, page.Subnets...)
+			return true
+		})
+	if err != nil {
+		return nil, fmt.Errorf("can't list subnets: %s", err)
+	}
+
+	used := make(map[string]bool)
+	for _, subnet := range subnets {
+		used[aws.StringValue(subnet.VpcId)] = true
+	}
+	for _, networkInterface := range interfaces {
+		used[aws.StringValue(networkInterface.VpcId)] = true
+	}
+
+	var leaks []leakedResource
+	for _, vpc := range vpcs {
+		vpcId := vpc.VpcId
+		creationDate, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, vpc.Tags, tagName)
+		if aws.BoolValue(vpc.IsDefault) || used[*vpcId] || isTTLManaged(ctx, vpc.Tags, tagName) {
+			continue
+		}
+		if creationDate.IsZero() || utils.Now(ctx).Sub(creationDate) < minAge {
+			continue
+		}
+
+		leaks = append(leaks, leakedResource{
+			Leak:        utils.Leak{Category: "vpc", Type: resources.VPC, Name: *vpcId, Region: region, Reason: "no subnet nor network interface", CreationDate: creationDate},
+			isProtected: isProtected,
+			delete: func(ctx context.Context) error {
+				return deleteEmptyVPC(ctx, ec2Session, *vpcId)
+			},
+		})
+	}
+
+	return leaks, nil
+}
+
+// deleteEmptyVPC deletes the internet gateways attached to the VPC, then the VPC
+func deleteEmptyVPC(ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) error {
+	output, err := ec2Session.DescribeInternetGatewaysWithContext(ctx, &ec2.DescribeInternetGatewaysInput{
+		Filters: []*ec2.Filter{{Name: aws.String("attachment.vpc-id"), Values: []*string{aws.String(vpcId)}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, gateway := range output.InternetGateways {
+		_, err = ec2Session.DetachInternetGatewayWithContext(ctx, &ec2.DetachInternetGatewayInput{InternetGatewayId: gateway.InternetGatewayId, VpcId: aws.String(vpcId)})
+		if err != nil {
+			return err
+		}
+		_, err = ec2Session.DeleteInternetGatewayWithContext(ctx, &ec2.DeleteInternetGatewayInput{InternetGatewayId: gateway.InternetGatewayId})
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ec2Session.DeleteVpcWithContext(ctx, &ec2.DeleteVpcInput{VpcId: aws.String(vpcId)})
+	return err
+}
@@ -1,10 +1,13 @@
 package logs
 
 import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs/cloudwatchlogsiface"
 	log "github.com/sirupsen/logrus"
 	"strings"
 	"time"
@@ -14,53 +17,64 @@ type CompleteLogGroup struct {
 	logGroupName string
 	tag string
 	ttl int64
+	ExpirationDate time.Time
+	DeletionScheduled time.Time
 	creationDate time.Time
 	clusterId string
 	IsProtected bool
+	Tags map[string]string
 }
 
-func getCloudwatchLogs(svc cloudwatchlogs.CloudWatchLogs)  []*cloudwatchlogs.LogGroup {
+func getCloudwatchLogs(ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI)  []*cloudwatchlogs.LogGroup {
 	input := &cloudwatchlogs.DescribeLogGroupsInput{
 		Limit: aws.Int64(50),
 	}
 
-	logs, err := svc.DescribeLogGroups(input)
+	var logGroups []*cloudwatchlogs.LogGroup
+	err := svc.DescribeLogGroupsPagesWithContext(ctx, input,
+		func(page *cloudwatchlogs.DescribeLogGroupsOutput, lastPage bool) bool {
+			logGroups = append(logGroups, page.LogGroups...)
+			return true
+		})
 	handleCloudwatchLogsError(err)
 
-	return logs.LogGroups
+	return logGroups
 }
 
-func getCompleteLogGroup(svc cloudwatchlogs.CloudWatchLogs, log cloudwatchlogs.LogGroup, tagName string) CompleteLogGroup {
-	tags := getLogGroupTag(svc, *log.LogGroupName)
-	_, ttl, isprotected, clusterId, tag := utils.GetEssentialTags(tags, tagName)
+func getCompleteLogGroup(ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, log cloudwatchlogs.LogGroup, tagName string) CompleteLogGroup {
+	tags := getLogGroupTag(ctx, svc, *log.LogGroupName)
+	_, ttl, isprotected, clusterId, tag, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, tags, tagName)
 
 	return CompleteLogGroup{
 		logGroupName:  *log.LogGroupName,
 		creationDate: time.Unix(*log.CreationTime/1000,0),
 		ttl:  ttl,
+		ExpirationDate: expirationDate,
+		DeletionScheduled: deletionScheduled,
 		clusterId: clusterId,
 		IsProtected: isprotected,
+		Tags: utils.TagsToMap(tags),
 		tag: tag,
 	}
 }
 
-func deleteCloudwatchLog (svc cloudwatchlogs.CloudWatchLogs, logGroupName string) (string, error) {
+func deleteCloudwatchLog (ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, logGroupName string) (string, error) {
 	input := &cloudwatchlogs.DeleteLogGroupInput{
 		LogGroupName: aws.String(logGroupName),
 	}
 
-	result, err := svc.DeleteLogGroup(input)
+	result, err := svc.DeleteLogGroupWithContext(ctx, input)
 	handleCloudwatchLogsError(err)
 
 	return result.String(), err
 }
 
-func getLogGroupTag (svc cloudwatchlogs.CloudWatchLogs, logGroupName string) map[string]*string{
+func getLogGroupTag (ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, logGroupName string) map[string]*string{
 	input := &cloudwatchlogs.ListTagsLogGroupInput{
 		LogGroupName: aws.String(logGroupName),
 	}
 
-	tags, err := svc.ListTagsLogGroup(input)
+	tags, err := svc.ListTagsLogGroupWithContext(ctx, input)
 	handleCloudwatchLogsError(err)
 
 	return tags.Tags
@@ -90,58 +104,67 @@ func handleCloudwatchLogsError(err error){
 	}
 }
 
-func DeleteExpiredLogs(svc cloudwatchlogs.CloudWatchLogs, tagName string, dryRun bool) {
-	logs := getCloudwatchLogs(svc)
-	region := svc.Config.Region
+func DeleteExpiredLogs(ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, region string, tagName string, dryRun bool) error {
+	logs := getCloudwatchLogs(ctx, svc)
 	var expiredLogs []CompleteLogGroup
 	for _, log := range logs {
-		completeLogGroup := getCompleteLogGroup(svc, *log, tagName)
-		if utils.CheckIfExpired(completeLogGroup.creationDate, completeLogGroup.ttl) && !completeLogGroup.IsProtected{
+		completeLogGroup := getCompleteLogGroup(ctx, svc, *log, tagName)
+		if utils.CheckIfDeletable(ctx, completeLogGroup.creationDate, completeLogGroup.ttl, completeLogGroup.ExpirationDate, completeLogGroup.DeletionScheduled, completeLogGroup.IsProtected, resources.LogGroup, region, completeLogGroup.logGroupName){
+			utils.SetReportTags(ctx, resources.LogGroup, region, completeLogGroup.logGroupName, completeLogGroup.Tags)
 			expiredLogs = append(expiredLogs, completeLogGroup)
 		}
 	}
 
-	count, start:= utils.ElemToDeleteFormattedInfos("expired Cloudwatch log", len(expiredLogs), *region)
+	count, start:= utils.ElemToDeleteFormattedInfos("expired Cloudwatch log", len(expiredLogs), region)
 
 	log.Debug(count)
 
 	if dryRun || len(expiredLogs) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.LogGroup, region, len(expiredLogs))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug(start)
 
 	for _, completeLog := range expiredLogs {
-		_, deletionErr := deleteCloudwatchLog(svc, completeLog.logGroupName)
+		_, deletionErr := deleteCloudwatchLog(ctx, svc, completeLog.logGroupName)
 		if deletionErr != nil {
-			log.Errorf("Deletion Cloudwatch error %s/%s: %s",
-				completeLog.logGroupName, *svc.Config.Region, deletionErr)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.LogGroup, region, completeLog.logGroupName).Errorf("Deletion Cloudwatch error %s/%s: %s",
+				completeLog.logGroupName, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.LogGroup, region, completeLog.logGroupName, deletionErr)
+			continue
 		}
+		utils.ReportDeleted(ctx, resources.LogGroup, region, completeLog.logGroupName)
 	}
 
+	return nil
 }
 
-func addTtlToLogGroup(svc cloudwatchlogs.CloudWatchLogs, logGroupName string) (string,error) {
+func addTtlToLogGroup(ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, logGroupName string, tagName string) (string,error) {
 	input := &cloudwatchlogs.TagLogGroupInput{
 		LogGroupName: aws.String(logGroupName),
-		Tags: aws.StringMap(map[string]string{"ttl": "1" }),
+		Tags: aws.StringMap(map[string]string{tagName: "1" }),
 	}
 
-	result, err := svc.TagLogGroup(input)
+	result, err := svc.TagLogGroupWithContext(ctx, input)
 	handleCloudwatchLogsError(err)
 
 	return result.String(), err
 }
 
-func TagLogsForDeletion(svc cloudwatchlogs.CloudWatchLogs, tagName string, clusterId string) error {
-	logs := getCloudwatchLogs(svc)
+func TagLogsForDeletion(ctx context.Context, svc cloudwatchlogsiface.CloudWatchLogsAPI, tagName string, clusterId string) error {
+	logs := getCloudwatchLogs(ctx, svc)
 	var numberOfLogsToTag int64
 
 	for _, log := range logs {
-		completeLogGroup := getCompleteLogGroup(svc, *log, tagName)
+		completeLogGroup := getCompleteLogGroup(ctx, svc, *log, tagName)
 
-		if completeLogGroup.ttl == 0 && strings.Contains(completeLogGroup.logGroupName, clusterId){
-			_, err := addTtlToLogGroup(svc, completeLogGroup.logGroupName)
+		if completeLogGroup.ttl == 0 && strings.Contains(completeLogGroup.logGroupName, clusterId) && !utils.IsExcluded(ctx, completeLogGroup.logGroupName) {
+			_, err := addTtlToLogGroup(ctx, svc, completeLogGroup.logGroupName, tagName)
 			if err != nil {
 				return err
 			}
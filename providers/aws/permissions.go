@@ -0,0 +1,351 @@
+package aws
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+// serviceActions are the IAM actions used by the cleaner of each service
+var serviceActions = map[string][]string{
+	"eks": {
+		"eks:ListClusters", "eks:DescribeCluster", "eks:ListNodegroups", "eks:DescribeNodegroup",
+		"eks:DeleteNodegroup", "eks:DeleteCluster",
+		"ec2:DescribeVpcs", "ec2:DescribeVolumes", "ec2:CreateTags",
+		"elasticloadbalancing:DescribeLoadBalancers", "elasticloadbalancing:DescribeTags", "elasticloadbalancing:AddTags",
+		"logs:DescribeLogGroups", "logs:ListTagsLogGroup", "logs:TagLogGroup",
+		"rds:DescribeDBSubnetGroups", "rds:AddTagsToResource",
+	},
+	"rds": {
+		"rds:DescribeDBInstances", "rds:ListTagsForResource", "rds:DeleteDBInstance",
+	},
+	"documentdb": {
+		"rds:DescribeDBClusters", "rds:DescribeDBInstances", "rds:DeleteDBCluster", "rds:DeleteDBInstance",
+	},
+	"elasticache": {
+		"tag:GetResources", "elasticache:DescribeCacheClusters", "elasticache:DeleteCacheCluster",
+		"elasticache:DeleteReplicationGroup",
+	},
+	"elb": {
+		"tag:GetResources", "elasticloadbalancing:DescribeLoadBalancers", "elasticloadbalancing:DescribeTags",
+		"elasticloadbalancing:DescribeLoadBalancerAttributes", "elasticloadbalancing:DeleteLoadBalancer",
+	},
+	"ebs": {
+		"ec2:DescribeVolumes", "ec2:DeleteVolume",
+	},
+	"vpc": {
+		"ec2:DescribeVpcs", "ec2:DeleteVpc", "ec2:CreateTags",
+		"ec2:DescribeSubnets", "ec2:DeleteSubnet",
+		"ec2:DescribeRouteTables", "ec2:DisassociateRouteTable", "ec2:DeleteRouteTable",
+		"ec2:DescribeInternetGateways", "ec2:DetachInternetGateway", "ec2:DeleteInternetGateway",
+		"ec2:DescribeSecurityGroups", "ec2:RevokeSecurityGroupIngress", "ec2:RevokeSecurityGroupEgress",
+		"ec2:DeleteSecurityGroup",
+		"ec2:DescribeNetworkInterfaces", "ec2:ModifyNetworkInterfaceAttribute", "ec2:DeleteNetworkInterface",
+		"ec2:DescribeNatGateways", "ec2:DeleteNatGateway",
+		"ec2:DescribeVpcEndpoints", "ec2:DeleteVpcEndpoints",
+		"ec2:DescribeVpnConnections", "ec2:DeleteVpnConnection",
+		"ec2:DescribeCustomerGateways", "ec2:DeleteCustomerGateway",
+		"ec2:DescribeTransitGateways", "ec2:DeleteTransitGateway",
+		"ec2:DescribeTransitGatewayAttachments", "ec2:DescribeTransitGatewayVpcAttachments",
+		"ec2:DeleteTransitGatewayVpcAttachment", "ec2:DeleteTransitGatewayPeeringAttachment",
+		"rds:DescribeDBSubnetGroups", "rds:ListTagsForResource", "rds:AddTagsToResource", "rds:DeleteDBSubnetGroup",
+		"rds:DescribeDBInstances", "rds:DescribeDBClusters",
+	},
+	"s3": {
+		"s3:ListAllMyBuckets", "s3:GetBucketLocation", "s3:GetBucketTagging", "s3:ListBucketVersions",
+		"s3:DeleteObject", "s3:DeleteObjectVersion", "s3:DeleteBucket",
+	},
+	"cloudwatch-logs": {
+		"logs:DescribeLogGroups", "logs:ListTagsLogGroup", "logs:DeleteLogGroup",
+	},
+	"kms": {
+		"kms:ListKeys", "kms:DescribeKey", "kms:ListResourceTags", "kms:TagResource", "kms:ScheduleKeyDeletion",
+	},
+	"iam": {
+		"iam:ListUsers", "iam:ListUserTags", "iam:ListAccessKeys", "iam:DeleteAccessKey", "iam:DeleteUser",
+		"iam:ListUserPolicies", "iam:DeleteUserPolicy", "iam:ListAttachedUserPolicies", "iam:DetachUserPolicy",
+		"iam:ListRoles", "iam:ListRoleTags", "iam:DeleteRole",
+		"iam:ListRolePolicies", "iam:DeleteRolePolicy", "iam:ListAttachedRolePolicies", "iam:DetachRolePolicy",
+		"iam:ListInstanceProfilesForRole", "iam:RemoveRoleFromInstanceProfile", "iam:DeleteInstanceProfile",
+		"iam:ListPolicies", "iam:ListPolicyVersions", "iam:DeletePolicyVersion", "iam:DeletePolicy",
+		"iam:ListGroups", "iam:DeleteGroup",
+	},
+	"ssh-keys": {
+		"ec2:DescribeKeyPairs", "ec2:CreateTags", "ec2:DeleteKeyPair",
+	},
+	"ecr": {
+		"ecr:DescribeRepositories", "ecr:DescribeImages", "ecr:DeleteRepository",
+	},
+	"glue": {
+		"tag:GetResources", "sts:GetCallerIdentity",
+		"glue:GetDatabases", "glue:GetTables", "glue:BatchDeleteTable", "glue:DeleteDatabase",
+		"glue:GetCrawlers", "glue:StopCrawler", "glue:DeleteCrawler", "glue:GetJobs", "glue:DeleteJob",
+	},
+	"elastic-beanstalk": {
+		"tag:GetResources", "elasticbeanstalk:DescribeEnvironments", "elasticbeanstalk:TerminateEnvironment",
+		"elasticbeanstalk:DescribeApplicationVersions", "elasticbeanstalk:DeleteApplicationVersion",
+		"elasticbeanstalk:UpdateTagsForResource",
+	},
+	"eventbridge": {
+		"events:ListEventBuses", "events:ListRules", "events:ListTagsForResource", "events:TagResource",
+		"events:ListTargetsByRule", "events:RemoveTargets", "events:DeleteRule",
+	},
+	"cloudwatch-alarms": {
+		"cloudwatch:DescribeAlarms", "cloudwatch:ListTagsForResource", "cloudwatch:TagResource", "cloudwatch:DeleteAlarms",
+	},
+	"db-groups": {
+		"tag:GetResources", "rds:DescribeDBInstances", "rds:DescribeDBClusters", "rds:ListTagsForResource", "rds:AddTagsToResource",
+		"rds:DescribeDBSubnetGroups", "rds:DeleteDBSubnetGroup", "rds:DescribeDBParameterGroups", "rds:DeleteDBParameterGroup",
+		"rds:DescribeDBClusterParameterGroups", "rds:DeleteDBClusterParameterGroup",
+		"elasticache:DescribeCacheClusters", "elasticache:AddTagsToResource",
+		"elasticache:DescribeCacheSubnetGroups", "elasticache:DeleteCacheSubnetGroup",
+		"elasticache:DescribeCacheParameterGroups", "elasticache:DeleteCacheParameterGroup",
+	},
+	"ecs": {
+		"ecs:ListClusters", "ecs:DescribeClusters", "ecs:ListServices", "ecs:DescribeServices", "ecs:TagResource",
+		"ecs:UpdateService", "ecs:DeleteService", "ecs:DeregisterTaskDefinition", "ecs:DeleteCluster",
+	},
+	"apprunner": {
+		"apprunner:ListServices", "apprunner:ListTagsForResource", "apprunner:TagResource", "apprunner:DeleteService",
+	},
+	"ec2-instances": {
+		"ec2:DescribeInstances", "ec2:CreateTags", "ec2:TerminateInstances",
+	},
+	"autoscaling": {
+		"autoscaling:DescribeAutoScalingGroups", "autoscaling:CreateOrUpdateTags", "autoscaling:DeleteAutoScalingGroup",
+	},
+}
+
+var (
+	leaksActions = []string{
+		"ec2:DescribeAddresses", "ec2:ReleaseAddress", "ec2:DescribeVolumes", "ec2:DeleteVolume",
+		"ec2:DescribeSecurityGroups", "ec2:DeleteSecurityGroup", "ec2:DescribeNetworkInterfaces",
+		"ec2:DescribeVpcs", "ec2:DescribeSubnets", "ec2:DescribeInternetGateways", "ec2:DetachInternetGateway",
+		"ec2:DeleteInternetGateway", "ec2:DeleteVpc", "ec2:DescribeTags",
+		"elasticloadbalancing:DescribeTargetGroups", "elasticloadbalancing:DeleteTargetGroup",
+	}
+	deletionProtectionActions = []string{"elasticloadbalancing:ModifyLoadBalancerAttributes"}
+	finalSnapshotActions      = map[string][]string{
+		"rds": {
+			"rds:CreateDBSnapshot", "rds:DescribeDBSnapshots", "rds:AddTagsToResource", "rds:RemoveTagsFromResource",
+			"rds:DeleteDBSnapshot",
+		},
+		"documentdb": {
+			"rds:CreateDBClusterSnapshot", "rds:DescribeDBClusterSnapshots", "rds:AddTagsToResource",
+			"rds:RemoveTagsFromResource", "rds:DeleteDBClusterSnapshot",
+		},
+		"ebs": {"ec2:CreateSnapshot", "ec2:CreateTags", "ec2:DescribeSnapshots", "ec2:DeleteSnapshot"},
+	}
+	scheduleActions    = []string{"sts:GetCallerIdentity", "tag:GetResources", "tag:TagResources"}
+	iamScheduleActions = []string{"iam:TagRole", "iam:TagUser"}
+	// quarantineActions are the actions stopping the expired resources of a service during the quarantine
+	quarantineActions = map[string][]string{
+		"rds":           {"rds:StopDBInstance"},
+		"eks":           {"eks:UpdateNodegroupConfig"},
+		"ec2-instances": {"ec2:StopInstances"},
+		"autoscaling":   {"autoscaling:UpdateAutoScalingGroup"},
+	}
+	allRegionsActions = []string{"ec2:DescribeRegions"}
+	costActions       = []string{"pricing:GetProducts"}
+	complianceActions = []string{"tag:GetResources"}
+	quotaActions      = []string{
+		"servicequotas:GetServiceQuota", "servicequotas:GetAWSDefaultServiceQuota",
+		"elasticloadbalancing:DescribeLoadBalancers", "elasticloadbalancing:DescribeListeners",
+		"elasticloadbalancing:DescribeTargetGroups", "ec2:DescribeAddresses", "ec2:DescribeVpcs",
+		"ec2:DescribeSecurityGroups",
+	}
+)
+
+// PermissionsCheck is the result of the simulation of the policies of a user or role
+type PermissionsCheck struct {
+	PrincipalArn string
+	// Denied are the required actions the policies don't allow
+	Denied []string
+}
+
+// RequiredActions returns the sorted IAM actions needed by the enabled cleaners and options, the grace and quarantine
+// periods of the policy add the tagging of the expired resources and extraActions are added as is
+func RequiredActions(config Config, policy utils.DeletionPolicy, extraActions ...string) []string {
+	actions := append([]string{}, extraActions...)
+
+	services := make(map[string]bool)
+	for service, enabled := range config.Services {
+		services[service] = enabled
+	}
+	// like the checks, EKS enables the load balancers and volumes unless they are disabled
+	if config.isServiceEnabled("eks") {
+		for _, service := range []string{"elb", "ebs"} {
+			if !config.isServiceDisabled(service) {
+				services[service] = true
+			}
+		}
+	}
+
+	for service, enabled := range services {
+		if !enabled {
+			continue
+		}
+
+		actions = append(actions, serviceActions[service]...)
+		if config.FinalSnapshotTTL > 0 {
+			actions = append(actions, finalSnapshotActions[service]...)
+		}
+		if policy.QuarantinePeriod > 0 {
+			actions = append(actions, quarantineActions[service]...)
+		}
+	}
+
+	if services["elb"] && config.OverrideDeletionProtection {
+		actions = append(actions, deletionProtectionActions...)
+	}
+	if config.Leaks != nil {
+		actions = append(actions, leaksActions...)
+	}
+	if len(config.RequiredTags) > 0 {
+		actions = append(actions, complianceActions...)
+	}
+	if config.QuotaThreshold > 0 {
+		actions = append(actions, quotaActions...)
+	}
+	if policy.GracePeriod > 0 || policy.QuarantinePeriod > 0 {
+		actions = append(actions, scheduleActions...)
+		if services["iam"] && policy.GracePeriod > 0 {
+			actions = append(actions, iamScheduleActions...)
+		}
+	}
+	if config.AllRegions {
+		actions = append(actions, allRegionsActions...)
+	}
+	if config.EstimateCosts {
+		actions = append(actions, costActions...)
+	}
+
+	return uniqueActions(actions)
+}
+
+func uniqueActions(actions []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, action := range actions {
+		if !seen[action] {
+			seen[action] = true
+			unique = append(unique, action)
+		}
+	}
+
+	sort.Strings(unique)
+	return unique
+}
+
+// getPrincipalArn returns the ARN of the user or role of the credentials, the role of an assumed role session
+func getPrincipalArn(ctx context.Context, stsSession *sts.STS) (string, error) {
+	identity, err := stsSession.GetCallerIdentityWithContext(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+
+	callerArn, err := arn.Parse(aws.StringValue(identity.Arn))
+	if err != nil {
+		return "", err
+	}
+
+	// arn:aws:sts::<account>:assumed-role/<role>/<session>, the path of the role isn't part of it
+	if callerArn.Service == "sts" && strings.HasPrefix(callerArn.Resource, "assumed-role/") {
+		parts := strings.Split(callerArn.Resource, "/")
+		callerArn.Service = "iam"
+		callerArn.Resource = "role/" + parts[1]
+	}
+
+	return callerArn.String(), nil
+}
+
+// simulateActions returns the actions the policies of the user or role don't allow
+func simulateActions(ctx context.Context, iamSession *iam.IAM, principalArn string, actions []string) ([]string, error) {
+	var denied []string
+
+	err := iamSession.SimulatePrincipalPolicyPagesWithContext(ctx,
+		&iam.SimulatePrincipalPolicyInput{
+			PolicySourceArn: aws.String(principalArn),
+			ActionNames:     aws.StringSlice(actions),
+		},
+		func(page *iam.SimulatePolicyResponse, lastPage bool) bool {
+			for _, result := range page.EvaluationResults {
+				if aws.StringValue(result.EvalDecision) != iam.PolicyEvaluationDecisionTypeAllowed {
+					denied = append(denied, aws.StringValue(result.EvalActionName))
+				}
+			}
+			return true
+		})
+
+	sort.Strings(denied)
+	return denied, err
+}
+
+// CheckPermissions simulates the actions with the policies of the credentials, or of each role to assume. The
+// credentials need the iam:SimulatePrincipalPolicy permission.
+func CheckPermissions(ctx context.Context, config Config, actions []string) ([]PermissionsCheck, error) {
+	region := DefaultRegion()
+	if len(config.Regions) > 0 {
+		region = config.Regions[0]
+	}
+
+	var checks []PermissionsCheck
+	for _, credentials := range config.getAccounts() {
+		sess, err := CreateSessionWithCredentials(region, credentials)
+		if err != nil {
+			return nil, err
+		}
+
+		principalArn := credentials.RoleArn
+		if principalArn == "" {
+			principalArn, err = getPrincipalArn(ctx, sts.New(sess))
+			if err != nil {
+				return nil, fmt.Errorf("can't get the identity of the credentials: %s", err)
+			}
+		}
+
+		if strings.HasSuffix(principalArn, ":root") {
+			logrus.Warnf("Credentials of the root user of %s are allowed everything, they can't be simulated.", GetRoleAccountId(principalArn))
+			continue
+		}
+
+		logrus.Infof("Simulating %d actions with the policies of %s.", len(actions), principalArn)
+		denied, err := simulateActions(ctx, iam.New(sess), principalArn, actions)
+		if err != nil {
+			return nil, fmt.Errorf("can't simulate the policies of %s: %s", principalArn, err)
+		}
+
+		checks = append(checks, PermissionsCheck{PrincipalArn: principalArn, Denied: denied})
+	}
+
+	return checks, nil
+}
+
+type policyStatement struct {
+	Effect   string
+	Action   []string
+	Resource string
+}
+
+type policyDocument struct {
+	Version   string
+	Statement []policyStatement
+}
+
+// GetPolicyDocument returns an IAM policy allowing the actions
+func GetPolicyDocument(actions []string) (string, error) {
+	document, err := json.MarshalIndent(policyDocument{
+		Version:   "2012-10-17",
+		Statement: []policyStatement{{Effect: "Allow", Action: actions, Resource: "*"}},
+	}, "", "  ")
+
+	return string(document), err
+}
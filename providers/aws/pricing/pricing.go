@@ -0,0 +1,274 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// the Pricing API is only served from a few regions, prices of every region are available from each of them
+const pricingRegion = "us-east-1"
+
+const hoursPerMonth = 730
+
+// Usage describes what a resource is billed on
+type Usage struct {
+	ServiceCode string
+	// Attributes are the product attributes to match, the region is added by the estimator
+	Attributes map[string]string
+	// UsageTypeSuffix picks the product among the matching ones when set
+	UsageTypeSuffix string
+	// Quantity multiplies the unit price (GB for storage, nodes for instances)
+	Quantity float64
+}
+
+func EBSVolume(volumeType string, sizeGb int64) Usage {
+	return Usage{
+		ServiceCode: "AmazonEC2",
+		Attributes:  map[string]string{"productFamily": "Storage", "volumeApiName": volumeType},
+		Quantity:    float64(sizeGb),
+	}
+}
+
+func LoadBalancer(loadBalancerType string) Usage {
+	productFamilies := map[string]string{
+		"application": "Load Balancer-Application",
+		"network":     "Load Balancer-Network",
+		"gateway":     "Load Balancer-Gateway",
+	}
+
+	attributes := map[string]string{}
+	if productFamily, isKnown := productFamilies[loadBalancerType]; isKnown {
+		attributes["productFamily"] = productFamily
+	}
+
+	return Usage{ServiceCode: "AWSELB", Attributes: attributes, UsageTypeSuffix: "LoadBalancerUsage", Quantity: 1}
+}
+
+func RDSInstance(instanceClass string, engine string, multiAZ bool) Usage {
+	deploymentOption := "Single-AZ"
+	if multiAZ {
+		deploymentOption = "Multi-AZ"
+	}
+
+	attributes := map[string]string{"instanceType": instanceClass, "deploymentOption": deploymentOption}
+	if databaseEngine := getDatabaseEngine(engine); databaseEngine != "" {
+		attributes["databaseEngine"] = databaseEngine
+	}
+
+	return Usage{ServiceCode: "AmazonRDS", Attributes: attributes, Quantity: 1}
+}
+
+func ElasticacheNodes(nodeType string, engine string, nodes int64) Usage {
+	return Usage{
+		ServiceCode: "AmazonElastiCache",
+		Attributes:  map[string]string{"instanceType": nodeType, "cacheEngine": strings.Title(engine)},
+		Quantity:    float64(nodes),
+	}
+}
+
+func EKSCluster() Usage {
+	return Usage{
+		ServiceCode:     "AmazonEKS",
+		Attributes:      map[string]string{},
+		UsageTypeSuffix: "AmazonEKS-Hours:perCluster",
+		Quantity:        1,
+	}
+}
+
+// getDatabaseEngine returns the Pricing API name of an RDS engine, empty if unknown
+func getDatabaseEngine(engine string) string {
+	switch {
+	case engine == "postgres":
+		return "PostgreSQL"
+	case engine == "mysql":
+		return "MySQL"
+	case engine == "mariadb":
+		return "MariaDB"
+	case engine == "aurora-postgresql":
+		return "Aurora PostgreSQL"
+	case engine == "aurora" || engine == "aurora-mysql":
+		return "Aurora MySQL"
+	case strings.HasPrefix(engine, "oracle"):
+		return "Oracle"
+	case strings.HasPrefix(engine, "sqlserver"):
+		return "SQL Server"
+	}
+
+	return ""
+}
+
+type priceListItem struct {
+	Product struct {
+		Attributes map[string]string `json:"attributes"`
+	} `json:"product"`
+	Terms struct {
+		OnDemand map[string]struct {
+			PriceDimensions map[string]struct {
+				Unit         string            `json:"unit"`
+				PricePerUnit map[string]string `json:"pricePerUnit"`
+			} `json:"priceDimensions"`
+		} `json:"OnDemand"`
+	} `json:"terms"`
+}
+
+type price struct {
+	monthly float64
+	err     error
+}
+
+// Estimator computes the on demand monthly cost of resources, prices are cached as they rarely change
+type Estimator struct {
+	sync.Mutex
+	svc    pricingiface.PricingAPI
+	prices map[string]price
+}
+
+type estimatorKey struct{}
+
+func NewEstimator(sess *session.Session) *Estimator {
+	return &Estimator{
+		svc:    pricing.New(sess, &aws.Config{Region: aws.String(pricingRegion)}),
+		prices: make(map[string]price),
+	}
+}
+
+// WithEstimator returns a context estimating the cost of the resources recorded in its report
+func WithEstimator(ctx context.Context, estimator *Estimator) context.Context {
+	return context.WithValue(ctx, estimatorKey{}, estimator)
+}
+
+func getPriceKey(region string, usage Usage) string {
+	var attributes []string
+	for name, value := range usage.Attributes {
+		attributes = append(attributes, name+"="+value)
+	}
+	sort.Strings(attributes)
+
+	return fmt.Sprintf("%s/%s/%s/%s", usage.ServiceCode, region, strings.Join(attributes, ","), usage.UsageTypeSuffix)
+}
+
+// MonthlyCost returns the on demand monthly cost of a resource in US dollars
+func (e *Estimator) MonthlyCost(ctx context.Context, region string, usage Usage) (float64, error) {
+	key := getPriceKey(region, usage)
+
+	e.Lock()
+	cached, isCached := e.prices[key]
+	e.Unlock()
+
+	if !isCached {
+		monthly, err := e.getMonthlyPrice(ctx, region, usage)
+		// a cancelled request says nothing about the price
+		if ctx.Err() != nil {
+			return 0, err
+		}
+
+		cached = price{monthly: monthly, err: err}
+		e.Lock()
+		e.prices[key] = cached
+		e.Unlock()
+	}
+
+	if cached.err != nil {
+		return 0, cached.err
+	}
+
+	return cached.monthly * usage.Quantity, nil
+}
+
+// getMonthlyPrice returns the monthly price of a unit of the usage, from the first priced product matching it
+func (e *Estimator) getMonthlyPrice(ctx context.Context, region string, usage Usage) (float64, error) {
+	filters := []*pricing.Filter{
+		{Type: aws.String(pricing.FilterTypeTermMatch), Field: aws.String("regionCode"), Value: aws.String(region)},
+	}
+	for name, value := range usage.Attributes {
+		filters = append(filters, &pricing.Filter{Type: aws.String(pricing.FilterTypeTermMatch), Field: aws.String(name), Value: aws.String(value)})
+	}
+
+	monthly := 0.0
+	found := false
+	err := e.svc.GetProductsPagesWithContext(ctx,
+		&pricing.GetProductsInput{
+			ServiceCode:   aws.String(usage.ServiceCode),
+			Filters:       filters,
+			FormatVersion: aws.String("aws_v1"),
+		},
+		func(page *pricing.GetProductsOutput, lastPage bool) bool {
+			for _, product := range page.PriceList {
+				monthly, found = getItemMonthlyPrice(product, usage.UsageTypeSuffix)
+				if found {
+					return false
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return 0, err
+	}
+
+	if !found {
+		return 0, fmt.Errorf("no %s price found in %s for %s", usage.ServiceCode, region, getPriceKey(region, usage))
+	}
+
+	return monthly, nil
+}
+
+func getItemMonthlyPrice(product aws.JSONValue, usageTypeSuffix string) (float64, bool) {
+	payload, err := json.Marshal(product)
+	if err != nil {
+		return 0, false
+	}
+
+	var item priceListItem
+	err = json.Unmarshal(payload, &item)
+	if err != nil {
+		return 0, false
+	}
+
+	if !strings.HasSuffix(item.Product.Attributes["usagetype"], usageTypeSuffix) {
+		return 0, false
+	}
+
+	for _, term := range item.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			unitPrice, err := strconv.ParseFloat(dimension.PricePerUnit["USD"], 64)
+			if err != nil || unitPrice == 0 {
+				continue
+			}
+
+			if dimension.Unit == "Hrs" {
+				return unitPrice * hoursPerMonth, true
+			}
+			return unitPrice, true
+		}
+	}
+
+	return 0, false
+}
+
+// ReportCost records in the report of the context the estimated monthly cost of a resource found deletable, if the
+// context has an estimator
+func ReportCost(ctx context.Context, resourceType string, region string, name string, usage Usage) {
+	estimator, hasEstimator := ctx.Value(estimatorKey{}).(*Estimator)
+	if !hasEstimator {
+		return
+	}
+
+	cost, err := estimator.MonthlyCost(ctx, region, usage)
+	if err != nil {
+		log.Warnf("Can't estimate the cost of %s %s in %s: %s", resourceType, name, region, err)
+		return
+	}
+
+	utils.SetReportCost(ctx, resourceType, region, name, cost)
+}
@@ -0,0 +1,332 @@
+// Package quotas watches the usage of the service quotas limiting the resources pleco cleans (load balancers, target
+// groups, Elastic IPs, VPCs, security groups), reports the quotas nearly reached and runs the cleaners of their
+// resources first in the next check.
+package quotas
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
+	"github.com/aws/aws-sdk-go/service/servicequotas/servicequotasiface"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Clients are the clients of a region listing the resources and their quotas
+type Clients struct {
+	EC2           ec2iface.EC2API
+	ELB           elbv2iface.ELBV2API
+	ServiceQuotas servicequotasiface.ServiceQuotasAPI
+}
+
+// quota is a service quota limiting resources cleaned by pleco
+type quota struct {
+	resourceType string
+	serviceCode  string
+	quotaCode    string
+	// jobs are the cleaners deleting the resources, run first once the quota is nearly reached
+	jobs []string
+	// getUsages returns the usage of the quota in the region, by resource for the quotas of each resource (ex: the
+	// listeners of a load balancer)
+	getUsages func(ctx context.Context, clients Clients) (map[string]int, error)
+}
+
+var quotas = []quota{
+	{
+		resourceType: resources.LoadBalancer,
+		serviceCode:  "elasticloadbalancing",
+		quotaCode:    "L-53DA6B97",
+		jobs:         []string{"ELB"},
+		getUsages:    getApplicationLoadBalancersUsage,
+	},
+	{
+		resourceType: resources.LoadBalancerListener,
+		serviceCode:  "elasticloadbalancing",
+		quotaCode:    "L-B6DF7632",
+		jobs:         []string{"ELB"},
+		getUsages:    getListenersUsage,
+	},
+	{
+		resourceType: resources.TargetGroup,
+		serviceCode:  "elasticloadbalancing",
+		quotaCode:    "L-B22855CB",
+		jobs:         []string{"ELB", "Leaks"},
+		getUsages:    getTargetGroupsUsage,
+	},
+	{
+		resourceType: resources.ElasticIP,
+		serviceCode:  "ec2",
+		quotaCode:    "L-0263D0A3",
+		jobs:         []string{"Leaks"},
+		getUsages:    getAddressesUsage,
+	},
+	{
+		resourceType: resources.VPC,
+		serviceCode:  "vpc",
+		quotaCode:    "L-F678F1CE",
+		jobs:         []string{"VPC", "EKS", "Leaks"},
+		getUsages:    getVPCsUsage,
+	},
+	{
+		resourceType: resources.SecurityGroup,
+		serviceCode:  "vpc",
+		quotaCode:    "L-E79EC296",
+		jobs:         []string{"VPC", "Leaks"},
+		getUsages:    getSecurityGroupsUsage,
+	},
+}
+
+// jobKey identifies the cleaner of a resource type in a region of an account
+type jobKey struct {
+	account string
+	region  string
+	name    string
+}
+
+// Watchdog reports the quotas nearly reached in every region, and remembers the cleaners of their resources so they run
+// first in the next check. It's shared by the checks of every region and account.
+type Watchdog struct {
+	sync.Mutex
+	// threshold is the usage, in percent of the quota, above which the quota is nearly reached
+	threshold   float64
+	prioritized map[jobKey]bool
+}
+
+func NewWatchdog(threshold float64) *Watchdog {
+	return &Watchdog{threshold: threshold, prioritized: make(map[jobKey]bool)}
+}
+
+// Check reports the quotas of the region used above the threshold, and prioritizes the cleaners of their resources in
+// the next check
+func (w *Watchdog) Check(ctx context.Context, clients Clients, region string, account string) error {
+	var prioritized []string
+	var errs []error
+	for _, quota := range quotas {
+		usages, err := quota.getUsages(ctx, clients)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't count the %ss: %s", quota.resourceType, err))
+			continue
+		}
+
+		// the quota isn't needed when nothing uses it
+		if len(usages) == 0 {
+			continue
+		}
+
+		name, limit, err := getQuota(ctx, clients.ServiceQuotas, quota.serviceCode, quota.quotaCode)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't get the %s quota %s: %s", quota.resourceType, quota.quotaCode, err))
+			continue
+		}
+
+		nearlyReached := false
+		for resource, usage := range usages {
+			quotaUsage := utils.QuotaUsage{
+				Type:     quota.resourceType,
+				Region:   region,
+				Quota:    name,
+				Resource: resource,
+				Usage:    usage,
+				Limit:    limit,
+			}
+			if quotaUsage.Percent() < w.threshold {
+				continue
+			}
+
+			utils.ReportQuotaUsage(ctx, quotaUsage)
+			nearlyReached = true
+		}
+
+		if nearlyReached {
+			prioritized = append(prioritized, quota.jobs...)
+		}
+	}
+
+	w.setPrioritized(account, region, prioritized)
+
+	return utils.JoinErrors(errs...)
+}
+
+func (w *Watchdog) setPrioritized(account string, region string, jobs []string) {
+	w.Lock()
+	defer w.Unlock()
+
+	for key := range w.prioritized {
+		if key.account == account && key.region == region {
+			delete(w.prioritized, key)
+		}
+	}
+
+	var names []string
+	for _, name := range jobs {
+		key := jobKey{account: account, region: region, name: name}
+		if !w.prioritized[key] {
+			w.prioritized[key] = true
+			names = append(names, name)
+		}
+	}
+
+	if len(names) > 0 {
+		sort.Strings(names)
+		log.Infof("Quotas nearly reached in %s, the %s cleaners run first in the next check.", region, strings.Join(names, ", "))
+	}
+}
+
+// Prioritize moves first the cleaners of the resources whose quota was nearly reached during the last check, so the
+// pool starts them before the others
+func (w *Watchdog) Prioritize(jobs []utils.Job) {
+	w.Lock()
+	prioritized := make(map[jobKey]bool, len(w.prioritized))
+	for key := range w.prioritized {
+		prioritized[key] = true
+	}
+	w.Unlock()
+
+	if len(prioritized) == 0 {
+		return
+	}
+
+	isPrioritized := func(job utils.Job) bool {
+		return prioritized[jobKey{account: job.Account, region: job.Region, name: job.Name}]
+	}
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return isPrioritized(jobs[i]) && !isPrioritized(jobs[j])
+	})
+}
+
+// getQuota returns the name and value of the quota applied to the account, or its default value if the account has
+// none
+func getQuota(ctx context.Context, svc servicequotasiface.ServiceQuotasAPI, serviceCode string, quotaCode string) (string, float64, error) {
+	result, err := svc.GetServiceQuotaWithContext(ctx,
+		&servicequotas.GetServiceQuotaInput{ServiceCode: aws.String(serviceCode), QuotaCode: aws.String(quotaCode)})
+	if err == nil {
+		return aws.StringValue(result.Quota.QuotaName), aws.Float64Value(result.Quota.Value), nil
+	}
+
+	if awsErr, ok := err.(awserr.Error); !ok || awsErr.Code() != servicequotas.ErrCodeNoSuchResourceException {
+		return "", 0, err
+	}
+
+	defaultResult, err := svc.GetAWSDefaultServiceQuotaWithContext(ctx,
+		&servicequotas.GetAWSDefaultServiceQuotaInput{ServiceCode: aws.String(serviceCode), QuotaCode: aws.String(quotaCode)})
+	if err != nil {
+		return "", 0, err
+	}
+
+	return aws.StringValue(defaultResult.Quota.QuotaName), aws.Float64Value(defaultResult.Quota.Value), nil
+}
+
+// getRegionUsage returns the usage of a quota of the region, none if it's 0
+func getRegionUsage(count int) map[string]int {
+	if count == 0 {
+		return nil
+	}
+
+	return map[string]int{"": count}
+}
+
+func getApplicationLoadBalancers(ctx context.Context, svc elbv2iface.ELBV2API) ([]*elbv2.LoadBalancer, error) {
+	var loadBalancers []*elbv2.LoadBalancer
+	err := svc.DescribeLoadBalancersPagesWithContext(ctx, &elbv2.DescribeLoadBalancersInput{},
+		func(page *elbv2.DescribeLoadBalancersOutput, lastPage bool) bool {
+			for _, loadBalancer := range page.LoadBalancers {
+				if aws.StringValue(loadBalancer.Type) == elbv2.LoadBalancerTypeEnumApplication {
+					loadBalancers = append(loadBalancers, loadBalancer)
+				}
+			}
+			return true
+		})
+
+	return loadBalancers, err
+}
+
+func getApplicationLoadBalancersUsage(ctx context.Context, clients Clients) (map[string]int, error) {
+	loadBalancers, err := getApplicationLoadBalancers(ctx, clients.ELB)
+	if err != nil {
+		return nil, err
+	}
+
+	return getRegionUsage(len(loadBalancers)), nil
+}
+
+func getListenersUsage(ctx context.Context, clients Clients) (map[string]int, error) {
+	loadBalancers, err := getApplicationLoadBalancers(ctx, clients.ELB)
+	if err != nil {
+		return nil, err
+	}
+
+	usages := make(map[string]int)
+	for _, loadBalancer := range loadBalancers {
+		count := 0
+		err := clients.ELB.DescribeListenersPagesWithContext(ctx,
+			&elbv2.DescribeListenersInput{LoadBalancerArn: loadBalancer.LoadBalancerArn},
+			func(page *elbv2.DescribeListenersOutput, lastPage bool) bool {
+				count += len(page.Listeners)
+				return true
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		if count > 0 {
+			usages[aws.StringValue(loadBalancer.LoadBalancerName)] = count
+		}
+	}
+
+	return usages, nil
+}
+
+func getTargetGroupsUsage(ctx context.Context, clients Clients) (map[string]int, error) {
+	count := 0
+	err := clients.ELB.DescribeTargetGroupsPagesWithContext(ctx, &elbv2.DescribeTargetGroupsInput{},
+		func(page *elbv2.DescribeTargetGroupsOutput, lastPage bool) bool {
+			count += len(page.TargetGroups)
+			return true
+		})
+
+	return getRegionUsage(count), err
+}
+
+func getAddressesUsage(ctx context.Context, clients Clients) (map[string]int, error) {
+	result, err := clients.EC2.DescribeAddressesWithContext(ctx,
+		&ec2.DescribeAddressesInput{
+			Filters: []*ec2.Filter{{Name: aws.String("domain"), Values: aws.StringSlice([]string{ec2.DomainTypeVpc})}},
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return getRegionUsage(len(result.Addresses)), nil
+}
+
+func getVPCsUsage(ctx context.Context, clients Clients) (map[string]int, error) {
+	count := 0
+	err := clients.EC2.DescribeVpcsPagesWithContext(ctx, &ec2.DescribeVpcsInput{},
+		func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+			count += len(page.Vpcs)
+			return true
+		})
+
+	return getRegionUsage(count), err
+}
+
+func getSecurityGroupsUsage(ctx context.Context, clients Clients) (map[string]int, error) {
+	count := 0
+	err := clients.EC2.DescribeSecurityGroupsPagesWithContext(ctx, &ec2.DescribeSecurityGroupsInput{},
+		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+			count += len(page.SecurityGroups)
+			return true
+		})
+
+	return getRegionUsage(count), err
+}
@@ -0,0 +1,60 @@
+// Package resources names the types of the AWS resources pleco checks. The names show in the logs, reports, metrics and
+// untagged rules, and the summaries count the resources of a check by type: every call site of a type uses its
+// constant so the counts of its listing, deletions and failures add up.
+package resources
+
+const (
+	EC2Instance      = "EC2 instance"
+	AutoScalingGroup = "auto scaling group"
+	EBSVolume        = "EBS volume"
+	EBSSnapshot      = "EBS snapshot"
+	EC2KeyPair       = "EC2 key pair"
+	ElasticIP        = "elastic IP"
+
+	LoadBalancer = "ELB load balancer"
+	TargetGroup  = "target group"
+	// LoadBalancerListener is only counted against its quota
+	LoadBalancerListener = "load balancer listener"
+
+	VPC                      = "VPC"
+	Subnet                   = "subnet"
+	SecurityGroup            = "security group"
+	InternetGateway          = "internet gateway"
+	NATGateway               = "NAT gateway"
+	RouteTable               = "route table"
+	NetworkInterface         = "network interface"
+	VPCEndpoint              = "VPC endpoint"
+	VPNConnection            = "VPN connection"
+	CustomerGateway          = "customer gateway"
+	TransitGateway           = "transit gateway"
+	TransitGatewayAttachment = "transit gateway attachment"
+
+	RDSDatabase                 = "RDS database"
+	RDSSnapshot                 = "RDS snapshot"
+	RDSSubnetGroup              = "RDS subnet group"
+	RDSParameterGroup           = "RDS parameter group"
+	RDSClusterParameterGroup    = "RDS cluster parameter group"
+	DocumentDBCluster           = "DocumentDB cluster"
+	DocumentDBClusterSnapshot   = "DocumentDB cluster snapshot"
+	ElasticacheCluster          = "Elasticache cluster"
+	ElasticacheSubnetGroup      = "Elasticache subnet group"
+	ElasticacheParameterGroup   = "Elasticache parameter group"
+	EKSCluster                  = "EKS cluster"
+	ECSService                  = "ECS service"
+	ECSCluster                  = "ECS cluster"
+	AppRunnerService            = "App Runner service"
+	S3Bucket                    = "S3 bucket"
+	LogGroup                    = "Cloudwatch log group"
+	CloudWatchAlarm             = "CloudWatch alarm"
+	EventBridgeRule             = "EventBridge rule"
+	KMSKey                      = "KMS key"
+	GlueDatabase                = "Glue database"
+	GlueCrawler                 = "Glue crawler"
+	GlueJob                     = "Glue job"
+	BeanstalkEnvironment        = "Elastic Beanstalk environment"
+	BeanstalkApplicationVersion = "Elastic Beanstalk application version"
+
+	IAMRole   = "IAM role"
+	IAMUser   = "IAM user"
+	IAMPolicy = "IAM policy"
+)
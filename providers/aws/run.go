@@ -1,253 +1,422 @@
 package aws
 
 import (
-	"github.com/Qovery/pleco/providers/aws/database"
-	ec22 "github.com/Qovery/pleco/providers/aws/ec2"
-	eks2 "github.com/Qovery/pleco/providers/aws/eks"
-	iam2 "github.com/Qovery/pleco/providers/aws/iam"
-	"github.com/Qovery/pleco/providers/aws/logs"
-	"github.com/Qovery/pleco/providers/aws/vpc"
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/leaks"
+	"github.com/Qovery/pleco/providers/aws/pricing"
+	"github.com/Qovery/pleco/providers/aws/quotas"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/ecr"
-	"github.com/aws/aws-sdk-go/service/eks"
-	"github.com/aws/aws-sdk-go/service/elasticache"
 	"github.com/aws/aws-sdk-go/service/elbv2"
-	"github.com/aws/aws-sdk-go/service/iam"
-	"github.com/aws/aws-sdk-go/service/kms"
-	"github.com/aws/aws-sdk-go/service/rds"
-	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/servicequotas"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
-	"sync"
+	"strings"
 	"time"
 )
 
 
-func RunPlecoAWS(cmd *cobra.Command, regions []string, interval int64, dryRun bool, wg *sync.WaitGroup) {
-	tagName, _ := cmd.Flags().GetString("tag-name")
+// Services are the names of the AWS services pleco can check
+var Services = []string{"eks", "rds", "documentdb", "elasticache", "elb", "ebs", "vpc", "s3", "cloudwatch-logs", "kms", "iam", "ssh-keys", "ecr", "glue", "elastic-beanstalk", "eventbridge", "cloudwatch-alarms", "db-groups", "ecs", "apprunner", "ec2-instances", "autoscaling"}
+
+// Config configures the AWS checks
+type Config struct {
+	// Regions are the checked regions, or the region listing the enabled ones with AllRegions
+	Regions    []string
+	AllRegions bool
+	// RoleArns are the IAM roles to assume, pleco checks the account of each role, or the account of the credentials
+	// without roles
+	RoleArns []string
+	// Accounts are the credentials of other checked accounts (ex: a role with an external id), on top of the roles
+	Accounts []Credentials
+	// Services enables (true) or disables (false) the services by name, disabling takes precedence over the services
+	// enabled by EKS
+	Services      map[string]bool
+	TagName       string
+	ClusterTagKey string
+	// ClusterTags match the tags of the load balancers of the EKS clusters, by default tags whose key or value contains
+	// the cluster name
+	ClusterTags utils.ClusterTagPatterns
+	// Parallelism is the maximum number of cleaners running at the same time
+	Parallelism int
+	// EstimateCosts adds the monthly cost of resources to the reports of dry runs
+	EstimateCosts bool
+	// Plugins are the paths of Go plugins registering cleaners (see the cleaner package), their services are enabled
+	// unless disabled
+	Plugins []string
+	// Leaks detects the orphaned resources without ttl in every region if set
+	Leaks *leaks.Config
+	// OverrideDeletionProtection disables the deletion protection of expired load balancers before deleting them
+	OverrideDeletionProtection bool
+	// FinalSnapshotTTL is the ttl in seconds of the final snapshots taken before deleting RDS databases, DocumentDB
+	// clusters and EBS volumes, final snapshots are skipped if it's 0
+	FinalSnapshotTTL int64
+	// RequiredTags reports the resources of every region missing one of these tags (ex: owner, cost-center, ttl),
+	// without deleting them, if set
+	RequiredTags []string
+	// CleanerTimeout stops a cleaner of a region running longer than this during a check, 0 for no timeout
+	CleanerTimeout time.Duration
+	// DeletionWaitTimeout is how long the deletions depending on asynchronous ones (ex: a VPC after its NAT gateways,
+	// an EKS cluster after its node groups) wait for them during a check, 0 to retry them on the next checks instead
+	DeletionWaitTimeout time.Duration
+	// QuotaThreshold reports the quotas of the resources pleco cleans used above this percent in every region, and runs
+	// the cleaners of their resources first in the next check. 0 to disable.
+	QuotaThreshold float64
+}
+
+func (c Config) isServiceEnabled(serviceName string) bool {
+	return c.Services[serviceName]
+}
+
+func (c Config) isServiceDisabled(serviceName string) bool {
+	enabled, isSet := c.Services[serviceName]
+	return isSet && !enabled
+}
+
+// jobsFactory returns the cleaners to run for a check, it's called again on every check
+type jobsFactory func(dryRun bool) []utils.Job
+
+// Cleaner checks the enabled services in every region and account
+type Cleaner struct {
+	jobsFactories []jobsFactory
+	parallelism   int
+	estimator     *pricing.Estimator
+	watchdog      *quotas.Watchdog
+	waitTimeout   time.Duration
+}
+
+// NewCleaner loads the plugins and opens the sessions of every region and account, it returns nil if there is nothing
+// to check
+func NewCleaner(ctx context.Context, config Config, dryRun bool) (*Cleaner, error) {
+	awsCleaner := &Cleaner{parallelism: config.Parallelism, waitTimeout: config.DeletionWaitTimeout}
+	if config.QuotaThreshold > 0 {
+		awsCleaner.watchdog = quotas.NewWatchdog(config.QuotaThreshold)
+	}
+
+	pluginServices, err := cleaner.LoadPlugins(config.Plugins)
+	if err != nil {
+		return nil, err
+	}
+
+	services := make(map[string]bool)
+	for _, service := range pluginServices {
+		services[service] = true
+	}
+	for service, enabled := range config.Services {
+		services[service] = enabled
+	}
+	config.Services = services
+
+	// prices are the same for every account, the credentials only need to access the Pricing API
+	if dryRun && config.EstimateCosts {
+		pricingSession, err := CreateSession("us-east-1")
+		if err != nil {
+			logrus.Errorf("Can't estimate costs, AWS session error: %s", err)
+		} else {
+			awsCleaner.estimator = pricing.NewEstimator(pricingSession)
+		}
+	}
+
+	for _, credentials := range config.getAccounts() {
+		account := GetRoleAccountId(credentials.RoleArn)
+
+		accountRegions := config.Regions
+		if config.AllRegions {
+			enabledRegions, err := discoverRegions(ctx, config.Regions, credentials)
+			if err != nil {
+				logrus.Errorf("Can't discover enabled AWS regions%s: %s", accountLogSuffix(account), err)
+				continue
+			}
+
+			logrus.Infof("Discovered %d enabled AWS regions%s: %s", len(enabledRegions), accountLogSuffix(account), strings.Join(enabledRegions, ", "))
+			accountRegions = enabledRegions
+		}
+
+		if len(accountRegions) == 0 {
+			continue
+		}
+
+		for _, region := range accountRegions {
+			// AWS session
+			currentSession, err := CreateSessionWithCredentials(region, credentials)
+			if err != nil {
+				logrus.Errorf("AWS session error: %s", err)
+				continue
+			}
+
+			awsCleaner.jobsFactories = append(awsCleaner.jobsFactories, getRegionJobs(ctx, config, region, account, dryRun, currentSession, awsCleaner.watchdog))
+		}
 
-	for _, region := range regions {
 		// AWS session
-		currentSession, err := CreateSession(region)
+		currentSession, err := CreateSessionWithCredentials(accountRegions[0], credentials)
 		if err != nil {
 			logrus.Errorf("AWS session error: %s", err)
+			continue
 		}
-
-		wg.Add(1)
-		go runPlecoInRegion(cmd, region, interval, dryRun, wg, currentSession, tagName)
+		awsCleaner.jobsFactories = append(awsCleaner.jobsFactories, getGlobalJobs(ctx, config, account, dryRun, currentSession))
 	}
 
-	// AWS session
-	currentSession, err := CreateSession(regions[0])
-	if err != nil {
-		logrus.Errorf("AWS session error: %s", err)
+	if len(awsCleaner.jobsFactories) == 0 {
+		return nil, nil
 	}
-	wg.Add(1)
-	go runPlecoInGlobal(cmd, interval, dryRun, wg, currentSession, tagName)
+
+	return awsCleaner, nil
 }
 
-func runPlecoInRegion(cmd *cobra.Command, region string, interval int64, dryRun bool, wg *sync.WaitGroup, currentSession *session.Session, tagName string) {
-	defer wg.Done()
+func (c *Cleaner) Name() string {
+	return "AWS"
+}
 
-	logrus.Infof("Starting to check expired resources in region %s." , *currentSession.Config.Region)
+func (c *Cleaner) Check(ctx context.Context, dryRun bool) []error {
+	if c.estimator != nil {
+		ctx = pricing.WithEstimator(ctx, c.estimator)
+	}
+	ctx = utils.WithWaitTimeout(ctx, c.waitTimeout)
 
-	var currentS3Session *s3.S3
-	var currentRdsSession *rds.RDS
-	var currentElasticacheSession *elasticache.ElastiCache
-	var currentEKSSession *eks.EKS
-	var currentElbSession *elbv2.ELBV2
-	var currentEC2Session *ec2.EC2
-	var currentCloudwatchLogsSession *cloudwatchlogs.CloudWatchLogs
-	var currentKMSSession *kms.KMS
-	var currentECRSession *ecr.ECR
-	elbEnabled := false
-	ebsEnabled := false
+	var jobs []utils.Job
+	for _, getJobs := range c.jobsFactories {
+		jobs = append(jobs, getJobs(dryRun)...)
+	}
 
-	// S3
-	s3Enabled, _ := cmd.Flags().GetBool("enable-s3")
-	if s3Enabled {
-		currentS3Session = s3.New(currentSession)
+	if c.watchdog != nil {
+		c.watchdog.Prioritize(jobs)
 	}
 
-	// RDS + DocumentDB connection
-	rdsEnabled, _ := cmd.Flags().GetBool("enable-rds")
-	documentdbEnabled, _ := cmd.Flags().GetBool("enable-documentdb")
-	if rdsEnabled || documentdbEnabled {
-		currentRdsSession = database.RdsSession(*currentSession, region)
+	errs := utils.RunJobs(ctx, jobs, c.parallelism)
+	for _, err := range errs {
+		logrus.Error(err)
 	}
 
-	// Elasticache connection
-	elasticacheEnabled, _ := cmd.Flags().GetBool("enable-elasticache")
-	if elasticacheEnabled {
-		currentElasticacheSession = database.ElasticacheSession(*currentSession, region)
+	if len(errs) > 0 {
+		logrus.Errorf("%d of %d AWS cleaners failed during this check.", len(errs), len(jobs))
 	}
 
-	// EKS connection
-	eksEnabled, _ := cmd.Flags().GetBool("enable-eks")
-	if eksEnabled {
-		currentEKSSession = eks.New(currentSession)
-		currentElbSession = elbv2.New(currentSession)
-		elbEnabled = true
-		currentEC2Session = ec2.New(currentSession)
-		ebsEnabled = true
-		currentCloudwatchLogsSession = cloudwatchlogs.New(currentSession)
+	return errs
+}
+
+// discoverRegions lists the regions enabled on the account, any region can list the others so the first configured
+// one is used if any
+func discoverRegions(ctx context.Context, regions []string, credentials Credentials) ([]string, error) {
+	discoveryRegion := DefaultRegion()
+	if len(regions) > 0 {
+		discoveryRegion = regions[0]
 	}
 
-	// ELB connection
-	elbEnabledByUser, _ := cmd.Flags().GetBool("enable-elb")
-	if elbEnabled || elbEnabledByUser {
-		currentElbSession = elbv2.New(currentSession)
-		elbEnabled = true
+	discoverySession, err := CreateSessionWithCredentials(discoveryRegion, credentials)
+	if err != nil {
+		return nil, err
 	}
 
-	// EBS connection
-	ebsEnabledByUser, _ := cmd.Flags().GetBool("enable-ebs")
-	if ebsEnabled || ebsEnabledByUser {
-		currentEC2Session = ec2.New(currentSession)
-		ebsEnabled = true
+	return GetEnabledRegions(ctx, discoverySession)
+}
+
+// getAccounts returns the credentials of the checked accounts: the roles to assume and the accounts, or the default
+// credentials without them
+func (config Config) getAccounts() []Credentials {
+	var accounts []Credentials
+	for _, roleArn := range config.RoleArns {
+		accounts = append(accounts, Credentials{RoleArn: roleArn})
 	}
+	accounts = append(accounts, config.Accounts...)
 
-	// VPC
-	vpcEnabled, _ := cmd.Flags().GetBool("enable-vpc")
-	if vpcEnabled {
-		currentEC2Session = ec2.New(currentSession)
-		currentRdsSession = rds.New(currentSession)
+	if len(accounts) == 0 {
+		return []Credentials{{}}
 	}
 
-	// Cloudwatch
-	cloudwatchLogsEnabled, _ := cmd.Flags().GetBool("enable-cloudwatch-logs")
-	if cloudwatchLogsEnabled {
-		currentCloudwatchLogsSession = cloudwatchlogs.New(currentSession)
+	return accounts
+}
+
+func accountLogSuffix(account string) string {
+	if account == "" {
+		return ""
 	}
 
-	// KMS
-	kmsEnabled, _ := cmd.Flags().GetBool("enable-kms")
-	if kmsEnabled {
-		currentKMSSession = kms.New(currentSession)
+	return " for account " + account
+}
+
+// getOptions returns the options given to the jobs of the services
+func (c Config) getOptions(account string) cleaner.Options {
+	return cleaner.Options{
+		Account:                    account,
+		TagName:                    c.TagName,
+		ClusterTagKey:              c.ClusterTagKey,
+		ClusterTags:                c.ClusterTags,
+		FinalSnapshotTTL:           c.FinalSnapshotTTL,
+		OverrideDeletionProtection: c.OverrideDeletionProtection,
+		IsEnabled:                  c.isChecked,
 	}
+}
 
-	// SSH
-	sshKeysEnabled, _ := cmd.Flags().GetBool("enable-ssh-keys")
-	if sshKeysEnabled {
-		currentEC2Session = ec2.New(currentSession)
+// isChecked returns true if the service is registered and enabled, EKS enables ELB and EBS unless they are explicitly
+// disabled
+func (c Config) isChecked(service string) bool {
+	if cleaner.GetFactory(service) == nil {
+		if _, hasJob := cleaner.GetJob(service); !hasJob {
+			return false
+		}
 	}
 
-	// ECR
-	ecrEnabled, _ := cmd.Flags().GetBool("enable-ecr")
-	if ecrEnabled {
-		currentECRSession = ecr.New(currentSession)
+	if (service == "elb" || service == "ebs") && c.isChecked("eks") {
+		return !c.isServiceDisabled(service)
 	}
 
-	for {
-		//tag cluster resources
-		if eksEnabled && vpcEnabled{
-			logrus.Debugf("Tagging clusters resources in region %s.", *currentRdsSession.Config.Region)
-			 err := eks2.TagClustersResources(*currentEKSSession, *currentEC2Session, *currentRdsSession, tagName)
-			 if err != nil {
-			 	logrus.Error(err)
-			 }
-		}
+	return c.isServiceEnabled(service)
+}
 
-		// check s3
-		if s3Enabled {
-			logrus.Debugf("Listing all S3 buckets in region %s.", *currentS3Session.Config.Region)
-			DeleteExpiredBuckets(*currentS3Session, tagName, dryRun)
-		}
+// serviceJob is the job of an enabled service in a region
+type serviceJob struct {
+	name string
+	run  cleaner.Job
+}
 
-		// check RDS
-		if rdsEnabled {
-			logrus.Debugf("Listing all RDS databases in region %s.", *currentRdsSession.Config.Region)
-			database.DeleteExpiredRDSDatabases(*currentRdsSession, tagName, dryRun)
-		}
+// getServiceJobs returns the jobs of the enabled services, regional or global ones, in their registration order. The
+// registered cleaners are run by a job deleting their expired resources.
+func getServiceJobs(ctx context.Context, config Config, account string, global bool, currentSession *session.Session, region string) []serviceJob {
+	options := config.getOptions(account)
 
-		// check DocumentDB
-		if documentdbEnabled {
-			logrus.Debugf("Listing all DocumentDB databases in region %s.", *currentRdsSession.Config.Region)
-			database.DeleteExpiredDocumentDBClusters(*currentRdsSession, tagName, dryRun)
+	var jobs []serviceJob
+	for _, service := range cleaner.OrderedServices() {
+		if !config.isChecked(service) {
+			continue
 		}
 
-		// check Elasticache
-		if elasticacheEnabled {
-			logrus.Debugf("Listing all Elasticache databases in region %s.", *currentElasticacheSession.Config.Region)
-			database.DeleteExpiredElasticacheDatabases(*currentElasticacheSession, tagName, dryRun)
+		if factory := cleaner.GetFactory(service); factory != nil {
+			if global {
+				continue
+			}
+			registeredCleaner := factory(currentSession, region)
+			jobs = append(jobs, serviceJob{name: registeredCleaner.Name(), run: func(ctx context.Context, dryRun bool) error {
+				logrus.Debugf("Listing all %ss in region %s.", registeredCleaner.Name(), region)
+				return cleaner.DeleteExpired(ctx, registeredCleaner, region, options.TagName, dryRun)
+			}})
+			continue
 		}
 
-		// check EKS
-		if eksEnabled {
-			logrus.Debugf("Listing all EKS clusters in region %s.", *currentEKSSession.Config.Region)
-			eks2.DeleteExpiredEKSClusters(*currentEKSSession, *currentEC2Session, *currentElbSession, *currentCloudwatchLogsSession, *currentRdsSession, tagName, dryRun)
+		job, _ := cleaner.GetJob(service)
+		if job.Global != global {
+			continue
 		}
+		run := job.New(ctx, currentSession, region, options)
+		if run != nil {
+			jobs = append(jobs, serviceJob{name: job.Name, run: run})
+		}
+	}
+
+	return jobs
+}
+
+func getRegionJobs(ctx context.Context, config Config, region string, account string, dryRun bool, currentSession *session.Session, watchdog *quotas.Watchdog) jobsFactory {
+	logrus.Infof("Starting to check expired resources in region %s%s.", *currentSession.Config.Region, accountLogSuffix(account))
+
+	tagName := config.TagName
+	serviceJobs := getServiceJobs(ctx, config, account, false, currentSession, region)
+
+	// Resource Groups Tagging API, used to discover tagged resources in a single sweep
+	currentTaggingSession := resourcegroupstaggingapi.New(currentSession)
+
+	// Leaks, orphaned EC2 and load balancing resources
+	var currentLeaksEC2Session *ec2.EC2
+	var currentLeaksElbSession *elbv2.ELBV2
+	if config.Leaks != nil {
+		currentLeaksEC2Session = ec2.New(currentSession)
+		currentLeaksElbSession = elbv2.New(currentSession)
+	}
 
-		// check load balancers
-		if elbEnabled {
-			logrus.Debugf("Listing all ELB load balancers in region %s.", *currentElbSession.Config.Region)
-			ec22.DeleteExpiredLoadBalancers(*currentElbSession, tagName, dryRun)
+	// Service Quotas, with the clients counting the resources limited by quotas
+	var quotasClients quotas.Clients
+	if watchdog != nil {
+		quotasClients = quotas.Clients{
+			EC2:           ec2.New(currentSession),
+			ELB:           elbv2.New(currentSession),
+			ServiceQuotas: servicequotas.New(currentSession),
 		}
+	}
 
-		// check EBS volumes
-		if ebsEnabled {
-			logrus.Debugf("Listing all EBS volumes in region %s.", *currentEC2Session.Config.Region)
-			ec22.DeleteExpiredVolumes(*currentEC2Session, tagName, dryRun)
+	// Deletions scheduling, expired resources are tagged with their deletion date through the tagging API
+	var deletionScheduler utils.DeletionScheduler
+	if !dryRun && (utils.HasGracePeriod(ctx) || utils.HasQuarantine(ctx)) {
+		accountId, err := GetAccountId(ctx, currentSession)
+		if err != nil {
+			logrus.Errorf("Can't get AWS account id, deletions can't be scheduled in region %s: %s", region, err)
 		}
+		deletionScheduler = tagging.NewDeletionScheduler(currentTaggingSession, region, accountId)
+	}
 
-		// check VPC
-		if vpcEnabled {
-			logrus.Debugf("Listing all VPC resources in region %s.", *currentEC2Session.Config.Region)
-			vpc.DeleteExpiredVPC(*currentEC2Session, tagName, dryRun)
-			database.DeleteExpiredRDSSubnetGroups(*currentRdsSession, tagName, dryRun)
+	return func(dryRun bool) []utils.Job {
+		var jobs []utils.Job
+
+		// tagged resources are discovered once per check and shared by the cleaners relying on them
+		taggedResources := tagging.NewSharedTaggedResources(currentTaggingSession, region, tagName)
+
+		addJob := func(name string, run func(ctx context.Context) error) {
+			sharedRun := run
+			run = func(ctx context.Context) error {
+				return sharedRun(tagging.WithSharedTaggedResources(ctx, taggedResources))
+			}
+			if !dryRun && deletionScheduler != nil {
+				scheduledRun := run
+				run = func(ctx context.Context) error {
+					return scheduledRun(utils.WithDeletionScheduler(ctx, deletionScheduler))
+				}
+			}
+			jobs = append(jobs, utils.Job{Name: name, Region: region, Account: account, Run: run, Timeout: config.CleanerTimeout})
 		}
 
-		//check Cloudwatch
-		if cloudwatchLogsEnabled {
-			logrus.Debugf("Listing all Cloudwatch logs in region %s.", *currentCloudwatchLogsSession.Config.Region)
-			logs.DeleteExpiredLogs(*currentCloudwatchLogsSession, tagName, dryRun)
+		// check the enabled services
+		for _, serviceJob := range serviceJobs {
+			serviceJob := serviceJob
+			addJob(serviceJob.name, func(ctx context.Context) error {
+				return serviceJob.run(ctx, dryRun)
+			})
 		}
 
-		// check KMS
-		if kmsEnabled {
-			logrus.Debugf("Listing all KMS keys in region %s.", *currentKMSSession.Config.Region)
-			DeleteExpiredKeys(*currentKMSSession, tagName, dryRun)
+		// report the resources missing required tags
+		if len(config.RequiredTags) > 0 {
+			addJob("Tag compliance", func(ctx context.Context) error {
+				logrus.Debugf("Checking the tags of all resources in region %s.", region)
+				return tagging.ReportTagViolations(ctx, currentTaggingSession, region, config.RequiredTags, tagName)
+			})
 		}
 
-		// check SSH
-		if sshKeysEnabled {
-			logrus.Debugf("Listing all EC2 key pairs in region %s.", *currentEC2Session.Config.Region)
-			ec22.DeleteExpiredKeys(currentEC2Session, tagName, dryRun)
+		// detect leaks
+		if config.Leaks != nil {
+			addJob("Leaks", func(ctx context.Context) error {
+				logrus.Debugf("Detecting leaked resources in region %s.", region)
+				return leaks.DetectLeaks(ctx, currentLeaksEC2Session, currentLeaksElbSession, region, *config.Leaks, tagName, dryRun)
+			})
 		}
 
-		// check ECR
-		if ecrEnabled {
-			logrus.Debugf("Listing all ECR repositories in region %s.", *currentECRSession.Config.Region)
-			eks2.DeleteEmptyRepositories(currentECRSession, dryRun)
+		// report the quotas nearly reached
+		if watchdog != nil {
+			addJob("Quotas", func(ctx context.Context) error {
+				logrus.Debugf("Checking the service quotas in region %s.", region)
+				return watchdog.Check(ctx, quotasClients, region, account)
+			})
 		}
 
-		time.Sleep(time.Duration(interval) * time.Second)
+		return jobs
 	}
-
 }
 
-func runPlecoInGlobal(cmd *cobra.Command, interval int64, dryRun bool, wg *sync.WaitGroup, currentSession *session.Session, tagName string) {
-	defer wg.Done()
-
-	logrus.Info("Starting to check global expired resources.")
+func getGlobalJobs(ctx context.Context, config Config, account string, dryRun bool, currentSession *session.Session) jobsFactory {
+	logrus.Infof("Starting to check global expired resources%s.", accountLogSuffix(account))
 
-	var currentIAMSession *iam.IAM
+	serviceJobs := getServiceJobs(ctx, config, account, true, currentSession, "global")
 
-	// IAM
-	iamEnabled, _ := cmd.Flags().GetBool("enable-iam")
-	if iamEnabled {
-		currentIAMSession = iam.New(currentSession)
-	}
+	return func(dryRun bool) []utils.Job {
+		var jobs []utils.Job
 
-	for {
-		// check IAM
-		if iamEnabled {
-			logrus.Debug("Listing all IAM access.")
-			iam2.DeleteExpiredIAM(currentIAMSession, tagName, dryRun)
+		// check the enabled global services
+		for _, serviceJob := range serviceJobs {
+			serviceJob := serviceJob
+			jobs = append(jobs, utils.Job{Name: serviceJob.name, Region: "global", Account: account, Timeout: config.CleanerTimeout, Run: func(ctx context.Context) error {
+				return serviceJob.run(ctx, dryRun)
+			}})
 		}
 
-		time.Sleep(time.Duration(interval) * time.Second)
+		return jobs
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,54 @@
+package aws
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"reflect"
+	"testing"
+)
+
+func TestServiceJobsFromRegistry(t *testing.T) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String("eu-west-3")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// plugins replace or disable built-in services, the registry is restored after the test
+	for _, service := range []string{"s3", "ecr"} {
+		job, _ := cleaner.GetJob(service)
+		defer cleaner.RegisterJob(service, job)
+	}
+	cleaner.RegisterJob("s3", cleaner.ServiceJob{Name: "Company S3", New: func(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+		return func(ctx context.Context, dryRun bool) error { return nil }
+	}})
+	cleaner.Unregister("ecr")
+
+	tests := []struct {
+		name     string
+		services map[string]bool
+		global   bool
+		want     []string
+	}{
+		{name: "replaced service", services: map[string]bool{"s3": true}, want: []string{"Company S3"}},
+		{name: "disabled service", services: map[string]bool{"ecr": true, "ecs": true}, want: []string{"ECS"}},
+		{name: "services enabled by EKS", services: map[string]bool{"eks": true, "vpc": true}, want: []string{"EKS", "ELB", "EBS", "VPC"}},
+		{name: "service enabled by EKS disabled", services: map[string]bool{"eks": true, "ebs": false}, want: []string{"EKS", "ELB"}},
+		{name: "global service", services: map[string]bool{"iam": true, "s3": true}, global: true, want: []string{"IAM"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config := Config{Services: test.services, TagName: "ttl"}
+
+			var names []string
+			for _, job := range getServiceJobs(context.Background(), config, "", test.global, sess, "eu-west-3") {
+				names = append(names, job.name)
+			}
+			if !reflect.DeepEqual(names, test.want) {
+				t.Errorf("jobs %v, want %v", names, test.want)
+			}
+		})
+	}
+}
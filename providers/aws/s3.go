@@ -1,10 +1,13 @@
 package aws
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	log "github.com/sirupsen/logrus"
 	"time"
 )
@@ -13,14 +16,16 @@ type s3Bucket struct {
 	Name string
 	CreateTime time.Time
 	TTL int64
+	ExpirationDate time.Time
+	DeletionScheduled time.Time
 	IsProtected bool
+	Tags map[string]string
 }
 
-func listTaggedBuckets(s3Session s3.S3, tagName string) ([]s3Bucket, error) {
+func listTaggedBuckets(ctx context.Context, s3Session s3iface.S3API, region string, tagName string) ([]s3Bucket, error) {
 	var taggedS3Buckets []s3Bucket
-	currentRegion := s3Session.Config.Region
 
-	result, bucketErr := s3Session.ListBuckets(&s3.ListBucketsInput{})
+	result, bucketErr := s3Session.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
 	if bucketErr != nil {
 		return nil, bucketErr
 	}
@@ -30,7 +35,7 @@ func listTaggedBuckets(s3Session s3.S3, tagName string) ([]s3Bucket, error) {
 	}
 
 	for _, bucket := range result.Buckets {
-		location, locationErr := s3Session.GetBucketLocation(
+		location, locationErr := s3Session.GetBucketLocationWithContext(ctx,
 			&s3.GetBucketLocationInput{
 				Bucket: aws.String(*bucket.Name),
 		})
@@ -38,11 +43,11 @@ func listTaggedBuckets(s3Session s3.S3, tagName string) ([]s3Bucket, error) {
 			continue
 		}
 
-		if *location.LocationConstraint != *currentRegion {
+		if *location.LocationConstraint != region {
 			continue
 		}
 
-		bucketTags, tagErr := s3Session.GetBucketTagging(
+		bucketTags, tagErr := s3Session.GetBucketTaggingWithContext(ctx,
 			&s3.GetBucketTaggingInput{
 				Bucket: aws.String(*bucket.Name),
 		})
@@ -50,21 +55,24 @@ func listTaggedBuckets(s3Session s3.S3, tagName string) ([]s3Bucket, error) {
 			continue
 		}
 
-		_, ttl, isProtected, _, _ := utils.GetEssentialTags(bucketTags.TagSet, tagName)
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, bucketTags.TagSet, tagName)
 
 		taggedS3Buckets = append(taggedS3Buckets, s3Bucket{
 			Name:   	*bucket.Name,
 			CreateTime: *bucket.CreationDate,
 			TTL:    	ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isProtected,
+			Tags: utils.TagsToMap(bucketTags.TagSet),
 		})
 	}
 
 	return taggedS3Buckets, nil
 }
 
-func deleteS3Objects(s3session s3.S3, bucket string, objects []*s3.ObjectIdentifier) error {
-	_, err := s3session.DeleteObjects(
+func deleteS3Objects(ctx context.Context, s3session s3iface.S3API, bucket string, objects []*s3.ObjectIdentifier) error {
+	_, err := s3session.DeleteObjectsWithContext(ctx,
 		&s3.DeleteObjectsInput{
 			Bucket: aws.String(bucket),
 			Delete: &s3.Delete{
@@ -79,12 +87,19 @@ func deleteS3Objects(s3session s3.S3, bucket string, objects []*s3.ObjectIdentif
 	return nil
 }
 
-func deleteS3ObjectsVersions(s3session s3.S3, bucket string) error {
+func deleteS3ObjectsVersions(ctx context.Context, s3session s3iface.S3API, bucket string) error {
 	// list all objects
-	result, err := s3session.ListObjectVersions(
+	var versions []*s3.ObjectVersion
+	var deleteMarkers []*s3.DeleteMarkerEntry
+	err := s3session.ListObjectVersionsPagesWithContext(ctx,
 		&s3.ListObjectVersionsInput{
 			Bucket: aws.String(bucket),
-	})
+		},
+		func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+			versions = append(versions, page.Versions...)
+			deleteMarkers = append(deleteMarkers, page.DeleteMarkers...)
+			return true
+		})
 	if err != nil {
 		return err
 	}
@@ -92,9 +107,9 @@ func deleteS3ObjectsVersions(s3session s3.S3, bucket string) error {
 	// delete all objects
 	var objectsIdentifiers []*s3.ObjectIdentifier
 	counter := 0
-	for _, version := range result.Versions {
+	for _, version := range versions {
 		if counter >= 1000 {
-			_ = deleteS3Objects(s3session, bucket, objectsIdentifiers)
+			_ = deleteS3Objects(ctx, s3session, bucket, objectsIdentifiers)
 			objectsIdentifiers = []*s3.ObjectIdentifier{}
 			counter = 0
 		}
@@ -108,14 +123,14 @@ func deleteS3ObjectsVersions(s3session s3.S3, bucket string) error {
 
 		counter++
 	}
-	_ = deleteS3Objects(s3session, bucket, objectsIdentifiers)
+	_ = deleteS3Objects(ctx, s3session, bucket, objectsIdentifiers)
 
 	// delete all Markers
 	objectsIdentifiers = []*s3.ObjectIdentifier{}
 	counter = 0
-	for _, version := range result.DeleteMarkers {
+	for _, version := range deleteMarkers {
 		if counter >= 1000 {
-			_ = deleteS3Objects(s3session, bucket, objectsIdentifiers)
+			_ = deleteS3Objects(ctx, s3session, bucket, objectsIdentifiers)
 			objectsIdentifiers = []*s3.ObjectIdentifier{}
 			counter = 0
 		}
@@ -129,17 +144,22 @@ func deleteS3ObjectsVersions(s3session s3.S3, bucket string) error {
 
 		counter++
 	}
-	_ = deleteS3Objects(s3session, bucket, objectsIdentifiers)
+	_ = deleteS3Objects(ctx, s3session, bucket, objectsIdentifiers)
 
 	return nil
 }
 
-func deleteAllS3Objects(s3session s3.S3, bucket string) error {
+func deleteAllS3Objects(ctx context.Context, s3session s3iface.S3API, bucket string) error {
 	// list all objects
-	result, err := s3session.ListObjectsV2(
+	var objects []*s3.Object
+	err := s3session.ListObjectsV2PagesWithContext(ctx,
 		&s3.ListObjectsV2Input{
 			Bucket: aws.String(bucket),
-	})
+		},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			objects = append(objects, page.Contents...)
+			return true
+		})
 	if err != nil {
 		return err
 	}
@@ -147,9 +167,9 @@ func deleteAllS3Objects(s3session s3.S3, bucket string) error {
 	// delete all objects
 	var objectsIdentifiers []*s3.ObjectIdentifier
 	counter := 0
-	for _, object := range result.Contents {
+	for _, object := range objects {
 		if counter >= 1000 {
-			_ = deleteS3Objects(s3session, bucket, objectsIdentifiers)
+			_ = deleteS3Objects(ctx, s3session, bucket, objectsIdentifiers)
 			objectsIdentifiers = []*s3.ObjectIdentifier{}
 			counter = 0
 		}
@@ -163,30 +183,30 @@ func deleteAllS3Objects(s3session s3.S3, bucket string) error {
 		counter++
 	}
 
-	_ = deleteS3Objects(s3session, bucket, objectsIdentifiers)
+	_ = deleteS3Objects(ctx, s3session, bucket, objectsIdentifiers)
 
 	return nil
 }
 
-func deleteS3Buckets(s3session s3.S3, bucket string) error {
-	log.Infof("Deleting bucket %s in %s", bucket, *s3session.Config.Region)
+func deleteS3Buckets(ctx context.Context, s3session s3iface.S3API, region string, bucket string) error {
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.S3Bucket, region, bucket).Infof("Deleting bucket %s in %s", bucket, region)
 
 	// delete objects versions
-	err := deleteS3ObjectsVersions(s3session, bucket)
+	err := deleteS3ObjectsVersions(ctx, s3session, bucket)
 	if err != nil {
 		log.Errorf("Error while deleting object version file: %v", err)
 		return err
 	}
 
 	// delete objects
-	err = deleteAllS3Objects(s3session, bucket)
+	err = deleteAllS3Objects(ctx, s3session, bucket)
 	if err != nil {
 		log.Errorf("Error while deleting object file: %v", err)
 		return err
 	}
 
 	// delete bucket
-	_, err = s3session.DeleteBucket(
+	_, err = s3session.DeleteBucketWithContext(ctx,
 		&s3.DeleteBucketInput{
 			Bucket: &bucket,
 		})
@@ -197,23 +217,22 @@ func deleteS3Buckets(s3session s3.S3, bucket string) error {
 	return nil
 }
 
-func DeleteExpiredBuckets(s3session s3.S3, tagName string, dryRun bool) {
-	buckets, err := listTaggedBuckets(s3session, tagName)
-	region := s3session.Config.Region
+func DeleteExpiredBuckets(ctx context.Context, s3session s3iface.S3API, region string, tagName string, dryRun bool) error {
+	buckets, err := listTaggedBuckets(ctx, s3session, region, tagName)
 	if err != nil {
-		log.Errorf("can't list S3 buckets: %s\n", err)
-		return
+		return fmt.Errorf("can't list S3 buckets: %s", err)
 	}
 	var expiredBuckets []s3Bucket
 	for _, bucket := range buckets {
-		if utils.CheckIfExpired(bucket.CreateTime, bucket.TTL) && !bucket.IsProtected {
+		if utils.CheckIfDeletable(ctx, bucket.CreateTime, bucket.TTL, bucket.ExpirationDate, bucket.DeletionScheduled, bucket.IsProtected, resources.S3Bucket, region, bucket.Name) {
+			utils.SetReportTags(ctx, resources.S3Bucket, region, bucket.Name, bucket.Tags)
 			expiredBuckets = append(expiredBuckets, bucket)
 		}
 	}
 
-	s := fmt.Sprintf("There is no expired S3 bucket to delete in %s.", *region)
+	s := fmt.Sprintf("There is no expired S3 bucket to delete in %s.", region)
 	if len(expiredBuckets) == 1 {
-		s = fmt.Sprintf("There is 1 expired S3 bucket to delete in %s.", *region)
+		s = fmt.Sprintf("There is 1 expired S3 bucket to delete in %s.", region)
 	}
 	if len(expiredBuckets) > 1 {
 		s = fmt.Sprintf("There are %d expired S3 buckets to delete.", len(expiredBuckets))
@@ -222,16 +241,26 @@ func DeleteExpiredBuckets(s3session s3.S3, tagName string, dryRun bool) {
 	log.Debug(s)
 
 	if dryRun || len(expiredBuckets) == 0 {
-		return
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.S3Bucket, region, len(expiredBuckets))
+	if limitErr != nil {
+		return limitErr
 	}
 
 	log.Debug("Starting expired S3 buckets deletion.")
 
-	for _, bucket := range buckets {
-		deletionErr := deleteS3Buckets(s3session, bucket.Name)
+	for _, bucket := range expiredBuckets {
+		deletionErr := deleteS3Buckets(ctx, s3session, region, bucket.Name)
 		if deletionErr != nil {
-			log.Errorf("Deletion S3 Bucket %s/%s error: %s",
-					bucket.Name, *region, err)
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.S3Bucket, region, bucket.Name).Errorf("Deletion S3 Bucket %s/%s error: %s",
+					bucket.Name, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.S3Bucket, region, bucket.Name, deletionErr)
+			continue
 		}
+		utils.ReportDeleted(ctx, resources.S3Bucket, region, bucket.Name)
 	}
+
+	return nil
 }
\ No newline at end of file
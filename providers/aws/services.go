@@ -0,0 +1,263 @@
+package aws
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/cleaner"
+	"github.com/Qovery/pleco/providers/aws/database"
+	ec22 "github.com/Qovery/pleco/providers/aws/ec2"
+	ecs2 "github.com/Qovery/pleco/providers/aws/ecs"
+	eks2 "github.com/Qovery/pleco/providers/aws/eks"
+	iam2 "github.com/Qovery/pleco/providers/aws/iam"
+	"github.com/Qovery/pleco/providers/aws/logs"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/providers/aws/vpc"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatchlogs"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/elasticbeanstalk"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/glue"
+	"github.com/aws/aws-sdk-go/service/iam"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sirupsen/logrus"
+)
+
+// The built-in services checked by their own job rather than a cleaner, in the order they run. Plugins can replace
+// them with cleaner.Register or cleaner.RegisterJob, or disable them with cleaner.Unregister.
+func init() {
+	cleaner.RegisterJob("s3", cleaner.ServiceJob{Name: "S3", New: newS3Job})
+	cleaner.RegisterJob("rds", cleaner.ServiceJob{Name: "RDS", New: newRDSJob})
+	cleaner.RegisterJob("documentdb", cleaner.ServiceJob{Name: "DocumentDB", New: newDocumentDBJob})
+	cleaner.RegisterJob("elasticache", cleaner.ServiceJob{Name: "Elasticache", New: newElasticacheJob})
+	cleaner.RegisterJob("eks", cleaner.ServiceJob{Name: "EKS", New: newEKSJob})
+	cleaner.RegisterJob("elb", cleaner.ServiceJob{Name: "ELB", New: newELBJob})
+	cleaner.RegisterJob("ebs", cleaner.ServiceJob{Name: "EBS", New: newEBSJob})
+	cleaner.RegisterJob("vpc", cleaner.ServiceJob{Name: "VPC", New: newVPCJob})
+	cleaner.RegisterJob("db-groups", cleaner.ServiceJob{Name: "DB groups", New: newDBGroupsJob})
+	cleaner.RegisterJob("cloudwatch-logs", cleaner.ServiceJob{Name: "Cloudwatch logs", New: newCloudwatchLogsJob})
+	cleaner.RegisterJob("ecr", cleaner.ServiceJob{Name: "ECR", New: newECRJob})
+	cleaner.RegisterJob("ecs", cleaner.ServiceJob{Name: "ECS", New: newECSJob})
+	cleaner.RegisterJob("glue", cleaner.ServiceJob{Name: "Glue", New: newGlueJob})
+	cleaner.RegisterJob("elastic-beanstalk", cleaner.ServiceJob{Name: "Elastic Beanstalk", New: newBeanstalkJob})
+	cleaner.RegisterJob("iam", cleaner.ServiceJob{Name: "IAM", Global: true, New: newIAMJob})
+}
+
+func newS3Job(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := s3.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all S3 buckets in region %s.", region)
+		return DeleteExpiredBuckets(ctx, svc, region, options.TagName, dryRun)
+	}
+}
+
+func newRDSJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := database.RdsSession(*sess, region)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all RDS databases in region %s.", region)
+		err := database.DeleteExpiredRDSDatabases(ctx, svc, region, options.TagName, options.FinalSnapshotTTL, dryRun)
+		if err != nil {
+			return err
+		}
+
+		return database.DeleteExpiredFinalSnapshots(ctx, svc, region, options.TagName, options.FinalSnapshotTTL, false, dryRun)
+	}
+}
+
+func newDocumentDBJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := database.RdsSession(*sess, region)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all DocumentDB databases in region %s.", region)
+		err := database.DeleteExpiredDocumentDBClusters(ctx, svc, region, options.TagName, options.FinalSnapshotTTL, dryRun)
+		if err != nil {
+			return err
+		}
+
+		return database.DeleteExpiredFinalSnapshots(ctx, svc, region, options.TagName, options.FinalSnapshotTTL, true, dryRun)
+	}
+}
+
+func newElasticacheJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := database.ElasticacheSession(*sess, region)
+
+	return func(ctx context.Context, dryRun bool) error {
+		taggedResources, err := tagging.GetSharedTaggedResources(ctx)
+		if err != nil {
+			return err
+		}
+
+		logrus.Debugf("Listing all Elasticache databases in region %s.", region)
+		return database.DeleteExpiredElasticacheDatabases(ctx, svc, region, taggedResources, options.TagName, dryRun)
+	}
+}
+
+func newEKSJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := eks.New(sess)
+	ec2Session := ec2.New(sess)
+	elbSession := elbv2.New(sess)
+	logsSession := cloudwatchlogs.New(sess)
+	rdsSession := database.RdsSession(*sess, region)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all EKS clusters in region %s.", region)
+		return eks2.DeleteExpiredEKSClusters(ctx, svc, region, ec2Session, elbSession, logsSession, rdsSession, options.TagName, options.ClusterTagKey, options.ClusterTags, dryRun)
+	}
+}
+
+func newELBJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := elbv2.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		taggedResources, err := tagging.GetSharedTaggedResources(ctx)
+		if err != nil {
+			return err
+		}
+
+		logrus.Debugf("Listing all ELB load balancers in region %s.", region)
+		return ec22.DeleteExpiredLoadBalancers(ctx, svc, region, taggedResources, options.TagName, options.OverrideDeletionProtection, dryRun)
+	}
+}
+
+func newEBSJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := ec2.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all EBS volumes in region %s.", region)
+		err := ec22.DeleteExpiredVolumes(ctx, svc, region, options.TagName, options.FinalSnapshotTTL, dryRun)
+		if err != nil {
+			return err
+		}
+
+		return ec22.DeleteExpiredFinalSnapshots(ctx, svc, region, options.TagName, dryRun)
+	}
+}
+
+func newVPCJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := ec2.New(sess)
+	rdsSession := database.RdsSession(*sess, region)
+	eksSession := eks.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		var tagErr error
+
+		//tag cluster resources
+		if options.IsEnabled("eks") {
+			logrus.Debugf("Tagging clusters resources in region %s.", region)
+			tagErr = eks2.TagClustersResources(ctx, eksSession, region, svc, rdsSession, options.TagName, options.ClusterTagKey)
+		}
+
+		// children first, VPC can't be deleted while they still exist
+		logrus.Debugf("Listing all VPC resources in region %s.", region)
+		vpcErr := utils.JoinErrors(
+			tagErr,
+			vpc.DeleteExpiredVpnConnections(ctx, svc, region, options.TagName, dryRun),
+			vpc.DeleteExpiredCustomerGateways(ctx, svc, region, options.TagName, dryRun),
+			vpc.DeleteExpiredTransitGatewayAttachments(ctx, svc, region, options.TagName, dryRun),
+			vpc.DeleteExpiredTransitGateways(ctx, svc, region, options.TagName, dryRun),
+			vpc.DeleteExpiredVPC(ctx, svc, region, options.TagName, dryRun),
+		)
+
+		// the database groups cleaner deletes them otherwise
+		if options.IsEnabled("db-groups") {
+			return vpcErr
+		}
+
+		return utils.JoinErrors(vpcErr, database.DeleteExpiredRDSSubnetGroups(ctx, rdsSession, region, options.TagName, dryRun))
+	}
+}
+
+// newDBGroupsJob checks the subnet and parameter groups left by the deleted databases
+func newDBGroupsJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	rdsSession := database.RdsSession(*sess, region)
+	elasticacheSession := database.ElasticacheSession(*sess, region)
+
+	return func(ctx context.Context, dryRun bool) error {
+		taggedResources, err := tagging.GetSharedTaggedResources(ctx)
+		if err != nil {
+			return err
+		}
+
+		logrus.Debugf("Listing all database subnet and parameter groups in region %s.", region)
+		return utils.JoinErrors(
+			database.DeleteExpiredRDSSubnetGroups(ctx, rdsSession, region, options.TagName, dryRun),
+			database.DeleteExpiredRDSParameterGroups(ctx, rdsSession, region, options.TagName, dryRun),
+			database.DeleteExpiredElasticacheGroups(ctx, elasticacheSession, region, taggedResources, options.TagName, dryRun),
+		)
+	}
+}
+
+func newCloudwatchLogsJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := cloudwatchlogs.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all Cloudwatch logs in region %s.", region)
+		return logs.DeleteExpiredLogs(ctx, svc, region, options.TagName, dryRun)
+	}
+}
+
+func newECRJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := ecr.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all ECR repositories in region %s.", region)
+		return eks2.DeleteEmptyRepositories(ctx, svc, region, dryRun)
+	}
+}
+
+func newECSJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := ecs.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debugf("Listing all ECS services and clusters in region %s.", region)
+		return ecs2.DeleteExpiredServicesAndClusters(ctx, svc, region, options.TagName, dryRun)
+	}
+}
+
+func newGlueJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	accountId, err := GetAccountId(ctx, sess)
+	if err != nil {
+		logrus.Errorf("Can't get AWS account id, disabling Glue watch in region %s: %s", region, err)
+		return nil
+	}
+	svc := glue.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		taggedResources, err := tagging.GetSharedTaggedResources(ctx)
+		if err != nil {
+			return err
+		}
+
+		return DeleteExpiredGlue(ctx, svc, region, accountId, taggedResources, options.TagName, dryRun)
+	}
+}
+
+func newBeanstalkJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := elasticbeanstalk.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		taggedResources, err := tagging.GetSharedTaggedResources(ctx)
+		if err != nil {
+			return err
+		}
+
+		return DeleteExpiredBeanstalk(ctx, svc, region, taggedResources, options.TagName, dryRun)
+	}
+}
+
+func newIAMJob(ctx context.Context, sess *session.Session, region string, options cleaner.Options) cleaner.Job {
+	svc := iam.New(sess)
+
+	return func(ctx context.Context, dryRun bool) error {
+		logrus.Debug("Listing all IAM access.")
+		if !dryRun && utils.HasGracePeriod(ctx) {
+			ctx = utils.WithDeletionScheduler(ctx, iam2.NewDeletionScheduler(svc))
+		}
+		return iam2.DeleteExpiredIAM(ctx, svc, options.TagName, dryRun)
+	}
+}
@@ -0,0 +1,219 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/database"
+	ec22 "github.com/Qovery/pleco/providers/aws/ec2"
+	"github.com/Qovery/pleco/providers/aws/tagging"
+	"github.com/Qovery/pleco/providers/aws/vpc"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/eks"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// TagSelector picks the resources of a region whose ttl tag is set by the tag command
+type TagSelector struct {
+	Arns        []string
+	NamePattern *regexp.Regexp
+	ClusterName string
+}
+
+// TagResourcesTTL sets the ttl tag of the resources of the region picked by the selector
+func TagResourcesTTL(ctx context.Context, sess *session.Session, region string, selector TagSelector, ttl int64, tagName string, clusterTagKey string, clusterTags utils.ClusterTagPatterns) error {
+	taggingSession := resourcegroupstaggingapi.New(sess)
+	elbSession := elbv2.New(sess)
+
+	if len(selector.Arns) > 0 {
+		err := tagArnsTTL(ctx, taggingSession, selector.Arns, ttl, tagName)
+		if err != nil {
+			return err
+		}
+		logrus.Infof("Set the %s tag of %d resources in %s.", tagName, len(selector.Arns), region)
+	}
+
+	if selector.NamePattern != nil {
+		err := tagMatchingResourcesTTL(ctx, taggingSession, elbSession, region, selector.NamePattern, ttl, tagName)
+		if err != nil {
+			return err
+		}
+	}
+
+	if selector.ClusterName != "" {
+		err := tagClusterResourcesTTL(ctx, sess, taggingSession, elbSession, region, selector.ClusterName, ttl, tagName, clusterTagKey, clusterTags)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tagArnsTTL sets the ttl tag through the Resource Groups Tagging API, which tags up to 20 resources at once
+func tagArnsTTL(ctx context.Context, svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, arns []string, ttl int64, tagName string) error {
+	for start := 0; start < len(arns); start += 20 {
+		end := start + 20
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		result, err := svc.TagResourcesWithContext(ctx,
+			&resourcegroupstaggingapi.TagResourcesInput{
+				ResourceARNList: aws.StringSlice(arns[start:end]),
+				Tags:            aws.StringMap(map[string]string{tagName: strconv.FormatInt(ttl, 10)}),
+			})
+		if err != nil {
+			return err
+		}
+
+		for failedArn, failure := range result.FailedResourcesMap {
+			return fmt.Errorf("can't tag %s: %s", failedArn, aws.StringValue(failure.ErrorMessage))
+		}
+	}
+
+	return nil
+}
+
+// getArnResourceName returns the name or id of the resource, the last part of its ARN
+func getArnResourceName(parsedArn arn.ARN) string {
+	return parsedArn.Resource[strings.LastIndexAny(parsedArn.Resource, "/:")+1:]
+}
+
+// tagMatchingResourcesTTL sets the ttl tag of the load balancers and of the resources known by the tagging API whose
+// name matches the pattern. The tagging API only knows the resources that were tagged at least once.
+func tagMatchingResourcesTTL(ctx context.Context, taggingSession resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, elbSession *elbv2.ELBV2, region string, namePattern *regexp.Regexp, ttl int64, tagName string) error {
+	loadBalancers, err := ec22.ListLoadBalancers(ctx, elbSession)
+	if err != nil {
+		return fmt.Errorf("can't list load balancers in %s: %s", region, err)
+	}
+
+	var matchingLoadBalancers []ec22.ElasticLoadBalancer
+	for _, lb := range loadBalancers {
+		if namePattern.MatchString(lb.Name) {
+			matchingLoadBalancers = append(matchingLoadBalancers, lb)
+		}
+	}
+
+	err = ec22.TagLoadBalancersForDeletion(ctx, elbSession, region, tagName, ttl, matchingLoadBalancers, "")
+	if err != nil {
+		return err
+	}
+
+	var matchingArns []string
+	err = taggingSession.GetResourcesPagesWithContext(ctx, &resourcegroupstaggingapi.GetResourcesInput{},
+		func(page *resourcegroupstaggingapi.GetResourcesOutput, lastPage bool) bool {
+			for _, resource := range page.ResourceTagMappingList {
+				parsedArn, err := arn.Parse(aws.StringValue(resource.ResourceARN))
+				// load balancers are already tagged
+				if err != nil || parsedArn.Service == "elasticloadbalancing" {
+					continue
+				}
+				if namePattern.MatchString(getArnResourceName(parsedArn)) {
+					matchingArns = append(matchingArns, parsedArn.String())
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return fmt.Errorf("can't list tagged resources in %s: %s", region, err)
+	}
+
+	err = tagArnsTTL(ctx, taggingSession, matchingArns, ttl, tagName)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Set the %s tag of %d resources matching %s in %s.", tagName, len(matchingLoadBalancers)+len(matchingArns), namePattern, region)
+
+	return nil
+}
+
+// tagClusterResourcesTTL sets the ttl tag of an EKS cluster, of its load balancers and of its VPCs with their children,
+// the way pleco tags them when it deletes the cluster
+func tagClusterResourcesTTL(ctx context.Context, sess *session.Session, taggingSession resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, elbSession *elbv2.ELBV2, region string, clusterName string, ttl int64, tagName string, clusterTagKey string, clusterTags utils.ClusterTagPatterns) error {
+	cluster, err := eks.New(sess).DescribeClusterWithContext(ctx, &eks.DescribeClusterInput{Name: aws.String(clusterName)})
+	if err != nil {
+		return fmt.Errorf("can't get EKS cluster %s in %s: %s", clusterName, region, err)
+	}
+
+	err = tagArnsTTL(ctx, taggingSession, []string{aws.StringValue(cluster.Cluster.Arn)}, ttl, tagName)
+	if err != nil {
+		return err
+	}
+
+	lbMatcher, err := clusterTags.ForCluster(clusterName)
+	if err != nil {
+		return err
+	}
+	loadBalancers, err := ec22.ListTaggedLoadBalancersMatching(ctx, elbSession, region, lbMatcher)
+	if err != nil {
+		return err
+	}
+
+	err = ec22.TagLoadBalancersForDeletion(ctx, elbSession, region, tagName, ttl, loadBalancers, clusterName)
+	if err != nil {
+		return err
+	}
+
+	err = vpc.TagVPCsForDeletion(ctx, ec2.New(sess), region, database.RdsSession(*sess, region), clusterName, aws.TimeValue(cluster.Cluster.CreatedAt), ttl, tagName, clusterTagKey)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Set the %s tag of EKS cluster %s, its %d load balancers and its VPCs in %s.", tagName, clusterName, len(loadBalancers), region)
+
+	return nil
+}
+
+// ExtendResourcesTTL pushes back the expiration of the resources of the region by the extension (in seconds), by
+// rewriting their expiration date tag if they have one or their ttl tag otherwise
+func ExtendResourcesTTL(ctx context.Context, sess *session.Session, region string, arns []string, extension int64, tagName string) error {
+	taggingSession := resourcegroupstaggingapi.New(sess)
+
+	// only the resources carrying a ttl or an expiration date can be extended
+	taggedResources, err := tagging.GetTaggedResources(ctx, taggingSession, region, tagName)
+	if err != nil {
+		return fmt.Errorf("can't get the tags of the resources in %s: %s", region, err)
+	}
+
+	var errs []error
+	for _, resourceArn := range arns {
+		resource, _ := taggedResources.Get(resourceArn)
+		tags, err := utils.ExtendExpiration(aws.StringValueMap(resource.Tags), tagName, extension)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't extend %s: %s", resourceArn, err))
+			continue
+		}
+
+		result, err := taggingSession.TagResourcesWithContext(ctx,
+			&resourcegroupstaggingapi.TagResourcesInput{
+				ResourceARNList: aws.StringSlice([]string{resourceArn}),
+				Tags:            aws.StringMap(tags),
+			})
+		if err == nil {
+			for _, failure := range result.FailedResourcesMap {
+				err = fmt.Errorf("%s", aws.StringValue(failure.ErrorMessage))
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("can't extend %s: %s", resourceArn, err))
+			continue
+		}
+
+		for key, value := range tags {
+			logrus.Infof("Extended %s in %s: %s tag set to %s.", resourceArn, region, key, value)
+		}
+	}
+
+	return utils.JoinErrors(errs...)
+}
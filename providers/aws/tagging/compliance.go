@@ -0,0 +1,58 @@
+package tagging
+
+import (
+	"context"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// getResourceName returns the id of the resource of an ARN, without its type (ex: vol-0123 for a volume)
+func getResourceName(resourceArn string) string {
+	parsedArn, err := arn.Parse(resourceArn)
+	if err != nil {
+		return resourceArn
+	}
+
+	resource := parsedArn.Resource
+	if i := strings.IndexAny(resource, "/:"); i >= 0 {
+		resource = resource[i+1:]
+	}
+
+	return resource
+}
+
+// ReportTagViolations reports the resources of the region missing required tags, it never deletes nor tags them. The
+// Resource Groups Tagging API only returns the resources carrying, or having carried, tags: resources never tagged
+// aren't reported.
+func ReportTagViolations(ctx context.Context, svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, region string, requiredTags []string, tagName string) error {
+	resources := make(TaggedResources)
+	err := addTaggedResources(ctx, svc, resources, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	violations := 0
+	for _, resource := range resources {
+		missingTags := utils.GetMissingTags(resource.Tags, requiredTags, tagName)
+		if len(missingTags) == 0 {
+			continue
+		}
+
+		violations++
+		utils.ReportTagViolation(ctx, utils.TagViolation{
+			Type:        resource.Type,
+			Name:        getResourceName(resource.Arn),
+			Arn:         resource.Arn,
+			Region:      region,
+			MissingTags: missingTags,
+		})
+	}
+	utils.SetTagsChecked(ctx)
+
+	log.Debugf("%d of %d resources of region %s are missing required tags.", violations, len(resources), region)
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package tagging
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"strings"
+	"time"
+)
+
+// resourceArnFormats gives the service and resource prefix of the ARN of the resources identified by name or id only
+var resourceArnFormats = map[string]struct {
+	service string
+	prefix  string
+}{
+	resources.EBSVolume:                {"ec2", "volume/"},
+	resources.EC2KeyPair:               {"ec2", "key-pair/"},
+	resources.VPC:                      {"ec2", "vpc/"},
+	resources.Subnet:                   {"ec2", "subnet/"},
+	resources.SecurityGroup:            {"ec2", "security-group/"},
+	resources.InternetGateway:          {"ec2", "internet-gateway/"},
+	resources.RouteTable:               {"ec2", "route-table/"},
+	resources.VPNConnection:            {"ec2", "vpn-connection/"},
+	resources.CustomerGateway:          {"ec2", "customer-gateway/"},
+	resources.TransitGateway:           {"ec2", "transit-gateway/"},
+	resources.TransitGatewayAttachment: {"ec2", "transit-gateway-attachment/"},
+	resources.RDSDatabase:              {"rds", "db:"},
+	resources.DocumentDBCluster:        {"rds", "cluster:"},
+	resources.ElasticacheCluster:       {"elasticache", "cluster:"},
+	resources.EKSCluster:               {"eks", "cluster/"},
+	resources.KMSKey:                   {"kms", "key/"},
+	resources.LogGroup:                 {"logs", "log-group:"},
+}
+
+// DeletionScheduler tags expired resources of a region with their deletion date through the Resource Groups Tagging API
+type DeletionScheduler struct {
+	svc       resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	region    string
+	accountId string
+	partition string
+}
+
+func NewDeletionScheduler(svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, region string, accountId string) *DeletionScheduler {
+	partition := "aws"
+	if p, ok := endpoints.PartitionForRegion(endpoints.DefaultPartitions(), region); ok {
+		partition = p.ID()
+	}
+
+	return &DeletionScheduler{svc: svc, region: region, accountId: accountId, partition: partition}
+}
+
+// getResourceArn returns the ARN given in the identifiers or builds it from the last one (the resource id)
+func (s *DeletionScheduler) getResourceArn(resourceType string, identifiers []string) (string, error) {
+	for _, identifier := range identifiers {
+		if strings.HasPrefix(identifier, "arn:") {
+			return identifier, nil
+		}
+	}
+
+	if len(identifiers) == 0 {
+		return "", fmt.Errorf("no identifier for %s", resourceType)
+	}
+	id := identifiers[len(identifiers)-1]
+
+	if resourceType == resources.S3Bucket {
+		return fmt.Sprintf("arn:%s:s3:::%s", s.partition, id), nil
+	}
+
+	format, ok := resourceArnFormats[resourceType]
+	if !ok {
+		return "", fmt.Errorf("can't build the ARN of %s %s", resourceType, id)
+	}
+
+	return fmt.Sprintf("arn:%s:%s:%s:%s:%s%s", s.partition, format.service, s.region, s.accountId, format.prefix, id), nil
+}
+
+func (s *DeletionScheduler) ScheduleDeletion(ctx context.Context, resourceType string, identifiers []string, deletionDate time.Time) error {
+	resourceArn, err := s.getResourceArn(resourceType, identifiers)
+	if err != nil {
+		return err
+	}
+
+	result, err := s.svc.TagResourcesWithContext(ctx,
+		&resourcegroupstaggingapi.TagResourcesInput{
+			ResourceARNList: aws.StringSlice([]string{resourceArn}),
+			Tags: aws.StringMap(map[string]string{
+				utils.DeletionScheduledTagName: deletionDate.Format(time.RFC3339),
+			}),
+		})
+	if err != nil {
+		return err
+	}
+
+	for failedArn, failure := range result.FailedResourcesMap {
+		return fmt.Errorf("can't tag %s: %s", failedArn, aws.StringValue(failure.ErrorMessage))
+	}
+
+	return nil
+}
+
+// GetOwner looks the resource up among the ones of its type, the tagging API of this SDK can't get the tags of an ARN
+func (s *DeletionScheduler) GetOwner(ctx context.Context, resourceType string, identifiers []string) (string, error) {
+	resourceArn, err := s.getResourceArn(resourceType, identifiers)
+	if err != nil {
+		return "", err
+	}
+
+	parsedArn, err := arn.Parse(resourceArn)
+	if err != nil {
+		return "", err
+	}
+
+	// resource type filters are <service>[:<resource type>], ex: ec2:volume
+	typeFilter := parsedArn.Service
+	if end := strings.IndexAny(parsedArn.Resource, "/:"); end > 0 {
+		typeFilter += ":" + parsedArn.Resource[:end]
+	}
+
+	tags := make(map[string]string)
+	err = s.svc.GetResourcesPagesWithContext(ctx,
+		&resourcegroupstaggingapi.GetResourcesInput{
+			ResourceTypeFilters: aws.StringSlice([]string{typeFilter}),
+		},
+		func(page *resourcegroupstaggingapi.GetResourcesOutput, lastPage bool) bool {
+			for _, resource := range page.ResourceTagMappingList {
+				if aws.StringValue(resource.ResourceARN) != resourceArn {
+					continue
+				}
+
+				for _, tag := range resource.Tags {
+					tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+				}
+				return false
+			}
+			return true
+		})
+	if err != nil {
+		return "", err
+	}
+
+	return utils.GetOwnerFromTags(tags), nil
+}
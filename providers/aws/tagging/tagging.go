@@ -0,0 +1,177 @@
+package tagging
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"sync"
+)
+
+const (
+	ElasticLoadBalancer         = "elasticloadbalancing:loadbalancer"
+	ElasticacheCluster          = "elasticache:cluster"
+	GlueDatabase                = "glue:database"
+	GlueCrawler                 = "glue:crawler"
+	GlueJob                     = "glue:job"
+	BeanstalkEnvironment        = "elasticbeanstalk:environment"
+	BeanstalkApplicationVersion = "elasticbeanstalk:applicationversion"
+)
+
+// untaggedRuleTypes are the types of the untagged rules checked by the cleaners using the tagging API, with their
+// "service:resource" type
+var untaggedRuleTypes = map[string]string{
+	resources.LoadBalancer:                ElasticLoadBalancer,
+	resources.ElasticacheCluster:          ElasticacheCluster,
+	resources.GlueDatabase:                GlueDatabase,
+	resources.GlueCrawler:                 GlueCrawler,
+	resources.GlueJob:                     GlueJob,
+	resources.BeanstalkEnvironment:        BeanstalkEnvironment,
+	resources.BeanstalkApplicationVersion: BeanstalkApplicationVersion,
+}
+
+type TaggedResource struct {
+	Arn  string
+	Type string
+	Tags map[string]*string
+}
+
+// TaggedResources holds every resource of a region carrying the pleco tag, and every resource with tags of the types
+// with untagged rules, indexed by ARN
+type TaggedResources map[string]TaggedResource
+
+type sharedTaggedResourcesKey struct{}
+
+// SharedTaggedResources lists the tagged resources of a region once per check, with the first cleaner needing them
+type SharedTaggedResources struct {
+	once      sync.Once
+	svc       resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	region    string
+	tagName   string
+	resources TaggedResources
+	err       error
+}
+
+// NewSharedTaggedResources returns the tagged resources of the region shared by the cleaners of a check, it has to be
+// called once per check
+func NewSharedTaggedResources(svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, region string, tagName string) *SharedTaggedResources {
+	return &SharedTaggedResources{svc: svc, region: region, tagName: tagName}
+}
+
+// WithSharedTaggedResources returns a context whose cleaners share the tagged resources
+func WithSharedTaggedResources(ctx context.Context, shared *SharedTaggedResources) context.Context {
+	return context.WithValue(ctx, sharedTaggedResourcesKey{}, shared)
+}
+
+// GetSharedTaggedResources returns the tagged resources shared by the cleaners of the context, listing them if no
+// cleaner did yet, an error if the context has none
+func GetSharedTaggedResources(ctx context.Context) (TaggedResources, error) {
+	shared, hasShared := ctx.Value(sharedTaggedResourcesKey{}).(*SharedTaggedResources)
+	if !hasShared {
+		return nil, fmt.Errorf("no tagged resources shared with the cleaners")
+	}
+
+	shared.once.Do(func() {
+		log.Debugf("Listing all tagged resources in region %s.", shared.region)
+		shared.resources, shared.err = GetTaggedResources(ctx, shared.svc, shared.region, shared.tagName)
+	})
+
+	return shared.resources, shared.err
+}
+
+// getResourceType returns the "service:resource" type of an ARN, as used by the Resource Groups Tagging API filters
+func getResourceType(resourceArn string) string {
+	parsedArn, err := arn.Parse(resourceArn)
+	if err != nil {
+		return ""
+	}
+
+	resource := parsedArn.Resource
+	if i := strings.IndexAny(resource, "/:"); i >= 0 {
+		resource = resource[:i]
+	}
+
+	return parsedArn.Service + ":" + resource
+}
+
+// GetTaggedResources lists all the resources of the region carrying the tag or an expiration date, with a paginated
+// sweep per tag as the API only matches resources carrying every filtered tag. Resources of the types with untagged
+// rules are all listed, whatever their tags, so their other tags (ex: protection tags) apply.
+func GetTaggedResources(ctx context.Context, svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, region string, tagName string) (TaggedResources, error) {
+	taggedResources := make(TaggedResources)
+
+	for _, tagKey := range []string{tagName, utils.ExpirationDateTagName} {
+		err := addTaggedResources(ctx, svc, taggedResources, []*resourcegroupstaggingapi.TagFilter{{Key: aws.String(tagKey)}}, nil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var resourceTypes []*string
+	for resourceType, taggingType := range untaggedRuleTypes {
+		if utils.HasUntaggedRule(ctx, resourceType) {
+			resourceTypes = append(resourceTypes, aws.String(taggingType))
+		}
+	}
+	if len(resourceTypes) > 0 {
+		err := addTaggedResources(ctx, svc, taggedResources, nil, resourceTypes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Debugf("Found %d resources tagged with %s in region %s.", len(taggedResources), tagName, region)
+
+	return taggedResources, nil
+}
+
+// addTaggedResources adds the resources matching the tag filters and of the "service:resource" types, every resource
+// with tags without filter
+func addTaggedResources(ctx context.Context, svc resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI, taggedResources TaggedResources, tagFilters []*resourcegroupstaggingapi.TagFilter, resourceTypes []*string) error {
+	return svc.GetResourcesPagesWithContext(ctx,
+		&resourcegroupstaggingapi.GetResourcesInput{
+			ResourcesPerPage:    aws.Int64(100),
+			ResourceTypeFilters: resourceTypes,
+			TagFilters:          tagFilters,
+		},
+		func(page *resourcegroupstaggingapi.GetResourcesOutput, lastPage bool) bool {
+			for _, mapping := range page.ResourceTagMappingList {
+				tags := make(map[string]*string)
+				for _, tag := range mapping.Tags {
+					tags[*tag.Key] = tag.Value
+				}
+
+				resourceArn := aws.StringValue(mapping.ResourceARN)
+				taggedResources[resourceArn] = TaggedResource{
+					Arn:  resourceArn,
+					Type: getResourceType(resourceArn),
+					Tags: tags,
+				}
+			}
+			return true
+		})
+}
+
+// Get returns the tagged resource matching the ARN, ok is false if the resource doesn't carry the tag
+func (r TaggedResources) Get(resourceArn string) (TaggedResource, bool) {
+	resource, ok := r[resourceArn]
+	return resource, ok
+}
+
+// ByType returns the tagged resources of a "service:resource" type (ex: elasticloadbalancing:loadbalancer)
+func (r TaggedResources) ByType(resourceType string) []TaggedResource {
+	var resources []TaggedResource
+	for _, resource := range r {
+		if resource.Type == resourceType {
+			resources = append(resources, resource)
+		}
+	}
+
+	return resources
+}
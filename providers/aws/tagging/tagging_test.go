@@ -0,0 +1,179 @@
+package tagging
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi"
+	"github.com/aws/aws-sdk-go/service/resourcegroupstaggingapi/resourcegroupstaggingapiiface"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+// mockTaggingAPI returns the resources matching the tag and type filters of the requests
+type mockTaggingAPI struct {
+	resourcegroupstaggingapiiface.ResourceGroupsTaggingAPIAPI
+	mappings []*resourcegroupstaggingapi.ResourceTagMapping
+	requests int
+}
+
+func (m *mockTaggingAPI) GetResourcesPagesWithContext(ctx aws.Context, input *resourcegroupstaggingapi.GetResourcesInput, fn func(*resourcegroupstaggingapi.GetResourcesOutput, bool) bool, opts ...request.Option) error {
+	m.requests++
+
+	// one page per resource, to go through the pagination
+	var pages []*resourcegroupstaggingapi.GetResourcesOutput
+	for _, mapping := range m.mappings {
+		if matchesFilters(mapping, input) {
+			pages = append(pages, &resourcegroupstaggingapi.GetResourcesOutput{ResourceTagMappingList: []*resourcegroupstaggingapi.ResourceTagMapping{mapping}})
+		}
+	}
+
+	for i, page := range pages {
+		if !fn(page, i == len(pages)-1) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func matchesFilters(mapping *resourcegroupstaggingapi.ResourceTagMapping, input *resourcegroupstaggingapi.GetResourcesInput) bool {
+	if len(input.ResourceTypeFilters) > 0 {
+		resourceType := getResourceType(aws.StringValue(mapping.ResourceARN))
+		if !contains(aws.StringValueSlice(input.ResourceTypeFilters), resourceType) {
+			return false
+		}
+	}
+
+	for _, filter := range input.TagFilters {
+		hasTag := false
+		for _, tag := range mapping.Tags {
+			hasTag = hasTag || aws.StringValue(tag.Key) == aws.StringValue(filter.Key)
+		}
+		if !hasTag {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func newMapping(resourceArn string, tags map[string]string) *resourcegroupstaggingapi.ResourceTagMapping {
+	mapping := &resourcegroupstaggingapi.ResourceTagMapping{ResourceARN: aws.String(resourceArn)}
+	for key, value := range tags {
+		mapping.Tags = append(mapping.Tags, &resourcegroupstaggingapi.Tag{Key: aws.String(key), Value: aws.String(value)})
+	}
+
+	return mapping
+}
+
+func TestGetResourceType(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{arn: "arn:aws:elasticloadbalancing:eu-west-3:123456789012:loadbalancer/net/my-lb/50dc6c495c0c9188", want: ElasticLoadBalancer},
+		{arn: "arn:aws:elasticache:eu-west-3:123456789012:cluster:my-cluster", want: ElasticacheCluster},
+		{arn: "arn:aws:glue:eu-west-3:123456789012:database/my-database", want: GlueDatabase},
+		{arn: "arn:aws:elasticbeanstalk:eu-west-3:123456789012:environment/my-app/my-env", want: BeanstalkEnvironment},
+		{arn: "arn:aws:s3:::my-bucket", want: "s3:my-bucket"},
+		{arn: "not an arn", want: ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.arn, func(t *testing.T) {
+			if got := getResourceType(test.arn); got != test.want {
+				t.Errorf("getResourceType(%q) = %q, want %q", test.arn, got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetTaggedResources(t *testing.T) {
+	ttlLoadBalancer := "arn:aws:elasticloadbalancing:eu-west-3:123456789012:loadbalancer/app/ttl/1"
+	expiringLoadBalancer := "arn:aws:elasticloadbalancing:eu-west-3:123456789012:loadbalancer/app/expiring/2"
+	otherLoadBalancer := "arn:aws:elasticloadbalancing:eu-west-3:123456789012:loadbalancer/app/other/3"
+	otherCluster := "arn:aws:elasticache:eu-west-3:123456789012:cluster:other"
+
+	mappings := []*resourcegroupstaggingapi.ResourceTagMapping{
+		newMapping(ttlLoadBalancer, map[string]string{"ttl": "3600", "team": "a"}),
+		newMapping(expiringLoadBalancer, map[string]string{utils.ExpirationDateTagName: "2021-03-01"}),
+		newMapping(otherLoadBalancer, map[string]string{"do_not_delete": "true"}),
+		newMapping(otherCluster, map[string]string{"team": "b"}),
+	}
+
+	tests := []struct {
+		name         string
+		policy       utils.DeletionPolicy
+		wantArns     []string
+		wantRequests int
+	}{
+		{
+			name:         "tagged resources",
+			wantArns:     []string{expiringLoadBalancer, ttlLoadBalancer},
+			wantRequests: 2,
+		},
+		{
+			name: "resources of the types with untagged rules",
+			policy: utils.DeletionPolicy{UntaggedRules: []utils.UntaggedRule{
+				{ResourceType: resources.LoadBalancer, Name: regexp.MustCompile(".*"), MaxAge: 3600},
+			}},
+			wantArns:     []string{expiringLoadBalancer, otherLoadBalancer, ttlLoadBalancer},
+			wantRequests: 3,
+		},
+		{
+			name: "untagged rules of types not found through the tagging API",
+			policy: utils.DeletionPolicy{UntaggedRules: []utils.UntaggedRule{
+				{ResourceType: resources.S3Bucket, Name: regexp.MustCompile(".*"), MaxAge: 3600},
+			}},
+			wantArns:     []string{expiringLoadBalancer, ttlLoadBalancer},
+			wantRequests: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := &mockTaggingAPI{mappings: mappings}
+			ctx := utils.WithDeletionPolicy(context.Background(), test.policy)
+
+			taggedResources, err := GetTaggedResources(ctx, svc, "eu-west-3", "ttl")
+			if err != nil {
+				t.Fatalf("GetTaggedResources failed: %s", err)
+			}
+
+			var arns []string
+			for resourceArn := range taggedResources {
+				arns = append(arns, resourceArn)
+			}
+			sort.Strings(arns)
+			if !reflect.DeepEqual(arns, test.wantArns) {
+				t.Errorf("GetTaggedResources returned %v, want %v", arns, test.wantArns)
+			}
+			if svc.requests != test.wantRequests {
+				t.Errorf("GetTaggedResources sent %d requests, want %d", svc.requests, test.wantRequests)
+			}
+
+			resource, isTagged := taggedResources.Get(ttlLoadBalancer)
+			if !isTagged || resource.Type != ElasticLoadBalancer || aws.StringValue(resource.Tags["team"]) != "a" {
+				t.Errorf("Get(%q) = %+v, %t", ttlLoadBalancer, resource, isTagged)
+			}
+			if got := len(taggedResources.ByType(ElasticacheCluster)); got != 0 {
+				t.Errorf("ByType(%q) returned %d resources, want 0", ElasticacheCluster, got)
+			}
+		})
+	}
+}
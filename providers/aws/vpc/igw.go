@@ -1,22 +1,16 @@
 package vpc
 
 import (
+	"context"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	log "github.com/sirupsen/logrus"
-	"sync"
 	"time"
 )
 
-type InternetGateway struct {
-	Id           string
-	CreationDate time.Time
-	ttl          int64
-	IsProtected  bool
-}
-
-func getInternetGatewaysByVpcId (ec2Session ec2.EC2, vpcId string) []*ec2.InternetGateway{
+func getInternetGatewaysByVpcId (ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) []*ec2.InternetGateway{
 	input := &ec2.DescribeInternetGatewaysInput{
 		Filters: []*ec2.Filter{
 			{
@@ -26,15 +20,20 @@ func getInternetGatewaysByVpcId (ec2Session ec2.EC2, vpcId string) []*ec2.Intern
 		},
 	}
 
-	gateways, err := ec2Session.DescribeInternetGateways(input)
+	var gateways []*ec2.InternetGateway
+	err := ec2Session.DescribeInternetGatewaysPagesWithContext(ctx, input,
+		func(page *ec2.DescribeInternetGatewaysOutput, lastPage bool) bool {
+			gateways = append(gateways, page.InternetGateways...)
+			return true
+		})
 	if err != nil {
 		log.Error(err)
 	}
 
-	return gateways.InternetGateways
+	return gateways
 }
 
-func getInternetGatewaysByVpcsIds (ec2Session ec2.EC2, vpcsIds []*string) []*ec2.InternetGateway{
+func getInternetGatewaysByVpcsIds (ctx context.Context, ec2Session ec2iface.EC2API, vpcsIds []*string) []*ec2.InternetGateway{
 	input := &ec2.DescribeInternetGatewaysInput{
 		Filters:  []*ec2.Filter{
 			{
@@ -44,59 +43,26 @@ func getInternetGatewaysByVpcsIds (ec2Session ec2.EC2, vpcsIds []*string) []*ec2
 		},
 	}
 
-	result , err := ec2Session.DescribeInternetGateways(input)
+	var gateways []*ec2.InternetGateway
+	err := ec2Session.DescribeInternetGatewaysPagesWithContext(ctx, input,
+		func(page *ec2.DescribeInternetGatewaysOutput, lastPage bool) bool {
+			gateways = append(gateways, page.InternetGateways...)
+			return true
+		})
 	if err != nil {
 		log.Error(err)
 	}
 
-	return result.InternetGateways
-}
-
-func SetInternetGatewaysIdsByVpcId (ec2Session ec2.EC2, vpc *VpcInfo, waitGroup *sync.WaitGroup, tagName string) {
-	defer waitGroup.Done()
-	var internetGateways []InternetGateway
-
-	gateways := getInternetGatewaysByVpcId(ec2Session, *vpc.VpcId)
-
-	for _, gateway := range gateways {
-		creationDate, ttl, isProtected, _, _ := utils.GetEssentialTags(gateway.Tags,tagName)
-
-		var gatewayStruct = InternetGateway{
-			Id: *gateway.InternetGatewayId,
-			CreationDate: creationDate,
-			ttl: ttl,
-			IsProtected: isProtected,
-		}
-
-		internetGateways = append(internetGateways, gatewayStruct)
-	}
-
-	vpc.InternetGateways= internetGateways
-}
-
-func DeleteInternetGatewaysByIds (ec2Session ec2.EC2, internetGateways []InternetGateway) {
-	for _, internetGateway := range internetGateways {
-		if utils.CheckIfExpired(internetGateway.CreationDate, internetGateway.ttl) && !internetGateway.IsProtected {
-			_, err := ec2Session.DeleteInternetGateway(
-				&ec2.DeleteInternetGatewayInput{
-					InternetGatewayId: aws.String(internetGateway.Id),
-				},
-			)
-
-			if err != nil {
-				log.Error(err)
-			}
-		}
-	}
+	return gateways
 }
 
-func AddCreationDateTagToIGW (ec2Session ec2.EC2, vpcsId []*string, creationDate time.Time, ttl int64) error {
-	gateways := getInternetGatewaysByVpcsIds(ec2Session, vpcsId)
+func AddCreationDateTagToIGW (ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcsId []*string, creationDate time.Time, ttl int64, tagName string) error {
+	gateways := getInternetGatewaysByVpcsIds(ctx, ec2Session, vpcsId)
 	var gatewaysIds []*string
 
 	for _, gateway := range gateways {
 		gatewaysIds = append(gatewaysIds, gateway.InternetGatewayId)
 	}
 
-	return utils.AddCreationDateTag(ec2Session, gatewaysIds, creationDate, ttl)
+	return utils.AddCreationDateTag(ctx, ec2Session, region, gatewaysIds, creationDate, ttl, tagName)
 }
\ No newline at end of file
@@ -1,128 +1,166 @@
 package vpc
 
 import (
+	"context"
+	"fmt"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	log "github.com/sirupsen/logrus"
-	"sync"
 	"time"
 )
 
-type SecurityGroup struct {
-	Id           string
-	CreationDate time.Time
-	ttl          int64
-	IsProtected  bool
+func getSecurityGroupsByVpcId (ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) []*ec2.SecurityGroup {
+	input := &ec2.DescribeSecurityGroupsInput{
+		Filters:  []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcId)},
+			},
+		},
+	}
+
+	var securityGroups []*ec2.SecurityGroup
+	err := ec2Session.DescribeSecurityGroupsPagesWithContext(ctx, input,
+		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+			securityGroups = append(securityGroups, page.SecurityGroups...)
+			return true
+		})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return securityGroups
 }
 
-func getSecurityGroupsByVpcId (ec2Session ec2.EC2, vpcId string) []*ec2.SecurityGroup {
+func getSecurityGroupsByVpcsIds (ctx context.Context, ec2Session ec2iface.EC2API, vpcsIds []*string) []*ec2.SecurityGroup{
 	input := &ec2.DescribeSecurityGroupsInput{
 		Filters:  []*ec2.Filter{
 			{
 				Name:   aws.String("vpc-id"),
-				Values: []*string{aws.String(vpcId)},
+				Values: vpcsIds,
 			},
 		},
 	}
 
-	result , err := ec2Session.DescribeSecurityGroups(input)
+	var securityGroups []*ec2.SecurityGroup
+	err := ec2Session.DescribeSecurityGroupsPagesWithContext(ctx, input,
+		func(page *ec2.DescribeSecurityGroupsOutput, lastPage bool) bool {
+			securityGroups = append(securityGroups, page.SecurityGroups...)
+			return true
+		})
 	if err != nil {
 		log.Error(err)
 	}
 
-	return result.SecurityGroups
+	return securityGroups
 }
 
-func getSecurityGroupsByVpcsIds (ec2Session ec2.EC2, vpcsIds []*string) []*ec2.SecurityGroup{
-		result , err := ec2Session.DescribeSecurityGroups(
-			&ec2.DescribeSecurityGroupsInput{
-				Filters:  []*ec2.Filter{
-					{
-						Name:   aws.String("vpc-id"),
-						Values: vpcsIds,
-					},
-				},
+// revokeSecurityGroupRules revokes the ingress and egress rules of a security group, so the groups it references can be
+// deleted. With groupIds, only the rules referencing these groups are revoked.
+func revokeSecurityGroupRules(ctx context.Context, ec2Session ec2iface.EC2API, securityGroup *ec2.SecurityGroup, groupIds map[string]bool) error {
+	groupId := aws.StringValue(securityGroup.GroupId)
+
+	ingress := filterRules(securityGroup.IpPermissions, groupIds)
+	if len(ingress) > 0 {
+		_, err := ec2Session.RevokeSecurityGroupIngressWithContext(ctx,
+			&ec2.RevokeSecurityGroupIngressInput{
+				GroupId:       aws.String(groupId),
+				IpPermissions: ingress,
 			})
-		if err != nil {
-			log.Error(err)
+		if err != nil && !hasErrorCode(err, "InvalidPermission.NotFound") {
+			return fmt.Errorf("can't revoke the ingress rules of security group %s: %s", groupId, err)
 		}
+	}
 
-	return result.SecurityGroups
-}
-
-func SetSecurityGroupsIdsByVpcId (ec2Session ec2.EC2, vpc *VpcInfo, waitGroup *sync.WaitGroup, tagName string) {
-	defer waitGroup.Done()
-	var securityGroupsStruct []SecurityGroup
+	egress := filterRules(securityGroup.IpPermissionsEgress, groupIds)
+	if len(egress) > 0 {
+		_, err := ec2Session.RevokeSecurityGroupEgressWithContext(ctx,
+			&ec2.RevokeSecurityGroupEgressInput{
+				GroupId:       aws.String(groupId),
+				IpPermissions: egress,
+			})
+		if err != nil && !hasErrorCode(err, "InvalidPermission.NotFound") {
+			return fmt.Errorf("can't revoke the egress rules of security group %s: %s", groupId, err)
+		}
+	}
 
-	securityGroups := getSecurityGroupsByVpcId(ec2Session, *vpc.VpcId)
+	return nil
+}
 
-	for _, securityGroup := range securityGroups {
-		if *securityGroup.GroupName != "default" {
-			creationDate, ttl, isProtected, _, _ := utils.GetEssentialTags(securityGroup.Tags, tagName)
-
-			var securityGroupStruct = SecurityGroup{
-				Id: *securityGroup.GroupId,
-				CreationDate: creationDate,
-				ttl: ttl,
-				IsProtected: isProtected,
+// filterRules returns the rules to revoke: all of them without groupIds, or only their references to these groups.
+// VPC security groups are referenced by id, names are dropped so the revocation matches the rules.
+func filterRules(permissions []*ec2.IpPermission, groupIds map[string]bool) []*ec2.IpPermission {
+	var rules []*ec2.IpPermission
+	for _, permission := range permissions {
+		rule := &ec2.IpPermission{
+			FromPort:   permission.FromPort,
+			ToPort:     permission.ToPort,
+			IpProtocol: permission.IpProtocol,
+		}
+		for _, pair := range permission.UserIdGroupPairs {
+			if groupIds == nil || groupIds[aws.StringValue(pair.GroupId)] {
+				rule.UserIdGroupPairs = append(rule.UserIdGroupPairs, &ec2.UserIdGroupPair{GroupId: pair.GroupId, UserId: pair.UserId})
 			}
+		}
+		if groupIds == nil {
+			rule.IpRanges = permission.IpRanges
+			rule.Ipv6Ranges = permission.Ipv6Ranges
+			rule.PrefixListIds = permission.PrefixListIds
+		}
 
-			securityGroupsStruct = append(securityGroupsStruct, securityGroupStruct)
+		if len(rule.UserIdGroupPairs) > 0 || len(rule.IpRanges) > 0 || len(rule.Ipv6Ranges) > 0 || len(rule.PrefixListIds) > 0 {
+			rules = append(rules, rule)
 		}
 	}
 
-
-	vpc.SecurityGroups = securityGroupsStruct
+	return rules
 }
 
-func DeleteSecurityGroupsByIds (ec2Session ec2.EC2, securityGroups []SecurityGroup) {
-	for _, securityGroup := range securityGroups {
-		if utils.CheckIfExpired(securityGroup.CreationDate, securityGroup.ttl) && !securityGroup.IsProtected{
-			deleteIpPermissions(ec2Session, securityGroup.Id)
+// detachSecurityGroups removes the groups from the network interfaces of the VPC using them, interfaces left without
+// group get the default one. Interfaces managed by AWS services (ex: load balancers) can't be modified and are skipped,
+// their owner releases them.
+func detachSecurityGroups(ctx context.Context, ec2Session ec2iface.EC2API, vpcId string, defaultGroupId string, groupIds map[string]bool) []string {
+	var errs []string
 
-			_, err := ec2Session.DeleteSecurityGroup(
-				&ec2.DeleteSecurityGroupInput{
-					GroupId: aws.String(securityGroup.Id),
-				},
-			)
+	for _, networkInterface := range getNetworkInterfacesByVpcId(ctx, ec2Session, vpcId) {
+		if aws.BoolValue(networkInterface.RequesterManaged) {
+			continue
+		}
 
-			if err != nil {
-				log.Error(err)
+		var keptGroups []*string
+		for _, group := range networkInterface.Groups {
+			if !groupIds[aws.StringValue(group.GroupId)] {
+				keptGroups = append(keptGroups, group.GroupId)
 			}
 		}
-	}
-}
-
-func deleteIpPermissions (ec2Session ec2.EC2, securityGroupId string) {
-	_, ingressErr := ec2Session.RevokeSecurityGroupIngress(
-		&ec2.RevokeSecurityGroupIngressInput{
-			GroupId: aws.String(securityGroupId),
-			IpProtocol: aws.String("-1"),
-		})
-
-	if ingressErr != nil {
-		log.Warn("Ingress Perms : " + ingressErr.Error())
-	}
-
-	_, egressErr := ec2Session.RevokeSecurityGroupEgress(
-		&ec2.RevokeSecurityGroupEgressInput{
-			GroupId: aws.String(securityGroupId),
-			IpPermissions: []*ec2.IpPermission{
-				{
-					IpProtocol: aws.String("-1"),
-				},
-			},
-		})
+		if len(keptGroups) == len(networkInterface.Groups) {
+			continue
+		}
+		if len(keptGroups) == 0 {
+			if defaultGroupId == "" {
+				continue
+			}
+			keptGroups = []*string{aws.String(defaultGroupId)}
+		}
 
-	if egressErr != nil {
-		log.Warn("Egress Perms : " + egressErr.Error())
+		id := aws.StringValue(networkInterface.NetworkInterfaceId)
+		_, err := ec2Session.ModifyNetworkInterfaceAttributeWithContext(ctx,
+			&ec2.ModifyNetworkInterfaceAttributeInput{
+				NetworkInterfaceId: aws.String(id),
+				Groups:             keptGroups,
+			})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't detach the security groups of network interface %s: %s", id, err))
+		}
 	}
 
+	return errs
 }
 
-func AddCreationDateTagToSG (ec2Session ec2.EC2, vpcsId []*string, creationDate time.Time, ttl int64) error {
-	securityGroups := getSecurityGroupsByVpcsIds(ec2Session, vpcsId)
+func AddCreationDateTagToSG (ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcsId []*string, creationDate time.Time, ttl int64, tagName string) error {
+	securityGroups := getSecurityGroupsByVpcsIds(ctx, ec2Session, vpcsId)
 	var securityGroupsIds []*string
 
 	for _, securityGroup := range securityGroups {
@@ -130,5 +168,5 @@ func AddCreationDateTagToSG (ec2Session ec2.EC2, vpcsId []*string, creationDate
 	}
 
 
-	return utils.AddCreationDateTag(ec2Session, securityGroupsIds, creationDate, ttl)
+	return utils.AddCreationDateTag(ctx, ec2Session, region, securityGroupsIds, creationDate, ttl, tagName)
 }
\ No newline at end of file
@@ -1,22 +1,16 @@
 package vpc
 
 import (
+	"context"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	log "github.com/sirupsen/logrus"
-	"sync"
 	"time"
 )
 
-type Subnet struct {
-	Id           string
-	CreationDate time.Time
-	ttl          int64
-	IsProtected  bool
-}
-
-func getSubnetsByVpcId (ec2Session ec2.EC2, vpcId string) []*ec2.Subnet {
+func getSubnetsByVpcId (ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) []*ec2.Subnet {
 	input := &ec2.DescribeSubnetsInput{
 		Filters: []*ec2.Filter{
 			{
@@ -26,15 +20,20 @@ func getSubnetsByVpcId (ec2Session ec2.EC2, vpcId string) []*ec2.Subnet {
 		},
 	}
 
-	subnets, err := ec2Session.DescribeSubnets(input)
+	var subnets []*ec2.Subnet
+	err := ec2Session.DescribeSubnetsPagesWithContext(ctx, input,
+		func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+			subnets = append(subnets, page.Subnets...)
+			return true
+		})
 	if err != nil {
 		log.Error(err)
 	}
 
-	return subnets.Subnets
+	return subnets
 }
 
-func getSubnetsByVpcsIds (ec2Session ec2.EC2, vpcsIds []*string) []*ec2.Subnet {
+func getSubnetsByVpcsIds (ctx context.Context, ec2Session ec2iface.EC2API, vpcsIds []*string) []*ec2.Subnet {
 	input := &ec2.DescribeSubnetsInput{
 		Filters:  []*ec2.Filter{
 			{
@@ -44,58 +43,26 @@ func getSubnetsByVpcsIds (ec2Session ec2.EC2, vpcsIds []*string) []*ec2.Subnet {
 		},
 	}
 
-	result , err := ec2Session.DescribeSubnets(input)
+	var subnets []*ec2.Subnet
+	err := ec2Session.DescribeSubnetsPagesWithContext(ctx, input,
+		func(page *ec2.DescribeSubnetsOutput, lastPage bool) bool {
+			subnets = append(subnets, page.Subnets...)
+			return true
+		})
 	if err != nil {
 		log.Error(err)
 	}
 
-	return result.Subnets
-}
-
-func SetSubnetsIdsByVpcId (ec2Session ec2.EC2, vpc *VpcInfo, waitGroup *sync.WaitGroup, tagName string) {
-	defer waitGroup.Done()
-	var subnetsStruct []Subnet
-
-	subnets := getSubnetsByVpcId(ec2Session, *vpc.VpcId)
-
-	for _, subnet := range subnets {
-		creationDate, ttl, isProtected, _, _ := utils.GetEssentialTags(subnet.Tags, tagName)
-
-		var subnetStruct = Subnet{
-			Id: *subnet.SubnetId,
-			CreationDate: creationDate,
-			ttl: ttl,
-			IsProtected: isProtected,
-		}
-		subnetsStruct = append(subnetsStruct, subnetStruct)
-	}
-
-	vpc.Subnets = subnetsStruct
-}
-
-func DeleteSubnetsByIds (ec2Session ec2.EC2, subnets []Subnet) {
-	for _, subnet := range subnets {
-		if utils.CheckIfExpired(subnet.CreationDate, subnet.ttl) && subnet.IsProtected {
-			_, err := ec2Session.DeleteSubnet(
-				&ec2.DeleteSubnetInput{
-					SubnetId: aws.String(subnet.Id),
-				},
-			)
-
-			if err != nil {
-				log.Error(err)
-			}
-		}
-	}
+	return subnets
 }
 
-func AddCreationDateTagToSubnets (ec2Session ec2.EC2, vpcsIds []*string, creationDate time.Time, ttl int64) error {
-	subnets := getSubnetsByVpcsIds(ec2Session, vpcsIds)
+func AddCreationDateTagToSubnets (ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcsIds []*string, creationDate time.Time, ttl int64, tagName string) error {
+	subnets := getSubnetsByVpcsIds(ctx, ec2Session, vpcsIds)
 	var subnetsIds []*string
 
 	for _, subnet := range subnets {
 		subnetsIds = append(subnetsIds, subnet.SubnetId)
 	}
 
-	return utils.AddCreationDateTag(ec2Session, subnetsIds, creationDate,ttl)
+	return utils.AddCreationDateTag(ctx, ec2Session, region, subnetsIds, creationDate,ttl, tagName)
 }
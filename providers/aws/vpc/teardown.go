@@ -0,0 +1,538 @@
+package vpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// maxTeardownAttempts is the number of passes over the dependencies of a VPC before its deletion is reported as
+// failed, the next check starts over
+const maxTeardownAttempts = 5
+
+// teardownRetryDelay is the time between two passes, asynchronous deletions (ex: NAT gateways) release their network
+// interfaces in the meantime
+const teardownRetryDelay = 15 * time.Second
+
+// natGatewayWaitInterval is the time between two checks of the NAT gateways being deleted, when waiting for them
+const natGatewayWaitInterval = 15 * time.Second
+
+// dependencyKeptError is returned when a dependency of the VPC is kept by the deletion policy, retrying won't help
+type dependencyKeptError struct {
+	dependency string
+}
+
+func (e dependencyKeptError) Error() string {
+	return e.dependency + " is kept by the deletion policy"
+}
+
+// teardownStep deletes one kind of dependency of a VPC, it returns the deletions which failed
+type teardownStep func(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error
+
+// teardownSteps are in dependency order, each kind of dependency can only be deleted once the previous ones are gone
+var teardownSteps = []teardownStep{
+	deleteVpcNetworkInterfaces,
+	deleteVpcNatGateways,
+	deleteVpcEndpoints,
+	deleteVpcSecurityGroups,
+	deleteVpcInternetGateways,
+	deleteVpcSubnets,
+	deleteVpcRouteTables,
+}
+
+// teardownVPC checks every dependency of a VPC against the deletion policy, then deletes them in dependency order and
+// the VPC. Nothing is deleted if a dependency is kept. The remaining dependencies are queried again on every attempt
+// until the VPC is gone or the attempts are exhausted.
+func teardownVPC(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	err := checkVpcDependencies(ctx, ec2Session, region, vpcId, tagName)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+
+	for attempt := 1; attempt <= maxTeardownAttempts; attempt++ {
+		if attempt > 1 && !utils.WaitUntil(ctx, time.Now().Add(teardownRetryDelay)) {
+			return fmt.Errorf("interrupted after %d attempts: %s", attempt-1, lastErr)
+		}
+
+		var errs []string
+		err := DeleteTransitGatewayAttachmentsByVpcId(ctx, ec2Session, region, vpcId)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+
+		for _, step := range teardownSteps {
+			err := step(ctx, ec2Session, region, vpcId, tagName)
+			if _, isKept := err.(dependencyKeptError); isKept {
+				return err
+			}
+			if err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
+		_, err = ec2Session.DeleteVpcWithContext(ctx,
+			&ec2.DeleteVpcInput{
+				VpcId: aws.String(vpcId),
+			},
+		)
+		if err == nil || hasErrorCode(err, "InvalidVpcID.NotFound") {
+			return nil
+		}
+
+		lastErr = joinErrors(append(errs, err.Error()))
+		log.Debugf("Can't delete VPC %s in %s yet (attempt %d/%d): %s", vpcId, region, attempt, maxTeardownAttempts, lastErr)
+	}
+
+	return fmt.Errorf("still has dependencies after %d attempts: %s", maxTeardownAttempts, lastErr)
+}
+
+func joinErrors(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(errs, "; "))
+}
+
+func hasErrorCode(err error, code string) bool {
+	aerr, ok := err.(awserr.Error)
+	return ok && aerr.Code() == code
+}
+
+// checkDependency returns an error if the deletion policy keeps a dependency of the VPC
+func checkDependency(ctx context.Context, tags interface{}, tagName string, vpcId string, resourceType string, region string, id string) error {
+	_, _, isProtected, _, _, _, _ := utils.GetEssentialTags(ctx, tags, tagName)
+	if !utils.CheckIfDependencyDeletable(ctx, isProtected, "expired VPC "+vpcId, resourceType, region, id) {
+		return dependencyKeptError{dependency: resourceType + " " + id}
+	}
+
+	return nil
+}
+
+// checkVpcDependencies returns an error if the deletion policy keeps any dependency of the VPC, so the teardown
+// doesn't leave it half deleted. Network interfaces in use are checked too, they are released by the deletion of the
+// other dependencies.
+func checkVpcDependencies(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	attachments, err := getVpcTransitGatewayAttachmentsByVpcId(ctx, ec2Session, vpcId)
+	if err != nil {
+		return fmt.Errorf("can't list transit gateway attachments: %s", err)
+	}
+	for _, attachment := range attachments {
+		if isTransitGatewayAttachmentGone(aws.StringValue(attachment.State)) {
+			continue
+		}
+		err := checkDependency(ctx, attachment.Tags, tagName, vpcId, resources.TransitGatewayAttachment, region, *attachment.TransitGatewayAttachmentId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, networkInterface := range getNetworkInterfacesByVpcId(ctx, ec2Session, vpcId) {
+		err := checkDependency(ctx, networkInterface.TagSet, tagName, vpcId, resources.NetworkInterface, region, *networkInterface.NetworkInterfaceId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, gateway := range getNatGatewaysByVpcId(ctx, ec2Session, vpcId) {
+		switch *gateway.State {
+		case ec2.NatGatewayStateDeleting, ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed:
+			continue
+		}
+		err := checkDependency(ctx, gateway.Tags, tagName, vpcId, resources.NATGateway, region, *gateway.NatGatewayId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, endpoint := range getVpcEndpointsByVpcId(ctx, ec2Session, vpcId) {
+		switch *endpoint.State {
+		case ec2.StateDeleting, ec2.StateDeleted:
+			continue
+		}
+		err := checkDependency(ctx, endpoint.Tags, tagName, vpcId, resources.VPCEndpoint, region, *endpoint.VpcEndpointId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, securityGroup := range getSecurityGroupsByVpcId(ctx, ec2Session, vpcId) {
+		if *securityGroup.GroupName == "default" {
+			continue
+		}
+		err := checkDependency(ctx, securityGroup.Tags, tagName, vpcId, resources.SecurityGroup, region, *securityGroup.GroupId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, gateway := range getInternetGatewaysByVpcId(ctx, ec2Session, vpcId) {
+		err := checkDependency(ctx, gateway.Tags, tagName, vpcId, resources.InternetGateway, region, *gateway.InternetGatewayId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, subnet := range getSubnetsByVpcId(ctx, ec2Session, vpcId) {
+		err := checkDependency(ctx, subnet.Tags, tagName, vpcId, resources.Subnet, region, *subnet.SubnetId)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, routeTable := range getRouteTablesByVpcId(ctx, ec2Session, vpcId) {
+		if isMainRouteTable(routeTable) {
+			continue
+		}
+		err := checkDependency(ctx, routeTable.Tags, tagName, vpcId, resources.RouteTable, region, *routeTable.RouteTableId)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getNetworkInterfacesByVpcId(ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) []*ec2.NetworkInterface {
+	input := &ec2.DescribeNetworkInterfacesInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcId)},
+			},
+		},
+	}
+
+	var interfaces []*ec2.NetworkInterface
+	err := ec2Session.DescribeNetworkInterfacesPagesWithContext(ctx, input,
+		func(page *ec2.DescribeNetworkInterfacesOutput, lastPage bool) bool {
+			interfaces = append(interfaces, page.NetworkInterfaces...)
+			return true
+		})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return interfaces
+}
+
+// deleteVpcNetworkInterfaces deletes the detached network interfaces, the attached ones are released by their owner
+// (ex: a NAT gateway or a load balancer being deleted)
+func deleteVpcNetworkInterfaces(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var errs []string
+
+	for _, networkInterface := range getNetworkInterfacesByVpcId(ctx, ec2Session, vpcId) {
+		id := *networkInterface.NetworkInterfaceId
+		if *networkInterface.Status != ec2.NetworkInterfaceStatusAvailable {
+			errs = append(errs, fmt.Sprintf("network interface %s is %s (%s)", id, *networkInterface.Status, aws.StringValue(networkInterface.Description)))
+			continue
+		}
+
+		err := checkDependency(ctx, networkInterface.TagSet, tagName, vpcId, resources.NetworkInterface, region, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = ec2Session.DeleteNetworkInterfaceWithContext(ctx,
+			&ec2.DeleteNetworkInterfaceInput{
+				NetworkInterfaceId: aws.String(id),
+			},
+		)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete network interface %s: %s", id, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+func getNatGatewaysByVpcId(ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) []*ec2.NatGateway {
+	input := &ec2.DescribeNatGatewaysInput{
+		Filter: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcId)},
+			},
+		},
+	}
+
+	var gateways []*ec2.NatGateway
+	err := ec2Session.DescribeNatGatewaysPagesWithContext(ctx, input,
+		func(page *ec2.DescribeNatGatewaysOutput, lastPage bool) bool {
+			gateways = append(gateways, page.NatGateways...)
+			return true
+		})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return gateways
+}
+
+func deleteVpcNatGateways(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var errs []string
+
+	for _, gateway := range getNatGatewaysByVpcId(ctx, ec2Session, vpcId) {
+		switch *gateway.State {
+		case ec2.NatGatewayStateDeleting, ec2.NatGatewayStateDeleted, ec2.NatGatewayStateFailed:
+			continue
+		}
+
+		id := *gateway.NatGatewayId
+		err := checkDependency(ctx, gateway.Tags, tagName, vpcId, resources.NATGateway, region, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = ec2Session.DeleteNatGatewayWithContext(ctx,
+			&ec2.DeleteNatGatewayInput{
+				NatGatewayId: aws.String(id),
+			},
+		)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete NAT gateway %s: %s", id, err))
+		}
+	}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
+
+	// NAT gateways release their network interfaces and elastic IPs once deleted, the next steps wait for it
+	return utils.WaitFor(ctx, "the deletion of the NAT gateways of VPC "+vpcId, natGatewayWaitInterval, func(ctx context.Context) (bool, error) {
+		for _, gateway := range getNatGatewaysByVpcId(ctx, ec2Session, vpcId) {
+			if aws.StringValue(gateway.State) == ec2.NatGatewayStateDeleting {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+}
+
+func getVpcEndpointsByVpcId(ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) []*ec2.VpcEndpoint {
+	input := &ec2.DescribeVpcEndpointsInput{
+		Filters: []*ec2.Filter{
+			{
+				Name:   aws.String("vpc-id"),
+				Values: []*string{aws.String(vpcId)},
+			},
+		},
+	}
+
+	var endpoints []*ec2.VpcEndpoint
+	err := ec2Session.DescribeVpcEndpointsPagesWithContext(ctx, input,
+		func(page *ec2.DescribeVpcEndpointsOutput, lastPage bool) bool {
+			endpoints = append(endpoints, page.VpcEndpoints...)
+			return true
+		})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return endpoints
+}
+
+func deleteVpcEndpoints(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var endpointsIds []*string
+
+	for _, endpoint := range getVpcEndpointsByVpcId(ctx, ec2Session, vpcId) {
+		switch *endpoint.State {
+		case ec2.StateDeleting, ec2.StateDeleted:
+			continue
+		}
+
+		err := checkDependency(ctx, endpoint.Tags, tagName, vpcId, resources.VPCEndpoint, region, *endpoint.VpcEndpointId)
+		if err != nil {
+			return err
+		}
+
+		endpointsIds = append(endpointsIds, endpoint.VpcEndpointId)
+	}
+
+	if len(endpointsIds) == 0 {
+		return nil
+	}
+
+	result, err := ec2Session.DeleteVpcEndpointsWithContext(ctx,
+		&ec2.DeleteVpcEndpointsInput{
+			VpcEndpointIds: endpointsIds,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("can't delete VPC endpoints: %s", err)
+	}
+
+	var errs []string
+	for _, item := range result.Unsuccessful {
+		errs = append(errs, fmt.Sprintf("can't delete VPC endpoint %s: %s", aws.StringValue(item.ResourceId), aws.StringValue(item.Error.Message)))
+	}
+
+	return joinErrors(errs)
+}
+
+// deleteVpcSecurityGroups revokes the rules of every security group, and the rules of the default group referencing
+// them, then detaches them from the network interfaces before deleting them, so the groups referencing each other or
+// still in use by an instance can be deleted in one pass
+func deleteVpcSecurityGroups(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var securityGroups []*ec2.SecurityGroup
+	var defaultGroup *ec2.SecurityGroup
+	groupIds := make(map[string]bool)
+
+	for _, securityGroup := range getSecurityGroupsByVpcId(ctx, ec2Session, vpcId) {
+		if *securityGroup.GroupName == "default" {
+			defaultGroup = securityGroup
+			continue
+		}
+
+		err := checkDependency(ctx, securityGroup.Tags, tagName, vpcId, resources.SecurityGroup, region, *securityGroup.GroupId)
+		if err != nil {
+			return err
+		}
+
+		securityGroups = append(securityGroups, securityGroup)
+		groupIds[*securityGroup.GroupId] = true
+	}
+
+	if len(securityGroups) == 0 {
+		return nil
+	}
+
+	var errs []string
+	for _, securityGroup := range securityGroups {
+		err := revokeSecurityGroupRules(ctx, ec2Session, securityGroup, nil)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	defaultGroupId := ""
+	if defaultGroup != nil {
+		defaultGroupId = *defaultGroup.GroupId
+		err := revokeSecurityGroupRules(ctx, ec2Session, defaultGroup, groupIds)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	errs = append(errs, detachSecurityGroups(ctx, ec2Session, vpcId, defaultGroupId, groupIds)...)
+
+	for _, securityGroup := range securityGroups {
+		_, err := ec2Session.DeleteSecurityGroupWithContext(ctx,
+			&ec2.DeleteSecurityGroupInput{
+				GroupId: securityGroup.GroupId,
+			},
+		)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete security group %s: %s", *securityGroup.GroupId, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+func deleteVpcInternetGateways(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var errs []string
+
+	for _, gateway := range getInternetGatewaysByVpcId(ctx, ec2Session, vpcId) {
+		id := *gateway.InternetGatewayId
+		err := checkDependency(ctx, gateway.Tags, tagName, vpcId, resources.InternetGateway, region, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = ec2Session.DetachInternetGatewayWithContext(ctx,
+			&ec2.DetachInternetGatewayInput{
+				InternetGatewayId: aws.String(id),
+				VpcId:             aws.String(vpcId),
+			},
+		)
+		if err != nil && !hasErrorCode(err, "Gateway.NotAttached") {
+			errs = append(errs, fmt.Sprintf("can't detach internet gateway %s: %s", id, err))
+			continue
+		}
+
+		_, err = ec2Session.DeleteInternetGatewayWithContext(ctx,
+			&ec2.DeleteInternetGatewayInput{
+				InternetGatewayId: aws.String(id),
+			},
+		)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete internet gateway %s: %s", id, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+func deleteVpcSubnets(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var errs []string
+
+	for _, subnet := range getSubnetsByVpcId(ctx, ec2Session, vpcId) {
+		id := *subnet.SubnetId
+		err := checkDependency(ctx, subnet.Tags, tagName, vpcId, resources.Subnet, region, id)
+		if err != nil {
+			return err
+		}
+
+		_, err = ec2Session.DeleteSubnetWithContext(ctx,
+			&ec2.DeleteSubnetInput{
+				SubnetId: aws.String(id),
+			},
+		)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete subnet %s: %s", id, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+// deleteVpcRouteTables deletes the route tables but the main one, deleted with the VPC, once their associations are
+// removed
+func deleteVpcRouteTables(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string, tagName string) error {
+	var errs []string
+
+	for _, routeTable := range getRouteTablesByVpcId(ctx, ec2Session, vpcId) {
+		if isMainRouteTable(routeTable) {
+			continue
+		}
+
+		id := *routeTable.RouteTableId
+		err := checkDependency(ctx, routeTable.Tags, tagName, vpcId, resources.RouteTable, region, id)
+		if err != nil {
+			return err
+		}
+
+		for _, association := range routeTable.Associations {
+			_, err = ec2Session.DisassociateRouteTableWithContext(ctx,
+				&ec2.DisassociateRouteTableInput{
+					AssociationId: association.RouteTableAssociationId,
+				},
+			)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("can't disassociate route table %s: %s", id, err))
+			}
+		}
+
+		_, err = ec2Session.DeleteRouteTableWithContext(ctx,
+			&ec2.DeleteRouteTableInput{
+				RouteTableId: aws.String(id),
+			},
+		)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete route table %s: %s", id, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
@@ -0,0 +1,188 @@
+package vpc
+
+import (
+	"context"
+	"errors"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// mockVpcEC2 returns the dependencies of a VPC and records the deletions, the VPC can't be deleted while a transit
+// gateway attachment remains
+type mockVpcEC2 struct {
+	ec2iface.EC2API
+	attachments       []*ec2.TransitGatewayVpcAttachment
+	networkInterfaces []*ec2.NetworkInterface
+	internetGateways  []*ec2.InternetGateway
+	subnets           []*ec2.Subnet
+	attachmentErr     error
+	deleted           []string
+}
+
+func (m *mockVpcEC2) DescribeTransitGatewayVpcAttachmentsPagesWithContext(ctx aws.Context, input *ec2.DescribeTransitGatewayVpcAttachmentsInput, fn func(*ec2.DescribeTransitGatewayVpcAttachmentsOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeTransitGatewayVpcAttachmentsOutput{TransitGatewayVpcAttachments: m.attachments}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeNetworkInterfacesPagesWithContext(ctx aws.Context, input *ec2.DescribeNetworkInterfacesInput, fn func(*ec2.DescribeNetworkInterfacesOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeNetworkInterfacesOutput{NetworkInterfaces: m.networkInterfaces}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeNatGatewaysPagesWithContext(ctx aws.Context, input *ec2.DescribeNatGatewaysInput, fn func(*ec2.DescribeNatGatewaysOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeNatGatewaysOutput{}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeVpcEndpointsPagesWithContext(ctx aws.Context, input *ec2.DescribeVpcEndpointsInput, fn func(*ec2.DescribeVpcEndpointsOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeVpcEndpointsOutput{}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeSecurityGroupsPagesWithContext(ctx aws.Context, input *ec2.DescribeSecurityGroupsInput, fn func(*ec2.DescribeSecurityGroupsOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeSecurityGroupsOutput{}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeInternetGatewaysPagesWithContext(ctx aws.Context, input *ec2.DescribeInternetGatewaysInput, fn func(*ec2.DescribeInternetGatewaysOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeInternetGatewaysOutput{InternetGateways: m.internetGateways}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeSubnetsPagesWithContext(ctx aws.Context, input *ec2.DescribeSubnetsInput, fn func(*ec2.DescribeSubnetsOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeSubnetsOutput{Subnets: m.subnets}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DescribeRouteTablesPagesWithContext(ctx aws.Context, input *ec2.DescribeRouteTablesInput, fn func(*ec2.DescribeRouteTablesOutput, bool) bool, opts ...request.Option) error {
+	fn(&ec2.DescribeRouteTablesOutput{}, true)
+	return nil
+}
+
+func (m *mockVpcEC2) DeleteTransitGatewayVpcAttachmentWithContext(ctx aws.Context, input *ec2.DeleteTransitGatewayVpcAttachmentInput, opts ...request.Option) (*ec2.DeleteTransitGatewayVpcAttachmentOutput, error) {
+	if m.attachmentErr != nil {
+		return nil, m.attachmentErr
+	}
+	m.deleted = append(m.deleted, aws.StringValue(input.TransitGatewayAttachmentId))
+	m.attachments = nil
+	return &ec2.DeleteTransitGatewayVpcAttachmentOutput{}, nil
+}
+
+func (m *mockVpcEC2) DeleteNetworkInterfaceWithContext(ctx aws.Context, input *ec2.DeleteNetworkInterfaceInput, opts ...request.Option) (*ec2.DeleteNetworkInterfaceOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValue(input.NetworkInterfaceId))
+	return &ec2.DeleteNetworkInterfaceOutput{}, nil
+}
+
+func (m *mockVpcEC2) DetachInternetGatewayWithContext(ctx aws.Context, input *ec2.DetachInternetGatewayInput, opts ...request.Option) (*ec2.DetachInternetGatewayOutput, error) {
+	return &ec2.DetachInternetGatewayOutput{}, nil
+}
+
+func (m *mockVpcEC2) DeleteInternetGatewayWithContext(ctx aws.Context, input *ec2.DeleteInternetGatewayInput, opts ...request.Option) (*ec2.DeleteInternetGatewayOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValue(input.InternetGatewayId))
+	return &ec2.DeleteInternetGatewayOutput{}, nil
+}
+
+func (m *mockVpcEC2) DeleteSubnetWithContext(ctx aws.Context, input *ec2.DeleteSubnetInput, opts ...request.Option) (*ec2.DeleteSubnetOutput, error) {
+	m.deleted = append(m.deleted, aws.StringValue(input.SubnetId))
+	return &ec2.DeleteSubnetOutput{}, nil
+}
+
+func (m *mockVpcEC2) DeleteVpcWithContext(ctx aws.Context, input *ec2.DeleteVpcInput, opts ...request.Option) (*ec2.DeleteVpcOutput, error) {
+	if len(m.attachments) > 0 {
+		return nil, awserr.New("DependencyViolation", "the vpc has dependencies", nil)
+	}
+	m.deleted = append(m.deleted, aws.StringValue(input.VpcId))
+	return &ec2.DeleteVpcOutput{}, nil
+}
+
+func newTeardownMock() *mockVpcEC2 {
+	return &mockVpcEC2{
+		attachments: []*ec2.TransitGatewayVpcAttachment{{
+			TransitGatewayAttachmentId: aws.String("tgw-attach-1"),
+			State:                      aws.String(ec2.TransitGatewayAttachmentStateAvailable),
+		}},
+		networkInterfaces: []*ec2.NetworkInterface{{
+			NetworkInterfaceId: aws.String("eni-1"),
+			Status:             aws.String(ec2.NetworkInterfaceStatusAvailable),
+		}},
+		internetGateways: []*ec2.InternetGateway{{InternetGatewayId: aws.String("igw-1")}},
+		subnets:          []*ec2.Subnet{{SubnetId: aws.String("subnet-1")}},
+	}
+}
+
+func TestTeardownVPC(t *testing.T) {
+	tests := []struct {
+		name          string
+		prepare       func(m *mockVpcEC2)
+		policy        utils.DeletionPolicy
+		cancelled     bool
+		wantDeleted   []string
+		wantErr       string
+		wantKeptError bool
+	}{
+		{
+			name:        "every dependency deletable",
+			wantDeleted: []string{"tgw-attach-1", "eni-1", "igw-1", "subnet-1", "vpc-1"},
+		},
+		{
+			name: "protected subnet",
+			prepare: func(m *mockVpcEC2) {
+				m.subnets[0].Tags = []*ec2.Tag{{Key: aws.String("do_not_delete"), Value: aws.String("true")}}
+			},
+			wantErr:       "subnet subnet-1 is kept by the deletion policy",
+			wantKeptError: true,
+		},
+		{
+			name:          "excluded transit gateway attachment",
+			policy:        utils.DeletionPolicy{Exclusions: []*regexp.Regexp{regexp.MustCompile("^tgw-attach-1$")}},
+			wantErr:       "transit gateway attachment tgw-attach-1 is kept by the deletion policy",
+			wantKeptError: true,
+		},
+		{
+			name: "failed transit gateway attachment deletion",
+			prepare: func(m *mockVpcEC2) {
+				m.attachmentErr = errors.New("IncorrectState")
+			},
+			cancelled:   true,
+			wantDeleted: []string{"eni-1", "igw-1", "subnet-1"},
+			wantErr:     "can't delete transit gateway attachment tgw-attach-1: IncorrectState",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			svc := newTeardownMock()
+			if test.prepare != nil {
+				test.prepare(svc)
+			}
+			ctx, cancel := context.WithCancel(utils.WithDeletionPolicy(context.Background(), test.policy))
+			defer cancel()
+			// a cancelled context stops the teardown after its first attempt
+			if test.cancelled {
+				cancel()
+			}
+
+			err := teardownVPC(ctx, svc, "eu-west-3", "vpc-1", "ttl")
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("teardownVPC failed: %s", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("teardownVPC error = %v, want %q", err, test.wantErr)
+			}
+			if _, isKept := err.(dependencyKeptError); isKept != test.wantKeptError {
+				t.Errorf("teardownVPC error = %v, want a kept dependency error: %t", err, test.wantKeptError)
+			}
+			if !reflect.DeepEqual(svc.deleted, test.wantDeleted) {
+				t.Errorf("deleted %v, want %v", svc.deleted, test.wantDeleted)
+			}
+		})
+	}
+}
@@ -0,0 +1,297 @@
+package vpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type TransitGatewayAttachment struct {
+	Id                string
+	TransitGatewayId  string
+	ResourceType      string
+	ResourceId        string
+	State             string
+	CreationDate      time.Time
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+type TransitGateway struct {
+	Id                string
+	State             string
+	CreationDate      time.Time
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+func getTransitGatewayAttachments(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) []*ec2.TransitGatewayAttachment {
+	var transitGatewayAttachments []*ec2.TransitGatewayAttachment
+	err := ec2Session.DescribeTransitGatewayAttachmentsPagesWithContext(ctx,
+		&ec2.DescribeTransitGatewayAttachmentsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag-key"),
+					Values: []*string{aws.String(tagName), aws.String(utils.ExpirationDateTagName)},
+				},
+			},
+		},
+		func(page *ec2.DescribeTransitGatewayAttachmentsOutput, lastPage bool) bool {
+			transitGatewayAttachments = append(transitGatewayAttachments, page.TransitGatewayAttachments...)
+			return true
+		})
+
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	return transitGatewayAttachments
+}
+
+func getTransitGateways(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) []*ec2.TransitGateway {
+	var transitGateways []*ec2.TransitGateway
+	err := ec2Session.DescribeTransitGatewaysPagesWithContext(ctx,
+		&ec2.DescribeTransitGatewaysInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag-key"),
+					Values: []*string{aws.String(tagName), aws.String(utils.ExpirationDateTagName)},
+				},
+			},
+		},
+		func(page *ec2.DescribeTransitGatewaysOutput, lastPage bool) bool {
+			transitGateways = append(transitGateways, page.TransitGateways...)
+			return true
+		})
+
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	return transitGateways
+}
+
+func getVpcTransitGatewayAttachmentsByVpcId(ctx context.Context, ec2Session ec2iface.EC2API, vpcId string) ([]*ec2.TransitGatewayVpcAttachment, error) {
+	var transitGatewayVpcAttachments []*ec2.TransitGatewayVpcAttachment
+	err := ec2Session.DescribeTransitGatewayVpcAttachmentsPagesWithContext(ctx,
+		&ec2.DescribeTransitGatewayVpcAttachmentsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("vpc-id"),
+					Values: []*string{aws.String(vpcId)},
+				},
+			},
+		},
+		func(page *ec2.DescribeTransitGatewayVpcAttachmentsOutput, lastPage bool) bool {
+			transitGatewayVpcAttachments = append(transitGatewayVpcAttachments, page.TransitGatewayVpcAttachments...)
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return transitGatewayVpcAttachments, nil
+}
+
+func listTaggedTransitGatewayAttachments(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) []TransitGatewayAttachment {
+	var taggedAttachments []TransitGatewayAttachment
+
+	for _, attachment := range getTransitGatewayAttachments(ctx, ec2Session, tagName) {
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, attachment.Tags, tagName)
+
+		taggedAttachments = append(taggedAttachments, TransitGatewayAttachment{
+			Id:                *attachment.TransitGatewayAttachmentId,
+			TransitGatewayId:  *attachment.TransitGatewayId,
+			ResourceType:      aws.StringValue(attachment.ResourceType),
+			ResourceId:        aws.StringValue(attachment.ResourceId),
+			State:             *attachment.State,
+			CreationDate:      aws.TimeValue(attachment.CreationTime),
+			ttl:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(attachment.Tags),
+		})
+	}
+
+	return taggedAttachments
+}
+
+func listTaggedTransitGateways(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) []TransitGateway {
+	var taggedGateways []TransitGateway
+
+	for _, gateway := range getTransitGateways(ctx, ec2Session, tagName) {
+		_, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, gateway.Tags, tagName)
+
+		taggedGateways = append(taggedGateways, TransitGateway{
+			Id:                *gateway.TransitGatewayId,
+			State:             *gateway.State,
+			CreationDate:      aws.TimeValue(gateway.CreationTime),
+			ttl:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(gateway.Tags),
+		})
+	}
+
+	return taggedGateways
+}
+
+func isTransitGatewayAttachmentGone(state string) bool {
+	switch state {
+	case ec2.TransitGatewayAttachmentStateDeleting, ec2.TransitGatewayAttachmentStateDeleted,
+		ec2.TransitGatewayAttachmentStateRejected, ec2.TransitGatewayAttachmentStateRejecting:
+		return true
+	}
+
+	return false
+}
+
+func deleteTransitGatewayAttachment(ctx context.Context, ec2Session ec2iface.EC2API, attachment TransitGatewayAttachment) error {
+	var err error
+
+	switch attachment.ResourceType {
+	case ec2.TransitGatewayAttachmentResourceTypeVpc:
+		_, err = ec2Session.DeleteTransitGatewayVpcAttachmentWithContext(ctx,
+			&ec2.DeleteTransitGatewayVpcAttachmentInput{
+				TransitGatewayAttachmentId: aws.String(attachment.Id),
+			})
+	case ec2.TransitGatewayAttachmentResourceTypePeering:
+		_, err = ec2Session.DeleteTransitGatewayPeeringAttachmentWithContext(ctx,
+			&ec2.DeleteTransitGatewayPeeringAttachmentInput{
+				TransitGatewayAttachmentId: aws.String(attachment.Id),
+			})
+	default:
+		// vpn attachments are removed with their vpn connection
+		log.Debugf("Transit gateway attachment %s of type %s can't be deleted directly, skipping...", attachment.Id, attachment.ResourceType)
+	}
+
+	return err
+}
+
+// DeleteTransitGatewayAttachmentsByVpcId detaches the VPC from every transit gateway, otherwise DeleteVpc fails. It
+// returns the deletions which failed.
+func DeleteTransitGatewayAttachmentsByVpcId(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcId string) error {
+	attachments, err := getVpcTransitGatewayAttachmentsByVpcId(ctx, ec2Session, vpcId)
+	if err != nil {
+		return fmt.Errorf("can't list transit gateway attachments: %s", err)
+	}
+
+	var errs []string
+	for _, attachment := range attachments {
+		if isTransitGatewayAttachmentGone(*attachment.State) {
+			continue
+		}
+
+		_, err := ec2Session.DeleteTransitGatewayVpcAttachmentWithContext(ctx,
+			&ec2.DeleteTransitGatewayVpcAttachmentInput{
+				TransitGatewayAttachmentId: attachment.TransitGatewayAttachmentId,
+			})
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("can't delete transit gateway attachment %s: %s", *attachment.TransitGatewayAttachmentId, err))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+func DeleteExpiredTransitGatewayAttachments(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, dryRun bool) error {
+	attachments := listTaggedTransitGatewayAttachments(ctx, ec2Session, tagName)
+
+	var expiredAttachments []TransitGatewayAttachment
+	for _, attachment := range attachments {
+		if utils.CheckIfDeletable(ctx, attachment.CreationDate, attachment.ttl, attachment.ExpirationDate, attachment.DeletionScheduled, attachment.IsProtected, resources.TransitGatewayAttachment, region, attachment.Id) && !isTransitGatewayAttachmentGone(attachment.State) {
+			utils.SetReportTags(ctx, resources.TransitGatewayAttachment, region, attachment.Id, attachment.Tags)
+			expiredAttachments = append(expiredAttachments, attachment)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired transit gateway attachment", len(expiredAttachments), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredAttachments) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.TransitGatewayAttachment, region, len(expiredAttachments))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, attachment := range expiredAttachments {
+		deletionErr := deleteTransitGatewayAttachment(ctx, ec2Session, attachment)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.TransitGatewayAttachment, region, attachment.Id).Errorf("Deletion transit gateway attachment error %s/%s: %s",
+				attachment.Id, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.TransitGatewayAttachment, region, attachment.Id, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.TransitGatewayAttachment, region, attachment.Id)
+	}
+
+	return nil
+}
+
+func DeleteExpiredTransitGateways(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, dryRun bool) error {
+	gateways := listTaggedTransitGateways(ctx, ec2Session, tagName)
+
+	var expiredGateways []TransitGateway
+	for _, gateway := range gateways {
+		if gateway.State == ec2.TransitGatewayStateDeleting || gateway.State == ec2.TransitGatewayStateDeleted {
+			continue
+		}
+
+		if utils.CheckIfDeletable(ctx, gateway.CreationDate, gateway.ttl, gateway.ExpirationDate, gateway.DeletionScheduled, gateway.IsProtected, resources.TransitGateway, region, gateway.Id) {
+			utils.SetReportTags(ctx, resources.TransitGateway, region, gateway.Id, gateway.Tags)
+			expiredGateways = append(expiredGateways, gateway)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired transit gateway", len(expiredGateways), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredGateways) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.TransitGateway, region, len(expiredGateways))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, gateway := range expiredGateways {
+		_, deletionErr := ec2Session.DeleteTransitGatewayWithContext(ctx,
+			&ec2.DeleteTransitGatewayInput{
+				TransitGatewayId: aws.String(gateway.Id),
+			})
+		if deletionErr != nil {
+			// ignore errors, certainly due to attachments that are not yet removed
+			log.Warnf("Can't delete transit gateway %s in %s yet: %s", gateway.Id, region, deletionErr.Error())
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.TransitGateway, region, gateway.Id)
+	}
+
+	return nil
+}
@@ -1,41 +1,49 @@
 package vpc
 
 import (
+	"context"
 	"fmt"
 	"github.com/Qovery/pleco/providers/aws/database"
+	"github.com/Qovery/pleco/providers/aws/resources"
 	"github.com/Qovery/pleco/utils"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
-	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	log "github.com/sirupsen/logrus"
-	"sync"
 	"time"
 )
 
 
 
 type VpcInfo struct {
-	VpcId            *string
-	SecurityGroups   []SecurityGroup
-	InternetGateways []InternetGateway
-	Subnets          []Subnet
-	RouteTables      []RouteTable
-	Status           string
-	TTL              int64
-	Tag              string
-	CreationDate     time.Time
-	IsProtected      bool
+	VpcId             *string
+	Status            string
+	TTL               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	Tag               string
+	CreationDate      time.Time
+	IsProtected       bool
+	Tags              map[string]string
 }
 
-func GetVpcsIdsByClusterNameTag (ec2Session ec2.EC2, clusterName string) []*string {
-	result, err := ec2Session.DescribeVpcs(
+func GetVpcsIdsByClusterNameTag (ctx context.Context, ec2Session ec2iface.EC2API, clusterTagKey string, clusterName string) []*string {
+	var vpcsIds []*string
+	err := ec2Session.DescribeVpcsPagesWithContext(ctx,
 		&ec2.DescribeVpcsInput{
 			Filters:    []*ec2.Filter{
 				{
-					Name:   aws.String("tag:ClusterName"),
+					Name:   aws.String("tag:" + clusterTagKey),
 					Values: []*string{aws.String(clusterName)},
 				},
 			},
+		},
+		func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+			for _, vpc := range page.Vpcs {
+				vpcsIds = append(vpcsIds, vpc.VpcId)
+			}
+			return true
 		})
 
 	if err != nil {
@@ -43,49 +51,51 @@ func GetVpcsIdsByClusterNameTag (ec2Session ec2.EC2, clusterName string) []*stri
 		return nil
 	}
 
-	var vpcsIds []*string
-	for _, vpc := range result.Vpcs {
-		vpcsIds = append(vpcsIds, vpc.VpcId)
-	}
-
 	return vpcsIds
 }
 
-func getVPCs(ec2Session ec2.EC2, tagName string) []*ec2.Vpc {
+func getVPCs(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) ([]*ec2.Vpc, error) {
 	input := &ec2.DescribeVpcsInput{
 		Filters: []*ec2.Filter{
 			{
 				Name: aws.String("tag-key"),
-				Values: []*string{&tagName},
+				Values: []*string{&tagName, aws.String(utils.ExpirationDateTagName)},
 			},
 		},
 	}
 
-	result, err := ec2Session.DescribeVpcs(input)
+	var vpcs []*ec2.Vpc
+	err := ec2Session.DescribeVpcsPagesWithContext(ctx, input,
+		func(page *ec2.DescribeVpcsOutput, lastPage bool) bool {
+			vpcs = append(vpcs, page.Vpcs...)
+			return true
+		})
 	if err != nil {
-		log.Error(err)
-		return nil
+		return nil, err
 	}
 
-	if len(result.Vpcs) == 0 {
-		return nil
-	}
-
-	return result.Vpcs
+	return vpcs, nil
 }
 
-func listTaggedVPC(ec2Session ec2.EC2, tagName string) ([]VpcInfo, error) {
+func listTaggedVPC(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string) ([]VpcInfo, error) {
 	var taggedVPCs []VpcInfo
-	var VPCs = getVPCs(ec2Session, tagName)
+	VPCs, err := getVPCs(ctx, ec2Session, tagName)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, vpc := range VPCs {
-		creationDate, ttl, isprotected, _, _ := utils.GetEssentialTags(vpc.Tags, tagName)
+		creationDate, ttl, isprotected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, vpc.Tags, tagName)
+		utils.AddMissingCreationDateTag(ctx, ec2Session, region, *vpc.VpcId, creationDate, ttl, tagName)
 		taggedVpc := VpcInfo{
 			VpcId:      vpc.VpcId,
 			Status:     *vpc.State,
 			CreationDate: creationDate,
 			TTL: ttl,
+			ExpirationDate: expirationDate,
+			DeletionScheduled: deletionScheduled,
 			IsProtected: isprotected,
+			Tags: utils.TagsToMap(vpc.Tags),
 		}
 
 		if *vpc.State != "available" {
@@ -104,11 +114,10 @@ func listTaggedVPC(ec2Session ec2.EC2, tagName string) ([]VpcInfo, error) {
 
 				taggedVpc.Tag = *tag.Value
 			}
-
-			getCompleteVpc(ec2Session, &taggedVpc, tagName)
 		}
 
-		if utils.CheckIfExpired(taggedVpc.CreationDate, taggedVpc.TTL) && !taggedVpc.IsProtected {
+		if utils.CheckIfDeletable(ctx, taggedVpc.CreationDate, taggedVpc.TTL, taggedVpc.ExpirationDate, taggedVpc.DeletionScheduled, taggedVpc.IsProtected, resources.VPC, region, *taggedVpc.VpcId) {
+			utils.SetReportTags(ctx, resources.VPC, region, *taggedVpc.VpcId, taggedVpc.Tags)
 			taggedVPCs = append(taggedVPCs, taggedVpc)
 		}
 
@@ -117,106 +126,84 @@ func listTaggedVPC(ec2Session ec2.EC2, tagName string) ([]VpcInfo, error) {
 	return taggedVPCs, nil
 }
 
-func deleteVPC(ec2Session ec2.EC2, VpcList []VpcInfo, dryRun bool) error {
-	if dryRun {
-		return nil
-	}
+func deleteVPC(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpc VpcInfo, tagName string) error {
+	utils.ResourceLog(ctx, utils.ActionDelete, resources.VPC, region, *vpc.VpcId).Infof("Deleting VPC %s in %s and its dependencies.", *vpc.VpcId, region)
 
-	if len(VpcList) == 0 {
-		return nil
-	}
-
-	region := *ec2Session.Config.Region
-
-	for _, vpc := range VpcList {
-			DeleteSecurityGroupsByIds(ec2Session,vpc.SecurityGroups)
-			DeleteInternetGatewaysByIds(ec2Session, vpc.InternetGateways)
-			DeleteSubnetsByIds(ec2Session, vpc.Subnets)
-			DeleteRouteTablesByIds(ec2Session, vpc.RouteTables)
-
-			_, err := ec2Session.DeleteVpc(
-				&ec2.DeleteVpcInput{
-					VpcId:  aws.String(*vpc.VpcId),
-				},
-			)
-			if err != nil {
-				// ignore errors, certainly due to dependencies that are not yet removed
-				log.Warnf("Can't delete VPC %s in %s yet: %s", *vpc.VpcId, region, err.Error())
-			}
+	err := teardownVPC(ctx, ec2Session, region, *vpc.VpcId, tagName)
+	if err != nil {
+		utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.VPC, region, *vpc.VpcId).Errorf("Deletion VPC error %s/%s: %s",
+			*vpc.VpcId, region, err)
+		utils.ReportDeletionError(ctx, resources.VPC, region, *vpc.VpcId, err)
+		return err
 	}
+	utils.ReportDeleted(ctx, resources.VPC, region, *vpc.VpcId)
 
 	return nil
 }
 
-func DeleteExpiredVPC(ec2Session ec2.EC2, tagName string, dryRun bool) {
-	VPCs, err := listTaggedVPC(ec2Session, tagName)
-	region := ec2Session.Config.Region
+func DeleteExpiredVPC(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, dryRun bool) error {
+	VPCs, err := listTaggedVPC(ctx, ec2Session, region, tagName)
 	if err != nil {
-		log.Errorf("can't list VPC: %s\n", err)
+		return fmt.Errorf("can't list VPC: %s", err)
 	}
 
-	count, start := utils.ElemToDeleteFormattedInfos("tagged VPC resource", len(VPCs), *region)
+	count, start := utils.ElemToDeleteFormattedInfos("tagged VPC resource", len(VPCs), region)
 
 	log.Debug(count)
 
 	if dryRun || len(VPCs) == 0 {
-		return
+		return nil
 	}
 
-	log.Debug(start)
+	limitErr := utils.ReserveDeletions(ctx, resources.VPC, region, len(VPCs))
+	if limitErr != nil {
+		return limitErr
+	}
 
-	_ = deleteVPC(ec2Session, VPCs, dryRun)
+	log.Debug(start)
 
-}
+	for _, vpc := range VPCs {
+		_ = deleteVPC(ctx, ec2Session, region, vpc, tagName)
+	}
 
-func getCompleteVpc(ec2Session ec2.EC2, vpc *VpcInfo, tagName string){
-	var waitGroup sync.WaitGroup
-	waitGroup.Add(1)
-	go SetSecurityGroupsIdsByVpcId(ec2Session, vpc, &waitGroup, tagName)
-	waitGroup.Add(1)
-	go SetInternetGatewaysIdsByVpcId(ec2Session, vpc, &waitGroup, tagName)
-	waitGroup.Add(1)
-	go SetSubnetsIdsByVpcId(ec2Session, vpc, &waitGroup, tagName)
-	waitGroup.Add(1)
-	go SetRouteTablesIdsByVpcId(ec2Session, vpc, &waitGroup, tagName)
-	waitGroup.Wait()
+	return nil
 }
 
-func addCreationDateToVpcs(ec2Session ec2.EC2, vpcsIds []*string, clusterCreationTime time.Time, clusterTtl int64) error {
-	return utils.AddCreationDateTag(ec2Session, vpcsIds, clusterCreationTime, clusterTtl)
+func addCreationDateToVpcs(ctx context.Context, ec2Session ec2iface.EC2API, region string, vpcsIds []*string, clusterCreationTime time.Time, clusterTtl int64, tagName string) error {
+	return utils.AddCreationDateTag(ctx, ec2Session, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 }
 
-func TagVPCsForDeletion(ec2Session ec2.EC2, rdsSession rds.RDS, clusterId string, clusterCreationTime time.Time, clusterTtl int64) error {
-	vpcsIds := GetVpcsIdsByClusterNameTag(ec2Session, clusterId)
+func TagVPCsForDeletion(ctx context.Context, ec2Session ec2iface.EC2API, region string, rdsSession rdsiface.RDSAPI, clusterId string, clusterCreationTime time.Time, clusterTtl int64, tagName string, clusterTagKey string) error {
+	vpcsIds := GetVpcsIdsByClusterNameTag(ctx, ec2Session, clusterTagKey, clusterId)
 
-	err := AddCreationDateTagToSG(ec2Session, vpcsIds, clusterCreationTime, clusterTtl)
+	err := AddCreationDateTagToSG(ctx, ec2Session, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 	if err != nil {
-		return fmt.Errorf("Can't tag security groups for cluster %s in region %s: %s", clusterId, * ec2Session.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag security groups for cluster %s in region %s: %s", clusterId, region, err.Error())
 	}
 
- 	err = AddCreationDateTagToIGW(ec2Session, vpcsIds, clusterCreationTime, clusterTtl)
+ 	err = AddCreationDateTagToIGW(ctx, ec2Session, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 	if err != nil {
-		return fmt.Errorf("Can't tag internet gateways for cluster %s in region %s: %s", clusterId, * ec2Session.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag internet gateways for cluster %s in region %s: %s", clusterId, region, err.Error())
 	}
 
-	err = AddCreationDateTagToSubnets(ec2Session, vpcsIds, clusterCreationTime, clusterTtl)
+	err = AddCreationDateTagToSubnets(ctx, ec2Session, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 	if err != nil {
-		return fmt.Errorf("Can't tag subnets for cluster %s in region %s: %s", clusterId, * ec2Session.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag subnets for cluster %s in region %s: %s", clusterId, region, err.Error())
 	}
 
-	err = AddCreationDateTagToRTB(ec2Session, vpcsIds, clusterCreationTime, clusterTtl)
+	err = AddCreationDateTagToRTB(ctx, ec2Session, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 	if err != nil {
-		return fmt.Errorf("Can't tag route tables for cluster %s in region %s: %s", clusterId, * ec2Session.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag route tables for cluster %s in region %s: %s", clusterId, region, err.Error())
 	}
 
-	err = database.AddCreationDateTagToRdsSubnetGroups(rdsSession, vpcsIds, clusterCreationTime, clusterTtl)
+	err = database.AddCreationDateTagToRdsSubnetGroups(ctx, rdsSession, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 	if err != nil {
-		return fmt.Errorf("Can't tag RDS subnet groups for cluster %s in region %s: %s", clusterId, * rdsSession.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag RDS subnet groups for cluster %s in region %s: %s", clusterId, region, err.Error())
 	}
 
-	err = addCreationDateToVpcs(ec2Session, vpcsIds, clusterCreationTime, clusterTtl)
+	err = addCreationDateToVpcs(ctx, ec2Session, region, vpcsIds, clusterCreationTime, clusterTtl, tagName)
 	if err != nil {
-		return fmt.Errorf("Can't tag VPC for cluster %s in region %s: %s", clusterId, * rdsSession.Config.Region, err.Error())
+		return fmt.Errorf("Can't tag VPC for cluster %s in region %s: %s", clusterId, region, err.Error())
 	}
 	return nil
 }
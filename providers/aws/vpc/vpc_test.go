@@ -0,0 +1,27 @@
+package vpc
+
+import (
+	"context"
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"strings"
+	"testing"
+)
+
+type failingDescribeVpcsEC2 struct {
+	ec2iface.EC2API
+}
+
+func (m *failingDescribeVpcsEC2) DescribeVpcsPagesWithContext(ctx aws.Context, input *ec2.DescribeVpcsInput, fn func(*ec2.DescribeVpcsOutput, bool) bool, opts ...request.Option) error {
+	return errors.New("UnauthorizedOperation")
+}
+
+func TestDeleteExpiredVPCListingError(t *testing.T) {
+	err := DeleteExpiredVPC(context.Background(), &failingDescribeVpcsEC2{}, "eu-west-3", "ttl", false)
+	if err == nil || !strings.Contains(err.Error(), "UnauthorizedOperation") {
+		t.Fatalf("DeleteExpiredVPC error = %v, want the DescribeVpcs error", err)
+	}
+}
@@ -0,0 +1,213 @@
+package vpc
+
+import (
+	"context"
+	"github.com/Qovery/pleco/providers/aws/resources"
+	"github.com/Qovery/pleco/utils"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type VpnConnection struct {
+	Id                string
+	CustomerGatewayId string
+	State             string
+	CreationDate      time.Time
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+type CustomerGateway struct {
+	Id                string
+	State             string
+	CreationDate      time.Time
+	ttl               int64
+	ExpirationDate    time.Time
+	DeletionScheduled time.Time
+	IsProtected       bool
+	Tags              map[string]string
+}
+
+func getVpnConnections(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) []*ec2.VpnConnection {
+	result, err := ec2Session.DescribeVpnConnectionsWithContext(ctx,
+		&ec2.DescribeVpnConnectionsInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag-key"),
+					Values: []*string{aws.String(tagName), aws.String(utils.ExpirationDateTagName)},
+				},
+			},
+		})
+
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	return result.VpnConnections
+}
+
+func getCustomerGateways(ctx context.Context, ec2Session ec2iface.EC2API, tagName string) []*ec2.CustomerGateway {
+	result, err := ec2Session.DescribeCustomerGatewaysWithContext(ctx,
+		&ec2.DescribeCustomerGatewaysInput{
+			Filters: []*ec2.Filter{
+				{
+					Name:   aws.String("tag-key"),
+					Values: []*string{aws.String(tagName), aws.String(utils.ExpirationDateTagName)},
+				},
+			},
+		})
+
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+
+	return result.CustomerGateways
+}
+
+// addMissingCreationDateTag records the first time pleco saw a resource, as vpn connections and customer gateways
+// don't expose any creation time
+func listTaggedVpnConnections(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string) []VpnConnection {
+	var taggedConnections []VpnConnection
+
+	for _, connection := range getVpnConnections(ctx, ec2Session, tagName) {
+		creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, connection.Tags, tagName)
+		utils.AddMissingCreationDateTag(ctx, ec2Session, region, *connection.VpnConnectionId, creationDate, ttl, tagName)
+
+		taggedConnections = append(taggedConnections, VpnConnection{
+			Id:                *connection.VpnConnectionId,
+			CustomerGatewayId: aws.StringValue(connection.CustomerGatewayId),
+			State:             *connection.State,
+			CreationDate:      creationDate,
+			ttl:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(connection.Tags),
+		})
+	}
+
+	return taggedConnections
+}
+
+func listTaggedCustomerGateways(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string) []CustomerGateway {
+	var taggedGateways []CustomerGateway
+
+	for _, gateway := range getCustomerGateways(ctx, ec2Session, tagName) {
+		creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, gateway.Tags, tagName)
+		utils.AddMissingCreationDateTag(ctx, ec2Session, region, *gateway.CustomerGatewayId, creationDate, ttl, tagName)
+
+		taggedGateways = append(taggedGateways, CustomerGateway{
+			Id:                *gateway.CustomerGatewayId,
+			State:             *gateway.State,
+			CreationDate:      creationDate,
+			ttl:               ttl,
+			ExpirationDate:    expirationDate,
+			DeletionScheduled: deletionScheduled,
+			IsProtected:       isProtected,
+			Tags:              utils.TagsToMap(gateway.Tags),
+		})
+	}
+
+	return taggedGateways
+}
+
+func DeleteExpiredVpnConnections(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, dryRun bool) error {
+	connections := listTaggedVpnConnections(ctx, ec2Session, region, tagName)
+
+	var expiredConnections []VpnConnection
+	for _, connection := range connections {
+		if connection.State == ec2.VpnStateDeleting || connection.State == ec2.VpnStateDeleted {
+			continue
+		}
+
+		if utils.CheckIfDeletable(ctx, connection.CreationDate, connection.ttl, connection.ExpirationDate, connection.DeletionScheduled, connection.IsProtected, resources.VPNConnection, region, connection.Id) {
+			utils.SetReportTags(ctx, resources.VPNConnection, region, connection.Id, connection.Tags)
+			expiredConnections = append(expiredConnections, connection)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired VPN connection", len(expiredConnections), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredConnections) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.VPNConnection, region, len(expiredConnections))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, connection := range expiredConnections {
+		_, deletionErr := ec2Session.DeleteVpnConnectionWithContext(ctx,
+			&ec2.DeleteVpnConnectionInput{
+				VpnConnectionId: aws.String(connection.Id),
+			})
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resources.VPNConnection, region, connection.Id).Errorf("Deletion VPN connection error %s/%s: %s",
+				connection.Id, region, deletionErr)
+			utils.ReportDeletionError(ctx, resources.VPNConnection, region, connection.Id, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.VPNConnection, region, connection.Id)
+	}
+
+	return nil
+}
+
+func DeleteExpiredCustomerGateways(ctx context.Context, ec2Session ec2iface.EC2API, region string, tagName string, dryRun bool) error {
+	gateways := listTaggedCustomerGateways(ctx, ec2Session, region, tagName)
+
+	var expiredGateways []CustomerGateway
+	for _, gateway := range gateways {
+		if gateway.State == "deleting" || gateway.State == "deleted" {
+			continue
+		}
+
+		if utils.CheckIfDeletable(ctx, gateway.CreationDate, gateway.ttl, gateway.ExpirationDate, gateway.DeletionScheduled, gateway.IsProtected, resources.CustomerGateway, region, gateway.Id) {
+			utils.SetReportTags(ctx, resources.CustomerGateway, region, gateway.Id, gateway.Tags)
+			expiredGateways = append(expiredGateways, gateway)
+		}
+	}
+
+	count, start := utils.ElemToDeleteFormattedInfos("expired customer gateway", len(expiredGateways), region)
+
+	log.Debug(count)
+
+	if dryRun || len(expiredGateways) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resources.CustomerGateway, region, len(expiredGateways))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	log.Debug(start)
+
+	for _, gateway := range expiredGateways {
+		_, deletionErr := ec2Session.DeleteCustomerGatewayWithContext(ctx,
+			&ec2.DeleteCustomerGatewayInput{
+				CustomerGatewayId: aws.String(gateway.Id),
+			})
+		if deletionErr != nil {
+			// ignore errors, certainly due to vpn connections that are not yet removed
+			log.Warnf("Can't delete customer gateway %s in %s yet: %s", gateway.Id, region, deletionErr.Error())
+			continue
+		}
+		utils.ReportDeleted(ctx, resources.CustomerGateway, region, gateway.Id)
+	}
+
+	return nil
+}
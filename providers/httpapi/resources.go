@@ -0,0 +1,252 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Resource is a resource returned by the list endpoint, ex:
+// {"id": "job-42", "type": "Nomad job", "created_at": "2021-03-01T18:00:00Z", "ttl": "1d", "tags": {"owner": "me"}}
+type Resource struct {
+	Id string `json:"id"`
+	// Name is shown in logs and reports instead of the id if set
+	Name string `json:"name,omitempty"`
+	// Type is the resource type, the name of the platform followed by resource if empty
+	Type string `json:"type,omitempty"`
+	// CreatedAt has the formats of the creationDate tag: RFC3339, day or seconds since the epoch
+	CreatedAt Value `json:"created_at,omitempty"`
+	// TTL is a number of seconds, or a duration in the format of the ttl tag (ex: 1d)
+	TTL Value `json:"ttl,omitempty"`
+	// Tags are read like the tags of cloud resources: expiration date, protection, owner...
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Value is a json string or number, read like a tag value
+type Value string
+
+func (v *Value) UnmarshalJSON(data []byte) error {
+	var value string
+	if json.Unmarshal(data, &value) == nil {
+		*v = Value(value)
+		return nil
+	}
+
+	var number json.Number
+	err := json.Unmarshal(data, &number)
+	if err != nil {
+		return fmt.Errorf("expected a string or a number, got %s", data)
+	}
+	*v = Value(number)
+
+	return nil
+}
+
+// getName returns the name shown in logs and reports
+func (r Resource) getName() string {
+	if r.Name == "" {
+		return r.Id
+	}
+
+	return r.Name
+}
+
+// getTags returns the tags of the resource with its ttl and creation date, which take precedence
+func (r Resource) getTags(tagName string) map[string]*string {
+	tags := make(map[string]*string)
+	for key, value := range r.Tags {
+		value := value
+		tags[key] = &value
+	}
+
+	if ttl := string(r.TTL); ttl != "" {
+		tags[tagName] = &ttl
+	}
+	if r.CreatedAt != "" {
+		createdAt := string(r.CreatedAt)
+		tags[utils.CreationDateTagName] = &createdAt
+	}
+
+	return tags
+}
+
+func (c *Cleaner) newRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.config.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.config.Token)
+	}
+
+	return request, nil
+}
+
+// do sends the request and returns the response body, responses without 2xx status are errors
+func (c *Cleaner) do(request *http.Request) ([]byte, error) {
+	response, err := c.client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s %s answered %s: %s", request.Method, request.URL.Redacted(), response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return body, nil
+}
+
+func (c *Cleaner) listResources(ctx context.Context) ([]Resource, error) {
+	request, err := c.newRequest(ctx, http.MethodGet, c.config.ListUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := c.do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []Resource
+	err = json.Unmarshal(body, &resources)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse the resources returned by %s: %s", c.config.ListUrl, err)
+	}
+
+	return resources, nil
+}
+
+// deleteResource calls the delete endpoint, resources already gone (404) are deleted
+func (c *Cleaner) deleteResource(ctx context.Context, resource Resource) error {
+	var request *http.Request
+	var err error
+	if strings.Contains(c.config.DeleteUrl, "{id}") {
+		deleteUrl := strings.ReplaceAll(c.config.DeleteUrl, "{id}", url.PathEscape(resource.Id))
+		request, err = c.newRequest(ctx, http.MethodDelete, deleteUrl, nil)
+	} else {
+		body, marshalErr := json.Marshal(resource)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		request, err = c.newRequest(ctx, http.MethodPost, c.config.DeleteUrl, bytes.NewReader(body))
+	}
+	if err != nil {
+		return err
+	}
+
+	response, err := c.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound || (response.StatusCode >= 200 && response.StatusCode < 300) {
+		return nil
+	}
+
+	body, _ := ioutil.ReadAll(response.Body)
+	return fmt.Errorf("%s %s answered %s: %s", request.Method, request.URL.Redacted(), response.Status, strings.TrimSpace(string(body)))
+}
+
+// deleteExpiredResources deletes the expired resources of every type returned by the list endpoint, or only reports
+// them in dry run
+func (c *Cleaner) deleteExpiredResources(ctx context.Context, dryRun bool) error {
+	resources, err := c.listResources(ctx)
+	if err != nil {
+		return fmt.Errorf("can't list %s resources: %s", c.config.Name, err)
+	}
+
+	resourcesByType := make(map[string][]Resource)
+	for _, resource := range resources {
+		if resource.Id == "" {
+			log.Warnf("Skipping %s resource %s without id.", c.config.Name, resource.getName())
+			continue
+		}
+		if resource.Type == "" {
+			resource.Type = c.config.Name + " resource"
+		}
+		resourcesByType[resource.Type] = append(resourcesByType[resource.Type], resource)
+	}
+
+	var resourceTypes []string
+	for resourceType := range resourcesByType {
+		resourceTypes = append(resourceTypes, resourceType)
+	}
+	sort.Strings(resourceTypes)
+
+	for _, resourceType := range resourceTypes {
+		err := c.deleteExpiredResourcesOfType(ctx, resourceType, resourcesByType[resourceType], dryRun)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cleaner) deleteExpiredResourcesOfType(ctx context.Context, resourceType string, resources []Resource, dryRun bool) error {
+	var expiredResources []Resource
+	for _, resource := range resources {
+		creationDate, ttl, isProtected, _, _, expirationDate, deletionScheduled := utils.GetEssentialTags(ctx, resource.getTags(c.config.TagName), c.config.TagName)
+		if creationDate.IsZero() && expirationDate.IsZero() {
+			log.Warnf("Skipping %s %s: the list endpoint returned neither its creation date nor its expiration date.", resourceType, resource.getName())
+			continue
+		}
+
+		identifiers := []string{resource.getName()}
+		if resource.Name != "" {
+			identifiers = append(identifiers, resource.Id)
+		}
+
+		if utils.CheckIfDeletable(ctx, creationDate, ttl, expirationDate, deletionScheduled, isProtected, resourceType, "", identifiers...) {
+			utils.SetReportTags(ctx, resourceType, "", resource.getName(), resource.Tags)
+			expiredResources = append(expiredResources, resource)
+		}
+	}
+
+	log.Debugf("There are %d expired %ss to delete.", len(expiredResources), resourceType)
+
+	if dryRun || len(expiredResources) == 0 {
+		return nil
+	}
+
+	limitErr := utils.ReserveDeletions(ctx, resourceType, "", len(expiredResources))
+	if limitErr != nil {
+		return limitErr
+	}
+
+	for _, resource := range expiredResources {
+		name := resource.getName()
+		utils.ResourceLog(ctx, utils.ActionDelete, resourceType, "", name).Infof("Deleting %s %s.", resourceType, name)
+		deletionErr := c.deleteResource(ctx, resource)
+		if deletionErr != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, resourceType, "", name).Errorf("Deletion %s error %s: %s",
+				resourceType, name, deletionErr)
+			utils.ReportDeletionError(ctx, resourceType, "", name, deletionErr)
+			continue
+		}
+		utils.ReportDeleted(ctx, resourceType, "", name)
+	}
+
+	return nil
+}
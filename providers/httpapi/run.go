@@ -0,0 +1,58 @@
+// Package httpapi checks the resources of internal platforms through HTTP endpoints: one listing the resources with
+// their creation date and ttl, another deleting them. Platforms plug their own resource types into the expiry checks
+// without writing Go.
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"net/http"
+	"time"
+)
+
+// Config configures the checks of the HTTP endpoints
+type Config struct {
+	// Name is the checked platform (ex: Nomad), the source of the reports
+	Name string
+	// ListUrl returns the resources as a json array (see Resource)
+	ListUrl string
+	// DeleteUrl deletes a resource: with a {id} placeholder, pleco sends a DELETE request to the url with the id of the
+	// resource, without it, a POST request with the resource as json body
+	DeleteUrl string
+	// Token is sent as bearer token to both endpoints if set
+	Token   string
+	TagName string
+}
+
+// Cleaner checks the resources returned by the list endpoint
+type Cleaner struct {
+	config Config
+	client *http.Client
+}
+
+// NewCleaner returns the cleaner of the endpoints, it returns nil if no list endpoint is set
+func NewCleaner(config Config) (*Cleaner, error) {
+	if config.ListUrl == "" {
+		return nil, nil
+	}
+	if config.DeleteUrl == "" {
+		return nil, fmt.Errorf("the %s resources list endpoint needs a delete endpoint", config.Name)
+	}
+
+	return &Cleaner{config: config, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (c *Cleaner) Name() string {
+	return c.config.Name
+}
+
+func (c *Cleaner) Check(ctx context.Context, dryRun bool) []error {
+	err := c.deleteExpiredResources(ctx, dryRun)
+	if err != nil {
+		logrus.Error(err)
+		return []error{err}
+	}
+
+	return nil
+}
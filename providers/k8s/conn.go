@@ -37,4 +37,17 @@ func AuthenticateOutOfCluster() (*kubernetes.Clientset, error) {
 		return nil, fmt.Errorf("failed to generate client set: %v", err)
 	}
 	return clientSet, nil
-}
\ No newline at end of file
+}
+
+// GetClientConfig returns the client configuration of a connection method: in (in cluster service account) or out
+// (KUBECONFIG)
+func GetClientConfig(connection string) (*rest.Config, error) {
+	switch connection {
+	case "in":
+		return rest.InClusterConfig()
+	case "out":
+		return clientcmd.BuildConfigFromFlags("", os.Getenv("KUBECONFIG"))
+	default:
+		return nil, fmt.Errorf("unknown kubernetes connection method %s, choose between : in/out", connection)
+	}
+}
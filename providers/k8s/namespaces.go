@@ -7,18 +7,24 @@ import (
 	log "github.com/sirupsen/logrus"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
-	"strconv"
 	"time"
 )
 
+// namespaceType is the resource type of the namespaces in the logs, reports and summaries
+const namespaceType = "namespace"
+
 type kubernetesNamespace struct {
 	Name string
 	NamespaceCreateTime time.Time
 	Status string
 	TTL int64
+	ExpirationDate time.Time
+	DeletionScheduled time.Time
+	IsProtected bool
+	Labels map[string]string
 }
 
-func listTaggedNamespaces(clientSet *kubernetes.Clientset, tagName string) ([]kubernetesNamespace, error) {
+func listTaggedNamespaces(ctx context.Context, clientSet kubernetes.Interface, tagName string) ([]kubernetesNamespace, error) {
 	var taggedNamespaces []kubernetesNamespace
 
 	listOptions := metav1.ListOptions{
@@ -26,7 +32,7 @@ func listTaggedNamespaces(clientSet *kubernetes.Clientset, tagName string) ([]ku
 	}
 
 	log.Debugf("Listing all Kubernetes namespaces with %s label", tagName)
-	namespaces, err := clientSet.CoreV1().Namespaces().List(context.TODO(), listOptions)
+	namespaces, err := clientSet.CoreV1().Namespaces().List(ctx, listOptions)
 	if err != nil {
 		return taggedNamespaces, err
 	}
@@ -39,7 +45,7 @@ func listTaggedNamespaces(clientSet *kubernetes.Clientset, tagName string) ([]ku
 	for _, namespace := range namespaces.Items {
 		for key, value := range namespace.ObjectMeta.Labels {
 			if key == tagName {
-				ttlValue, err := strconv.Atoi(value)
+				ttlValue, err := utils.ParseTTL(value)
 
 				if err != nil {
 					log.Errorf("ttl value unrecognized for namespace %s", namespace.Name)
@@ -50,7 +56,10 @@ func listTaggedNamespaces(clientSet *kubernetes.Clientset, tagName string) ([]ku
 					Name:                namespace.Name,
 					NamespaceCreateTime: namespace.CreationTimestamp.Time,
 					Status:              string(namespace.Status.Phase),
-					TTL:                 int64(ttlValue),
+					TTL:                 extendTTL(ttlValue, namespace.ObjectMeta),
+					DeletionScheduled:   getDeletionScheduled(namespace.ObjectMeta.Annotations),
+					IsProtected:         isProtectedNamespace(ctx, namespace.Name, namespace.ObjectMeta.Labels),
+					Labels:              namespace.ObjectMeta.Labels,
 				})
 			}
 		}
@@ -59,7 +68,57 @@ func listTaggedNamespaces(clientSet *kubernetes.Clientset, tagName string) ([]ku
 	return taggedNamespaces, nil
 }
 
-func deleteNamespace(clientSet *kubernetes.Clientset, namespace kubernetesNamespace, dryRun bool) error {
+func isProtectedNamespace(ctx context.Context, name string, labels map[string]string) bool {
+	// namespaces are listed by their ttl label, a production one is a conflict
+	if marker, isMarked := utils.GetProductionMarker(ctx, labels); isMarked {
+		utils.AlertProductionConflict(marker, "namespace "+name, 0, time.Time{})
+		return true
+	}
+
+	for key, value := range labels {
+		if utils.IsProtectionTag(key, value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// extendTTL adds the duration of the extend-ttl label or annotation to the ttl of a namespace
+func extendTTL(ttl int64, meta metav1.ObjectMeta) int64 {
+	value, ok := meta.Labels[utils.ExtendTTLTagName]
+	if !ok {
+		value, ok = meta.Annotations[utils.ExtendTTLTagName]
+	}
+	if !ok || ttl == 0 {
+		return ttl
+	}
+
+	extension, err := utils.ParseTTL(value)
+	if err != nil {
+		log.Warnf("Can't parse %s value %s of namespace %s: %s", utils.ExtendTTLTagName, value, meta.Name, err)
+		return ttl
+	}
+
+	return ttl + extension
+}
+
+func getDeletionScheduled(annotations map[string]string) time.Time {
+	value, ok := annotations[utils.DeletionScheduledTagName]
+	if !ok {
+		return time.Time{}
+	}
+
+	deletionScheduled, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Warnf("Can't parse %s annotation value %s: %s", utils.DeletionScheduledTagName, value, err)
+		return time.Time{}
+	}
+
+	return deletionScheduled
+}
+
+func deleteNamespace(ctx context.Context, clientSet kubernetes.Interface, namespace kubernetesNamespace, dryRun bool) error {
 	deleteOptions := metav1.DeleteOptions{}
 
 	if namespace.Status == "Terminating" {
@@ -70,30 +129,45 @@ func deleteNamespace(clientSet *kubernetes.Clientset, namespace kubernetesNamesp
 		return nil
 	}
 
-	log.Infof("Deleting namespace %s, expired after %d seconds", namespace.Name, namespace.TTL)
+	utils.ResourceLog(ctx, utils.ActionDelete, namespaceType, "", namespace.Name).Infof("Deleting namespace %s, expired after %d seconds", namespace.Name, namespace.TTL)
 	if !dryRun {
-		err := clientSet.CoreV1().Namespaces().Delete(context.TODO(), namespace.Name, deleteOptions)
+		err := clientSet.CoreV1().Namespaces().Delete(ctx, namespace.Name, deleteOptions)
 		if err != nil {
 			return err
 		}
+		utils.ReportDeleted(ctx, namespaceType, "", namespace.Name)
 	}
 
 	return nil
 }
 
-func DeleteExpiredNamespaces(clientSet *kubernetes.Clientset, tagName string, dryRun bool) error {
+func DeleteExpiredNamespaces(ctx context.Context, clientSet kubernetes.Interface, tagName string, dryRun bool) error {
 
-	namespaces, err := listTaggedNamespaces(clientSet, tagName)
+	namespaces, err := listTaggedNamespaces(ctx, clientSet, tagName)
 	if err != nil {
 		return fmt.Errorf("can't list kubernetes namespaces: %s\n", err)
 	}
 
+	var expiredNamespaces []kubernetesNamespace
 	for _, namespace := range namespaces {
-		if utils.CheckIfExpired(namespace.NamespaceCreateTime, namespace.TTL) {
-			err := deleteNamespace(clientSet, namespace, dryRun)
-			if err != nil {
-				log.Errorf("error while trying to delete namespace: %s", err)
-			}
+		if utils.CheckIfDeletable(ctx, namespace.NamespaceCreateTime, namespace.TTL, namespace.ExpirationDate, namespace.DeletionScheduled, namespace.IsProtected, namespaceType, "", namespace.Name) {
+			utils.SetReportTags(ctx, namespaceType, "", namespace.Name, namespace.Labels)
+			expiredNamespaces = append(expiredNamespaces, namespace)
+		}
+	}
+
+	if !dryRun {
+		limitErr := utils.ReserveDeletions(ctx, namespaceType, "", len(expiredNamespaces))
+		if limitErr != nil {
+			return limitErr
+		}
+	}
+
+	for _, namespace := range expiredNamespaces {
+		err := deleteNamespace(ctx, clientSet, namespace, dryRun)
+		if err != nil {
+			utils.ResourceLog(ctx, utils.ActionDeleteFailed, namespaceType, "", namespace.Name).Errorf("error while trying to delete namespace: %s", err)
+			utils.ReportDeletionError(ctx, namespaceType, "", namespace.Name, err)
 		}
 	}
 
@@ -1,50 +1,60 @@
 package k8s
 
 import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/cobra"
 	"k8s.io/client-go/kubernetes"
-	"sync"
-	"time"
 )
 
-
-func RunPlecoKubernetes(cmd *cobra.Command, interval int64, dryRun bool, wg *sync.WaitGroup) {
-	wg.Add(1)
-	go runPlecoOnKube(cmd, interval, dryRun, wg)
+// Config configures the Kubernetes checks
+type Config struct {
+	// Connection is the connection method: in (in cluster service account), out (KUBECONFIG) or off
+	Connection string
+	TagName    string
 }
 
-func runPlecoOnKube(cmd *cobra.Command, interval int64, dryRun bool, wg *sync.WaitGroup) {
-	defer wg.Done()
+// Cleaner checks the namespaces of a cluster
+type Cleaner struct {
+	clientSet *kubernetes.Clientset
+	tagName   string
+}
 
-	// Kubernetes connection
+// NewCleaner connects to the cluster, it returns nil if the connection is off
+func NewCleaner(config Config) (*Cleaner, error) {
 	var k8sClientSet *kubernetes.Clientset
 	var err error
-	kubernetesEnabled := true
-	KubernetesConn, _ := cmd.Flags().GetString("kube-conn")
-	tagName, _ := cmd.Flags().GetString("tag-name")
 
-	switch KubernetesConn {
+	switch config.Connection {
 	case "in":
 		k8sClientSet, err = AuthenticateInCluster()
 	case "out":
 		k8sClientSet, err = AuthenticateOutOfCluster()
 	default:
-		kubernetesEnabled = false
+		return nil, nil
 	}
 	if err != nil {
-		logrus.Errorf("failed to authenticate on kubernetes with %s connection: %v", KubernetesConn, err)
+		return nil, fmt.Errorf("failed to authenticate on kubernetes with %s connection: %v", config.Connection, err)
+	}
+
+	return &Cleaner{clientSet: k8sClientSet, tagName: config.TagName}, nil
+}
+
+func (c *Cleaner) Name() string {
+	return "Kubernetes"
+}
+
+func (c *Cleaner) Check(ctx context.Context, dryRun bool) []error {
+	if !dryRun && utils.HasGracePeriod(ctx) {
+		ctx = utils.WithDeletionScheduler(ctx, NewDeletionScheduler(c.clientSet))
 	}
 
-	// check Kubernetes
-	for {
-		if kubernetesEnabled {
-			err := DeleteExpiredNamespaces(k8sClientSet, tagName, dryRun)
-			if err != nil {
-				logrus.Error(err)
-			}
-		}
-		time.Sleep(time.Duration(interval) * time.Second)
+	err := DeleteExpiredNamespaces(ctx, c.clientSet, c.tagName, dryRun)
+	if err != nil {
+		logrus.Error(err)
+		return []error{err}
 	}
 
-}
\ No newline at end of file
+	return nil
+}
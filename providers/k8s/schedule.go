@@ -0,0 +1,61 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"time"
+)
+
+// DeletionScheduler annotates expired namespaces with their deletion date, label values can't hold a date
+type DeletionScheduler struct {
+	clientSet kubernetes.Interface
+}
+
+func NewDeletionScheduler(clientSet kubernetes.Interface) *DeletionScheduler {
+	return &DeletionScheduler{clientSet: clientSet}
+}
+
+func (s *DeletionScheduler) ScheduleDeletion(ctx context.Context, resourceType string, identifiers []string, deletionDate time.Time) error {
+	if resourceType != namespaceType || len(identifiers) == 0 {
+		return fmt.Errorf("can't schedule the deletion of %s %v", resourceType, identifiers)
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				utils.DeletionScheduledTagName: deletionDate.Format(time.RFC3339),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.clientSet.CoreV1().Namespaces().Patch(ctx, identifiers[0], types.MergePatchType, patch, metav1.PatchOptions{})
+
+	return err
+}
+
+// GetOwner reads the owner from the namespace annotations first, as label values can't hold an email address
+func (s *DeletionScheduler) GetOwner(ctx context.Context, resourceType string, identifiers []string) (string, error) {
+	if resourceType != namespaceType || len(identifiers) == 0 {
+		return "", fmt.Errorf("can't get the owner of %s %v", resourceType, identifiers)
+	}
+
+	namespace, err := s.clientSet.CoreV1().Namespaces().Get(ctx, identifiers[0], metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+
+	owner := utils.GetOwnerFromTags(namespace.ObjectMeta.Annotations)
+	if owner == "" {
+		owner = utils.GetOwnerFromTags(namespace.ObjectMeta.Labels)
+	}
+
+	return owner, nil
+}
@@ -0,0 +1,113 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	aws2 "github.com/Qovery/pleco/providers/aws"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	log "github.com/sirupsen/logrus"
+	"io/ioutil"
+	"strings"
+)
+
+// identifierAttributes are the resources attributes holding their names, ids or ARNs, as given to the cleaners
+var identifierAttributes = []string{"id", "arn", "name", "bucket", "key_id", "identifier", "cluster_identifier", "cluster_id"}
+
+type state struct {
+	Version int `json:"version"`
+	// Terraform >= 0.12
+	Resources []struct {
+		Instances []struct {
+			Attributes map[string]interface{} `json:"attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+	// Terraform < 0.12
+	Modules []struct {
+		Resources map[string]struct {
+			Primary struct {
+				Attributes map[string]interface{} `json:"attributes"`
+			} `json:"primary"`
+		} `json:"resources"`
+	} `json:"modules"`
+}
+
+// LoadManagedIds returns the names, ids and ARNs of the resources found in the Terraform states. Sources are local
+// paths or s3://bucket/key URLs.
+func LoadManagedIds(ctx context.Context, sources []string) (map[string]bool, error) {
+	managedIds := make(map[string]bool)
+
+	for _, source := range sources {
+		content, err := readState(ctx, source)
+		if err != nil {
+			return nil, fmt.Errorf("can't read Terraform state %s: %s", source, err)
+		}
+
+		var currentState state
+		err = json.Unmarshal(content, &currentState)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse Terraform state %s: %s", source, err)
+		}
+
+		count := len(managedIds)
+		for _, resource := range currentState.Resources {
+			for _, instance := range resource.Instances {
+				addIdentifiers(managedIds, instance.Attributes)
+			}
+		}
+		for _, module := range currentState.Modules {
+			for _, resource := range module.Resources {
+				addIdentifiers(managedIds, resource.Primary.Attributes)
+			}
+		}
+
+		log.Debugf("Found %d resources identifiers in Terraform state %s.", len(managedIds)-count, source)
+	}
+
+	return managedIds, nil
+}
+
+func addIdentifiers(managedIds map[string]bool, attributes map[string]interface{}) {
+	for _, attribute := range identifierAttributes {
+		value, ok := attributes[attribute].(string)
+		if ok && value != "" {
+			managedIds[value] = true
+		}
+	}
+}
+
+func readState(ctx context.Context, source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "s3://") {
+		return ioutil.ReadFile(source)
+	}
+
+	location := strings.SplitN(strings.TrimPrefix(source, "s3://"), "/", 2)
+	if len(location) != 2 || location[0] == "" || location[1] == "" {
+		return nil, fmt.Errorf("expected s3://bucket/key")
+	}
+	bucket, key := location[0], location[1]
+
+	sess, err := aws2.CreateSessionWithoutRegion()
+	if err != nil {
+		return nil, err
+	}
+
+	region, err := s3manager.GetBucketRegion(ctx, sess, bucket, aws2.DefaultRegion())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s3.New(sess, &aws.Config{Region: aws.String(region)}).GetObjectWithContext(ctx,
+		&s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+	if err != nil {
+		return nil, err
+	}
+	defer result.Body.Close()
+
+	return ioutil.ReadAll(result.Body)
+}
@@ -0,0 +1,92 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// failingAfter is the number of failed deletions in a row after which a deletion keeps failing
+const failingAfter = 2
+
+// Diff is what changed during a check, compared to the previous ones
+type Diff struct {
+	// New are the resources seen for the first time, newly tagged with a ttl or an expiration date
+	New []Resource
+	// Expired are the resources found expired for the first time
+	Expired []Resource
+	// Deleted are the resources deleted by the check
+	Deleted []Resource
+	// FailingDeletions are the resources whose deletion failed during the check and the previous ones
+	FailingDeletions []Resource
+}
+
+// GetDiff returns the changes of the resources of these types since the start of a check
+func (s *Store) GetDiff(since time.Time, resourceTypes map[string]bool) Diff {
+	s.Lock()
+	defer s.Unlock()
+
+	var diff Diff
+	for _, resource := range s.getResources() {
+		if !resourceTypes[resource.Type] {
+			continue
+		}
+
+		if !resource.FirstSeen.Before(since) {
+			diff.New = append(diff.New, resource)
+		}
+		if !resource.ExpiredAt.IsZero() && !resource.ExpiredAt.Before(since) {
+			diff.Expired = append(diff.Expired, resource)
+		}
+		if resource.DeletedAt.Before(since) {
+			continue
+		}
+		if resource.LastDeletionError == "" {
+			diff.Deleted = append(diff.Deleted, resource)
+		} else if resource.FailedDeletions >= failingAfter {
+			diff.FailingDeletions = append(diff.FailingDeletions, resource)
+		}
+	}
+
+	return diff
+}
+
+// LogDiff is a report handler logging what changed during a check compared to the previous ones, from the state
+// recorded by the check
+func (s *Store) LogDiff(ctx context.Context, source string, report *utils.Report) {
+	resourceTypes := make(map[string]bool)
+	for _, row := range report.Summary() {
+		resourceTypes[row.Cleaner] = true
+	}
+
+	diff := s.GetDiff(report.StartedAt.UTC(), resourceTypes)
+	logChanges(source, "new", diff.New, func(resource Resource) string {
+		return "first seen"
+	})
+	logChanges(source, "expired", diff.Expired, func(resource Resource) string {
+		return "expired since the previous check"
+	})
+	logChanges(source, "deleted", diff.Deleted, func(resource Resource) string {
+		return "deleted"
+	})
+	logChanges(source, "failing_deletion", diff.FailingDeletions, func(resource Resource) string {
+		return fmt.Sprintf("deletion failed %d times in a row: %s", resource.FailedDeletions, resource.LastDeletionError)
+	})
+
+	log.Infof("%s check diff: %d new, %d newly expired, %d deleted, %d failing deletions.", source,
+		len(diff.New), len(diff.Expired), len(diff.Deleted), len(diff.FailingDeletions))
+}
+
+func logChanges(source string, change string, resources []Resource, describe func(resource Resource) string) {
+	for _, resource := range resources {
+		log.WithFields(log.Fields{
+			"source":        source,
+			"change":        change,
+			"resource_type": resource.Type,
+			"resource_id":   resource.Id,
+			"region":        resource.Region,
+		}).Infof("%s check diff: %s %s in %s %s.", source, resource.Type, resource.Id, resource.Region, describe(resource))
+	}
+}
@@ -0,0 +1,103 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	aws2 "github.com/Qovery/pleco/providers/aws"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"strings"
+	"time"
+)
+
+// dynamoDBBackend keeps the state in a DynamoDB table with a key string partition key, an item per resource. Items
+// have the expires_at epoch attribute so the table time to live removes the resources not seen, nor protected, anymore.
+type dynamoDBBackend struct {
+	client *dynamodb.DynamoDB
+	table  string
+}
+
+// dynamoDBItem is a resource with the expiration of its item
+type dynamoDBItem struct {
+	Resource
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// maxBatchWrite is the maximum number of items of a DynamoDB batch write
+const maxBatchWrite = 25
+
+func newDynamoDBBackend(location string) (*dynamoDBBackend, error) {
+	parts := strings.SplitN(location, "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("expected dynamodb://region/table, got dynamodb://%s", location)
+	}
+
+	sess, err := aws2.CreateSession(parts[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return &dynamoDBBackend{client: dynamodb.New(sess), table: parts[1]}, nil
+}
+
+func (b *dynamoDBBackend) Load(ctx context.Context) ([]Resource, error) {
+	var resources []Resource
+	var unmarshalErr error
+
+	err := b.client.ScanPagesWithContext(ctx, &dynamodb.ScanInput{TableName: aws.String(b.table)},
+		func(page *dynamodb.ScanOutput, lastPage bool) bool {
+			for _, item := range page.Items {
+				var resource Resource
+				unmarshalErr = dynamodbattribute.UnmarshalMap(item, &resource)
+				if unmarshalErr != nil {
+					return false
+				}
+				// the table time to live removes expired items within days
+				if !isExpired(resource) {
+					resources = append(resources, resource)
+				}
+			}
+			return true
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return resources, unmarshalErr
+}
+
+// Save writes the changed resources by batches
+func (b *dynamoDBBackend) Save(ctx context.Context, all []Resource, changed []Resource) error {
+	var requests []*dynamodb.WriteRequest
+	for _, resource := range changed {
+		item, err := dynamodbattribute.MarshalMap(dynamoDBItem{Resource: resource, ExpiresAt: getRetentionStart(resource).Add(Retention).Unix()})
+		if err != nil {
+			return err
+		}
+		requests = append(requests, &dynamodb.WriteRequest{PutRequest: &dynamodb.PutRequest{Item: item}})
+	}
+
+	for len(requests) > 0 {
+		batch := requests
+		if len(batch) > maxBatchWrite {
+			batch = batch[:maxBatchWrite]
+		}
+		requests = requests[len(batch):]
+
+		output, err := b.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{b.table: batch},
+		})
+		if err != nil {
+			return err
+		}
+
+		// throttled items are written again with the next batches
+		if unprocessed := output.UnprocessedItems[b.table]; len(unprocessed) > 0 {
+			requests = append(requests, unprocessed...)
+			time.Sleep(time.Second)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package state
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// fileBackend keeps the state in a local json file
+type fileBackend struct {
+	path string
+}
+
+func (b fileBackend) Load(ctx context.Context) ([]Resource, error) {
+	content, err := ioutil.ReadFile(b.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var resources []Resource
+	err = json.Unmarshal(content, &resources)
+
+	return resources, err
+}
+
+// Save rewrites the whole file, through a temporary file so a crash doesn't corrupt it
+func (b fileBackend) Save(ctx context.Context, all []Resource, changed []Resource) error {
+	content, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(filepath.Dir(b.path), filepath.Base(b.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = file.Write(content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), b.path)
+}
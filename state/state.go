@@ -0,0 +1,274 @@
+// Package state records when resources were first seen, tagged and deleted by the checks, in a local file or a
+// DynamoDB table, so it's kept across restarts.
+package state
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qovery/pleco/utils"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retention is how long resources not seen anymore are kept in the state
+const Retention = 30 * 24 * time.Hour
+
+// protectionType is the type of the state entries holding the temporary protections of the API
+const protectionType = "protection"
+
+// Resource is the state of a resource
+type Resource struct {
+	// Key is the resource type, region and id joined by slashes
+	Key       string    `json:"key"`
+	Type      string    `json:"type"`
+	Region    string    `json:"region,omitempty"`
+	Id        string    `json:"id"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Checks is the number of checks which saw the resource
+	Checks int `json:"checks"`
+	// ExpiredAt is the first check finding the resource expired
+	ExpiredAt time.Time `json:"expired_at,omitempty"`
+	TaggedAt  time.Time `json:"tagged_at,omitempty"`
+	// DeletedAt is the last deletion of the resource, a resource seen after it wasn't deleted
+	DeletedAt         time.Time `json:"deleted_at,omitempty"`
+	DeletionAttempts  int       `json:"deletion_attempts,omitempty"`
+	LastDeletionError string    `json:"last_deletion_error,omitempty"`
+	// SurvivedDeletions is the number of deletions in a row the resource was seen again after
+	SurvivedDeletions int `json:"survived_deletions,omitempty"`
+	// FailedDeletions is the number of deletions in a row which failed
+	FailedDeletions int `json:"failed_deletions,omitempty"`
+	// ProtectedUntil is the end of the temporary protection of a protection entry, its id is the protected identifier
+	ProtectedUntil time.Time `json:"protected_until,omitempty"`
+}
+
+// IsDeleted returns true if the resource wasn't seen since its deletion
+func (r Resource) IsDeleted() bool {
+	return !r.DeletedAt.IsZero() && !r.LastSeen.After(r.DeletedAt)
+}
+
+// Backend loads and saves the state
+type Backend interface {
+	Load(ctx context.Context) ([]Resource, error)
+	// Save writes the resources changed since the last save, all holds every resource of the state
+	Save(ctx context.Context, all []Resource, changed []Resource) error
+}
+
+// Store keeps the state of the resources in memory and saves it to its backend after every check
+type Store struct {
+	sync.Mutex
+	backend   Backend
+	resources map[string]*Resource
+	changed   map[string]bool
+}
+
+// NewStore loads the state from a local path or from a DynamoDB table with dynamodb://region/table
+func NewStore(ctx context.Context, destination string) (*Store, error) {
+	var backend Backend
+	var err error
+
+	if strings.HasPrefix(destination, "dynamodb://") {
+		backend, err = newDynamoDBBackend(strings.TrimPrefix(destination, "dynamodb://"))
+	} else {
+		backend = fileBackend{path: destination}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resources, err := backend.Load(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("can't load state from %s: %s", destination, err)
+	}
+
+	store := &Store{backend: backend, resources: make(map[string]*Resource), changed: make(map[string]bool)}
+	for i := range resources {
+		store.resources[resources[i].Key] = &resources[i]
+	}
+	log.Infof("Loaded the state of %d resources from %s.", len(resources), destination)
+
+	return store, nil
+}
+
+// isExpired returns true if the resource wasn't seen, or protected, during the retention
+func isExpired(resource Resource) bool {
+	return time.Since(getRetentionStart(resource)) > Retention
+}
+
+// getRetentionStart returns when the resource was last seen, or when its protection ends if it's later
+func getRetentionStart(resource Resource) time.Time {
+	if resource.ProtectedUntil.After(resource.LastSeen) {
+		return resource.ProtectedUntil
+	}
+
+	return resource.LastSeen
+}
+
+func getKey(resourceType string, region string, resourceId string) string {
+	return resourceType + "/" + region + "/" + resourceId
+}
+
+// Record updates the state of a resource with an event, it's the state recorder of the checks
+func (s *Store) Record(resourceType string, region string, resourceId string, event string, at time.Time, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	key := getKey(resourceType, region, resourceId)
+	resource, exists := s.resources[key]
+	if !exists {
+		resource = &Resource{Key: key, Type: resourceType, Region: region, Id: resourceId, FirstSeen: at}
+		s.resources[key] = resource
+	}
+	s.changed[key] = true
+
+	switch event {
+	case utils.EventSeen:
+		// seen again since its last deletion, or seen and not deleted by the previous check
+		if resource.IsDeleted() {
+			resource.SurvivedDeletions++
+		} else {
+			resource.SurvivedDeletions = 0
+		}
+		resource.LastSeen = at
+		resource.Checks++
+	case utils.EventExpired:
+		if resource.ExpiredAt.IsZero() {
+			resource.ExpiredAt = at
+		}
+	case utils.EventTagged:
+		resource.TaggedAt = at
+	case utils.EventDeleted:
+		// the failure of a deletion is recorded after it
+		if resource.LastDeletionError == "" {
+			resource.FailedDeletions = 0
+		}
+		resource.DeletedAt = at
+		resource.DeletionAttempts++
+		resource.LastDeletionError = ""
+	case utils.EventDeletionFailed:
+		resource.LastDeletionError = err.Error()
+		resource.FailedDeletions++
+	}
+}
+
+// Get returns the state of a resource, false if it was never seen
+func (s *Store) Get(resourceType string, region string, resourceId string) (Resource, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	resource, exists := s.resources[getKey(resourceType, region, resourceId)]
+	if !exists {
+		return Resource{}, false
+	}
+
+	return *resource, true
+}
+
+// SurvivedDeletions returns how many deletions in a row the resource survived and when the last one was, it's how the
+// checks find the stuck resources
+func (s *Store) SurvivedDeletions(resourceType string, region string, resourceId string) (int, time.Time) {
+	resource, exists := s.Get(resourceType, region, resourceId)
+	if !exists {
+		return 0, time.Time{}
+	}
+
+	return resource.SurvivedDeletions, resource.DeletedAt
+}
+
+// Resources returns the state of every resource sorted by key
+func (s *Store) Resources() []Resource {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.getResources()
+}
+
+func (s *Store) getResources() []Resource {
+	resources := make([]Resource, 0, len(s.resources))
+	for _, resource := range s.resources {
+		resources = append(resources, *resource)
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		return resources[i].Key < resources[j].Key
+	})
+
+	return resources
+}
+
+// Report is a report handler saving the state once a check is done, resources not seen during the retention are
+// removed
+func (s *Store) Report(ctx context.Context, source string, report *utils.Report) {
+	s.Lock()
+	defer s.Unlock()
+
+	for key, resource := range s.resources {
+		if isExpired(*resource) {
+			delete(s.resources, key)
+		}
+	}
+
+	err := s.save(ctx)
+	if err != nil {
+		log.Errorf("Can't save the state of the %s check: %s", source, err)
+	}
+}
+
+// save writes the resources changed since the last save to the backend
+func (s *Store) save(ctx context.Context) error {
+	var changed []Resource
+	for key := range s.changed {
+		if resource, exists := s.resources[key]; exists {
+			changed = append(changed, *resource)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+
+	err := s.backend.Save(ctx, s.getResources(), changed)
+	if err != nil {
+		return err
+	}
+
+	s.changed = make(map[string]bool)
+	return nil
+}
+
+// SaveProtection records the end of the temporary protection of the resource with this name, id or ARN and saves it
+// right away, so it outlives a restart. A protection ending now removes it.
+func (s *Store) SaveProtection(ctx context.Context, identifier string, until time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := time.Now().UTC()
+	key := getKey(protectionType, "", identifier)
+	resource, exists := s.resources[key]
+	if !exists {
+		resource = &Resource{Key: key, Type: protectionType, Id: identifier, FirstSeen: now}
+		s.resources[key] = resource
+	}
+	resource.LastSeen = now
+	resource.ProtectedUntil = until
+	s.changed[key] = true
+
+	return s.save(ctx)
+}
+
+// Protections returns the identifiers of the resources temporarily protected with the end of their protection
+func (s *Store) Protections() map[string]time.Time {
+	s.Lock()
+	defer s.Unlock()
+
+	protections := make(map[string]time.Time)
+	for _, resource := range s.resources {
+		if resource.Type == protectionType && time.Now().Before(resource.ProtectedUntil) {
+			protections[resource.Id] = resource.ProtectedUntil
+		}
+	}
+
+	return protections
+}
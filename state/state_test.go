@@ -0,0 +1,48 @@
+package state
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProtectionsSurviveRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pleco-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	destination := filepath.Join(dir, "state.json")
+	ctx := context.Background()
+
+	store, err := NewStore(ctx, destination)
+	if err != nil {
+		t.Fatalf("NewStore failed: %s", err)
+	}
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	for _, identifier := range []string{"vol-1", "vol-2"} {
+		err = store.SaveProtection(ctx, identifier, until)
+		if err != nil {
+			t.Fatalf("SaveProtection failed: %s", err)
+		}
+	}
+	// removing a protection ends it now
+	err = store.SaveProtection(ctx, "vol-2", time.Now().UTC())
+	if err != nil {
+		t.Fatalf("SaveProtection failed: %s", err)
+	}
+
+	restarted, err := NewStore(ctx, destination)
+	if err != nil {
+		t.Fatalf("NewStore failed: %s", err)
+	}
+	got := restarted.Protections()
+	want := map[string]time.Time{"vol-1": until}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Protections after restart = %v, want %v", got, want)
+	}
+}
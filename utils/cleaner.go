@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cleaner checks the expired resources of a provider
+type Cleaner interface {
+	// Name is the checked provider (ex: AWS), the source of the check reports
+	Name() string
+	// Check deletes the expired resources, or only reports them in dry run, it logs and returns the check errors
+	Check(ctx context.Context, dryRun bool) []error
+}
+
+// RunCleaners runs the checks of the cleaners on the schedule until the context is done, or a single check without
+// schedule. The cleaners of a check run concurrently, each with its own report. Reports of dry run checks are printed in
+// the report format, if set.
+func RunCleaners(ctx context.Context, cleaners []Cleaner, schedule CheckSchedule, dryRun bool, reportFormat string, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	source := getSource(cleaners)
+
+	nextCheck := time.Now()
+	if schedule != nil {
+		nextCheck = schedule.FirstCheck(nextCheck)
+	}
+
+	for {
+		if !WaitUntil(ctx, nextCheck) {
+			log.Infof("Stopping %s checks.", source)
+			return
+		}
+
+		checkDryRun := GetCheckDryRun(ctx, dryRun, source)
+		limitErr := checkDeletionsLimit(ctx, cleaners, checkDryRun)
+		checkCtx := WithDryRun(WithDeletionsBudget(ctx), checkDryRun)
+
+		var checks sync.WaitGroup
+		for _, cleaner := range cleaners {
+			checks.Add(1)
+			go func(cleaner Cleaner) {
+				defer checks.Done()
+				runCheck(checkCtx, cleaner, checkDryRun, reportFormat, limitErr)
+			}(cleaner)
+		}
+		checks.Wait()
+
+		if schedule == nil {
+			return
+		}
+
+		nextCheck = schedule.NextCheck(time.Now())
+	}
+}
+
+// runCheck runs a check of the cleaner and hands its report to the report handlers. The check is aborted if the
+// cleaners would exceed the deletions allowed per check, its report only holds the circuit breaker error then.
+func runCheck(ctx context.Context, cleaner Cleaner, dryRun bool, reportFormat string, limitErr error) {
+	checkCtx := ctx
+	report := NewCheckReport(ctx, dryRun)
+	if report != nil {
+		checkCtx = WithReport(checkCtx, report)
+	}
+
+	if limitErr != nil {
+		ReportCheckError(checkCtx, fmt.Errorf("%s check aborted: %s", cleaner.Name(), limitErr))
+	} else {
+		checkCtx, endCheck := StartSpan(checkCtx, "check", map[string]string{
+			"source":  cleaner.Name(),
+			"dry_run": strconv.FormatBool(dryRun),
+		})
+		errs := checkSafely(checkCtx, cleaner, dryRun)
+		for _, err := range errs {
+			ReportCheckError(checkCtx, err)
+		}
+		endCheck(JoinErrors(errs...))
+	}
+
+	CloseCheckReport(ctx, report, cleaner.Name(), reportFormat)
+}
+
+// checkDeletionsLimit counts the resources the cleaners would delete through a dry run, before any of them deletes
+// anything. It returns an error if they exceed the deletions allowed per check: the whole check has to be aborted, so
+// a tagging mistake doesn't delete part of the resources before the limit is reached.
+func checkDeletionsLimit(ctx context.Context, cleaners []Cleaner, dryRun bool) error {
+	maxDeletions := getDeletionPolicy(ctx).MaxDeletions
+	if dryRun || maxDeletions == 0 {
+		return nil
+	}
+
+	report := NewReport()
+	report.DryRun = true
+	countCtx := WithReport(WithDryRun(withoutStateTracking(ctx), true), report)
+
+	var checks sync.WaitGroup
+	for _, cleaner := range cleaners {
+		checks.Add(1)
+		go func(cleaner Cleaner) {
+			defer checks.Done()
+			errs := checkSafely(countCtx, cleaner, true)
+			if len(errs) > 0 {
+				log.Warnf("%s check failed while counting the resources to delete, they may exceed the count.", cleaner.Name())
+			}
+		}(cleaner)
+	}
+	checks.Wait()
+
+	count := len(report.Candidates())
+	if count <= maxDeletions {
+		return nil
+	}
+
+	log.WithField("alert", "circuit_breaker").Errorf("Circuit breaker: the %s check would delete %d resources, more than the %d deletions allowed per check, aborting it.",
+		getSource(cleaners), count, maxDeletions)
+	return fmt.Errorf("%d resources to delete exceed the %d deletions allowed per check", count, maxDeletions)
+}
+
+// withoutStateTracking returns a context whose checks neither record the events of the resources nor detect the stuck
+// ones: the counting pass would record every resource as seen twice per check otherwise
+func withoutStateTracking(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, stateRecorderKey{}, nil)
+	return context.WithValue(ctx, stuckDetectionKey{}, nil)
+}
+
+// getSource returns the names of the cleaners, the source of their checks in logs
+func getSource(cleaners []Cleaner) string {
+	names := make([]string, 0, len(cleaners))
+	for _, cleaner := range cleaners {
+		names = append(names, cleaner.Name())
+	}
+
+	return strings.Join(names, "/")
+}
+
+// checkSafely runs a check of the cleaner, a panic is turned into a check error so the checks of the other providers
+// and the next checks keep running
+func checkSafely(ctx context.Context, cleaner Cleaner, dryRun bool) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("%s check panicked: %v\n%s", cleaner.Name(), r, debug.Stack())
+			errs = append(errs, fmt.Errorf("%s check panicked: %v", cleaner.Name(), r))
+		}
+	}()
+
+	return cleaner.Check(ctx, dryRun)
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeCleaner finds an expired resource on every check
+type fakeCleaner struct{}
+
+func (c fakeCleaner) Name() string {
+	return "fake"
+}
+
+func (c fakeCleaner) Check(ctx context.Context, dryRun bool) []error {
+	if CheckIfDeletable(ctx, time.Now().Add(-2*time.Hour), 3600, time.Time{}, time.Time{}, false, "volume", "eu-west-3", "vol-1") && !dryRun {
+		ReportDeleted(ctx, "volume", "eu-west-3", "vol-1")
+	}
+
+	return nil
+}
+
+func TestRunCleanersRecordsEachResourceOnce(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxDeletions int
+	}{
+		{name: "without deletions limit", maxDeletions: 0},
+		{name: "with deletions limit", maxDeletions: 10},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events := make(map[string]int)
+			var lock sync.Mutex
+			recorder := func(resourceType string, region string, resourceId string, event string, at time.Time, err error) {
+				lock.Lock()
+				defer lock.Unlock()
+				events[event]++
+			}
+			var survivedCalls int
+			survivedDeletions := func(resourceType string, region string, resourceId string) (int, time.Time) {
+				lock.Lock()
+				defer lock.Unlock()
+				survivedCalls++
+				return 0, time.Time{}
+			}
+
+			ctx := WithDeletionPolicy(context.Background(), DeletionPolicy{MaxDeletions: test.maxDeletions})
+			ctx = WithStuckDetection(WithStateRecorder(ctx, recorder), survivedDeletions, 3)
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			RunCleaners(ctx, []Cleaner{fakeCleaner{}}, nil, false, "", &wg)
+
+			if events[EventSeen] != 1 || events[EventDeleted] != 1 {
+				t.Errorf("recorded events %v, want the resource seen and deleted once", events)
+			}
+			if survivedCalls != 1 {
+				t.Errorf("checked if the resource is stuck %d times, want 1", survivedCalls)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+type clockKey struct{}
+
+// WithClock returns a context whose cleaners check the expiration of resources at the time returned by now instead of
+// the current time, so tests can advance the time instead of waiting for ttls
+func WithClock(ctx context.Context, now func() time.Time) context.Context {
+	return context.WithValue(ctx, clockKey{}, now)
+}
+
+// Now returns the time of the clock of the context, the current time if it has none
+func Now(ctx context.Context) time.Time {
+	if now, hasClock := ctx.Value(clockKey{}).(func() time.Time); hasClock {
+		return now()
+	}
+
+	return time.Now()
+}
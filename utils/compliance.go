@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// TagViolation is a resource missing required tags (ex: owner, cost-center, ttl), only reported
+type TagViolation struct {
+	Type        string   `json:"type"`
+	Name        string   `json:"name"`
+	Arn         string   `json:"arn,omitempty"`
+	Region      string   `json:"region,omitempty"`
+	MissingTags []string `json:"missing_tags"`
+}
+
+// TagViolationCount is the number of resources of a type in a region missing a required tag
+type TagViolationCount struct {
+	Type   string `json:"type"`
+	Region string `json:"region,omitempty"`
+	Tag    string `json:"tag"`
+	Count  int    `json:"count"`
+}
+
+// GetMissingTags returns the required tags the resource doesn't carry, tag keys are matched case insensitively. An
+// expiration date tag stands for the ttl tag, both make the resource expire.
+func GetMissingTags(tags map[string]*string, requiredTags []string, tagName string) []string {
+	var missingTags []string
+	for _, requiredTag := range requiredTags {
+		hasTag := false
+		for key, value := range tags {
+			if value == nil || *value == "" {
+				continue
+			}
+			if strings.EqualFold(key, requiredTag) || (requiredTag == tagName && key == ExpirationDateTagName) {
+				hasTag = true
+				break
+			}
+		}
+
+		if !hasTag {
+			missingTags = append(missingTags, requiredTag)
+		}
+	}
+
+	return missingTags
+}
+
+// ReportTagViolation logs a resource missing required tags and records it in the report of the context, if any
+func ReportTagViolation(ctx context.Context, violation TagViolation) {
+	ResourceLog(ctx, ActionNonCompliant, violation.Type, violation.Region, violation.Name).Debugf("%s is missing the %s tags.",
+		describeResource(violation.Type, violation.Region, []string{violation.Name}), strings.Join(violation.MissingTags, ", "))
+
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.violations = append(report.violations, violation)
+}
+
+// SetTagsChecked records in the report of the context, if any, that the tags of the resources were checked
+func SetTagsChecked(ctx context.Context) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.tagsChecked = true
+}
+
+// TagViolations returns the resources missing required tags sorted by region, type and name
+func (r *Report) TagViolations() []TagViolation {
+	r.Lock()
+	defer r.Unlock()
+
+	violations := make([]TagViolation, len(r.violations))
+	copy(violations, r.violations)
+
+	sort.Slice(violations, func(i, j int) bool {
+		if violations[i].Region != violations[j].Region {
+			return violations[i].Region < violations[j].Region
+		}
+		if violations[i].Type != violations[j].Type {
+			return violations[i].Type < violations[j].Type
+		}
+		return violations[i].Name < violations[j].Name
+	})
+
+	return violations
+}
+
+// CountTagViolations returns the number of resources missing each required tag by region and type
+func CountTagViolations(violations []TagViolation) []TagViolationCount {
+	counts := make(map[TagViolationCount]int)
+	for _, violation := range violations {
+		for _, tag := range violation.MissingTags {
+			counts[TagViolationCount{Type: violation.Type, Region: violation.Region, Tag: tag}]++
+		}
+	}
+
+	var violationCounts []TagViolationCount
+	for count, value := range counts {
+		count.Count = value
+		violationCounts = append(violationCounts, count)
+	}
+
+	sort.Slice(violationCounts, func(i, j int) bool {
+		if violationCounts[i].Region != violationCounts[j].Region {
+			return violationCounts[i].Region < violationCounts[j].Region
+		}
+		if violationCounts[i].Type != violationCounts[j].Type {
+			return violationCounts[i].Type < violationCounts[j].Type
+		}
+		return violationCounts[i].Tag < violationCounts[j].Tag
+	})
+
+	return violationCounts
+}
+
+// WriteTagViolations writes the resources missing required tags as csv, one line per resource, or as json with the
+// counts by region and type
+func WriteTagViolations(w io.Writer, violations []TagViolation, format string) error {
+	switch format {
+	case "csv":
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"region", "type", "name", "arn", "missing_tags"})
+		for _, violation := range violations {
+			_ = writer.Write([]string{violation.Region, violation.Type, violation.Name, violation.Arn, strings.Join(violation.MissingTags, " ")})
+		}
+		writer.Flush()
+		return writer.Error()
+	case "json":
+		if violations == nil {
+			violations = []TagViolation{}
+		}
+		counts := CountTagViolations(violations)
+		if counts == nil {
+			counts = []TagViolationCount{}
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"counts":    counts,
+			"resources": violations,
+		})
+	default:
+		return fmt.Errorf("unknown compliance report format %s, choose between csv/json", format)
+	}
+}
+
+// GetComplianceReportFormat returns the format of a compliance report file from its extension, json by default
+func GetComplianceReportFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return "csv"
+	}
+
+	return "json"
+}
+
+// NewComplianceReportHandler returns a report handler logging the number of resources missing each required tag by
+// region and type, and replacing the file, if any, with the resources found by each check. Reports of checks without
+// compliance checks are ignored.
+func NewComplianceReportHandler(path string) ReportHandler {
+	return func(ctx context.Context, source string, report *Report) {
+		report.Lock()
+		tagsChecked := report.tagsChecked
+		report.Unlock()
+		if !tagsChecked {
+			return
+		}
+
+		violations := report.TagViolations()
+		for _, count := range CountTagViolations(violations) {
+			log.Infof("%d %s resources of %s are missing the %s tag.", count.Count, count.Type, orDash(count.Region), count.Tag)
+		}
+		if path == "" {
+			return
+		}
+
+		// the file is replaced at once so readers never see a partial report
+		file, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".*")
+		if err != nil {
+			log.Errorf("Can't write the compliance report %s: %s", path, err)
+			return
+		}
+		defer os.Remove(file.Name())
+
+		err = WriteTagViolations(file, violations, GetComplianceReportFormat(path))
+		closeErr := file.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(file.Name(), path)
+		}
+		if err != nil {
+			log.Errorf("Can't write the compliance report %s: %s", path, err)
+			return
+		}
+
+		log.Infof("Wrote %d resources missing required tags to %s.", len(violations), path)
+	}
+}
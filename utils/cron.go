@@ -0,0 +1,227 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CheckSchedule tells when the checks of a daemon run
+type CheckSchedule interface {
+	// FirstCheck returns when the first check runs, now being the start of pleco
+	FirstCheck(now time.Time) time.Time
+	// NextCheck returns when the next check runs, now being the end of the previous one
+	NextCheck(now time.Time) time.Time
+}
+
+// IntervalSchedule runs a check at start, then waits the interval after the end of each check
+type IntervalSchedule time.Duration
+
+func (s IntervalSchedule) FirstCheck(now time.Time) time.Time {
+	return now
+}
+
+func (s IntervalSchedule) NextCheck(now time.Time) time.Time {
+	return now.Add(time.Duration(s))
+}
+
+// CronSchedule runs the checks at the times matching a standard 5 fields cron expression (minute, hour, day of month,
+// month, day of week), in the local time zone. Checks running late skip the times they overlap.
+type CronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	// days match the day of month or the day of week when both are restricted, like cron does
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+type cronField struct {
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronMinutes     = cronField{min: 0, max: 59}
+	cronHours       = cronField{min: 0, max: 23}
+	cronDaysOfMonth = cronField{min: 1, max: 31}
+	cronMonths      = cronField{min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6, "jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is sunday too
+	cronDaysOfWeek = cronField{min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// ParseCronSchedule parses a cron expression like "0 */2 * * *" or a descriptor like "@daily"
+func ParseCronSchedule(expression string) (*CronSchedule, error) {
+	if descriptor, ok := cronDescriptors[strings.TrimSpace(expression)]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q, expected 5 fields: minute hour day-of-month month day-of-week", expression)
+	}
+
+	var schedule CronSchedule
+	var err error
+	for i, parse := range []struct {
+		field cronField
+		bits  *uint64
+	}{
+		{cronMinutes, &schedule.minutes},
+		{cronHours, &schedule.hours},
+		{cronDaysOfMonth, &schedule.daysOfMonth},
+		{cronMonths, &schedule.months},
+		{cronDaysOfWeek, &schedule.daysOfWeek},
+	} {
+		*parse.bits, err = parse.field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %s", expression, err)
+		}
+	}
+
+	// sunday can be written 7
+	if schedule.daysOfWeek&(1<<7) != 0 {
+		schedule.daysOfWeek |= 1
+	}
+	schedule.anyDayOfMonth = fields[2] == "*" || strings.HasPrefix(fields[2], "*/")
+	schedule.anyDayOfWeek = fields[4] == "*" || strings.HasPrefix(fields[4], "*/")
+
+	if schedule.next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", expression)
+	}
+
+	return &schedule, nil
+}
+
+// parse returns the bits of the values of a list of values, ranges and steps (ex: 1,5-10,*/15)
+func (f cronField) parse(value string) (uint64, error) {
+	var bits uint64
+
+	for _, part := range strings.Split(value, ",") {
+		step := 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s", part)
+			}
+			part = part[:slash]
+		}
+
+		start, end := f.min, f.max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			start, err = f.parseValue(bounds[0])
+			if err != nil {
+				return 0, err
+			}
+
+			end = start
+			if len(bounds) == 2 {
+				end, err = f.parseValue(bounds[1])
+				if err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				// 5/15 is 5-max/15
+				end = f.max
+			}
+
+			if end < start {
+				return 0, fmt.Errorf("invalid range %s", part)
+			}
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (f cronField) parseValue(value string) (int, error) {
+	if number, ok := f.names[strings.ToLower(value)]; ok {
+		return number, nil
+	}
+
+	number, err := strconv.Atoi(value)
+	if err != nil || number < f.min || number > f.max {
+		return 0, fmt.Errorf("invalid value %s, expected %d-%d", value, f.min, f.max)
+	}
+
+	return number, nil
+}
+
+func (s *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+
+	return dayOfMonth || dayOfWeek
+}
+
+// next returns the first matching minute after now, zero if there is none within 5 years
+func (s *CronSchedule) next(now time.Time) time.Time {
+	t := now.Truncate(time.Minute).Add(time.Minute)
+	limit := now.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		switch {
+		case s.months&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+		case s.hours&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+		case s.minutes&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+func (s *CronSchedule) FirstCheck(now time.Time) time.Time {
+	return s.next(now)
+}
+
+func (s *CronSchedule) NextCheck(now time.Time) time.Time {
+	return s.next(now)
+}
+
+// WaitUntil returns true once the time is reached, false if the context is done before
+func WaitUntil(ctx context.Context, t time.Time) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(time.Until(t)):
+		return ctx.Err() == nil
+	}
+}
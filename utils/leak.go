@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// Leak is a resource without ttl showing orphan signals (ex: a volume detached for days)
+type Leak struct {
+	// Category is the kind of orphan, deletions are enabled by category
+	Category     string    `json:"category"`
+	Type         string    `json:"type"`
+	Name         string    `json:"name"`
+	Region       string    `json:"region,omitempty"`
+	Reason       string    `json:"reason"`
+	CreationDate time.Time `json:"creation_date,omitempty"`
+}
+
+// ReportLeak logs an orphaned resource and records it in the report of the context, if any
+func ReportLeak(ctx context.Context, leak Leak) {
+	ResourceLog(ctx, ActionLeak, leak.Type, leak.Region, leak.Name).Infof("Leaked %s: %s.",
+		describeResource(leak.Type, leak.Region, []string{leak.Name}), leak.Reason)
+
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.leaks = append(report.leaks, leak)
+}
+
+// CheckIfLeakDeletable returns true if an orphaned resource whose category is deleted isn't kept by the deletion
+// policy, the resource is then reported like an expired one
+func CheckIfLeakDeletable(ctx context.Context, creationTime time.Time, isProtected bool, resourceType string, region string, identifiers ...string) bool {
+	if isKeptByPolicy(ctx, creationTime, isProtected, "leaked", resourceType, region, identifiers) {
+		return false
+	}
+
+	resource := describeResource(resourceType, region, identifiers)
+	ResourceLog(ctx, ActionExpire, resourceType, region, getResourceId(identifiers)).Infof("Leaked %s is deletable.", resource)
+	// leaks expire once detected
+	reportCandidate(ctx, creationTime, 0, Now(ctx).UTC().Truncate(time.Second), resourceType, region, identifiers)
+
+	return true
+}
+
+// Leaks returns the orphaned resources sorted by region, type and name
+func (r *Report) Leaks() []Leak {
+	r.Lock()
+	defer r.Unlock()
+
+	leaks := make([]Leak, len(r.leaks))
+	copy(leaks, r.leaks)
+
+	sort.Slice(leaks, func(i, j int) bool {
+		if leaks[i].Region != leaks[j].Region {
+			return leaks[i].Region < leaks[j].Region
+		}
+		if leaks[i].Type != leaks[j].Type {
+			return leaks[i].Type < leaks[j].Type
+		}
+		return leaks[i].Name < leaks[j].Name
+	})
+
+	return leaks
+}
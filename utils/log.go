@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions of the cleaners on resources, set as the action field of their logs
+const (
+	// ActionSkip is an expired resource kept by the deletion policy
+	ActionSkip = "skip"
+	// ActionSchedule is an expired resource tagged with its deletion date
+	ActionSchedule = "schedule"
+	// ActionQuarantine is an expired resource stopped until its scheduled deletion
+	ActionQuarantine = "quarantine"
+	// ActionNotifyOwner is the owner of a resource warned of its coming deletion
+	ActionNotifyOwner = "notify_owner"
+	// ActionExpire is an expired resource the cleaner deletes, unless it's a dry run
+	ActionExpire = "expire"
+	// ActionDelete is the deletion of a resource
+	ActionDelete = "delete"
+	// ActionDeleteFailed is the failed deletion of a resource
+	ActionDeleteFailed = "delete_failed"
+	// ActionStuck is a resource still there after its deletion by previous checks
+	ActionStuck = "stuck"
+	// ActionLeak is a resource without ttl showing orphan signals
+	ActionLeak = "leak"
+	// ActionNonCompliant is a resource missing required tags
+	ActionNonCompliant = "non_compliant"
+	// ActionQuota is a service quota limiting a resource type nearly reached
+	ActionQuota = "quota"
+)
+
+type dryRunKey struct{}
+
+// WithDryRun returns a context telling the resource logs whether the check is a dry run
+func WithDryRun(ctx context.Context, dryRun bool) context.Context {
+	return context.WithValue(ctx, dryRunKey{}, dryRun)
+}
+
+// ResourceLog returns a log entry with the fields identifying the action of a cleaner on a resource, the same for every
+// cleaner so json logs can be indexed. The action is also an event of the span of the cleaner, when traced.
+func ResourceLog(ctx context.Context, action string, resourceType string, region string, resourceId string) *log.Entry {
+	dryRun, _ := ctx.Value(dryRunKey{}).(bool)
+	addSpanEvent(ctx, action, map[string]string{
+		"resource_type": resourceType,
+		"resource_id":   resourceId,
+		"region":        region,
+	})
+
+	return log.WithFields(log.Fields{
+		"resource_type": resourceType,
+		"resource_id":   resourceId,
+		"region":        region,
+		"action":        action,
+		"dry_run":       dryRun,
+	})
+}
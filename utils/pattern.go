@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// globPrefix marks the patterns which are globs instead of regexes
+const globPrefix = "glob:"
+
+// clusterPlaceholder is replaced by the cluster name in the cluster tag patterns
+const clusterPlaceholder = "{cluster}"
+
+// TagMatcher matches a tag by key and value patterns, matched independently, a missing pattern matches anything.
+// Without patterns, it matches the tags whose key or value contains Contains.
+type TagMatcher struct {
+	KeyPattern   *regexp.Regexp
+	ValuePattern *regexp.Regexp
+	Contains     string
+}
+
+// Match returns true if the tag matches
+func (m TagMatcher) Match(key string, value string) bool {
+	if m.KeyPattern == nil && m.ValuePattern == nil {
+		return strings.Contains(key, m.Contains) || strings.Contains(value, m.Contains)
+	}
+
+	return (m.KeyPattern == nil || m.KeyPattern.MatchString(key)) && (m.ValuePattern == nil || m.ValuePattern.MatchString(value))
+}
+
+// ClusterTagPatterns are the key and value patterns of the tags of the resources of a cluster (ex: its load
+// balancers), {cluster} is replaced by the cluster name. Patterns are regexes, or globs matching the whole key or
+// value when prefixed with glob: (ex: glob:kubernetes.io/cluster/{cluster}).
+type ClusterTagPatterns struct {
+	Key   string
+	Value string
+}
+
+// Validate returns an error if a pattern doesn't compile
+func (p ClusterTagPatterns) Validate() error {
+	_, err := p.ForCluster("cluster")
+	return err
+}
+
+// ForCluster returns the matcher of the tags of the resources of the cluster, without patterns it matches the tags
+// whose key or value contains the cluster name
+func (p ClusterTagPatterns) ForCluster(clusterName string) (TagMatcher, error) {
+	matcher := TagMatcher{Contains: clusterName}
+
+	var err error
+	if p.Key != "" {
+		matcher.KeyPattern, err = compilePattern(p.Key, clusterName)
+		if err != nil {
+			return TagMatcher{}, fmt.Errorf("invalid tag key pattern %s: %s", p.Key, err)
+		}
+	}
+	if p.Value != "" {
+		matcher.ValuePattern, err = compilePattern(p.Value, clusterName)
+		if err != nil {
+			return TagMatcher{}, fmt.Errorf("invalid tag value pattern %s: %s", p.Value, err)
+		}
+	}
+
+	return matcher, nil
+}
+
+// compilePattern compiles a regex or a glob, where the placeholder is replaced by the literal cluster name
+func compilePattern(pattern string, clusterName string) (*regexp.Regexp, error) {
+	if !strings.HasPrefix(pattern, globPrefix) {
+		return regexp.Compile(strings.ReplaceAll(pattern, clusterPlaceholder, regexp.QuoteMeta(clusterName)))
+	}
+
+	var parts []string
+	for _, part := range strings.Split(strings.TrimPrefix(pattern, globPrefix), clusterPlaceholder) {
+		parts = append(parts, globToRegex(part))
+	}
+
+	return regexp.Compile("^" + strings.Join(parts, regexp.QuoteMeta(clusterName)) + "$")
+}
+
+// globToRegex converts the * and ? wildcards of a glob, other characters are literal
+func globToRegex(glob string) string {
+	var regex strings.Builder
+	for _, char := range glob {
+		switch char {
+		case '*':
+			regex.WriteString(".*")
+		case '?':
+			regex.WriteString(".")
+		default:
+			regex.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+
+	return regex.String()
+}
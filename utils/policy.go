@@ -0,0 +1,265 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DeletionPolicy holds the rules checked by every cleaner before tagging or deleting a resource
+type DeletionPolicy struct {
+	// Exclusions are matched against resources names, ids and ARNs, matching resources are never tagged nor deleted
+	Exclusions []*regexp.Regexp
+	// MinAge protects resources created recently, whatever their ttl, so a mistagged resource isn't deleted while
+	// it's still being provisioned
+	MinAge time.Duration
+	// MaxDeletions limits the number of resources deleted during a check, 0 for no limit
+	MaxDeletions int
+	// MaxDeletionsPerType limits the number of resources of a type deleted at once in a region, 0 for no limit
+	MaxDeletionsPerType int
+	// GracePeriod is the time between the moment an expired resource is tagged for deletion and its deletion, 0 to
+	// delete expired resources right away
+	GracePeriod time.Duration
+	// QuarantinePeriod is the time between the moment an expired resource which can be stopped (ex: a database) is
+	// stopped and its deletion, it replaces the grace period of these resources. 0 to not stop them.
+	QuarantinePeriod time.Duration
+	// DeletionWindows are the times deletions are allowed, checks outside of them only report the expired resources.
+	// Deletions are always allowed without windows.
+	DeletionWindows []DeletionWindow
+	// ProductionMarkers are tags marking production resources, never deleted even once expired
+	ProductionMarkers []ProductionMarker
+	// UntaggedRules make the resources without ttl nor expiration date matching them expire after a maximum age
+	UntaggedRules []UntaggedRule
+}
+
+// deletionsBudget counts the deletions left during a check, shared by the cleaners running concurrently
+type deletionsBudget struct {
+	sync.Mutex
+	remaining int
+	exceeded  bool
+}
+
+type deletionsBudgetKey struct{}
+
+type deletionPolicyKey struct{}
+
+// terraformManagedIds holds the names, ids and ARNs found in the Terraform states, refreshed while checks are running
+var terraformManagedIds struct {
+	sync.RWMutex
+	ids map[string]bool
+}
+
+// temporaryProtections holds the names, ids and ARNs of resources protected until a date (ex: through the API)
+var temporaryProtections struct {
+	sync.Mutex
+	until map[string]time.Time
+}
+
+// WithDeletionPolicy returns a context applying the policy to the cleaners using it, each run can have its own
+func WithDeletionPolicy(ctx context.Context, policy DeletionPolicy) context.Context {
+	return context.WithValue(ctx, deletionPolicyKey{}, policy)
+}
+
+// getDeletionPolicy returns the deletion policy of the context, a policy without any rule if it has none
+func getDeletionPolicy(ctx context.Context) DeletionPolicy {
+	policy, _ := ctx.Value(deletionPolicyKey{}).(DeletionPolicy)
+	return policy
+}
+
+// IsExcluded returns true if any of the resource identifiers (name, id, ARN) matches an exclusion
+func IsExcluded(ctx context.Context, identifiers ...string) bool {
+	exclusions := getDeletionPolicy(ctx).Exclusions
+	for _, identifier := range identifiers {
+		if identifier == "" {
+			continue
+		}
+
+		for _, exclusion := range exclusions {
+			if exclusion.MatchString(identifier) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// SetTerraformManagedIds replaces the identifiers of the resources managed by Terraform, they are never deleted
+func SetTerraformManagedIds(ids map[string]bool) {
+	terraformManagedIds.Lock()
+	defer terraformManagedIds.Unlock()
+
+	terraformManagedIds.ids = ids
+}
+
+// isManagedByTerraform returns true if any of the resource identifiers (name, id, ARN) is found in a Terraform state
+func isManagedByTerraform(identifiers ...string) bool {
+	terraformManagedIds.RLock()
+	defer terraformManagedIds.RUnlock()
+
+	for _, identifier := range identifiers {
+		if identifier != "" && terraformManagedIds.ids[identifier] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ProtectUntil keeps the resource with this name, id or ARN from being deleted until the date
+func ProtectUntil(identifier string, until time.Time) {
+	temporaryProtections.Lock()
+	defer temporaryProtections.Unlock()
+
+	if temporaryProtections.until == nil {
+		temporaryProtections.until = make(map[string]time.Time)
+	}
+	temporaryProtections.until[identifier] = until
+}
+
+// Unprotect removes the temporary protection of the resource, it returns false if it had none
+func Unprotect(identifier string) bool {
+	temporaryProtections.Lock()
+	defer temporaryProtections.Unlock()
+
+	_, isProtected := temporaryProtections.until[identifier]
+	delete(temporaryProtections.until, identifier)
+
+	return isProtected
+}
+
+// GetTemporaryProtections returns the identifiers of the resources temporarily protected with the end of their
+// protection, once the ended ones are removed
+func GetTemporaryProtections() map[string]time.Time {
+	temporaryProtections.Lock()
+	defer temporaryProtections.Unlock()
+
+	protections := make(map[string]time.Time)
+	for identifier, until := range temporaryProtections.until {
+		if time.Now().After(until) {
+			delete(temporaryProtections.until, identifier)
+			continue
+		}
+		protections[identifier] = until
+	}
+
+	return protections
+}
+
+// getTemporaryProtection returns the end of the temporary protection of the resource, zero if it has none
+func getTemporaryProtection(identifiers ...string) time.Time {
+	temporaryProtections.Lock()
+	defer temporaryProtections.Unlock()
+
+	for _, identifier := range identifiers {
+		until, isProtected := temporaryProtections.until[identifier]
+		if isProtected && time.Now().Before(until) {
+			return until
+		}
+	}
+
+	return time.Time{}
+}
+
+// isTooYoung returns true if the resource was created less than the minimum age ago, resources without known creation
+// time can't be checked
+func isTooYoung(ctx context.Context, creationTime time.Time) bool {
+	minAge := getDeletionPolicy(ctx).MinAge
+	if minAge == 0 || creationTime.Year() < 1972 {
+		return false
+	}
+
+	return Now(ctx).Sub(creationTime) < minAge
+}
+
+// WithDeletionsBudget returns a context limiting the deletions of the cleaners using it to MaxDeletions, it has to be
+// called once per check
+func WithDeletionsBudget(ctx context.Context) context.Context {
+	maxDeletions := getDeletionPolicy(ctx).MaxDeletions
+	if maxDeletions == 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, deletionsBudgetKey{}, &deletionsBudget{remaining: maxDeletions})
+}
+
+// ReserveDeletions has to be called by a cleaner before deleting resources. It returns an error, and the cleaner must
+// not delete anything, if the deletion windows closed since the check started, or if the count exceeds the limit per
+// resource type or the deletions left for the check. Once the check limit is exceeded, every other deletion of the check
+// is aborted too.
+func ReserveDeletions(ctx context.Context, resourceType string, region string, count int) error {
+	err := reserveDeletions(ctx, resourceType, region, count)
+	if err != nil {
+		countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.aborted += count })
+		if report, hasReport := ctx.Value(reportKey{}).(*Report); hasReport {
+			report.setOutcomes(func(entry ReportEntry) bool {
+				return entry.Type == resourceType && entry.Region == region && entry.Outcome == ""
+			}, OutcomeAborted, err)
+		}
+		return err
+	}
+
+	StartPhase(ctx, "delete", map[string]string{
+		"resource_type": resourceType,
+		"region":        region,
+		"count":         strconv.Itoa(count),
+	})
+
+	return nil
+}
+
+func reserveDeletions(ctx context.Context, resourceType string, region string, count int) error {
+	// a check started in a window can outlast it
+	if !IsInDeletionWindow(ctx, Now(ctx)) {
+		log.Warnf("Outside the deletion windows, aborting the deletion of %s.",
+			describeResource(fmt.Sprintf("%d %ss", count, resourceType), region, nil))
+		return fmt.Errorf("%d %ss to delete outside the deletion windows", count, resourceType)
+	}
+
+	deletionPolicy := getDeletionPolicy(ctx)
+	if deletionPolicy.MaxDeletionsPerType > 0 && count > deletionPolicy.MaxDeletionsPerType {
+		log.Errorf("Circuit breaker: %s to delete, more than the %d allowed per resource type, aborting their deletion.",
+			describeResource(fmt.Sprintf("%d %ss", count, resourceType), region, nil), deletionPolicy.MaxDeletionsPerType)
+		return fmt.Errorf("%d %ss to delete exceed the %d deletions allowed per resource type", count, resourceType, deletionPolicy.MaxDeletionsPerType)
+	}
+
+	budget, hasBudget := ctx.Value(deletionsBudgetKey{}).(*deletionsBudget)
+	if !hasBudget {
+		return nil
+	}
+
+	budget.Lock()
+	defer budget.Unlock()
+
+	if budget.exceeded || count > budget.remaining {
+		budget.exceeded = true
+		log.Errorf("Circuit breaker: deleting %s would exceed the %d deletions allowed per check, aborting deletions until the next check.",
+			describeResource(fmt.Sprintf("%d %ss", count, resourceType), region, nil), deletionPolicy.MaxDeletions)
+		return fmt.Errorf("%d %ss to delete exceed the %d deletions allowed per check", count, resourceType, deletionPolicy.MaxDeletions)
+	}
+
+	budget.remaining -= count
+
+	return nil
+}
+
+func filterExcludedIds(ctx context.Context, ids []*string) []*string {
+	if len(getDeletionPolicy(ctx).Exclusions) == 0 {
+		return ids
+	}
+
+	var filteredIds []*string
+	for _, id := range ids {
+		if id != nil && IsExcluded(ctx, *id) {
+			continue
+		}
+
+		filteredIds = append(filteredIds, id)
+	}
+
+	return filteredIds
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Job is a cleaner run by the worker pool, usually one resource type in one region of an account
+type Job struct {
+	Name    string
+	Region  string
+	Account string
+	Run     func(ctx context.Context) error
+	// Timeout stops the job once it runs longer than this, 0 for no timeout
+	Timeout time.Duration
+}
+
+// JobError is the failure of a job, it keeps the job to aggregate the failures by cleaner
+type JobError struct {
+	Job Job
+	Err error
+}
+
+func (e *JobError) Error() string {
+	return fmt.Sprintf("%s cleaner failed in %s: %s", e.Job.Name, e.Job.location(), e.Err)
+}
+
+func (job Job) location() string {
+	if job.Account == "" {
+		return job.Region
+	}
+
+	return job.Region + " of account " + job.Account
+}
+
+// RunJobs runs the jobs concurrently with at most parallelism of them at the same time. A failing job, timing out or
+// panicking, doesn't stop the others, its error is returned with the ones of every other failed job. Once the context
+// is done, the jobs not started yet are skipped.
+func RunJobs(ctx context.Context, jobs []Job, parallelism int) []error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	jobsQueue := make(chan Job)
+	jobsErrors := make(chan error, len(jobs))
+
+	var workers sync.WaitGroup
+	for i := 0; i < parallelism && i < len(jobs); i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for job := range jobsQueue {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if err := runJob(ctx, job); err != nil {
+					jobsErrors <- err
+				}
+			}
+		}()
+	}
+
+	for _, job := range jobs {
+		jobsQueue <- job
+	}
+	close(jobsQueue)
+
+	workers.Wait()
+	close(jobsErrors)
+
+	var errs []error
+	for err := range jobsErrors {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+func runJob(ctx context.Context, job Job) error {
+	start := time.Now()
+	log.Debugf("Starting %s cleaner in %s.", job.Name, job.location())
+
+	// the cleaner scans its resources, then deletes the expired ones (see ReserveDeletions)
+	ctx, endJob := StartSpan(ctx, job.Name, map[string]string{
+		"resource_type": job.Name,
+		"region":        job.Region,
+		"account":       job.Account,
+	})
+	StartPhase(ctx, "scan", nil)
+
+	err := runJobWithTimeout(ctx, job)
+	endJob(err)
+	if err != nil {
+		return &JobError{Job: job, Err: err}
+	}
+
+	log.Debugf("%s cleaner in %s done in %s.", job.Name, job.location(), time.Since(start).Round(time.Millisecond))
+
+	return nil
+}
+
+// runJobWithTimeout runs the job under its timeout, if any. The job context is canceled once the timeout is reached,
+// a job still running then, blocked by a call ignoring its context, is left behind so it can't hang the check.
+func runJobWithTimeout(ctx context.Context, job Job) error {
+	if job.Timeout == 0 {
+		return runJobSafely(ctx, job)
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- runJobSafely(jobCtx, job)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil && ctx.Err() == nil && jobCtx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("timed out after %s: %s", job.Timeout, err)
+		}
+		return err
+	case <-jobCtx.Done():
+	}
+
+	// the check is stopping, the job returns once it notices like without timeout
+	if ctx.Err() != nil {
+		return <-done
+	}
+
+	return fmt.Errorf("timed out after %s", job.Timeout)
+}
+
+// runJobSafely runs the job, a panic is turned into its error so a bug hit by one cleaner doesn't crash the others
+func runJobSafely(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("%s cleaner panicked in %s: %v\n%s", job.Name, job.location(), r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return job.Run(ctx)
+}
+
+// JoinErrors merges the non nil errors into a single one, nil if there is none
+func JoinErrors(errs ...error) error {
+	var messages []string
+	for _, err := range errs {
+		if err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(messages, " ; "))
+}
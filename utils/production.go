@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// ProductionMarker is a tag marking production resources, which are never deleted whatever their ttl or expiration
+// date. An empty value matches any value of the tag.
+type ProductionMarker struct {
+	Key   string
+	Value string
+}
+
+// ParseProductionMarker parses a key=value marker, or a key matching any value
+func ParseProductionMarker(value string) (ProductionMarker, error) {
+	parts := strings.SplitN(value, "=", 2)
+	marker := ProductionMarker{Key: strings.TrimSpace(parts[0])}
+	if len(parts) == 2 {
+		marker.Value = strings.TrimSpace(parts[1])
+	}
+
+	if marker.Key == "" {
+		return ProductionMarker{}, fmt.Errorf("invalid production marker %q, expected key=value or key", value)
+	}
+
+	return marker, nil
+}
+
+func (m ProductionMarker) String() string {
+	if m.Value == "" {
+		return m.Key
+	}
+
+	return m.Key + "=" + m.Value
+}
+
+// matches returns true if the tag is the marker, keys and values are matched case insensitively
+func (m ProductionMarker) matches(key string, value string) bool {
+	return strings.EqualFold(m.Key, key) && (m.Value == "" || strings.EqualFold(m.Value, value))
+}
+
+// getProductionMarker returns the production marker of the deletion policy matching the tag, if any
+func getProductionMarker(ctx context.Context, key string, value string) (ProductionMarker, bool) {
+	for _, marker := range getDeletionPolicy(ctx).ProductionMarkers {
+		if marker.matches(key, value) {
+			return marker, true
+		}
+	}
+
+	return ProductionMarker{}, false
+}
+
+// GetProductionMarker returns the production marker of the deletion policy carried by the tags, if any
+func GetProductionMarker(ctx context.Context, tags map[string]string) (ProductionMarker, bool) {
+	for key, value := range tags {
+		if marker, isMarked := getProductionMarker(ctx, key, value); isMarked {
+			return marker, true
+		}
+	}
+
+	return ProductionMarker{}, false
+}
+
+// AlertProductionConflict logs an alert for a production resource carrying a ttl or an expiration date: pleco refuses
+// to delete it, but its tags are wrong and someone has to fix them
+func AlertProductionConflict(marker ProductionMarker, resource string, ttl int64, expirationDate time.Time) {
+	expiration := fmt.Sprintf("a ttl of %d seconds", ttl)
+	if !expirationDate.IsZero() {
+		expiration = "an expiration date of " + expirationDate.Format(time.RFC3339)
+	}
+
+	log.WithFields(log.Fields{"alert": "production_conflict", "marker": marker.String()}).Errorf(
+		"PRODUCTION CONFLICT: %s carries the %s production marker and %s, it will never be deleted. Remove its ttl or its marker.",
+		resource, marker, expiration)
+}
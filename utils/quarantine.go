@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// Quarantiner stops an expired resource (ex: stops a database, scales node groups to zero) while its deletion is
+// scheduled, so it can be restarted if it expired by mistake. It's called on every check until the deletion, and must
+// do nothing if the resource is already stopped. Identifiers are the ones given to CheckIfDeletable.
+type Quarantiner func(ctx context.Context, identifiers []string) error
+
+type quarantinerKey struct {
+	resourceType string
+}
+
+// HasQuarantine returns true if the resources which can be stopped are quarantined before their deletion
+func HasQuarantine(ctx context.Context) bool {
+	return getDeletionPolicy(ctx).QuarantinePeriod > 0
+}
+
+// WithQuarantiner returns a context quarantining the expired resources of the type with the quarantiner, when the
+// deletion policy has a quarantine period
+func WithQuarantiner(ctx context.Context, resourceType string, quarantine Quarantiner) context.Context {
+	if !HasQuarantine(ctx) {
+		return ctx
+	}
+
+	return context.WithValue(ctx, quarantinerKey{resourceType: resourceType}, quarantine)
+}
+
+func getQuarantiner(ctx context.Context, resourceType string) (Quarantiner, bool) {
+	quarantine, hasQuarantiner := ctx.Value(quarantinerKey{resourceType: resourceType}).(Quarantiner)
+	return quarantine, hasQuarantiner
+}
+
+// isDeletionScheduled returns true if the deletion of the expired resources of the type is scheduled instead of done
+// right away, because of the grace period or of the quarantine
+func isDeletionScheduled(ctx context.Context, resourceType string) bool {
+	_, hasQuarantiner := getQuarantiner(ctx, resourceType)
+	return HasGracePeriod(ctx) || hasQuarantiner
+}
+
+// getDeletionDelay returns the time between the scheduling of the deletion of a resource of the type and its deletion,
+// the quarantine period replaces the grace period for the resources quarantined
+func getDeletionDelay(ctx context.Context, resourceType string) time.Duration {
+	if _, hasQuarantiner := getQuarantiner(ctx, resourceType); hasQuarantiner {
+		return getDeletionPolicy(ctx).QuarantinePeriod
+	}
+
+	return getDeletionPolicy(ctx).GracePeriod
+}
+
+// quarantine stops the resource if its type has a quarantiner, the deletion of the resource is scheduled anyway
+func quarantine(ctx context.Context, resourceType string, region string, identifiers []string) {
+	quarantine, hasQuarantiner := getQuarantiner(ctx, resourceType)
+	if !hasQuarantiner {
+		return
+	}
+
+	resource := describeResource(resourceType, region, identifiers)
+	quarantineLog := ResourceLog(ctx, ActionQuarantine, resourceType, region, getResourceId(identifiers))
+
+	err := quarantine(ctx, identifiers)
+	if err != nil {
+		quarantineLog.Errorf("Can't quarantine %s: %s", resource, err)
+		return
+	}
+
+	quarantineLog.Debugf("%s is quarantined until its deletion.", resource)
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"context"
+	"sort"
+)
+
+// QuotaUsage is the usage of a service quota limiting resources pleco cleans (ex: target groups per region), reported
+// once it crosses the quota threshold
+type QuotaUsage struct {
+	Type   string `json:"type"`
+	Region string `json:"region,omitempty"`
+	// Quota is the name of the quota, Resource the resource it applies to if it's per resource (ex: a load balancer)
+	Quota    string  `json:"quota"`
+	Resource string  `json:"resource,omitempty"`
+	Usage    int     `json:"usage"`
+	Limit    float64 `json:"limit"`
+}
+
+// Percent returns the usage in percent of the limit
+func (q QuotaUsage) Percent() float64 {
+	if q.Limit <= 0 {
+		return 0
+	}
+
+	return float64(q.Usage) * 100 / q.Limit
+}
+
+// ReportQuotaUsage logs a quota crossing its threshold and records it in the report of the context, if any
+func ReportQuotaUsage(ctx context.Context, usage QuotaUsage) {
+	resource := ""
+	if usage.Resource != "" {
+		resource = " of " + usage.Resource
+	}
+	ResourceLog(ctx, ActionQuota, usage.Type, usage.Region, usage.Resource).Warnf(
+		"%s quota%s in %s is %.0f%% used: %d of %.0f.", usage.Quota, resource, orDash(usage.Region), usage.Percent(), usage.Usage, usage.Limit)
+
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.quotas = append(report.quotas, usage)
+}
+
+// QuotaUsages returns the quotas crossing their threshold sorted by region, type and quota
+func (r *Report) QuotaUsages() []QuotaUsage {
+	r.Lock()
+	defer r.Unlock()
+
+	quotas := make([]QuotaUsage, len(r.quotas))
+	copy(quotas, r.quotas)
+
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Region != quotas[j].Region {
+			return quotas[i].Region < quotas[j].Region
+		}
+		if quotas[i].Type != quotas[j].Type {
+			return quotas[i].Type < quotas[j].Type
+		}
+		return quotas[i].Quota < quotas[j].Quota
+	})
+
+	return quotas
+}
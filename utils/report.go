@@ -0,0 +1,623 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// ReportEntry is a resource deleted, or that would be deleted by a dry run, during a check. Audits read the outcome of
+// every resource found deletable, deleted or not.
+type ReportEntry struct {
+	Type                 string    `json:"type"`
+	Name                 string    `json:"name"`
+	Arn                  string    `json:"arn,omitempty"`
+	Region               string    `json:"region,omitempty"`
+	CreationDate         time.Time `json:"creation_date,omitempty"`
+	AgeSeconds           int64     `json:"age_seconds,omitempty"`
+	TTL                  int64     `json:"ttl"`
+	ExpirationTime       time.Time `json:"expiration_time"`
+	EstimatedMonthlyCost float64   `json:"estimated_monthly_cost,omitempty"`
+	// Status tells deletable resources from expiring ones in exports
+	Status string `json:"status,omitempty"`
+	// Outcome is what happened to the resource found deletable, empty until its cleaner tried to delete it and in dry
+	// runs
+	Outcome string `json:"outcome,omitempty"`
+	// Error is why the deletion failed or was aborted
+	Error string            `json:"error,omitempty"`
+	Tags  map[string]string `json:"tags,omitempty"`
+}
+
+const (
+	EntryStatusDeletable = "deletable"
+	EntryStatusExpiring  = "expiring"
+)
+
+// Outcomes of the resources found deletable during a check
+const (
+	OutcomeDeleted = "deleted"
+	OutcomeFailed  = "failed"
+	// OutcomeAborted is a deletion aborted by a circuit breaker
+	OutcomeAborted = "aborted"
+	// OutcomeSkipped is a resource its cleaner didn't try to delete, because it failed or the check was stopped before
+	OutcomeSkipped = "skipped"
+)
+
+// Report collects the resources deletable during a check, shared by the cleaners running concurrently. With an
+// expiring window, it also collects the resources expiring soon.
+type Report struct {
+	sync.Mutex
+	DryRun         bool
+	ExpiringWithin time.Duration
+	StartedAt      time.Time
+	// candidates are the resources found deletable, they are entries of the report once deleted, or right away in dry
+	// runs
+	candidates []ReportEntry
+	expiring   []ReportEntry
+	failures   []ReportFailure
+	leaks      []Leak
+	violations []TagViolation
+	quotas     []QuotaUsage
+	stuck      []StuckResource
+	errors     []string
+	jobErrors  []JobError
+	counts     map[resourceLocation]*resourceCounts
+	// tagsChecked is true once the tags of the resources were checked against the required ones
+	tagsChecked bool
+}
+
+// resourceLocation is a resource type in a region
+type resourceLocation struct {
+	resourceType string
+	region       string
+}
+
+// resourceCounts counts the resources checked by the cleaners of a type in a region, and why they weren't deleted
+type resourceCounts struct {
+	scanned    int
+	notExpired int
+	kept       int
+	scheduled  int
+	aborted    int
+	stuck      int
+}
+
+// ReportFailure is a resource whose deletion failed during a check
+type ReportFailure struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ReportHandler receives the report of every check once it's done, source is the checked provider (ex: AWS)
+type ReportHandler func(ctx context.Context, source string, report *Report)
+
+type reportKey struct{}
+
+type reportHandlerKey struct{}
+
+type reportHandler struct {
+	handle         ReportHandler
+	expiringWithin time.Duration
+}
+
+func NewReport() *Report {
+	return &Report{}
+}
+
+// WithReport returns a context recording in the report every resource found deletable by the cleaners using it
+func WithReport(ctx context.Context, report *Report) context.Context {
+	return context.WithValue(ctx, reportKey{}, report)
+}
+
+// HasReport returns true if the deletable resources are already recorded in a report of the context
+func HasReport(ctx context.Context) bool {
+	_, hasReport := ctx.Value(reportKey{}).(*Report)
+	return hasReport
+}
+
+// WithReportHandler returns a context handing the report of every check to the handler, after the handlers already
+// set. Reports include the resources expiring within the longest duration of the handlers.
+func WithReportHandler(ctx context.Context, handle ReportHandler, expiringWithin time.Duration) context.Context {
+	handlers, _ := ctx.Value(reportHandlerKey{}).([]reportHandler)
+	handlers = append(handlers[:len(handlers):len(handlers)], reportHandler{handle: handle, expiringWithin: expiringWithin})
+
+	return context.WithValue(ctx, reportHandlerKey{}, handlers)
+}
+
+// NewCheckReport returns the report of a check, nil if nobody reads it: dry runs print it and report handlers receive
+// it. It's nil too if the caller already collects the deletable resources in its own report.
+func NewCheckReport(ctx context.Context, dryRun bool) *Report {
+	if HasReport(ctx) {
+		return nil
+	}
+
+	handlers, _ := ctx.Value(reportHandlerKey{}).([]reportHandler)
+	if !dryRun && len(handlers) == 0 {
+		return nil
+	}
+
+	report := &Report{DryRun: dryRun, StartedAt: time.Now()}
+	for _, handler := range handlers {
+		if handler.expiringWithin > report.ExpiringWithin {
+			report.ExpiringWithin = handler.expiringWithin
+		}
+	}
+
+	return report
+}
+
+// CloseCheckReport prints the report of a dry run check, unless the format is empty, and hands it to the report
+// handlers
+func CloseCheckReport(ctx context.Context, report *Report, source string, format string) {
+	if report == nil {
+		return
+	}
+
+	if !report.DryRun {
+		report.setOutcomes(func(entry ReportEntry) bool { return entry.Outcome == "" }, OutcomeSkipped, nil)
+	}
+
+	if report.DryRun && format != "" {
+		PrintReport(report, format)
+	}
+
+	handlers, _ := ctx.Value(reportHandlerKey{}).([]reportHandler)
+	for _, handler := range handlers {
+		handler.handle(ctx, source, report)
+	}
+}
+
+func newReportEntry(creationTime time.Time, ttl int64, expirationDate time.Time, resourceType string, region string, identifiers []string) ReportEntry {
+	entry := ReportEntry{
+		Type:           resourceType,
+		Region:         region,
+		TTL:            ttl,
+		ExpirationTime: getExpirationTime(creationTime, ttl, expirationDate),
+	}
+
+	// resources without known creation time have no age
+	if creationTime.Year() >= 1972 {
+		entry.CreationDate = creationTime
+		entry.AgeSeconds = int64(time.Since(creationTime).Seconds())
+	}
+
+	for i, identifier := range identifiers {
+		if i == 0 {
+			entry.Name = identifier
+		} else if strings.HasPrefix(identifier, "arn:") {
+			entry.Arn = identifier
+		}
+	}
+
+	return entry
+}
+
+// reportCandidate records a resource found deletable, its cleaner reports its outcome once it tried to delete it
+func reportCandidate(ctx context.Context, creationTime time.Time, ttl int64, expirationDate time.Time, resourceType string, region string, identifiers []string) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	entry := newReportEntry(creationTime, ttl, expirationDate, resourceType, region, identifiers)
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.candidates = append(report.candidates, entry)
+}
+
+// ReportDependency records a resource deleted with an expired one (ex: the services of an expired cluster), whatever
+// its own ttl. Its outcome is then reported like the one of an expired resource.
+func ReportDependency(ctx context.Context, resourceType string, region string, identifiers ...string) {
+	reportCandidate(ctx, time.Time{}, 0, time.Time{}, resourceType, region, identifiers)
+}
+
+// ReportDeleted records the deletion of a resource, cleaners call it once the deletion succeeded
+func ReportDeleted(ctx context.Context, resourceType string, region string, name string) {
+	recordState(ctx, resourceType, region, []string{name}, EventDeleted, nil)
+
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.setOutcomes(func(entry ReportEntry) bool {
+		return entry.Type == resourceType && entry.Region == region && entry.Name == name
+	}, OutcomeDeleted, nil)
+}
+
+// setOutcomes sets the outcome of the resources found deletable matching the filter
+func (r *Report) setOutcomes(matches func(entry ReportEntry) bool, outcome string, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for i, candidate := range r.candidates {
+		if !matches(candidate) {
+			continue
+		}
+
+		r.candidates[i].Outcome = outcome
+		if err != nil {
+			r.candidates[i].Error = err.Error()
+		}
+	}
+}
+
+// isEntry returns true if the resource found deletable is an entry of the report: deleted, or deletable by a dry run
+func (r *Report) isEntry(candidate ReportEntry) bool {
+	return r.DryRun || candidate.Outcome == OutcomeDeleted
+}
+
+// reportExpiring records a resource not expired yet if it expires within the report expiring window
+func reportExpiring(ctx context.Context, creationTime time.Time, ttl int64, expirationDate time.Time, resourceType string, region string, identifiers []string) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport || report.ExpiringWithin == 0 {
+		return
+	}
+
+	// resources without ttl nor expiration date never expire
+	if expirationDate.IsZero() && (ttl == 0 || creationTime.Year() < 1972) {
+		return
+	}
+
+	entry := newReportEntry(creationTime, ttl, expirationDate, resourceType, region, identifiers)
+	if time.Until(entry.ExpirationTime) > report.ExpiringWithin {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.expiring = append(report.expiring, entry)
+}
+
+// countResources updates the counts of the resource type in the region of the report of the context, if any
+func countResources(ctx context.Context, resourceType string, region string, update func(counts *resourceCounts)) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	if report.counts == nil {
+		report.counts = make(map[resourceLocation]*resourceCounts)
+	}
+	location := resourceLocation{resourceType: resourceType, region: region}
+	if _, exists := report.counts[location]; !exists {
+		report.counts[location] = &resourceCounts{}
+	}
+
+	update(report.counts[location])
+}
+
+// ReportDeletionError records the failed deletion of a resource found deletable during the check, cleaners call it
+// along with logging the error
+func ReportDeletionError(ctx context.Context, resourceType string, region string, name string, err error) {
+	if err == nil {
+		return
+	}
+	recordState(ctx, resourceType, region, []string{name}, EventDeletionFailed, err)
+
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.setOutcomes(func(entry ReportEntry) bool {
+		return entry.Type == resourceType && entry.Region == region && entry.Name == name
+	}, OutcomeFailed, err)
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.failures = append(report.failures, ReportFailure{Type: resourceType, Name: name, Region: region, Error: err.Error()})
+	report.errors = append(report.errors, fmt.Sprintf("%s deletion failed: %s", describeResource(resourceType, region, []string{name}), err))
+}
+
+// ReportCheckError records a failure of the check in the report of the context, if any
+func ReportCheckError(ctx context.Context, err error) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.AddError(err)
+}
+
+// SetReportCost records the estimated monthly cost, in US dollars, of a resource found deletable during the check
+func SetReportCost(ctx context.Context, resourceType string, region string, name string, monthlyCost float64) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	for i, candidate := range report.candidates {
+		if candidate.Type == resourceType && candidate.Region == region && candidate.Name == name {
+			report.candidates[i].EstimatedMonthlyCost = monthlyCost
+		}
+	}
+}
+
+// SetReportTags records the tags of a resource found deletable during the check
+func SetReportTags(ctx context.Context, resourceType string, region string, name string, tags map[string]string) {
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport || len(tags) == 0 {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	for i, candidate := range report.candidates {
+		if candidate.Type == resourceType && candidate.Region == region && candidate.Name == name {
+			report.candidates[i].Tags = tags
+		}
+	}
+}
+
+// EstimatedMonthlyCost returns the sum of the estimated monthly cost of the recorded resources
+func (r *Report) EstimatedMonthlyCost() float64 {
+	total := 0.0
+	for _, entry := range r.Entries() {
+		total += entry.EstimatedMonthlyCost
+	}
+
+	return total
+}
+
+// Failures returns the resources whose deletion failed
+func (r *Report) Failures() []ReportFailure {
+	r.Lock()
+	defer r.Unlock()
+
+	failures := make([]ReportFailure, len(r.failures))
+	copy(failures, r.failures)
+
+	return failures
+}
+
+// AddError records a failure of the check
+func (r *Report) AddError(err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.errors = append(r.errors, err.Error())
+
+	var jobErr *JobError
+	if errors.As(err, &jobErr) {
+		r.jobErrors = append(r.jobErrors, *jobErr)
+	}
+}
+
+// Expiring returns the resources expiring soon sorted by expiration time
+func (r *Report) Expiring() []ReportEntry {
+	r.Lock()
+	defer r.Unlock()
+
+	expiring := make([]ReportEntry, len(r.expiring))
+	copy(expiring, r.expiring)
+
+	sort.Slice(expiring, func(i, j int) bool {
+		return expiring[i].ExpirationTime.Before(expiring[j].ExpirationTime)
+	})
+
+	return expiring
+}
+
+// Errors returns the failures of the check
+func (r *Report) Errors() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	errs := make([]string, len(r.errors))
+	copy(errs, r.errors)
+
+	return errs
+}
+
+// Entries returns the deleted resources, or the ones a dry run would delete, sorted by region, type and name
+func (r *Report) Entries() []ReportEntry {
+	r.Lock()
+	defer r.Unlock()
+
+	var entries []ReportEntry
+	for _, candidate := range r.candidates {
+		if r.isEntry(candidate) {
+			entries = append(entries, candidate)
+		}
+	}
+	sortEntries(entries)
+
+	return entries
+}
+
+// Candidates returns every resource found deletable with its outcome, deleted or not, sorted by region, type and name
+func (r *Report) Candidates() []ReportEntry {
+	r.Lock()
+	defer r.Unlock()
+
+	candidates := make([]ReportEntry, len(r.candidates))
+	copy(candidates, r.candidates)
+	sortEntries(candidates)
+
+	return candidates
+}
+
+func sortEntries(entries []ReportEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Region != entries[j].Region {
+			return entries[i].Region < entries[j].Region
+		}
+		if entries[i].Type != entries[j].Type {
+			return entries[i].Type < entries[j].Type
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
+
+// PrintReport prints the report of a dry run check on the standard output
+func PrintReport(report *Report, format string) {
+	err := report.Write(os.Stdout, format)
+	if err != nil {
+		log.Errorf("Can't print the dry run report: %s", err)
+	}
+}
+
+// Write prints the recorded resources as a table, json or csv
+func (r *Report) Write(w io.Writer, format string) error {
+	return r.Export(w, format, false)
+}
+
+// Export prints the recorded resources as a table, json or csv. With expiring, it adds the resources expiring soon,
+// their status tells them apart from the deletable ones.
+func (r *Report) Export(w io.Writer, format string, withExpiring bool) error {
+	entries := r.Entries()
+	for i := range entries {
+		entries[i].Status = EntryStatusDeletable
+	}
+
+	var expiring []ReportEntry
+	if withExpiring {
+		expiring = r.Expiring()
+		for i := range expiring {
+			expiring[i].Status = EntryStatusExpiring
+		}
+	}
+
+	switch format {
+	case "json":
+		entries = append(entries, expiring...)
+		if entries == nil {
+			entries = []ReportEntry{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case "csv":
+		writer := csv.NewWriter(w)
+		_ = writer.Write([]string{"status", "type", "name", "arn", "region", "creation_date", "age_seconds", "ttl", "expiration_time", "estimated_monthly_cost"})
+		for _, entry := range append(entries, expiring...) {
+			creationDate := ""
+			if !entry.CreationDate.IsZero() {
+				creationDate = entry.CreationDate.Format(time.RFC3339)
+			}
+			monthlyCost := ""
+			if entry.EstimatedMonthlyCost != 0 {
+				monthlyCost = strconv.FormatFloat(entry.EstimatedMonthlyCost, 'f', 2, 64)
+			}
+
+			_ = writer.Write([]string{
+				entry.Status,
+				entry.Type,
+				entry.Name,
+				entry.Arn,
+				entry.Region,
+				creationDate,
+				strconv.FormatInt(entry.AgeSeconds, 10),
+				strconv.FormatInt(entry.TTL, 10),
+				entry.ExpirationTime.Format(time.RFC3339),
+				monthlyCost,
+			})
+		}
+		writer.Flush()
+		return writer.Error()
+	case "table":
+		table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(table, "TYPE\tNAME\tREGION\tAGE\tTTL\tEXPIRED AT\tMONTHLY COST\tARN")
+		for _, entry := range entries {
+			_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n",
+				entry.Type,
+				entry.Name,
+				orDash(entry.Region),
+				orDash(formatAge(entry.AgeSeconds)),
+				entry.TTL,
+				entry.ExpirationTime.Format(time.RFC3339),
+				orDash(formatCost(entry.EstimatedMonthlyCost)),
+				orDash(entry.Arn))
+		}
+		_, _ = fmt.Fprintf(table, "\n%d resources to delete.\n", len(entries))
+		if total := r.EstimatedMonthlyCost(); total > 0 {
+			_, _ = fmt.Fprintf(table, "Estimated monthly savings: %s.\n", formatCost(total))
+		}
+
+		if len(expiring) > 0 {
+			_, _ = fmt.Fprintln(table, "\nTYPE\tNAME\tREGION\tTTL\tEXPIRES AT\tARN")
+			for _, entry := range expiring {
+				_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%s\t%s\n", entry.Type, entry.Name, orDash(entry.Region), entry.TTL,
+					entry.ExpirationTime.Format(time.RFC3339), orDash(entry.Arn))
+			}
+			_, _ = fmt.Fprintf(table, "\n%d resources expiring.\n", len(expiring))
+		}
+
+		if leaks := r.Leaks(); len(leaks) > 0 {
+			_, _ = fmt.Fprintln(table, "\nCATEGORY\tTYPE\tNAME\tREGION\tREASON")
+			for _, leak := range leaks {
+				_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n", leak.Category, leak.Type, leak.Name, orDash(leak.Region), leak.Reason)
+			}
+			_, _ = fmt.Fprintf(table, "\n%d leaked resources.\n", len(leaks))
+		}
+
+		if quotas := r.QuotaUsages(); len(quotas) > 0 {
+			_, _ = fmt.Fprintln(table, "\nQUOTA\tTYPE\tREGION\tRESOURCE\tUSAGE\tLIMIT")
+			for _, quota := range quotas {
+				_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%d (%.0f%%)\t%.0f\n", quota.Quota, quota.Type, orDash(quota.Region), orDash(quota.Resource),
+					quota.Usage, quota.Percent(), quota.Limit)
+			}
+			_, _ = fmt.Fprintf(table, "\n%d quotas nearly reached.\n", len(quotas))
+		}
+
+		if violations := r.TagViolations(); len(violations) > 0 {
+			_, _ = fmt.Fprintln(table, "\nTYPE\tNAME\tREGION\tMISSING TAGS")
+			for _, violation := range violations {
+				_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", violation.Type, violation.Name, orDash(violation.Region), strings.Join(violation.MissingTags, ", "))
+			}
+			_, _ = fmt.Fprintf(table, "\n%d resources missing required tags.\n", len(violations))
+		}
+		return table.Flush()
+	default:
+		return fmt.Errorf("unknown report format %s, choose between table/json/csv", format)
+	}
+}
+
+func formatAge(ageSeconds int64) string {
+	if ageSeconds == 0 {
+		return ""
+	}
+
+	return (time.Duration(ageSeconds) * time.Second).String()
+}
+
+func formatCost(monthlyCost float64) string {
+	if monthlyCost == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("$%.2f", monthlyCost)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Exit codes of the commands running a single check
+const (
+	ExitOK = 0
+	// ExitError is returned when a cleaner failed, or the check couldn't run
+	ExitError = 1
+	// ExitPendingDeletions is returned when a dry run found resources to delete
+	ExitPendingDeletions = 2
+	// ExitDeletionsFailed is returned when the deletion of a resource failed or a resource is stuck, it takes precedence
+	// over the others
+	ExitDeletionsFailed = 3
+)
+
+// SummaryRow aggregates the results of a cleaner in a region, cleaners are named by the type of the resources they
+// checked or by their job name when they failed. Scanned resources are the ones with a ttl or an expiration date,
+// they are either not expired, kept by the deletion policy (protected, excluded, too young...), scheduled for a later
+// deletion or expired. Deletions of expired resources can be aborted by the circuit breakers, or fail. Stuck resources
+// are scanned resources still there after the deletions of previous checks.
+type SummaryRow struct {
+	Source     string `json:"source"`
+	Cleaner    string `json:"cleaner"`
+	Region     string `json:"region,omitempty"`
+	Scanned    int    `json:"scanned"`
+	NotExpired int    `json:"not_expired"`
+	Kept       int    `json:"kept"`
+	Scheduled  int    `json:"scheduled"`
+	Expired    int    `json:"expired"`
+	Aborted    int    `json:"aborted"`
+	Deleted    int    `json:"deleted"`
+	Failed     int    `json:"failed"`
+	Stuck      int    `json:"stuck"`
+	Errors     int    `json:"errors"`
+}
+
+// Skipped returns the number of scanned resources not deleted because they are not expired, kept or scheduled
+func (row SummaryRow) Skipped() int {
+	return row.NotExpired + row.Kept + row.Scheduled
+}
+
+// RunResult aggregates the reports of the checks of a run, to print their summary and return the exit code of the
+// process. Its Report method is a report handler.
+type RunResult struct {
+	sync.Mutex
+	reports map[string]*Report
+}
+
+func NewRunResult() *RunResult {
+	return &RunResult{reports: make(map[string]*Report)}
+}
+
+// Report records the report of a check, the last one of each source is kept
+func (r *RunResult) Report(ctx context.Context, source string, report *Report) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.reports[source] = report
+}
+
+// Summary returns the results of every cleaner of every source sorted by source, cleaner and region
+func (r *RunResult) Summary() []SummaryRow {
+	r.Lock()
+	defer r.Unlock()
+
+	var summary []SummaryRow
+	for source, report := range r.reports {
+		for _, row := range report.Summary() {
+			row.Source = source
+			summary = append(summary, row)
+		}
+	}
+
+	sort.SliceStable(summary, func(i, j int) bool {
+		return summary[i].Source < summary[j].Source
+	})
+
+	return summary
+}
+
+// Summary returns the results of every cleaner of the check sorted by cleaner and region, without source
+func (r *Report) Summary() []SummaryRow {
+	candidates := r.Candidates()
+	failures := r.Failures()
+
+	r.Lock()
+	defer r.Unlock()
+
+	rows := make(map[resourceLocation]*SummaryRow)
+	getRow := func(cleaner string, region string) *SummaryRow {
+		location := resourceLocation{resourceType: cleaner, region: region}
+		if _, exists := rows[location]; !exists {
+			rows[location] = &SummaryRow{Cleaner: cleaner, Region: region}
+		}
+		return rows[location]
+	}
+
+	for location, counts := range r.counts {
+		row := getRow(location.resourceType, location.region)
+		row.Scanned = counts.scanned
+		row.NotExpired = counts.notExpired
+		row.Kept = counts.kept
+		row.Scheduled = counts.scheduled
+		row.Aborted = counts.aborted
+		row.Stuck = counts.stuck
+	}
+	for _, candidate := range candidates {
+		row := getRow(candidate.Type, candidate.Region)
+		row.Expired++
+		if candidate.Outcome == OutcomeDeleted {
+			row.Deleted++
+		}
+	}
+	for _, failure := range failures {
+		getRow(failure.Type, failure.Region).Failed++
+	}
+	for _, jobErr := range r.jobErrors {
+		getRow(jobErr.Job.Name, jobErr.Job.location()).Errors++
+	}
+
+	// errors not coming from a job nor a deletion, a check failing as a whole for instance
+	if otherErrors := len(r.errors) - len(r.failures) - len(r.jobErrors); otherErrors > 0 {
+		getRow("", "").Errors += otherErrors
+	}
+
+	summary := make([]SummaryRow, 0, len(rows))
+	for _, row := range rows {
+		summary = append(summary, *row)
+	}
+
+	sort.Slice(summary, func(i, j int) bool {
+		if summary[i].Cleaner != summary[j].Cleaner {
+			return summary[i].Cleaner < summary[j].Cleaner
+		}
+		return summary[i].Region < summary[j].Region
+	})
+
+	return summary
+}
+
+// WriteSummary prints the results of every cleaner as a table, with the totals of the run
+func (r *RunResult) WriteSummary(w io.Writer) error {
+	var total SummaryRow
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(table, "SOURCE\tCLEANER\tREGION\tSCANNED\tSKIPPED\tEXPIRED\tABORTED\tDELETED\tFAILED\tSTUCK\tERRORS")
+	for _, row := range r.Summary() {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%d\t%d\n", row.Source, orDash(row.Cleaner), orDash(row.Region),
+			row.Scanned, row.Skipped(), row.Expired, row.Aborted, row.Deleted, row.Failed, row.Stuck, row.Errors)
+		total.add(row)
+	}
+	_, _ = fmt.Fprintf(table, "\n%s.\n", total.describe())
+
+	return table.Flush()
+}
+
+func (row *SummaryRow) add(other SummaryRow) {
+	row.Scanned += other.Scanned
+	row.NotExpired += other.NotExpired
+	row.Kept += other.Kept
+	row.Scheduled += other.Scheduled
+	row.Expired += other.Expired
+	row.Aborted += other.Aborted
+	row.Deleted += other.Deleted
+	row.Failed += other.Failed
+	row.Stuck += other.Stuck
+	row.Errors += other.Errors
+}
+
+// describe returns the counts of the row as a sentence, for totals
+func (row SummaryRow) describe() string {
+	return fmt.Sprintf("%d resources scanned, %d skipped (%d not expired, %d kept, %d scheduled), %d expired, %d aborted, %d deleted, %d failed deletions, %d stuck, %d cleaner errors",
+		row.Scanned, row.Skipped(), row.NotExpired, row.Kept, row.Scheduled, row.Expired, row.Aborted, row.Deleted, row.Failed, row.Stuck, row.Errors)
+}
+
+// ExitCode returns ExitDeletionsFailed if a deletion failed or a resource is stuck, ExitError if a cleaner failed, ExitPendingDeletions if a
+// dry run found resources to delete and ExitOK otherwise
+func (r *RunResult) ExitCode() int {
+	exitCode := ExitOK
+	for _, row := range r.Summary() {
+		if row.Failed > 0 || row.Stuck > 0 {
+			return ExitDeletionsFailed
+		}
+		if row.Errors > 0 {
+			exitCode = ExitError
+		}
+	}
+	if exitCode != ExitOK {
+		return exitCode
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	for _, report := range r.reports {
+		if report.DryRun && len(report.Entries()) > 0 {
+			return ExitPendingDeletions
+		}
+	}
+
+	return ExitOK
+}
+
+// LogSummary is a report handler logging the results of every cleaner once a check is done, the counts are fields of
+// json logs
+func LogSummary(ctx context.Context, source string, report *Report) {
+	var total SummaryRow
+	for _, row := range report.Summary() {
+		log.WithFields(log.Fields{
+			"source":        source,
+			"resource_type": row.Cleaner,
+			"region":        row.Region,
+			"dry_run":       report.DryRun,
+			"scanned":       row.Scanned,
+			"not_expired":   row.NotExpired,
+			"kept":          row.Kept,
+			"scheduled":     row.Scheduled,
+			"expired":       row.Expired,
+			"aborted":       row.Aborted,
+			"deleted":       row.Deleted,
+			"failed":        row.Failed,
+			"stuck":         row.Stuck,
+			"errors":        row.Errors,
+		}).Infof("%s check summary of %s in %s: %s.", source, orDash(row.Cleaner), orDash(row.Region), row.describe())
+		total.add(row)
+	}
+
+	log.Infof("%s check done in %s: %s.", source, time.Since(report.StartedAt).Round(time.Second), total.describe())
+}
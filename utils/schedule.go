@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OwnerTagNames are the tags holding the owner of a resource (email address or Slack user id), the first one set wins
+var OwnerTagNames = []string{"owner", "email"}
+
+// DeletionScheduler tags an expired resource with the date it will be deleted at, giving its owners a grace period to
+// rescue it. Identifiers are the ones given to CheckIfDeletable.
+type DeletionScheduler interface {
+	ScheduleDeletion(ctx context.Context, resourceType string, identifiers []string, deletionDate time.Time) error
+	// GetOwner returns the owner of the resource from its owner tags, empty if it has none
+	GetOwner(ctx context.Context, resourceType string, identifiers []string) (string, error)
+}
+
+// ScheduledDeletion is a resource scheduled for deletion whose owner is notified
+type ScheduledDeletion struct {
+	Type         string
+	Name         string
+	Region       string
+	Owner        string
+	DeletionDate time.Time
+}
+
+// OwnerNotifier warns the owner of a resource that it will be deleted soon
+type OwnerNotifier func(ctx context.Context, deletion ScheduledDeletion) error
+
+type deletionSchedulerKey struct{}
+
+type ownerNotifierKey struct{}
+
+type ownerNotification struct {
+	notify   OwnerNotifier
+	within   time.Duration
+	notified *sync.Map
+}
+
+// WithDeletionScheduler returns a context scheduling the deletions of the cleaners using it with the scheduler, dry
+// runs must not set it so nothing is tagged
+func WithDeletionScheduler(ctx context.Context, scheduler DeletionScheduler) context.Context {
+	return context.WithValue(ctx, deletionSchedulerKey{}, scheduler)
+}
+
+// HasDeletionScheduler returns true if the deletions of the cleaners using the context are scheduled
+func HasDeletionScheduler(ctx context.Context) bool {
+	_, hasScheduler := ctx.Value(deletionSchedulerKey{}).(DeletionScheduler)
+	return hasScheduler
+}
+
+// WithOwnerNotifier returns a context notifying the owners of resources scheduled for deletion, once their deletion is
+// within the duration. Owners are notified once per deletion date and only when a deletion scheduler is set.
+func WithOwnerNotifier(ctx context.Context, notify OwnerNotifier, within time.Duration) context.Context {
+	return context.WithValue(ctx, ownerNotifierKey{}, ownerNotification{notify: notify, within: within, notified: &sync.Map{}})
+}
+
+// GetOwnerFromTags returns the value of the first owner tag found, empty if none
+func GetOwnerFromTags(tags map[string]string) string {
+	for _, ownerTagName := range OwnerTagNames {
+		for key, value := range tags {
+			if strings.EqualFold(key, ownerTagName) && value != "" {
+				return value
+			}
+		}
+	}
+
+	return ""
+}
+
+// HasGracePeriod returns true if expired resources are scheduled for deletion instead of being deleted right away
+func HasGracePeriod(ctx context.Context) bool {
+	return getDeletionPolicy(ctx).GracePeriod > 0
+}
+
+// getExpirationTime returns when the resource expired, from its expiration date if any or its ttl otherwise
+func getExpirationTime(creationTime time.Time, ttl int64, expirationDate time.Time) time.Time {
+	if !expirationDate.IsZero() {
+		return expirationDate
+	}
+
+	return creationTime.Add(time.Duration(ttl) * time.Second)
+}
+
+// checkScheduledDeletion returns true once the scheduled deletion date of the expired resource is reached. Resources
+// without deletion date, or with one set before their current expiration (ex: their ttl was extended since), are
+// scheduled for deletion after the grace period, or quarantined until the end of the quarantine period.
+func checkScheduledDeletion(ctx context.Context, creationTime time.Time, ttl int64, expirationDate time.Time, deletionScheduled time.Time, resourceType string, region string, identifiers []string) bool {
+	resource := describeResource(resourceType, region, identifiers)
+	scheduleLog := ResourceLog(ctx, ActionSchedule, resourceType, region, getResourceId(identifiers))
+	scheduler, hasScheduler := ctx.Value(deletionSchedulerKey{}).(DeletionScheduler)
+
+	if !deletionScheduled.IsZero() && !deletionScheduled.Before(getExpirationTime(creationTime, ttl, expirationDate)) {
+		if Now(ctx).Before(deletionScheduled) {
+			scheduleLog.Debugf("Skipping %s: expired, its deletion is scheduled at %s.", resource, deletionScheduled.Format(time.RFC3339))
+			if hasScheduler {
+				quarantine(ctx, resourceType, region, identifiers)
+				notifyOwner(ctx, scheduler, resourceType, region, identifiers, deletionScheduled)
+			}
+			return false
+		}
+
+		return true
+	}
+
+	deletionDate := Now(ctx).Add(getDeletionDelay(ctx, resourceType)).UTC().Truncate(time.Second)
+
+	if !hasScheduler {
+		scheduleLog.Infof("Skipping %s: expired, its deletion would be scheduled at %s.", resource, deletionDate.Format(time.RFC3339))
+		return false
+	}
+
+	tagCtx, endTag := StartSpan(ctx, "tag", map[string]string{
+		"resource_type": resourceType,
+		"region":        region,
+		"resource_id":   getResourceId(identifiers),
+	})
+	err := scheduler.ScheduleDeletion(tagCtx, resourceType, identifiers, deletionDate)
+	endTag(err)
+	if err != nil {
+		scheduleLog.Errorf("Can't schedule %s deletion: %s", resource, err)
+		return false
+	}
+
+	scheduleLog.Infof("Expired %s is scheduled for deletion at %s.", resource, deletionDate.Format(time.RFC3339))
+	recordState(ctx, resourceType, region, identifiers, EventTagged, nil)
+	quarantine(ctx, resourceType, region, identifiers)
+	notifyOwner(ctx, scheduler, resourceType, region, identifiers, deletionDate)
+
+	return false
+}
+
+// notifyOwner warns the owner of a resource scheduled for deletion if its deletion is close enough and its owner
+// wasn't notified of this deletion date yet
+func notifyOwner(ctx context.Context, scheduler DeletionScheduler, resourceType string, region string, identifiers []string, deletionDate time.Time) {
+	notification, hasNotifier := ctx.Value(ownerNotifierKey{}).(ownerNotification)
+	if !hasNotifier || len(identifiers) == 0 || time.Until(deletionDate) > notification.within {
+		return
+	}
+
+	key := fmt.Sprintf("%s/%s/%s/%s", resourceType, region, strings.Join(identifiers, "/"), deletionDate.Format(time.RFC3339))
+	if _, isNotified := notification.notified.Load(key); isNotified {
+		return
+	}
+
+	resource := describeResource(resourceType, region, identifiers)
+	notifyLog := ResourceLog(ctx, ActionNotifyOwner, resourceType, region, identifiers[0])
+
+	owner, err := scheduler.GetOwner(ctx, resourceType, identifiers)
+	if err != nil {
+		notifyLog.Errorf("Can't get the owner of %s: %s", resource, err)
+		return
+	}
+
+	if owner == "" {
+		notification.notified.Store(key, true)
+		return
+	}
+
+	err = notification.notify(ctx, ScheduledDeletion{
+		Type:         resourceType,
+		Name:         identifiers[0],
+		Region:       region,
+		Owner:        owner,
+		DeletionDate: deletionDate,
+	})
+	if err != nil {
+		notifyLog.Errorf("Can't notify %s of the deletion of %s: %s", owner, resource, err)
+		return
+	}
+
+	notification.notified.Store(key, true)
+	notifyLog.Infof("Notified %s of the deletion of %s at %s.", owner, resource, deletionDate.Format(time.RFC3339))
+}
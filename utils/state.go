@@ -0,0 +1,45 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// Events of resources recorded by the state store
+const (
+	// EventSeen is a resource listed by a check
+	EventSeen = "seen"
+	// EventExpired is a resource found expired by a check, kept by the deletion policy or not
+	EventExpired = "expired"
+	// EventTagged is an expired resource tagged with its deletion date
+	EventTagged = "tagged"
+	// EventDeleted is a resource deleted by a check
+	EventDeleted = "deleted"
+	// EventDeletionFailed is the failed deletion of a resource
+	EventDeletionFailed = "deletion_failed"
+)
+
+// StateRecorder receives the events of the resources during the checks, err is only set for failed deletions
+type StateRecorder func(resourceType string, region string, resourceId string, event string, at time.Time, err error)
+
+type stateRecorderKey struct{}
+
+// WithStateRecorder returns a context handing the events of the resources to the recorder
+func WithStateRecorder(ctx context.Context, recorder StateRecorder) context.Context {
+	return context.WithValue(ctx, stateRecorderKey{}, recorder)
+}
+
+// recordState hands an event of the resource to the recorder of the context, if any. Dry runs only record the
+// resources seen and expired.
+func recordState(ctx context.Context, resourceType string, region string, identifiers []string, event string, err error) {
+	recorder, hasRecorder := ctx.Value(stateRecorderKey{}).(StateRecorder)
+	if !hasRecorder {
+		return
+	}
+
+	if dryRun, _ := ctx.Value(dryRunKey{}).(bool); dryRun && event != EventSeen && event != EventExpired {
+		return
+	}
+
+	recorder(resourceType, region, getResourceId(identifiers), event, time.Now().UTC(), err)
+}
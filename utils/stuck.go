@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// StuckResource is a resource still listed by the checks following its deletion, its deletion silently fails
+type StuckResource struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Region string `json:"region,omitempty"`
+	// Deletions is the number of deletions the resource survived in a row
+	Deletions    int       `json:"deletions"`
+	LastDeletion time.Time `json:"last_deletion"`
+}
+
+// SurvivedDeletions returns how many deletions in a row a resource survived, and when the last one was
+type SurvivedDeletions func(resourceType string, region string, resourceId string) (int, time.Time)
+
+type stuckDetectionKey struct{}
+
+type stuckDetection struct {
+	survivedDeletions SurvivedDeletions
+	after             int
+}
+
+// WithStuckDetection returns a context reporting the resources which survived this number of deletions in a row as
+// stuck, it needs a state recorder recording the deletions
+func WithStuckDetection(ctx context.Context, survivedDeletions SurvivedDeletions, after int) context.Context {
+	return context.WithValue(ctx, stuckDetectionKey{}, stuckDetection{survivedDeletions: survivedDeletions, after: after})
+}
+
+// checkStuck logs the resource and records it in the report of the context if it survived enough deletions, it's
+// called once the resource is recorded as seen
+func checkStuck(ctx context.Context, resourceType string, region string, identifiers []string) {
+	detection, hasDetection := ctx.Value(stuckDetectionKey{}).(stuckDetection)
+	if !hasDetection || detection.after <= 0 {
+		return
+	}
+
+	name := getResourceId(identifiers)
+	deletions, lastDeletion := detection.survivedDeletions(resourceType, region, name)
+	if deletions < detection.after {
+		return
+	}
+
+	ResourceLog(ctx, ActionStuck, resourceType, region, name).Warnf("Stuck %s: still there after %d deletions, the last one at %s.",
+		describeResource(resourceType, region, identifiers), deletions, lastDeletion.Format(time.RFC3339))
+	countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.stuck++ })
+
+	report, hasReport := ctx.Value(reportKey{}).(*Report)
+	if !hasReport {
+		return
+	}
+
+	report.Lock()
+	defer report.Unlock()
+
+	report.stuck = append(report.stuck, StuckResource{
+		Type:         resourceType,
+		Name:         name,
+		Region:       region,
+		Deletions:    deletions,
+		LastDeletion: lastDeletion,
+	})
+}
+
+// Stuck returns the resources surviving their deletions sorted by region, type and name
+func (r *Report) Stuck() []StuckResource {
+	r.Lock()
+	defer r.Unlock()
+
+	stuck := make([]StuckResource, len(r.stuck))
+	copy(stuck, r.stuck)
+
+	sort.Slice(stuck, func(i, j int) bool {
+		if stuck[i].Region != stuck[j].Region {
+			return stuck[i].Region < stuck[j].Region
+		}
+		if stuck[i].Type != stuck[j].Type {
+			return stuck[i].Type < stuck[j].Type
+		}
+		return stuck[i].Name < stuck[j].Name
+	})
+
+	return stuck
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// Targets are the names, ids and ARNs of the resources destroyed by a check, whatever their ttl (ex: the resources of
+// a cluster destroyed on demand)
+type Targets struct {
+	sync.RWMutex
+	ids map[string]bool
+}
+
+func NewTargets() *Targets {
+	return &Targets{ids: make(map[string]bool)}
+}
+
+// Add adds the names, ids or ARNs of resources to destroy
+func (t *Targets) Add(identifiers ...string) {
+	t.Lock()
+	defer t.Unlock()
+
+	for _, identifier := range identifiers {
+		if identifier != "" {
+			t.ids[identifier] = true
+		}
+	}
+}
+
+// Len returns the number of names, ids and ARNs
+func (t *Targets) Len() int {
+	t.RLock()
+	defer t.RUnlock()
+
+	return len(t.ids)
+}
+
+// contains returns true if any of the resource identifiers is a target
+func (t *Targets) contains(identifiers ...string) bool {
+	t.RLock()
+	defer t.RUnlock()
+
+	for _, identifier := range identifiers {
+		if t.ids[identifier] {
+			return true
+		}
+	}
+
+	return false
+}
+
+type targetsKey struct{}
+
+// WithTargets returns a context whose checks delete the targets as if they were expired, and keep every other
+// resource. The deletion policy still keeps protected and excluded targets.
+func WithTargets(ctx context.Context, targets *Targets) context.Context {
+	return context.WithValue(ctx, targetsKey{}, targets)
+}
+
+// isTargeted returns whether the resource is a target of the check, and whether the check has targets
+func isTargeted(ctx context.Context, identifiers []string) (bool, bool) {
+	targets, hasTargets := ctx.Value(targetsKey{}).(*Targets)
+	if !hasTargets {
+		return false, false
+	}
+
+	return targets.contains(identifiers...), true
+}
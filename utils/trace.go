@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Span is a timed operation of a check: the check itself, a cleaner (a resource type in a region), a phase of a
+// cleaner (scan, delete) or the tagging of a resource. Spans of a check share its trace id.
+type Span struct {
+	TraceId    string
+	SpanId     string
+	ParentId   string
+	Name       string
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]string
+	// Events are the actions of the cleaner on resources during the span (see ResourceLog)
+	Events []SpanEvent
+	// Error is the error the operation ended with, empty on success
+	Error string
+}
+
+// SpanEvent is an action on a resource during a span
+type SpanEvent struct {
+	Name       string
+	Time       time.Time
+	Attributes map[string]string
+}
+
+// SpanExporter receives the spans of a check once it's done, even if the check was stopped, a check without tracing
+// doesn't record any span
+type SpanExporter func(spans []Span)
+
+type tracingKey struct{}
+
+type spanKey struct{}
+
+// WithTracing returns a context recording the spans of the checks and handing them to the exporter once each check is
+// done
+func WithTracing(ctx context.Context, export SpanExporter) context.Context {
+	return context.WithValue(ctx, tracingKey{}, export)
+}
+
+// trace holds the ended spans of a check until its root span ends
+type trace struct {
+	sync.Mutex
+	spans []Span
+}
+
+type activeSpan struct {
+	sync.Mutex
+	span  Span
+	trace *trace
+	// phase is the running phase of the span, ended by the next one or with the span
+	phase *activeSpan
+}
+
+// StartSpan starts a span child of the span of the context, or the root span of a new trace, and returns the context
+// of the span with the function ending it. The spans of the trace are exported once its root span ends. Without
+// tracing, spans aren't recorded.
+func StartSpan(ctx context.Context, name string, attributes map[string]string) (context.Context, func(err error)) {
+	export, hasTracing := ctx.Value(tracingKey{}).(SpanExporter)
+	if !hasTracing {
+		return ctx, func(err error) {}
+	}
+
+	parent, _ := ctx.Value(spanKey{}).(*activeSpan)
+	span := newSpan(name, attributes, parent)
+
+	return context.WithValue(ctx, spanKey{}, span), func(err error) {
+		span.end(err)
+		if parent == nil {
+			exportTrace(export, span.trace)
+		}
+	}
+}
+
+// StartPhase starts a phase of the span of the context (ex: scan, then delete) ending its previous phase, if any.
+// Phases end with their span.
+func StartPhase(ctx context.Context, name string, attributes map[string]string) {
+	parent, hasSpan := ctx.Value(spanKey{}).(*activeSpan)
+	if !hasSpan {
+		return
+	}
+
+	phase := newSpan(name, attributes, parent)
+
+	parent.Lock()
+	previous := parent.phase
+	parent.phase = phase
+	parent.Unlock()
+
+	if previous != nil {
+		previous.end(nil)
+	}
+}
+
+// addSpanEvent adds an event to the running phase of the span of the context, or to the span itself without phase
+func addSpanEvent(ctx context.Context, name string, attributes map[string]string) {
+	span, hasSpan := ctx.Value(spanKey{}).(*activeSpan)
+	if !hasSpan {
+		return
+	}
+
+	span.Lock()
+	if span.phase != nil {
+		span.Unlock()
+		span = span.phase
+		span.Lock()
+	}
+	defer span.Unlock()
+
+	span.span.Events = append(span.span.Events, SpanEvent{Name: name, Time: time.Now(), Attributes: attributes})
+}
+
+func newSpan(name string, attributes map[string]string, parent *activeSpan) *activeSpan {
+	span := &activeSpan{
+		span: Span{
+			SpanId:     newTraceId(8),
+			Name:       name,
+			Start:      time.Now(),
+			Attributes: attributes,
+		},
+	}
+
+	if parent == nil {
+		span.span.TraceId = newTraceId(16)
+		span.trace = &trace{}
+	} else {
+		span.span.TraceId = parent.span.TraceId
+		span.span.ParentId = parent.span.SpanId
+		span.trace = parent.trace
+	}
+
+	return span
+}
+
+// end ends the running phase of the span, then the span itself
+func (s *activeSpan) end(err error) {
+	s.Lock()
+	phase := s.phase
+	s.phase = nil
+	s.Unlock()
+
+	if phase != nil {
+		phase.end(nil)
+	}
+
+	s.Lock()
+	s.span.End = time.Now()
+	if err != nil {
+		s.span.Error = err.Error()
+	}
+	span := s.span
+	s.Unlock()
+
+	s.trace.Lock()
+	defer s.trace.Unlock()
+
+	s.trace.spans = append(s.trace.spans, span)
+}
+
+func exportTrace(export SpanExporter, trace *trace) {
+	trace.Lock()
+	spans := trace.spans
+	trace.spans = nil
+	trace.Unlock()
+
+	if len(spans) > 0 {
+		export(spans)
+	}
+}
+
+// newTraceId returns a random hex id of size bytes, trace ids have 16 bytes and span ids 8
+func newTraceId(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// UntaggedRule makes the resources of a type without ttl nor expiration date expire once older than a maximum age, if
+// their name, id or ARN matches. It cleans the resources created before the tagging convention, which never get a ttl.
+type UntaggedRule struct {
+	ResourceType string
+	Name         *regexp.Regexp
+	// MaxAge is in seconds, like a ttl
+	MaxAge int64
+}
+
+// ParseUntaggedRule parses a <resource type>=<max age>:<name regex> rule (ex: "S3 bucket=14d:z-test-.*"), the max age
+// has the format of the ttl tag and the regex must match the whole name, id or ARN
+func ParseUntaggedRule(value string) (UntaggedRule, error) {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return UntaggedRule{}, fmt.Errorf("invalid untagged rule %q, expected <resource type>=<max age>:<name regex>", value)
+	}
+
+	ageAndName := strings.SplitN(parts[1], ":", 2)
+	if len(ageAndName) != 2 || ageAndName[1] == "" {
+		return UntaggedRule{}, fmt.Errorf("invalid untagged rule %q, expected <resource type>=<max age>:<name regex>", value)
+	}
+
+	maxAge, err := ParseTTL(ageAndName[0])
+	if err != nil || maxAge <= 0 {
+		return UntaggedRule{}, fmt.Errorf("invalid max age %q in untagged rule %q", ageAndName[0], value)
+	}
+
+	name, err := regexp.Compile("^(?:" + ageAndName[1] + ")$")
+	if err != nil {
+		return UntaggedRule{}, fmt.Errorf("invalid name regex in untagged rule %q: %s", value, err)
+	}
+
+	return UntaggedRule{ResourceType: strings.TrimSpace(parts[0]), Name: name, MaxAge: maxAge}, nil
+}
+
+// matches returns true if the rule applies to the resource, resource types are matched case insensitively
+func (r UntaggedRule) matches(resourceType string, identifiers []string) bool {
+	if !strings.EqualFold(r.ResourceType, resourceType) {
+		return false
+	}
+
+	for _, identifier := range identifiers {
+		if identifier != "" && r.Name.MatchString(identifier) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasUntaggedRule returns true if an untagged rule of the deletion policy applies to the resource type, its cleaner has
+// to check the resources without ttl nor expiration date too
+func HasUntaggedRule(ctx context.Context, resourceType string) bool {
+	for _, rule := range getDeletionPolicy(ctx).UntaggedRules {
+		if strings.EqualFold(rule.ResourceType, resourceType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getUntaggedMaxAge returns the max age in seconds of the first untagged rule of the deletion policy matching the
+// resource, 0 if none matches
+func getUntaggedMaxAge(ctx context.Context, resourceType string, identifiers []string) int64 {
+	for _, rule := range getDeletionPolicy(ctx).UntaggedRules {
+		if rule.matches(resourceType, identifiers) {
+			return rule.MaxAge
+		}
+	}
+
+	return 0
+}
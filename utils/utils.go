@@ -1,20 +1,42 @@
 package utils
 
 import (
+	"context"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
 	"github.com/aws/aws-sdk-go/service/elasticache"
+	"github.com/aws/aws-sdk-go/service/elasticache/elasticacheiface"
+	"github.com/aws/aws-sdk-go/service/elasticbeanstalk"
 	"github.com/aws/aws-sdk-go/service/elbv2"
 	"github.com/aws/aws-sdk-go/service/iam"
 	"github.com/aws/aws-sdk-go/service/kms"
 	"github.com/aws/aws-sdk-go/service/rds"
+	"github.com/aws/aws-sdk-go/service/rds/rdsiface"
 	"github.com/aws/aws-sdk-go/service/s3"
 	log "github.com/sirupsen/logrus"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// ExpirationDateTagName is the tag holding an absolute expiration date, used instead of the ttl when set
+const ExpirationDateTagName = "expiration-date"
+
+// CreationDateTagName is the tag holding the creation date of the resources whose API doesn't return it (ex: VPCs,
+// security groups, route tables), written by pleco when it tags them
+const CreationDateTagName = "creationDate"
+
+// ExtendTTLTagName is the tag holding a duration added to the ttl, or to the expiration date, of a resource
+const ExtendTTLTagName = "extend-ttl"
+
+// DeletionScheduledTagName is the tag holding the date a resource will be deleted at, set when a grace period is used
+const DeletionScheduledTagName = "pleco-deletion-scheduled"
+
+// ProtectionTagNames are the tags protecting a resource from deletion whatever its ttl, unless their value is false
+var ProtectionTagNames = []string{"do_not_delete", "do-not-delete", "pleco-protected"}
+
 type Tag struct {
 	_     struct{} `type:"structure"`
 	Key   *string  `type:"string"`
@@ -27,12 +49,8 @@ type MyTag struct {
 	Value string  `type:"string"`
 }
 
-func GetEssentialTags(tagsInput interface{}, tagName string) (time.Time, int64, bool, string, string) {
-	var creationDate = time.Time{}
-	var ttl int64
-	var isProtected bool
-	var clusterId string
-	var tag string
+// getTags returns the tags of the AWS SDK types, of pleco tags or of a map
+func getTags(tagsInput interface{}) []MyTag {
 	var tags []MyTag
 
 	switch tagsInput.(type) {
@@ -71,6 +89,11 @@ func GetEssentialTags(tagsInput interface{}, tagName string) (time.Time, int64,
 			for _, elem := range m {
 				tags = append(tags, MyTag{Key: *elem.Key, Value: *elem.Value})
 			}
+		case []*elasticbeanstalk.Tag:
+			m := tagsInput.([]*elasticbeanstalk.Tag)
+			for _, elem := range m {
+				tags = append(tags, MyTag{Key: *elem.Key, Value: *elem.Value})
+			}
 		case []*Tag:
 			m := tagsInput.([]*Tag)
 			for _, elem := range m {
@@ -81,109 +104,498 @@ func GetEssentialTags(tagsInput interface{}, tagName string) (time.Time, int64,
 			for key, value := range m {
 				tags = append(tags, MyTag{Key: key, Value: *value})
 			}
+		case map[string]string:
+			m := tagsInput.(map[string]string)
+			for key, value := range m {
+				tags = append(tags, MyTag{Key: key, Value: value})
+			}
 		default:
 			log.Debugf("Can't parse tags %s.", tagsInput)
 	}
 
+	return tags
+}
+
+// TagsToMap returns the tags of the AWS SDK types, of pleco tags or of a map by key, for the reports
+func TagsToMap(tagsInput interface{}) map[string]string {
+	tags := make(map[string]string)
+	for _, tag := range getTags(tagsInput) {
+		tags[tag.Key] = tag.Value
+	}
+
+	return tags
+}
+
+func GetEssentialTags(ctx context.Context, tagsInput interface{}, tagName string) (time.Time, int64, bool, string, string, time.Time, time.Time) {
+	var creationDate = time.Time{}
+	var expirationDate = time.Time{}
+	var deletionScheduled = time.Time{}
+	var ttl int64
+	var extension int64
+	var isProtected bool
+	var clusterId string
+	var tag string
+	var productionMarker *ProductionMarker
+	var resourceName string
+
+	tags := getTags(tagsInput)
+
 	for i := range tags {
 		switch tags[i].Key {
-			case "creationDate":
-				creationDate = stringDateToTimeDate(tags[i].Value)
-			case "ttl":
-				result, _ := strconv.ParseInt(tags[i].Value, 10, 64)
-				ttl = result
-			case "do_not_delete":
-				result, _ := strconv.ParseBool(tags[i].Value)
-				isProtected = result
+			case CreationDateTagName:
+				result, err := ParseCreationDate(tags[i].Value)
+				if err != nil {
+					log.Warnf("Can't parse %s tag value %s: %s", CreationDateTagName, tags[i].Value, err)
+					continue
+				}
+				creationDate = result
+			case ExpirationDateTagName:
+				result, err := parseExpirationDate(tags[i].Value)
+				if err != nil {
+					log.Warnf("Can't parse %s tag value %s: %s", ExpirationDateTagName, tags[i].Value, err)
+					continue
+				}
+				expirationDate = result
+			case DeletionScheduledTagName:
+				result, err := time.Parse(time.RFC3339, tags[i].Value)
+				if err != nil {
+					log.Warnf("Can't parse %s tag value %s: %s", DeletionScheduledTagName, tags[i].Value, err)
+					continue
+				}
+				deletionScheduled = result
+			case ExtendTTLTagName:
+				result, err := ParseTTL(tags[i].Value)
+				if err != nil {
+					log.Warnf("Can't parse %s tag value %s: %s", ExtendTTLTagName, tags[i].Value, err)
+					continue
+				}
+				extension = result
 			case "ClusterId":
 				clusterId = tags[i].Value
 			case tagName:
 				tag = tags[i].Value
+				result, err := ParseTTL(tags[i].Value)
+				if err != nil {
+					log.Warnf("Can't parse %s tag value %s: %s", tagName, tags[i].Value, err)
+					continue
+				}
+				ttl = result
 			default:
-				continue
+				if IsProtectionTag(tags[i].Key, tags[i].Value) {
+					isProtected = true
+				}
+				if marker, isMarked := getProductionMarker(ctx, tags[i].Key, tags[i].Value); isMarked {
+					isProtected = true
+					productionMarker = &marker
+				}
+				if tags[i].Key == "Name" {
+					resourceName = tags[i].Value
+				}
 			}
 	}
 
-	return creationDate, ttl, isProtected, clusterId, tag
+	// the tags don't identify the resource, its Name tag is the best hint
+	if productionMarker != nil && (ttl != 0 || !expirationDate.IsZero()) {
+		resource := "a resource"
+		if resourceName != "" {
+			resource = "resource " + resourceName
+		}
+		AlertProductionConflict(*productionMarker, resource, ttl, expirationDate)
+	}
+
+	// resources without ttl nor expiration date never expire, extended or not
+	if ttl != 0 {
+		ttl += extension
+	}
+	if !expirationDate.IsZero() {
+		expirationDate = expirationDate.Add(time.Duration(extension) * time.Second)
+	}
+
+	return creationDate, ttl, isProtected, clusterId, tag, expirationDate, deletionScheduled
+}
+
+var ttlUnits = map[string]int64{
+	"s": 1,
+	"m": 60,
+	"h": 60 * 60,
+	"d": 24 * 60 * 60,
+	"w": 7 * 24 * 60 * 60,
+}
+
+// ParseTTL returns the time to leave in seconds of a tag value, either raw seconds (7200) or a number followed by a
+// unit among s, m, h, d and w (2h, 3d, 1w)
+func ParseTTL(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return seconds, nil
+	}
+
+	if len(value) < 2 {
+		return 0, fmt.Errorf("invalid ttl %q", value)
+	}
+
+	unit, isKnownUnit := ttlUnits[strings.ToLower(value[len(value)-1:])]
+	if !isKnownUnit {
+		return 0, fmt.Errorf("invalid ttl %q, unit must be one of s, m, h, d, w", value)
+	}
+
+	count, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid ttl %q", value)
+	}
+
+	return count * unit, nil
+}
+
+// ExtendExpiration returns the tags to set to push back the expiration of a resource by the extension (in seconds): its
+// expiration date if it has one, its ttl otherwise. Resources with neither don't expire and can't be extended.
+func ExtendExpiration(tags map[string]string, tagName string, extension int64) (map[string]string, error) {
+	if value, ok := tags[ExpirationDateTagName]; ok {
+		expirationDate, err := parseExpirationDate(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse %s tag value %s: %s", ExpirationDateTagName, value, err)
+		}
+
+		extendedDate := expirationDate.Add(time.Duration(extension) * time.Second).UTC()
+		return map[string]string{ExpirationDateTagName: extendedDate.Format(time.RFC3339)}, nil
+	}
+
+	if value, ok := tags[tagName]; ok {
+		ttl, err := ParseTTL(value)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse %s tag value %s: %s", tagName, value, err)
+		}
+		if ttl == 0 {
+			return nil, fmt.Errorf("%s tag value %s never expires", tagName, value)
+		}
+
+		return map[string]string{tagName: strconv.FormatInt(ttl+extension, 10)}, nil
+	}
+
+	return nil, fmt.Errorf("no %s nor %s tag", tagName, ExpirationDateTagName)
+}
+
+// IsProtectionTag returns true if the tag is a protection tag whose value isn't false
+func IsProtectionTag(key string, value string) bool {
+	for _, protectionTagName := range ProtectionTagNames {
+		if key == protectionTagName {
+			isProtected, err := strconv.ParseBool(value)
+			return err != nil || isProtected
+		}
+	}
+
+	return false
+}
+
+// parseExpirationDate accepts a RFC3339 timestamp or a yyyy-mm-dd date, the latter expiring at midnight UTC
+func parseExpirationDate(date string) (time.Time, error) {
+	expirationDate, err := time.Parse(time.RFC3339, date)
+	if err == nil {
+		return expirationDate, nil
+	}
+
+	return time.Parse("2006-01-02", date)
+}
+
+// maxEpochSeconds is the largest creationDate tag read as seconds since the epoch (year 5138), larger ones are
+// milliseconds
+const maxEpochSeconds = 100000000000
+
+// legacyCreationDateLayout is the layout of the creationDate tags written by the previous versions, time.Time.String
+const legacyCreationDateLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// FormatCreationDate returns the value of the creationDate tag of a resource created at this time, RFC3339 in UTC
+func FormatCreationDate(creationDate time.Time) string {
+	return creationDate.UTC().Format(time.RFC3339)
+}
+
+// ParseCreationDate accepts a RFC3339 timestamp, a yyyy-mm-dd date (midnight UTC), seconds or milliseconds since the
+// epoch or the format written by the previous versions (2021-03-01 18:00:00.123 +0100 CET), with its time zone
+func ParseCreationDate(date string) (time.Time, error) {
+	date = strings.TrimSpace(date)
+
+	if seconds, err := strconv.ParseInt(date, 10, 64); err == nil {
+		if seconds > maxEpochSeconds {
+			return time.Unix(seconds/1000, seconds%1000*int64(time.Millisecond)).UTC(), nil
+		}
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	if creationDate, err := time.Parse(time.RFC3339Nano, date); err == nil {
+		return creationDate, nil
+	}
+
+	if creationDate, err := time.Parse("2006-01-02", date); err == nil {
+		return creationDate, nil
+	}
+
+	// time.Time.String adds the monotonic clock reading of times returned by time.Now
+	if index := strings.Index(date, " m="); index != -1 {
+		date = date[:index]
+	}
+
+	creationDate, err := time.Parse(legacyCreationDateLayout, date)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a RFC3339 timestamp, a yyyy-mm-dd date or seconds or milliseconds since the epoch")
+	}
+
+	return creationDate, nil
+}
+
+// GetCreationTime returns the creation time returned by the API of a resource if it has one, the date of its
+// creationDate tag otherwise
+func GetCreationTime(createdTime time.Time, creationDateTag time.Time) time.Time {
+	if isKnownTime(createdTime) {
+		return createdTime
+	}
+
+	return creationDateTag
+}
+
+// isKnownTime returns false for zero times and for the null dates of some APIs
+func isKnownTime(t time.Time) bool {
+	return t.Year() >= 1972
 }
 
-func CheckIfExpired(creationTime time.Time, ttl int64) bool {
+// CheckIfExpired returns true once the expiration date is reached if there is one, or when the ttl is elapsed since
+// the creation otherwise. The creation time is the one returned by the API of the resource or the one of its
+// creationDate tag (see GetCreationTime), resources with neither never expire by ttl.
+func CheckIfExpired(ctx context.Context, creationTime time.Time, ttl int64, expirationDate time.Time) bool {
+	if !expirationDate.IsZero() {
+		return Now(ctx).After(expirationDate)
+	}
+
 	expirationTime := creationTime.Add(time.Duration(ttl) * time.Second)
-	if ttl == 0  || creationTime.Year() < 1972 {
+	if ttl == 0  || !isKnownTime(creationTime) {
 		return false
 	}
-	return time.Now().After(expirationTime)
+	return Now(ctx).After(expirationTime)
+}
+
+// AddMissingCreationDateTag sets the creationDate tag of a resource with a ttl whose API doesn't return its creation
+// time, to the current time, so its ttl counts from the first check seeing it. The id is an EC2 id or a RDS ARN.
+func AddMissingCreationDateTag(ctx context.Context, svc interface{}, region string, id string, creationDate time.Time, ttl int64, tagName string) {
+	if ttl == 0 || isKnownTime(creationDate) {
+		return
+	}
+
+	tagCtx, endTag := StartSpan(ctx, "tag", map[string]string{"region": region, "resource_id": id})
+	err := AddCreationDateTag(tagCtx, svc, region, []*string{aws.String(id)}, Now(ctx), ttl, tagName)
+	endTag(err)
+	if err != nil {
+		log.Error(err)
+	}
 }
 
-func AddCreationDateTag(svc interface{}, idsToTag []*string, creationDate time.Time, ttl int64) error {
+func AddCreationDateTag(ctx context.Context, svc interface{}, region string, idsToTag []*string, creationDate time.Time, ttl int64, tagName string) error {
+	idsToTag = filterExcludedIds(ctx, idsToTag)
 	if idsToTag != nil {
 
-		ec2Session, isOk := svc.(ec2.EC2)
+		ec2Session, isOk := svc.(ec2iface.EC2API)
+		if isOk {
+			return  ec2CreationDateTag(ctx, ec2Session, region, idsToTag, creationDate, ttl, tagName)
+		}
+
+		rdsSession, isOk := svc.(rdsiface.RDSAPI)
 		if isOk {
-			return  ec2CreationDateTag(ec2Session, idsToTag, creationDate, ttl)
+			return  rdsCreationDateTag(ctx, rdsSession, region, idsToTag, creationDate, ttl, tagName)
 		}
 
-		rdsSession, isOk := svc.(rds.RDS)
+		elasticacheSession, isOk := svc.(elasticacheiface.ElastiCacheAPI)
 		if isOk {
-			return  rdsCreationDateTag(rdsSession, idsToTag, creationDate, ttl)
+			return elasticacheCreationDateTag(ctx, elasticacheSession, region, idsToTag, creationDate, ttl, tagName)
 		}
 	}
 
 	return nil
 }
 
-func ec2CreationDateTag(ec2Session ec2.EC2, idsToTag []*string, creationDate time.Time, ttl int64) error {
+func ec2CreationDateTag(ctx context.Context, ec2Session ec2iface.EC2API, region string, idsToTag []*string, creationDate time.Time, ttl int64, tagName string) error {
 	slicedArray := getSlicedArray(idsToTag, 20)
 
 	for _, slice := range slicedArray {
-			_, err := ec2Session.CreateTags(
+			_, err := ec2Session.CreateTagsWithContext(ctx,
 				&ec2.CreateTagsInput{
 					Resources: 	slice,
 					Tags: []*ec2.Tag{
 						{
-							Key: aws.String("creationDate"),
-							Value: aws.String(creationDate.String()),
+							Key: aws.String(CreationDateTagName),
+							Value: aws.String(FormatCreationDate(creationDate)),
 						},
 						{
-							Key: aws.String("ttl"),
+							Key: aws.String(tagName),
 							Value: aws.String(strconv.FormatInt(ttl,10)),
 						},
 					},
 				})
 
 			if err != nil {
-				return fmt.Errorf("Can't add tags to %p in region %s: %s", slice, *ec2Session.Config.Region, err.Error())
+				return fmt.Errorf("Can't add tags to %p in region %s: %s", slice, region, err.Error())
 			}
 		}
 
 	return nil
 }
 
-func rdsCreationDateTag(rdsSession rds.RDS, idsToTag []*string, creationDate time.Time, ttl int64) error {
+func rdsCreationDateTag(ctx context.Context, rdsSession rdsiface.RDSAPI, region string, idsToTag []*string, creationDate time.Time, ttl int64, tagName string) error {
 	for _, id := range idsToTag {
-		_, err := 	rdsSession.AddTagsToResource(
+		_, err := 	rdsSession.AddTagsToResourceWithContext(ctx,
 			&rds.AddTagsToResourceInput{
 				ResourceName: aws.String(*id),
 				Tags: []*rds.Tag{
 					{
-						Key: aws.String("creationDate"),
-						Value: aws.String(creationDate.String()),
+						Key: aws.String(CreationDateTagName),
+						Value: aws.String(FormatCreationDate(creationDate)),
 					},
 					{
-						Key: aws.String("ttl"),
+						Key: aws.String(tagName),
 						Value: aws.String(strconv.FormatInt(ttl,10)),
 					},
 				},
 				})
 
 		if err != nil {
-			return fmt.Errorf("Can't add tags to %s in region %s: %s", *id, *rdsSession.Config.Region, err.Error())
+			return fmt.Errorf("Can't add tags to %s in region %s: %s", *id, region, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// elasticacheCreationDateTag tags Elasticache resources by ARN
+func elasticacheCreationDateTag(ctx context.Context, elasticacheSession elasticacheiface.ElastiCacheAPI, region string, idsToTag []*string, creationDate time.Time, ttl int64, tagName string) error {
+	for _, id := range idsToTag {
+		_, err := elasticacheSession.AddTagsToResourceWithContext(ctx,
+			&elasticache.AddTagsToResourceInput{
+				ResourceName: id,
+				Tags: []*elasticache.Tag{
+					{Key: aws.String(CreationDateTagName), Value: aws.String(FormatCreationDate(creationDate))},
+					{Key: aws.String(tagName), Value: aws.String(strconv.FormatInt(ttl, 10))},
+				},
+			})
+		if err != nil {
+			return fmt.Errorf("Can't add tags to %s in region %s: %s", *id, region, err.Error())
 		}
 	}
 
 	return nil
 }
 
+// CheckIfDeletable returns true if the resource is expired, old enough, not protected, not excluded and, with a grace
+// period, if its scheduled deletion date is reached. Expired resources kept are logged so it's clear why they outlive
+// their ttl. The first identifier is the resource name used in logs, the others (ex: ARN) are matched against the
+// exclusions and used to schedule the deletion.
+func CheckIfDeletable(ctx context.Context, creationTime time.Time, ttl int64, expirationDate time.Time, deletionScheduled time.Time, isProtected bool, resourceType string, region string, identifiers ...string) bool {
+	recordState(ctx, resourceType, region, identifiers, EventSeen, nil)
+	countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.scanned++ })
+	checkStuck(ctx, resourceType, region, identifiers)
+
+	// resources without ttl nor expiration date get the max age of the untagged rule matching them as ttl, if any
+	if ttl == 0 && expirationDate.IsZero() {
+		ttl = getUntaggedMaxAge(ctx, resourceType, identifiers)
+	}
+
+	// targets are deleted right away, expired or not, the other resources are kept
+	targeted, hasTargets := isTargeted(ctx, identifiers)
+	if hasTargets && !targeted {
+		log.Debugf("Skipping %s: not targeted.", describeResource(resourceType, region, identifiers))
+		countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.kept++ })
+		return false
+	}
+
+	if !targeted && !CheckIfExpired(ctx, creationTime, ttl, expirationDate) {
+		reportExpiring(ctx, creationTime, ttl, expirationDate, resourceType, region, identifiers)
+		countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.notExpired++ })
+		return false
+	}
+	recordState(ctx, resourceType, region, identifiers, EventExpired, nil)
+
+	if isKeptByPolicy(ctx, creationTime, isProtected, "expired", resourceType, region, identifiers) {
+		countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.kept++ })
+		return false
+	}
+
+	if !targeted && isDeletionScheduled(ctx, resourceType) && !checkScheduledDeletion(ctx, creationTime, ttl, expirationDate, deletionScheduled, resourceType, region, identifiers) {
+		countResources(ctx, resourceType, region, func(counts *resourceCounts) { counts.scheduled++ })
+		return false
+	}
+
+	resource := describeResource(resourceType, region, identifiers)
+	ResourceLog(ctx, ActionExpire, resourceType, region, getResourceId(identifiers)).Infof("Expired %s is deletable.", resource)
+	reportCandidate(ctx, creationTime, ttl, expirationDate, resourceType, region, identifiers)
+
+	return true
+}
+
+// isKeptByPolicy returns true, and logs why, if the deletion policy keeps the resource: too young, protected, excluded
+// or managed by Terraform. The state (ex: expired) is why the resource would be deleted otherwise.
+func isKeptByPolicy(ctx context.Context, creationTime time.Time, isProtected bool, state string, resourceType string, region string, identifiers []string) bool {
+	resource := describeResource(resourceType, region, identifiers)
+	skipLog := ResourceLog(ctx, ActionSkip, resourceType, region, getResourceId(identifiers))
+
+	deletionPolicy := getDeletionPolicy(ctx)
+	if isTooYoung(ctx, creationTime) {
+		skipLog.Infof("Skipping %s: %s but created less than %s ago.", resource, state, deletionPolicy.MinAge)
+		return true
+	}
+
+	if isProtected {
+		if len(deletionPolicy.ProductionMarkers) > 0 {
+			skipLog.Infof("Skipping %s: %s but protected by a %s tag or a production marker.", resource, state, strings.Join(ProtectionTagNames, "/"))
+		} else {
+			skipLog.Infof("Skipping %s: %s but protected by a %s tag.", resource, state, strings.Join(ProtectionTagNames, "/"))
+		}
+		return true
+	}
+
+	if IsExcluded(ctx, identifiers...) {
+		skipLog.Infof("Skipping %s: %s but matching an exclusion.", resource, state)
+		return true
+	}
+
+	if until := getTemporaryProtection(identifiers...); !until.IsZero() {
+		skipLog.Infof("Skipping %s: %s but protected until %s.", resource, state, until.Format(time.RFC3339))
+		return true
+	}
+
+	if isManagedByTerraform(identifiers...) {
+		skipLog.Infof("Skipping %s: %s but managed by Terraform.", resource, state)
+		return true
+	}
+
+	return false
+}
+
+// CheckIfDependencyDeletable returns true if a resource blocking the deletion of an expired one (ex: the subnet of an
+// expired VPC) isn't kept by the deletion policy, whatever its own ttl
+func CheckIfDependencyDeletable(ctx context.Context, isProtected bool, dependent string, resourceType string, region string, identifiers ...string) bool {
+	return !isKeptByPolicy(ctx, time.Time{}, isProtected, "blocking the deletion of "+dependent, resourceType, region, identifiers)
+}
+
+// getResourceId returns the resource name used in logs, the first identifier
+func getResourceId(identifiers []string) string {
+	if len(identifiers) == 0 {
+		return ""
+	}
+
+	return identifiers[0]
+}
+
+func describeResource(resourceType string, region string, identifiers []string) string {
+	resource := resourceType
+	if len(identifiers) > 0 {
+		resource += " " + identifiers[0]
+	}
+
+	if region != "" {
+		resource += " in " + region
+	}
+
+	return resource
+}
+
 func ElemToDeleteFormattedInfos(elemName string, arraySize int, region string) (string,string) {
 	count := fmt.Sprintf("There is no %s to delete in region %s.", elemName,region)
 	if arraySize == 1 {
@@ -228,16 +640,6 @@ func getSlicedArray(arrayToSlice []*string, sliceRange int) [][]*string {
 	return slicedArray
 }
 
-func stringDateToTimeDate(date string) time.Time {
-	year, _ := strconv.ParseInt(date[0:4], 10, 32)
-	month, _ := strconv.ParseInt(date[5:7], 10, 32)
-	day, _ := strconv.ParseInt(date[8:10], 10, 32)
-	hour, _ := strconv.ParseInt(date[11:13], 10, 32)
-	minutes, _ := strconv.ParseInt(date[14:16], 10, 32)
-	seconds, _ := strconv.ParseInt(date[17:18], 10, 32)
-
-	return time.Date(int(year), time.Month(month), int(day), int(hour), int(minutes), int(seconds), 0, time.Local)
-}
 
 
 
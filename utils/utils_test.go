@@ -0,0 +1,148 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseCreationDate(t *testing.T) {
+	paris := time.FixedZone("CET", 3600)
+
+	tests := []struct {
+		name    string
+		date    string
+		want    time.Time
+		wantErr bool
+	}{
+		{name: "RFC3339 UTC", date: "2021-03-01T18:00:00Z", want: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "RFC3339 positive offset", date: "2021-03-01T19:00:00+01:00", want: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "RFC3339 negative offset", date: "2021-03-01T13:00:00-05:00", want: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "RFC3339 offset over midnight", date: "2021-03-02T01:30:00+07:30", want: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "RFC3339 nanoseconds", date: "2021-03-01T18:00:00.123456789Z", want: time.Date(2021, 3, 1, 18, 0, 0, 123456789, time.UTC)},
+		{name: "day", date: "2021-03-01", want: time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "epoch seconds", date: "1614621600", want: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "epoch milliseconds", date: "1614621600123", want: time.Date(2021, 3, 1, 18, 0, 0, 123000000, time.UTC)},
+		{name: "epoch zero", date: "0", want: time.Unix(0, 0).UTC()},
+		{name: "surrounding spaces", date: " 1614621600 ", want: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "legacy format", date: "2021-03-01 19:00:00.123 +0100 CET", want: time.Date(2021, 3, 1, 19, 0, 0, 123000000, paris)},
+		{name: "legacy format with monotonic clock", date: "2021-03-01 19:00:00.123 +0100 CET m=+0.012345678", want: time.Date(2021, 3, 1, 19, 0, 0, 123000000, paris)},
+		{name: "empty", date: "", wantErr: true},
+		{name: "text", date: "yesterday", wantErr: true},
+		{name: "invalid month", date: "2021-13-01", wantErr: true},
+		{name: "invalid day", date: "2021-02-30", wantErr: true},
+		{name: "invalid offset", date: "2021-03-01T18:00:00+25:00", wantErr: true},
+		{name: "missing time zone", date: "2021-03-01T18:00:00", wantErr: true},
+		{name: "decimal epoch", date: "1614621600.5", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseCreationDate(test.date)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("ParseCreationDate(%q) = %s, want an error", test.date, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseCreationDate(%q) failed: %s", test.date, err)
+			}
+			if !got.Equal(test.want) {
+				t.Errorf("ParseCreationDate(%q) = %s, want %s", test.date, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckIfExpired(t *testing.T) {
+	now := time.Now()
+	// the same instant in another time zone, expiration doesn't depend on it
+	tokyo := time.FixedZone("JST", 9*3600)
+
+	tests := []struct {
+		name           string
+		creationTime   time.Time
+		ttl            int64
+		expirationDate time.Time
+		want           bool
+	}{
+		{name: "ttl elapsed", creationTime: now.Add(-2 * time.Hour), ttl: 3600, want: true},
+		{name: "ttl not elapsed", creationTime: now.Add(-30 * time.Minute), ttl: 3600, want: false},
+		{name: "ttl elapsed in another time zone", creationTime: now.Add(-2 * time.Hour).In(tokyo), ttl: 3600, want: true},
+		{name: "ttl not elapsed in another time zone", creationTime: now.Add(-30 * time.Minute).In(tokyo), ttl: 3600, want: false},
+		{name: "no ttl", creationTime: now.Add(-24 * time.Hour), ttl: 0, want: false},
+		{name: "unknown creation time", creationTime: time.Time{}, ttl: 3600, want: false},
+		{name: "null date of an API", creationTime: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC), ttl: 3600, want: false},
+		{name: "expiration date reached", creationTime: now, ttl: 0, expirationDate: now.Add(-time.Minute), want: true},
+		{name: "expiration date not reached", creationTime: now.Add(-24 * time.Hour), ttl: 0, expirationDate: now.Add(time.Hour), want: false},
+		{name: "expiration date wins over an elapsed ttl", creationTime: now.Add(-2 * time.Hour), ttl: 3600, expirationDate: now.Add(time.Hour), want: false},
+		{name: "expiration date wins over a pending ttl", creationTime: now, ttl: 3600, expirationDate: now.Add(-time.Minute), want: true},
+		{name: "expiration date in another time zone", creationTime: now, ttl: 0, expirationDate: now.Add(-time.Minute).In(tokyo), want: true},
+		{name: "expiration date without creation time", creationTime: time.Time{}, ttl: 0, expirationDate: now.Add(-time.Minute), want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := CheckIfExpired(context.Background(), test.creationTime, test.ttl, test.expirationDate)
+			if got != test.want {
+				t.Errorf("CheckIfExpired(%s, %d, %s) = %t, want %t", test.creationTime, test.ttl, test.expirationDate, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckIfExpiredWithClock(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name           string
+		ttl            int64
+		expirationDate time.Time
+		advance        time.Duration
+		want           bool
+	}{
+		{name: "ttl not elapsed", ttl: 3600, advance: 0, want: false},
+		{name: "ttl elapsed once the clock advanced", ttl: 3600, advance: 45 * time.Minute, want: true},
+		{name: "expiration date not reached", expirationDate: now.Add(time.Hour), advance: 45 * time.Minute, want: false},
+		{name: "expiration date reached once the clock advanced", expirationDate: now.Add(time.Hour), advance: 2 * time.Hour, want: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := WithClock(context.Background(), func() time.Time { return now.Add(test.advance) })
+
+			got := CheckIfExpired(ctx, now.Add(-30*time.Minute), test.ttl, test.expirationDate)
+			if got != test.want {
+				t.Errorf("CheckIfExpired with the clock %s ahead = %t, want %t", test.advance, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReserveDeletionsInDeletionWindow(t *testing.T) {
+	window, err := ParseDeletionWindow("08:00-09:00")
+	if err != nil {
+		t.Fatalf("ParseDeletionWindow failed: %s", err)
+	}
+	ctx := WithDeletionPolicy(context.Background(), DeletionPolicy{DeletionWindows: []DeletionWindow{window}})
+
+	tests := []struct {
+		name    string
+		now     time.Time
+		wantErr bool
+	}{
+		{name: "window open", now: time.Date(2021, 3, 1, 8, 30, 0, 0, time.Local)},
+		{name: "window closed since the check started", now: time.Date(2021, 3, 1, 9, 5, 0, 0, time.Local), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := test.now
+			err := ReserveDeletions(WithClock(ctx, func() time.Time { return now }), "volume", "eu-west-3", 1)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ReserveDeletions at %s error = %v, want an error: %t", now.Format("15:04"), err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type waitTimeoutKey struct{}
+
+// WithWaitTimeout returns a context where the deletions depending on asynchronous ones (ex: a VPC after its NAT
+// gateways, an EKS cluster after its node groups) wait up to the timeout for them to complete, instead of being retried
+// by the next checks. 0 disables the waits.
+func WithWaitTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	if timeout <= 0 {
+		return ctx
+	}
+
+	return context.WithValue(ctx, waitTimeoutKey{}, timeout)
+}
+
+// IsWaitEnabled returns true if the deletions of the context wait for the asynchronous deletions they depend on
+func IsWaitEnabled(ctx context.Context) bool {
+	_, hasWaitTimeout := ctx.Value(waitTimeoutKey{}).(time.Duration)
+	return hasWaitTimeout
+}
+
+// WaitFor calls isDone every interval until it returns true, an error, or the wait timeout of the context is reached.
+// It returns right away without waiting if the waits are disabled: the callers go on like before and rely on the next
+// checks.
+func WaitFor(ctx context.Context, description string, interval time.Duration, isDone func(ctx context.Context) (bool, error)) error {
+	timeout, hasWaitTimeout := ctx.Value(waitTimeoutKey{}).(time.Duration)
+	if !hasWaitTimeout {
+		return nil
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		done, err := isDone(ctx)
+		if err != nil {
+			return fmt.Errorf("can't wait for %s: %s", description, err)
+		}
+		if done {
+			return nil
+		}
+
+		next := time.Now().Add(interval)
+		if next.After(deadline) {
+			return fmt.Errorf("%s still not done after %s, retrying on the next check", description, timeout)
+		}
+
+		log.Debugf("Waiting for %s.", description)
+		if !WaitUntil(ctx, next) {
+			return fmt.Errorf("stopped waiting for %s: %s", description, ctx.Err())
+		}
+	}
+}
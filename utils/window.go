@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeletionWindow is a time range of some days of the week during which deletions are allowed, in the local time zone.
+// A window ending before it starts ends the next day.
+type DeletionWindow struct {
+	daysOfWeek uint64
+	start      time.Duration
+	end        time.Duration
+}
+
+// ParseDeletionWindow parses a window like "20:00-06:00", or "mon-thu 20:00-06:00" to restrict it to some days. Days
+// are a cron day of week list and are the days the window starts.
+func ParseDeletionWindow(value string) (DeletionWindow, error) {
+	window := DeletionWindow{daysOfWeek: 1<<7 - 1}
+
+	fields := strings.Fields(value)
+	if len(fields) == 0 || len(fields) > 2 {
+		return window, fmt.Errorf("invalid deletion window %q, expected [days] hh:mm-hh:mm", value)
+	}
+
+	if len(fields) == 2 {
+		days, err := cronDaysOfWeek.parse(fields[0])
+		if err != nil {
+			return window, fmt.Errorf("invalid deletion window %q: %s", value, err)
+		}
+		// sunday can be written 7
+		if days&(1<<7) != 0 {
+			days |= 1
+		}
+		window.daysOfWeek = days & (1<<7 - 1)
+	}
+
+	bounds := strings.Split(fields[len(fields)-1], "-")
+	if len(bounds) != 2 {
+		return window, fmt.Errorf("invalid deletion window %q, expected [days] hh:mm-hh:mm", value)
+	}
+
+	var err error
+	window.start, err = parseTimeOfDay(bounds[0])
+	if err != nil {
+		return window, fmt.Errorf("invalid deletion window %q: %s", value, err)
+	}
+	window.end, err = parseTimeOfDay(bounds[1])
+	if err != nil {
+		return window, fmt.Errorf("invalid deletion window %q: %s", value, err)
+	}
+
+	return window, nil
+}
+
+// parseTimeOfDay returns the time since midnight of hh:mm, 24:00 being the end of the day
+func parseTimeOfDay(value string) (time.Duration, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("invalid time %s, expected hh:mm", value)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 || hours > 24 {
+		return 0, fmt.Errorf("invalid hour in %s", value)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || (hours == 24 && minutes > 0) {
+		return 0, fmt.Errorf("invalid minutes in %s", value)
+	}
+
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
+func (w DeletionWindow) startsOn(weekday time.Weekday) bool {
+	return w.daysOfWeek&(1<<uint(weekday)) != 0
+}
+
+// Contains returns true if the time is within the window
+func (w DeletionWindow) Contains(t time.Time) bool {
+	timeOfDay := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	if w.start < w.end {
+		return w.startsOn(t.Weekday()) && timeOfDay >= w.start && timeOfDay < w.end
+	}
+
+	// the window runs over midnight, or all day long if it ends when it starts
+	yesterday := (t.Weekday() + 6) % 7
+	return (w.startsOn(t.Weekday()) && timeOfDay >= w.start) || (w.startsOn(yesterday) && timeOfDay < w.end)
+}
+
+// IsInDeletionWindow returns true if deletions are allowed at this time: there is no deletion window or one of them
+// contains it
+func IsInDeletionWindow(ctx context.Context, t time.Time) bool {
+	windows := getDeletionPolicy(ctx).DeletionWindows
+	if len(windows) == 0 {
+		return true
+	}
+
+	localTime := t.Local()
+	for _, window := range windows {
+		if window.Contains(localTime) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetCheckDryRun returns if a check starting now is a dry run: checks outside the deletion windows only report the
+// expired resources
+func GetCheckDryRun(ctx context.Context, dryRun bool, source string) bool {
+	if dryRun || IsInDeletionWindow(ctx, Now(ctx)) {
+		return dryRun
+	}
+
+	log.Infof("Outside the deletion windows, the %s check won't delete expired resources.", source)
+	return true
+}